@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ActivityWatch keeps events in buckets, one per watcher and host: the
+// window watcher's events have the app and title, the AFK watcher's say
+// whether you were at the computer. "activitywatch export" turns spans into
+// such events and "activitywatch import" turns them back into spans, so
+// history moves either way and ActivityWatch's watchers can record where
+// the tracker does not run.
+
+// awClient names the buckets this tracker writes.
+const awClient = "focus-tracker"
+
+// awEvent is an event as the ActivityWatch API and export files have it;
+// duration is in seconds.
+type awEvent struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Duration  float64        `json:"duration"`
+	Data      map[string]any `json:"data"`
+}
+
+func (e awEvent) end() time.Time {
+	return e.Timestamp.Add(time.Duration(e.Duration * float64(time.Second)))
+}
+
+func (e awEvent) text(key string) string {
+	s, _ := e.Data[key].(string)
+	return s
+}
+
+// awBucket is a bucket with its events, as in an export file.
+type awBucket struct {
+	ID       string    `json:"id"`
+	Created  time.Time `json:"created"`
+	Type     string    `json:"type"`
+	Client   string    `json:"client"`
+	Hostname string    `json:"hostname"`
+	Events   []awEvent `json:"events"`
+}
+
+// runActivityWatch handles "activitywatch export|import".
+func runActivityWatch(args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return errors.New("usage: activitywatch export [-day D | -from D -to D] [-host NAME] [-o FILE] | activitywatch import [-day D | -from D -to D] [-host NAME] [-i FILE]")
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("activitywatch "+action, flag.ExitOnError)
+	dayStr := fs.String("day", "", "day to "+action+" (YYYY-MM-DD, today or yesterday)")
+	fromStr := fs.String("from", "", "first day of a range instead of -day")
+	toStr := fs.String("to", "", "last day of the range (default today)")
+	var host, file *string
+	if action == "export" {
+		host = fs.String("host", awClient, "host name for the buckets in ActivityWatch")
+		file = fs.String("o", "", "write an ActivityWatch export file to import instead of sending to ACTIVITYWATCH_URL")
+	} else {
+		host = fs.String("host", "", "host whose watchers to import (default this computer, or the only one)")
+		file = fs.String("i", "", "read an ActivityWatch export file instead of asking ACTIVITYWATCH_URL")
+	}
+	fs.Parse(args[1:])
+
+	from, err := parseDay(*dayStr)
+	if err != nil {
+		return err
+	}
+	to := from
+	switch {
+	case *fromStr != "" && *dayStr != "":
+		return errors.New("use -day or -from, not both")
+	case *fromStr != "":
+		if from, err = parseDay(*fromStr); err != nil {
+			return err
+		}
+		if to, err = parseDay(*toStr); err != nil {
+			return err
+		}
+		if to.Before(from) {
+			return errors.New("-to is before -from")
+		}
+	case *toStr != "":
+		return errors.New("-to needs -from")
+	}
+	to = to.AddDate(0, 0, 1)
+
+	if action == "export" {
+		return awExport(from, to, *host, *file)
+	}
+	return awImport(from, to, *host, *file)
+}
+
+// awEvents turns spans into window and AFK events. Time away becomes AFK;
+// paused time is not tracked, so it is left out of both, as are the coarse
+// spans of pruned days, which have no times of day.
+func awEvents(spans []Span) (window, afk []awEvent) {
+	for _, s := range spans {
+		if s.App == pausedApp || s.Coarse || s.Duration() <= 0 {
+			continue
+		}
+		status := "not-afk"
+		if s.Away() {
+			status = "afk"
+		} else {
+			data := map[string]any{"app": s.App, "title": s.Title}
+			for key, value := range map[string]string{"project": s.Project, "category": s.Category, "domain": s.Domain, "issue": s.Issue} {
+				if value != "" {
+					data[key] = value
+				}
+			}
+			window = append(window, awEvent{Timestamp: s.Start, Duration: s.Duration().Seconds(), Data: data})
+		}
+		// Adjoining spans with the same status are one AFK event
+		if n := len(afk); n > 0 && afk[n-1].text("status") == status && afk[n-1].end().Equal(s.Start) {
+			afk[n-1].Duration += s.Duration().Seconds()
+			continue
+		}
+		afk = append(afk, awEvent{Timestamp: s.Start, Duration: s.Duration().Seconds(), Data: map[string]any{"status": status}})
+	}
+	return window, afk
+}
+
+// awExport sends the spans in [from, to) to the ActivityWatch server, in
+// buckets of their own named after host, skipping events already there;
+// or, with a file, writes them in the format of ActivityWatch's exports.
+func awExport(from, to time.Time, host, file string) error {
+	spans, err := readSpanRange(from, to)
+	if err != nil {
+		return err
+	}
+	var kept []Span
+	for _, s := range withContexts(spans) {
+		if !s.Start.Before(from) && s.Start.Before(to) {
+			kept = append(kept, s)
+		}
+	}
+	window, afk := awEvents(kept)
+	buckets := []awBucket{
+		{ID: "aw-watcher-window_" + host, Type: "currentwindow", Events: window},
+		{ID: "aw-watcher-afk_" + host, Type: "afkstatus", Events: afk},
+	}
+	for i := range buckets {
+		buckets[i].Client, buckets[i].Hostname, buckets[i].Created = awClient, host, time.Now()
+	}
+
+	if file != "" {
+		export := map[string]map[string]awBucket{"buckets": {}}
+		for _, b := range buckets {
+			export["buckets"][b.ID] = b
+		}
+		data, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("%s Wrote %d window and %d AFK events to %s; import it in ActivityWatch under Settings\n", glyphs.ok, len(window), len(afk), file)
+		return nil
+	}
+
+	for _, b := range buckets {
+		body := map[string]string{"client": b.Client, "type": b.Type, "hostname": b.Hostname}
+		// Creating a bucket that exists answers 304
+		err := awRequest(http.MethodPost, "/buckets/"+url.PathEscape(b.ID), body, nil)
+		var status *statusError
+		if err != nil && !(errors.As(err, &status) && status.code == http.StatusNotModified) {
+			return fmt.Errorf("activitywatch: %w", err)
+		}
+		existing, err := awBucketEvents(b.ID, from, to)
+		if err != nil {
+			return fmt.Errorf("activitywatch: %w", err)
+		}
+		starts := make(map[int64]bool)
+		for _, e := range existing {
+			starts[e.Timestamp.Unix()] = true
+		}
+		var fresh []awEvent
+		for _, e := range b.Events {
+			if !starts[e.Timestamp.Unix()] {
+				fresh = append(fresh, e)
+			}
+		}
+		if len(fresh) > 0 {
+			if err := awRequest(http.MethodPost, "/buckets/"+url.PathEscape(b.ID)+"/events", fresh, nil); err != nil {
+				return fmt.Errorf("activitywatch: %w", err)
+			}
+		}
+		fmt.Printf("%s ActivityWatch: %d new events in %s, %d already there\n", glyphs.ok, len(fresh), b.ID, len(b.Events)-len(fresh))
+	}
+	return nil
+}
+
+// awImport journals the host's window and AFK events in [from, to) as
+// spans. Only time no journaled span covers is filled in, so importing a
+// day again, or one the tracker also recorded, adds nothing twice.
+func awImport(from, to time.Time, host, file string) error {
+	var buckets []awBucket
+	var err error
+	if file != "" {
+		buckets, err = readAWExport(file)
+	} else {
+		buckets, err = awServerBuckets()
+	}
+	if err != nil {
+		return err
+	}
+	windowBucket, afkBucket, err := awHostBuckets(buckets, host)
+	if err != nil {
+		return err
+	}
+	window, afk := windowBucket.Events, afkBucket.Events
+	if file == "" {
+		if window, err = awBucketEvents(windowBucket.ID, from, to); err == nil && afkBucket.ID != "" {
+			afk, err = awBucketEvents(afkBucket.ID, from, to)
+		}
+		if err != nil {
+			return fmt.Errorf("activitywatch: %w", err)
+		}
+	}
+
+	byDay := make(map[string][]Span)
+	for _, s := range awSpans(window, afk) {
+		for _, part := range splitAtMidnight(s) {
+			if !part.Start.Before(from) && part.Start.Before(to) {
+				day := part.Start.Format("2006-01-02")
+				byDay[day] = append(byDay[day], part)
+			}
+		}
+	}
+	if len(byDay) == 0 {
+		fmt.Printf("%s ActivityWatch: no events from %s in that time\n", glyphs.ok, windowBucket.ID)
+		return nil
+	}
+
+	for _, key := range sortedKeys(byDay) {
+		day, _ := time.ParseInLocation("2006-01-02", key, time.Local)
+		if err := checkUnlocked(day, day.AddDate(0, 0, 1)); err != nil {
+			fmt.Printf("%s %s: %v\n", glyphs.warn, key, err)
+			continue
+		}
+		existing, err := readSpans(day)
+		if err != nil {
+			return err
+		}
+		var added []Span
+		for _, s := range byDay[key] {
+			for _, part := range uncovered(s, existing) {
+				if part.Duration() < time.Second {
+					continue
+				}
+				part = classify(part)
+				// "project set" is for what is tracked now, not imported history
+				if p := projectFor(part); part.Project != p && !part.Away() {
+					part.Project = p
+					part.Contexts = contextsFor(part)
+				}
+				added = append(added, part)
+			}
+		}
+		if len(added) == 0 {
+			fmt.Printf("%s %s: nothing new\n", glyphs.ok, key)
+			continue
+		}
+		if err := journalImported(day, existing, added); err != nil {
+			return err
+		}
+		var total time.Duration
+		for _, s := range added {
+			total += s.Duration()
+		}
+		fmt.Printf("%s %s: imported %s\n", glyphs.ok, key, shortDuration(total))
+	}
+	return nil
+}
+
+// awSpans turns window events into spans, leaving out the time the AFK
+// watcher says you were away, which becomes time on the locked screen as
+// the tracker records it.
+func awSpans(window, afk []awEvent) []Span {
+	var away []Span
+	for _, e := range afk {
+		if e.text("status") == "afk" && e.Duration > 0 {
+			away = append(away, Span{Start: e.Timestamp.Local(), End: e.end().Local(), App: lockedApp})
+		}
+	}
+	sort.Slice(away, func(i, j int) bool { return away[i].Start.Before(away[j].Start) })
+	sort.Slice(window, func(i, j int) bool { return window[i].Timestamp.Before(window[j].Timestamp) })
+
+	var spans []Span
+	var last time.Time
+	for _, e := range window {
+		app := e.text("app")
+		if app == "" || e.Duration <= 0 {
+			continue
+		}
+		s := Span{Start: e.Timestamp.Local(), End: e.end().Local(), App: app, Title: e.text("title"), Domain: e.text("domain"), Issue: e.text("issue")}
+		if u := e.text("url"); u != "" {
+			s.Domain, s.Issue = urlDomain(u), urlIssue(u)
+		}
+		if s.Start.Before(last) {
+			s.Start = last // events of a watcher restart can overlap
+		}
+		for _, part := range uncovered(s, away) {
+			if part.Duration() > 0 {
+				spans = append(spans, part)
+				last = part.End
+			}
+		}
+	}
+	var merged []Span
+	for _, a := range away {
+		if n := len(merged); n > 0 && !a.Start.After(merged[n-1].End) {
+			if a.End.After(merged[n-1].End) {
+				merged[n-1].End = a.End
+			}
+			continue
+		}
+		merged = append(merged, a)
+	}
+	for _, a := range merged {
+		a.Title = strings.ReplaceAll(a.Start.Format("15:04:05"), ":", "-")
+		spans = append(spans, a)
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+	return spans
+}
+
+// uncovered returns the parts of s that none of the spans cover.
+func uncovered(s Span, spans []Span) []Span {
+	parts := []Span{s}
+	for _, c := range spans {
+		var next []Span
+		for _, p := range parts {
+			if !c.Start.Before(p.End) || !c.End.After(p.Start) {
+				next = append(next, p)
+				continue
+			}
+			if c.Start.After(p.Start) {
+				before := p
+				before.End = c.Start
+				next = append(next, before)
+			}
+			if c.End.Before(p.End) {
+				after := p
+				after.Start = c.End
+				next = append(next, after)
+			}
+		}
+		parts = next
+	}
+	return parts
+}
+
+// splitAtMidnight splits a span into its parts on each day, as the tracker
+// does at midnight.
+func splitAtMidnight(s Span) []Span {
+	var parts []Span
+	for {
+		midnight := startOfDay(s.Start).AddDate(0, 0, 1)
+		if !s.End.After(midnight) {
+			return append(parts, s)
+		}
+		part := s
+		part.End = midnight
+		parts = append(parts, part)
+		s.Start = midnight
+	}
+}
+
+// journalImported adds imported spans to a day's journal, keeping it in
+// time order, and to SQLite with STORE, and rewrites the day's summaries.
+func journalImported(day time.Time, existing, added []Span) error {
+	spans := append(append([]Span(nil), existing...), added...)
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+	if err := writeSpans(day, spans); err != nil {
+		return err
+	}
+	if sqlitePath != "" {
+		if err := insertSQLiteSpans(sqlitePath, added); err != nil {
+			return fmt.Errorf("could not write spans to SQLite: %v", err)
+		}
+	}
+	date := day.Format("2006-01-02")
+	for suffix, totals := range totalsFromSpans(spans) {
+		var b bytes.Buffer
+		writeSummary(&b, totals, date, suffix)
+		path := filepath.Join(logs, fmt.Sprintf("focus_tracker_%s%s.log", date, suffix))
+		if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// awHostBuckets picks the window bucket of host and its AFK bucket, if
+// there is one. Without a host it takes this computer's, or the only
+// host's; buckets this tracker exported are only taken when asked for.
+func awHostBuckets(buckets []awBucket, host string) (window, afk awBucket, err error) {
+	hosts := make(map[string]bool)
+	for _, b := range buckets {
+		if b.Type == "currentwindow" && (b.Client != awClient || host != "") {
+			hosts[b.Hostname] = true
+		}
+	}
+	if host == "" {
+		if name, _ := os.Hostname(); hosts[name] {
+			host = name
+		} else if len(hosts) == 1 {
+			host = sortedKeys(hosts)[0]
+		} else if len(hosts) == 0 {
+			return window, afk, errors.New("no ActivityWatch window watcher buckets found; to import ones exported by the tracker, choose them with -host")
+		} else {
+			return window, afk, fmt.Errorf("several hosts have window watchers, choose one with -host: %s", strings.Join(sortedKeys(hosts), ", "))
+		}
+	}
+	for _, b := range buckets {
+		if b.Hostname != host {
+			continue
+		}
+		switch {
+		case b.Type == "currentwindow" && window.ID == "":
+			window = b
+		case b.Type == "afkstatus" && afk.ID == "":
+			afk = b
+		}
+	}
+	if window.ID == "" {
+		return window, afk, fmt.Errorf("no ActivityWatch window watcher bucket for host %q", host)
+	}
+	return window, afk, nil
+}
+
+// readAWExport reads the buckets of a file exported from ActivityWatch.
+func readAWExport(path string) ([]awBucket, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var export struct {
+		Buckets map[string]awBucket `json:"buckets"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var buckets []awBucket
+	for _, id := range sortedKeys(export.Buckets) {
+		b := export.Buckets[id]
+		if b.ID == "" {
+			b.ID = id
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+// awServerBuckets lists the server's buckets, without their events.
+func awServerBuckets() ([]awBucket, error) {
+	var byID map[string]awBucket
+	if err := awRequest(http.MethodGet, "/buckets/", nil, &byID); err != nil {
+		return nil, fmt.Errorf("activitywatch: %w (is ActivityWatch running at %s?)", err, activityWatchURL)
+	}
+	var buckets []awBucket
+	for _, id := range sortedKeys(byID) {
+		buckets = append(buckets, byID[id])
+	}
+	return buckets, nil
+}
+
+// awBucketEvents returns a bucket's events in [from, to).
+func awBucketEvents(id string, from, to time.Time) ([]awEvent, error) {
+	q := url.Values{"start": {from.Format(time.RFC3339)}, "end": {to.Format(time.RFC3339)}, "limit": {"-1"}}
+	var events []awEvent
+	err := awRequest(http.MethodGet, "/buckets/"+url.PathEscape(id)+"/events?"+q.Encode(), nil, &events)
+	return events, err
+}
+
+// awRequest calls the ActivityWatch server, which runs locally without
+// credentials.
+func awRequest(method, path string, body, out any) error {
+	return apiRequest(method, strings.TrimRight(activityWatchURL, "/")+"/api/0"+path, func(*http.Request) {}, body, out)
+}
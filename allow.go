@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// With [allow] sections in the rules file only the apps they list are
+// tracked; time in any other app is recorded as otherApp, without bundle
+// ID, path or title. A section matches when all of its patterns do:
+//
+//	[allow "Editors"]
+//	bundle_id = ^com\.(microsoft\.VSCode|apple\.dt\.Xcode)$
+//
+//	[allow "Terminal"]
+//	app = ^(Terminal|iTerm2)$
+type allowRule struct {
+	name     string
+	app      *regexp.Regexp
+	bundleID *regexp.Regexp
+}
+
+const otherApp = "Other"
+
+var allowRules []allowRule
+
+// allowedApp reports whether an app is tracked: always without [allow]
+// sections, otherwise when one of them matches.
+func allowedApp(app, bundleID string) bool {
+	if len(allowRules) == 0 {
+		return true
+	}
+	for _, r := range allowRules {
+		if r.app != nil && !r.app.MatchString(app) {
+			continue
+		}
+		if r.bundleID != nil && !r.bundleID.MatchString(bundleID) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func parseAllow(path string, sec ruleSection) (allowRule, []error) {
+	r := allowRule{name: sec.name}
+	var errs []error
+	for _, k := range sec.keys {
+		where := fmt.Sprintf("%s:%d", path, k.line)
+		var err error
+		switch k.key {
+		case "app":
+			r.app, err = regexp.Compile(k.value)
+		case "bundle_id":
+			r.bundleID, err = regexp.Compile(k.value)
+		default:
+			msg := fmt.Sprintf("%s: unknown allow key %q", where, k.key)
+			if guess := closestMatch(k.key, []string{"app", "bundle_id"}); guess != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", guess)
+			}
+			errs = append(errs, errors.New(msg))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", where, k.key, err))
+		}
+	}
+	if r.app == nil && r.bundleID == nil {
+		errs = append(errs, fmt.Errorf("%s:%d: allow %q needs an app or bundle_id pattern", path, sec.line, sec.name))
+	}
+	return r, errs
+}
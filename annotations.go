@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// annotation is a tag over a stretch of time ("the last 30 minutes were
+// Client A") or, with Start equal to End, a note at a point in time.
+type annotation struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Tag   string    `json:"tag,omitempty"`
+	Note  string    `json:"note,omitempty"`
+}
+
+func annotationsPath(day time.Time) string {
+	return filepath.Join(logs, fmt.Sprintf("focus_tracker_%s_annotations.jsonl", day.Format("2006-01-02")))
+}
+
+func appendAnnotation(a annotation) error {
+	return appendJSONLine(annotationsPath(a.End), a)
+}
+
+// runTag handles "tag [-last 30m] [NAME]"; without a name it asks in a dialog
+// so the command can be bound to a hotkey.
+func runTag(args []string) error {
+	fs := flag.NewFlagSet("tag", flag.ExitOnError)
+	last := fs.Duration("last", 30*time.Minute, "how far back the tag reaches")
+	fs.Parse(args)
+	if *last <= 0 {
+		return errors.New("-last must be positive")
+	}
+
+	tag := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if tag == "" {
+		var err error
+		if tag, err = askText(fmt.Sprintf("Tag the last %s as:", shortDuration(*last))); err != nil || tag == "" {
+			return err
+		}
+	}
+	now := time.Now()
+	if err := checkUnlocked(now.Add(-*last), now); err != nil {
+		return err
+	}
+	if err := appendAnnotation(annotation{Start: now.Add(-*last), End: now, Tag: tag}); err != nil {
+		return err
+	}
+	fmt.Printf("%s Tagged %s-%s as %s.\n", glyphs.ok, now.Add(-*last).Format("15:04"), now.Format("15:04"), tag)
+	return nil
+}
+
+// runNote handles "note [TEXT]", asking in a dialog without text.
+func runNote(args []string) error {
+	text := strings.TrimSpace(strings.Join(args, " "))
+	if text == "" {
+		var err error
+		if text, err = askText("Note:"); err != nil || text == "" {
+			return err
+		}
+	}
+	now := time.Now()
+	if err := appendAnnotation(annotation{Start: now, End: now, Note: text}); err != nil {
+		return err
+	}
+	fmt.Printf("%s Note saved at %s.\n", glyphs.ok, now.Format("15:04"))
+	return nil
+}
+
+// askText shows a text input dialog; cancelling returns "".
+func askText(prompt string) (string, error) {
+	script := fmt.Sprintf(`text returned of (display dialog "%s" default answer "" with title "Focus Tracker")`, appleScriptEscape(prompt))
+	out, err := runAppleScript(script)
+	if err != nil {
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
+			return "", nil // the user pressed Cancel
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func readAnnotations(day time.Time) ([]annotation, error) {
+	f, err := os.Open(annotationsPath(day))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var result []annotation
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var a annotation
+		if json.Unmarshal(scanner.Bytes(), &a) == nil {
+			result = append(result, a)
+		}
+	}
+	return result, scanner.Err()
+}
+
+// writeAnnotations lists the tracked (not away) time under each tag, and
+// the day's notes.
+func writeAnnotations(w io.Writer, annotations []annotation, spans []Span) {
+	tagged := make(map[string]time.Duration)
+	var notes []annotation
+	for _, a := range annotations {
+		if a.Tag == "" {
+			notes = append(notes, a)
+			continue
+		}
+		for _, s := range spans {
+			if !s.Away() {
+				tagged[a.Tag] += overlap(s.Start, s.End, a.Start, a.End)
+			}
+		}
+	}
+	if len(tagged) > 0 {
+		fmt.Fprintf(w, "\nTags\n")
+		for _, tag := range sortedByDuration(tagged) {
+			fmt.Fprintf(w, "  %s: %s\n", tag, shortDuration(tagged[tag]))
+		}
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(w, "\nNotes\n")
+		for _, n := range notes {
+			fmt.Fprintf(w, "  %s %s\n", n.Start.Format("15:04"), n.Note)
+		}
+	}
+}
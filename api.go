@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// apiRoutes registers the read-only JSON API for scripts, launchers and
+// status bars: GET /status, /current, /today and /range?from=&to=.
+func apiRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/status", apiGet(func(r *http.Request) (any, error) {
+		return currentStatus(), nil
+	}))
+	mux.HandleFunc("/current", apiGet(func(r *http.Request) (any, error) {
+		return currentFocus(time.Now()), nil
+	}))
+	mux.HandleFunc("/today", apiGet(func(r *http.Request) (any, error) {
+		today, _ := parseDay("")
+		return summarizeDays(today, today, time.Now())
+	}))
+	mux.HandleFunc("/range", apiGet(func(r *http.Request) (any, error) {
+		q := r.URL.Query()
+		from, err := parseDay(q.Get("from"))
+		if err != nil {
+			return nil, apiError{http.StatusBadRequest, err}
+		}
+		to, err := parseDay(q.Get("to"))
+		if err != nil {
+			return nil, apiError{http.StatusBadRequest, err}
+		}
+		if to.Before(from) {
+			return nil, apiError{http.StatusBadRequest, fmt.Errorf("to is before from")}
+		}
+		if to.Sub(from) >= maxSpanRangeDays*24*time.Hour {
+			return nil, apiError{http.StatusBadRequest, fmt.Errorf("at most %d days per request", maxSpanRangeDays)}
+		}
+		return summarizeDays(from, to, time.Now())
+	}))
+}
+
+// apiError is an error with the HTTP status to answer it with.
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e apiError) Error() string { return e.err.Error() }
+
+// apiGet wraps a GET endpoint: errors become plain text with their status,
+// 500 unless they are an apiError.
+func apiGet(get func(r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		v, err := get(r)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if e, ok := err.(apiError); ok {
+				status = e.status
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		writeJSON(w, v)
+	}
+}
+
+// apiCurrent is what the tracker is timing right now.
+type apiCurrent struct {
+	Tracking       bool      `json:"tracking"`
+	App            string    `json:"app,omitempty"`
+	Title          string    `json:"title,omitempty"`
+	Domain         string    `json:"domain,omitempty"`
+	Project        string    `json:"project,omitempty"`
+	Category       string    `json:"category,omitempty"`
+	Work           bool      `json:"work"`
+	Away           bool      `json:"away"`
+	Since          time.Time `json:"since,omitempty"`
+	ElapsedSeconds int       `json:"elapsed_seconds"`
+}
+
+// currentSpan is the span the tracker is timing, classified as it would be
+// recorded, and false while tracking is paused.
+func currentSpan(now time.Time) (Span, bool) {
+	metrics.Lock()
+	s, since := metrics.focus, metrics.since
+	metrics.Unlock()
+	if s.App == "" {
+		return Span{}, false
+	}
+	s.Start, s.End = since, now
+	return classify(s), true
+}
+
+func currentFocus(now time.Time) apiCurrent {
+	s, ok := currentSpan(now)
+	if !ok {
+		return apiCurrent{}
+	}
+	return apiCurrent{
+		Tracking: true, App: s.App, Title: s.Title, Domain: s.Domain,
+		Project: s.Project, Category: s.Category, Work: s.Work, Away: s.Away(),
+		Since: s.Start, ElapsedSeconds: int(s.Duration().Seconds()),
+	}
+}
+
+// apiTotal is the time of one app, project or category.
+type apiTotal struct {
+	Name    string `json:"name"`
+	Seconds int    `json:"seconds"`
+}
+
+type apiDay struct {
+	Day            string `json:"day"`
+	WorkSeconds    int    `json:"work_seconds"`
+	OutsideSeconds int    `json:"outside_seconds"`
+}
+
+// apiSummary is the tracked time of a range of days, today's including the
+// current span. Away time is left out.
+type apiSummary struct {
+	From           string     `json:"from"`
+	To             string     `json:"to"`
+	WorkSeconds    int        `json:"work_seconds"`
+	OutsideSeconds int        `json:"outside_seconds"`
+	Days           []apiDay   `json:"days"`
+	Apps           []apiTotal `json:"apps"`
+	Projects       []apiTotal `json:"projects"`
+	Categories     []apiTotal `json:"categories"`
+}
+
+func summarizeDays(from, to, now time.Time) (apiSummary, error) {
+	var spans []Span
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		daySpans, err := liveSpans(day, now)
+		if err != nil {
+			return apiSummary{}, err
+		}
+		spans = append(spans, daySpans...)
+	}
+	return summarizeSpans(from, to, spans), nil
+}
+
+// summarizeSpans totals the spans of the days from to to, counting each span
+// on the day it started.
+func summarizeSpans(from, to time.Time, spans []Span) apiSummary {
+	sum := apiSummary{From: from.Format("2006-01-02"), To: to.Format("2006-01-02"),
+		Days: []apiDay{}, Apps: []apiTotal{}, Projects: []apiTotal{}, Categories: []apiTotal{}}
+	apps := make(map[string]time.Duration)
+	projects := make(map[string]time.Duration)
+	categories := make(map[string]time.Duration)
+	work := make(map[string]time.Duration)
+	outside := make(map[string]time.Duration)
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		day := s.Start.Format("2006-01-02")
+		if s.Work {
+			work[day] += s.Duration()
+		} else {
+			outside[day] += s.Duration()
+		}
+		apps[s.App] += s.Duration()
+		if s.Project != "" {
+			projects[s.Project] += s.Duration()
+		}
+		if s.Category != "" {
+			categories[s.Category] += s.Duration()
+		}
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		sum.Days = append(sum.Days, apiDay{key, int(work[key].Seconds()), int(outside[key].Seconds())})
+		sum.WorkSeconds += int(work[key].Seconds())
+		sum.OutsideSeconds += int(outside[key].Seconds())
+	}
+	sum.Apps = appendTotals(sum.Apps, apps)
+	sum.Projects = appendTotals(sum.Projects, projects)
+	sum.Categories = appendTotals(sum.Categories, categories)
+	return sum
+}
+
+func appendTotals(list []apiTotal, m map[string]time.Duration) []apiTotal {
+	for _, name := range sortedByDuration(m) {
+		list = append(list, apiTotal{name, int(m[name].Seconds())})
+	}
+	return list
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// appVersions caches each app bundle's version by path. An entry is kept
+// while the bundle's Info.plist is unchanged, so updating an app while the
+// tracker runs is noticed on the next switch to it.
+var appVersions struct {
+	sync.Mutex
+	byPath map[string]appVersionEntry
+}
+
+type appVersionEntry struct {
+	modified time.Time
+	version  string
+}
+
+// appVersion reads the version of the app bundle at path, e.g. "1.90.2", or
+// "" if it cannot be read.
+func appVersion(path string) string {
+	if path == "" {
+		return ""
+	}
+	plist := filepath.Join(path, "Contents", "Info.plist")
+	info, err := os.Stat(plist)
+	if err != nil {
+		return ""
+	}
+	appVersions.Lock()
+	defer appVersions.Unlock()
+	if e, ok := appVersions.byPath[path]; ok && e.modified.Equal(info.ModTime()) {
+		return e.version
+	}
+	// plutil reads binary and XML property lists alike
+	out, err := exec.Command("plutil", "-extract", "CFBundleShortVersionString", "raw", "-o", "-", plist).Output()
+	version := strings.TrimSpace(string(out))
+	if err != nil {
+		version = ""
+	}
+	if appVersions.byPath == nil {
+		appVersions.byPath = make(map[string]appVersionEntry)
+	}
+	appVersions.byPath[path] = appVersionEntry{info.ModTime(), version}
+	return version
+}
+
+// appInstall is one way an app showed up in the journal: a name, bundle ID
+// and path, with the versions seen and the time spent in it.
+type appInstall struct {
+	app, bundleID, path string
+	versions            map[string]time.Duration
+	time                time.Duration
+}
+
+// runApps handles "apps [-days N]": time per app install and version, and
+// apps whose time is split across several names or copies.
+func runApps(args []string) error {
+	fs := flag.NewFlagSet("apps", flag.ExitOnError)
+	days := fs.Int("days", 28, "number of days up to today to include")
+	fs.Parse(args)
+
+	today, _ := parseDay("")
+	spans, err := readSpanRange(today.AddDate(0, 0, 1-*days), today.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	installs := make(map[string]*appInstall)
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		key := s.App + "\x00" + s.BundleID + "\x00" + s.AppPath
+		in := installs[key]
+		if in == nil {
+			in = &appInstall{app: s.App, bundleID: s.BundleID, path: s.AppPath, versions: make(map[string]time.Duration)}
+			installs[key] = in
+		}
+		in.time += s.Duration()
+		if s.AppVersion != "" {
+			in.versions[s.AppVersion] += s.Duration()
+		}
+	}
+	if len(installs) == 0 {
+		fmt.Println("No apps tracked.")
+		return nil
+	}
+	list := make([]*appInstall, 0, len(installs))
+	for _, in := range installs {
+		list = append(list, in)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].app != list[j].app {
+			return list[i].app < list[j].app
+		}
+		return list[i].time > list[j].time
+	})
+
+	names := make(map[string][]string) // bundle ID -> app names
+	copies := make(map[string]int)     // app name -> installs
+	for _, in := range list {
+		if in.bundleID != "" && !containsString(names[in.bundleID], in.app) {
+			names[in.bundleID] = append(names[in.bundleID], in.app)
+		}
+		copies[in.app]++
+	}
+
+	fmt.Printf("%-28s %-32s %8s  %s\n", "App", "Bundle ID", "Time", "Versions")
+	for _, in := range list {
+		var versions []string
+		for _, v := range sortedByDuration(in.versions) {
+			versions = append(versions, fmt.Sprintf("%s (%s)", v, shortDuration(in.versions[v])))
+		}
+		fmt.Printf("%-28s %-32s %8s  %s\n", truncate(in.app, 28), truncate(in.bundleID, 32), shortDuration(in.time), strings.Join(versions, ", "))
+		if in.path != "" && (copies[in.app] > 1 || len(names[in.bundleID]) > 1) {
+			fmt.Printf("  %s\n", paintDim(in.path))
+		}
+	}
+
+	var notes []string
+	for _, id := range sortedKeys(names) {
+		if len(names[id]) > 1 {
+			notes = append(notes, fmt.Sprintf("%s was tracked as %s; rename the copies or add a project rule matching all of them", id, strings.Join(names[id], ", ")))
+		}
+	}
+	for _, app := range sortedKeys(copies) {
+		if copies[app] > 1 {
+			notes = append(notes, fmt.Sprintf("%s ran from %d places or bundle IDs; its time is summed in reports", app, copies[app]))
+		}
+	}
+	if len(notes) > 0 {
+		fmt.Println()
+		for _, n := range notes {
+			fmt.Printf("%s %s\n", glyphs.warn, n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// question is what "ask" understood: a period and either a total for one
+// subject or a top list.
+type question struct {
+	from, to time.Time
+	period   string // e.g. "yesterday (Mon 2024-06-03)"
+	top      string // "apps" or "projects" for a top list
+	subject  string // "app", "project", "client", "context", "work", "outside" or "" for everything
+	name     string
+}
+
+var askExamples = `examples:
+  focus-tracker ask "how long was I in Chrome yesterday"
+  focus-tracker ask "total for project ACME last week"
+  focus-tracker ask "top apps this month"
+  focus-tracker ask "how much did I work on monday"`
+
+// askStopWords carry no meaning for the questions "ask" answers.
+var askStopWords = map[string]bool{
+	"how": true, "long": true, "much": true, "time": true, "was": true, "were": true, "i": true,
+	"in": true, "on": true, "at": true, "for": true, "the": true, "did": true, "do": true,
+	"spend": true, "spent": true, "total": true, "what": true, "whats": true, "my": true, "me": true,
+	"with": true, "using": true, "used": true, "of": true, "a": true, "is": true, "have": true,
+	"been": true, "hours": true, "show": true, "tracked": true, "many": true, "give": true,
+}
+
+var askWeekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// parsePeriod finds a period in the words, such as "yesterday", "last
+// week", "last 7 days", "on monday", "since 2024-06-01" or a date, and
+// returns the words left over. Weeks start on Monday. Without a period the
+// question is about today.
+func parsePeriod(words []string, today time.Time) (from, to time.Time, label string, rest []string, err error) {
+	from, to, label = today, today.AddDate(0, 0, 1), "today"
+	found := false
+	set := func(f, t time.Time, l string) error {
+		if found {
+			return errors.New("more than one period in the question")
+		}
+		from, to, label, found = f, t, l, true
+		return nil
+	}
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	firstOfMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	firstOfYear := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, today.Location())
+	for i := 0; i < len(words); i++ {
+		w, next := words[i], ""
+		if i+1 < len(words) {
+			next = words[i+1]
+		}
+		var err error
+		switch {
+		case w == "today":
+			err = set(today, today.AddDate(0, 0, 1), "today")
+		case w == "yesterday":
+			err = set(today.AddDate(0, 0, -1), today, "yesterday")
+		case (w == "this" || w == "last" || w == "past") && (next == "week" || next == "month" || next == "year"):
+			i++
+			start, length := monday, [3]int{0, 0, 7}
+			switch next {
+			case "month":
+				start, length = firstOfMonth, [3]int{0, 1, 0}
+			case "year":
+				start, length = firstOfYear, [3]int{1, 0, 0}
+			}
+			if w == "this" {
+				err = set(start, today.AddDate(0, 0, 1), "this "+next)
+			} else {
+				prev := start.AddDate(-length[0], -length[1], -length[2])
+				err = set(prev, start, "last "+next)
+			}
+		case (w == "last" || w == "past") && next != "":
+			n, convErr := strconv.Atoi(next)
+			if convErr != nil || n <= 0 || i+2 >= len(words) || !strings.HasPrefix(words[i+2], "day") {
+				if wd, ok := askWeekdays[next]; ok {
+					i++
+					err = set(lastWeekday(today.AddDate(0, 0, -1), wd), lastWeekday(today.AddDate(0, 0, -1), wd).AddDate(0, 0, 1), "last "+next)
+					break
+				}
+				rest = append(rest, w)
+				continue
+			}
+			i += 2
+			err = set(today.AddDate(0, 0, 1-n), today.AddDate(0, 0, 1), fmt.Sprintf("the last %d days", n))
+		case w == "since" && next != "":
+			day, dayErr := parseDay(next)
+			if dayErr != nil {
+				return from, to, label, nil, dayErr
+			}
+			i++
+			err = set(day, today.AddDate(0, 0, 1), "since "+next)
+		default:
+			if wd, ok := askWeekdays[w]; ok {
+				day := lastWeekday(today, wd)
+				err = set(day, day.AddDate(0, 0, 1), w)
+			} else if day, dayErr := time.ParseInLocation("2006-01-02", w, time.Local); dayErr == nil {
+				err = set(day, day.AddDate(0, 0, 1), w)
+			} else {
+				rest = append(rest, w)
+			}
+		}
+		if err != nil {
+			return from, to, label, nil, err
+		}
+	}
+	if to.Sub(from) <= 24*time.Hour && label != from.Format("2006-01-02") {
+		label += " (" + from.Format("Mon 2006-01-02") + ")"
+	} else if to.Sub(from) > 24*time.Hour {
+		label += fmt.Sprintf(" (%s to %s)", from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	return from, to, label, rest, nil
+}
+
+// lastWeekday is the latest day on or before day that falls on wd.
+func lastWeekday(day time.Time, wd time.Weekday) time.Time {
+	return day.AddDate(0, 0, -(int(day.Weekday())-int(wd)+7)%7)
+}
+
+// parseQuestion reads a question about tracked time. appsIn lists the apps
+// journaled in the question's period, to recognize app names.
+func parseQuestion(text string, today time.Time, appsIn func(from, to time.Time) ([]string, error)) (question, error) {
+	text = strings.ToLower(text)
+	text = strings.NewReplacer("?", " ", "!", " ", ",", " ", "\"", " ", "'", "", "’", "").Replace(text)
+	words := strings.Fields(text)
+	var q question
+	var err error
+	if q.from, q.to, q.period, words, err = parsePeriod(words, today); err != nil {
+		return q, err
+	}
+
+	var rest []string
+	for i := 0; i < len(words); i++ {
+		switch w := words[i]; w {
+		case "top", "most", "biggest":
+			if q.top == "" {
+				q.top = "apps"
+			}
+		case "apps", "applications":
+			if q.top == "" {
+				q.top = "apps"
+			}
+		case "projects":
+			q.top = "projects"
+		case "project", "client", "context", "app":
+			if i+1 < len(words) {
+				q.subject, q.name = w, strings.Join(words[i+1:], " ")
+				i = len(words)
+			}
+		default:
+			if !askStopWords[w] {
+				rest = append(rest, w)
+			}
+		}
+	}
+	if q.subject != "" {
+		if len(rest) > 0 {
+			return q, fmt.Errorf("did not understand %q in the question\n%s", strings.Join(rest, " "), askExamples)
+		}
+		return q, resolveSubject(&q, appsIn)
+	}
+
+	phrase := strings.Join(rest, " ")
+	switch phrase {
+	case "":
+		return q, nil
+	case "work", "working", "worked", "work hours":
+		q.subject = "work"
+		return q, nil
+	case "outside", "outside hours", "overtime", "after hours":
+		q.subject = "outside"
+		return q, nil
+	}
+	q.name = phrase
+	return q, resolveSubject(&q, appsIn)
+}
+
+// describe names what the question is about, e.g. "Project ACME".
+func (q question) describe() string {
+	switch q.subject {
+	case "":
+		return "Tracked time"
+	case "work":
+		return "Work hours"
+	case "outside":
+		return "Outside hours"
+	case "app":
+		return q.name
+	}
+	return strings.ToUpper(q.subject[:1]) + q.subject[1:] + " " + q.name
+}
+
+// resolveSubject matches q.name against the names of projects, clients,
+// contexts and the apps of the period, unless q.subject says which. An exact
+// name wins over one containing it, so "chrome" is Google Chrome.
+func resolveSubject(q *question, appsIn func(from, to time.Time) ([]string, error)) error {
+	if q.name == "" {
+		return nil
+	}
+	candidates := make(map[string][]string) // subject -> names
+	if q.subject == "" || q.subject == "project" {
+		for _, p := range projects {
+			candidates["project"] = append(candidates["project"], p.name)
+			candidates["project"] = append(candidates["project"], p.aliases...)
+		}
+	}
+	if q.subject == "" || q.subject == "client" {
+		for _, c := range clients {
+			candidates["client"] = append(candidates["client"], c.name)
+			candidates["client"] = append(candidates["client"], c.aliases...)
+		}
+	}
+	if q.subject == "" || q.subject == "context" {
+		for _, c := range contexts {
+			candidates["context"] = append(candidates["context"], c.name)
+		}
+	}
+	if q.subject == "" || q.subject == "app" {
+		apps, err := appsIn(q.from, q.to)
+		if err != nil {
+			return err
+		}
+		candidates["app"] = apps
+	}
+	for _, pass := range []func(string) bool{
+		func(name string) bool { return strings.EqualFold(name, q.name) },
+		func(name string) bool { return strings.Contains(strings.ToLower(name), q.name) },
+	} {
+		for _, subject := range []string{"project", "client", "context", "app"} {
+			for _, name := range candidates[subject] {
+				if pass(name) {
+					q.subject, q.name = subject, name
+					switch subject {
+					case "project":
+						q.name = canonicalProject(name)
+					case "client":
+						q.name = canonicalClient(name)
+					}
+					return nil
+				}
+			}
+		}
+	}
+	what := q.subject
+	if what == "" {
+		what = "project, client, context or app"
+	}
+	msg := fmt.Sprintf("no %s %q", what, q.name)
+	if q.subject == "" || q.subject == "app" {
+		msg += " " + q.period
+	}
+	return fmt.Errorf("%s\n%s", msg, askExamples)
+}
+
+// runAsk handles `ask "QUESTION"`: totals and top lists from a question in
+// plain English.
+func runAsk(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: ask QUESTION\n" + askExamples)
+	}
+	today, _ := parseDay("")
+	var spans []Span
+	read := func(from, to time.Time) ([]Span, error) {
+		if spans == nil {
+			s, err := readSpanRange(from, to)
+			if err != nil {
+				return nil, err
+			}
+			spans = withContexts(s)
+			for i := range spans {
+				if spans[i].Project == "" && !spans[i].Away() {
+					spans[i].Project = projectFor(spans[i])
+				}
+			}
+		}
+		return spans, nil
+	}
+	q, err := parseQuestion(strings.Join(args, " "), today, func(from, to time.Time) ([]string, error) {
+		spans, err := read(from, to)
+		if err != nil {
+			return nil, err
+		}
+		apps := make(map[string]time.Duration)
+		for _, s := range spans {
+			if !s.Away() {
+				apps[s.App] += s.Duration()
+			}
+		}
+		return sortedByDuration(apps), nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := read(q.from, q.to); err != nil {
+		return err
+	}
+
+	totals := make(map[string]time.Duration)
+	var total time.Duration
+	days := make(map[string]bool)
+	for _, s := range spans {
+		if s.Away() || !q.matches(s) {
+			continue
+		}
+		d := overlap(s.Start, s.End, q.from, q.to)
+		if d <= 0 {
+			continue
+		}
+		total += d
+		days[s.Start.Format("2006-01-02")] = true
+		switch q.top {
+		case "apps":
+			totals[s.App] += d
+		case "projects":
+			name := canonicalProject(s.Project)
+			if name == "" {
+				name = "(no project)"
+			}
+			totals[name] += d
+		}
+	}
+
+	if q.top != "" {
+		header := "Top " + q.top
+		if q.subject != "" {
+			header += " in " + strings.ToLower(q.describe()[:1]) + q.describe()[1:]
+		}
+		fmt.Printf("%s, %s\n", header, q.period)
+		for i, name := range sortedByDuration(totals) {
+			if i == 10 {
+				break
+			}
+			fmt.Printf("  %s: %s\n", name, shortDuration(totals[name]))
+		}
+		fmt.Printf("Total: %s\n", shortDuration(total))
+		return nil
+	}
+	fmt.Printf("%s, %s: %s\n", q.describe(), q.period, shortDuration(total))
+	if len(days) > 1 {
+		fmt.Printf("  %d days, %s per day on average\n", len(days), shortDuration(total/time.Duration(len(days))))
+	}
+	return nil
+}
+
+func (q question) matches(s Span) bool {
+	switch q.subject {
+	case "work":
+		return s.Work
+	case "outside":
+		return !s.Work
+	case "app":
+		return s.App == q.name
+	case "project":
+		return canonicalProject(s.Project) == q.name
+	case "client":
+		p, ok := lookupProject(s.Project)
+		return ok && canonicalClient(p.client) == q.name
+	case "context":
+		for _, c := range s.Contexts {
+			if strings.EqualFold(c, q.name) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const bigqueryAPI = "https://bigquery.googleapis.com/bigquery/v2"
+
+// bigqueryClient talks to the BigQuery REST API as a service account.
+type bigqueryClient struct {
+	project, dataset, table string
+	token                   string
+}
+
+// syncBigQuery creates or extends the span table and merges the day's
+// spans into it.
+func syncBigQuery(day time.Time, spans []Span) error {
+	if bigqueryCredentials == "" || bigqueryDataset == "" {
+		return errors.New("BIGQUERY_CREDENTIALS and BIGQUERY_DATASET must be set")
+	}
+	c, err := newBigqueryClient()
+	if err != nil {
+		return err
+	}
+	if err := c.ensureTable(); err != nil {
+		return err
+	}
+	spans = withContexts(spans)
+	if len(spans) > 0 {
+		if err := c.merge(spans); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%s Merged %d spans for %s into %s.%s.%s\n", glyphs.ok, len(spans), day.Format("2006-01-02"), c.project, c.dataset, c.table)
+	return nil
+}
+
+// newBigqueryClient logs in with the service account key file: a signed
+// JWT is exchanged for an access token.
+func newBigqueryClient() (*bigqueryClient, error) {
+	data, err := os.ReadFile(bigqueryCredentials)
+	if err != nil {
+		return nil, err
+	}
+	var sa struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+		ProjectID   string `json:"project_id"`
+	}
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("%s: %v", bigqueryCredentials, err)
+	}
+	var key *rsa.PrivateKey
+	if key, err = parseRSAKey([]byte(sa.PrivateKey)); err != nil {
+		return nil, fmt.Errorf("%s: %v", bigqueryCredentials, err)
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(map[string]any{
+		"iss":   sa.ClientEmail,
+		"scope": "https://www.googleapis.com/auth/bigquery",
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.PostForm(sa.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error_description"`
+	}
+	json.NewDecoder(resp.Body).Decode(&tok)
+	if resp.StatusCode != http.StatusOK || tok.AccessToken == "" {
+		return nil, newStatusError(resp.StatusCode, "BigQuery login: %s %s", resp.Status, tok.Error)
+	}
+
+	c := &bigqueryClient{project: sa.ProjectID, dataset: bigqueryDataset, table: bigqueryTable, token: tok.AccessToken}
+	if p, d, ok := strings.Cut(bigqueryDataset, "."); ok {
+		c.project, c.dataset = p, d
+	}
+	return c, nil
+}
+
+// ensureTable creates the table, or adds the columns it is missing.
+func (c *bigqueryClient) ensureTable() error {
+	path := fmt.Sprintf("/projects/%s/datasets/%s/tables", c.project, c.dataset)
+	var table struct {
+		Schema struct {
+			Fields []map[string]string `json:"fields"`
+		} `json:"schema"`
+	}
+	err := c.call("GET", path+"/"+c.table, nil, &table)
+	if errors.Is(err, errNotFound) {
+		return c.call("POST", path, map[string]any{
+			"tableReference":   map[string]string{"projectId": c.project, "datasetId": c.dataset, "tableId": c.table},
+			"schema":           map[string]any{"fields": bigqueryFields()},
+			"timePartitioning": map[string]string{"type": "DAY", "field": "day"},
+		}, nil)
+	}
+	if err != nil {
+		return err
+	}
+	if len(table.Schema.Fields) >= len(warehouseColumns) {
+		return nil
+	}
+	return c.call("PATCH", path+"/"+c.table, map[string]any{"schema": map[string]any{"fields": bigqueryFields()}}, nil)
+}
+
+func bigqueryFields() []map[string]string {
+	var fields []map[string]string
+	for _, col := range warehouseColumns {
+		fields = append(fields, map[string]string{"name": col.name, "type": col.bigquery, "mode": "NULLABLE"})
+	}
+	return fields
+}
+
+// merge upserts the spans with one MERGE statement, passing the rows as an
+// array-of-struct query parameter.
+func (c *bigqueryClient) merge(spans []Span) error {
+	var structTypes []map[string]any
+	var selects, sets, names []string
+	for _, col := range warehouseColumns {
+		structTypes = append(structTypes, map[string]any{"name": col.name, "type": map[string]string{"type": "STRING"}})
+		selects = append(selects, fmt.Sprintf("CAST(NULLIF(r.%s, '') AS %s) AS %s", col.name, col.bigquery, col.name))
+		sets = append(sets, fmt.Sprintf("%s = s.%s", col.name, col.name))
+		names = append(names, col.name)
+	}
+	var on []string
+	for _, k := range warehouseKey {
+		on = append(on, fmt.Sprintf("t.%s = s.%s", k, k))
+	}
+	query := fmt.Sprintf("MERGE `%s.%s.%s` t USING (SELECT %s FROM UNNEST(@rows) r) s ON %s "+
+		"WHEN MATCHED THEN UPDATE SET %s WHEN NOT MATCHED THEN INSERT (%s) VALUES (s.%s)",
+		c.project, c.dataset, c.table, strings.Join(selects, ", "), strings.Join(on, " AND "),
+		strings.Join(sets, ", "), strings.Join(names, ", "), strings.Join(names, ", s."))
+
+	var values []map[string]any
+	for _, s := range spans {
+		fields := make(map[string]map[string]string)
+		for i, v := range warehouseRow(s) {
+			fields[warehouseColumns[i].name] = map[string]string{"value": v}
+		}
+		values = append(values, map[string]any{"structValues": fields})
+	}
+	var result struct {
+		JobComplete bool `json:"jobComplete"`
+		Errors      []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	err := c.call("POST", fmt.Sprintf("/projects/%s/queries", c.project), map[string]any{
+		"query":         query,
+		"useLegacySql":  false,
+		"parameterMode": "NAMED",
+		"timeoutMs":     60000,
+		"queryParameters": []map[string]any{{
+			"name": "rows",
+			"parameterType": map[string]any{
+				"type":      "ARRAY",
+				"arrayType": map[string]any{"type": "STRUCT", "structTypes": structTypes},
+			},
+			"parameterValue": map[string]any{"arrayValues": values},
+		}},
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("BigQuery merge: %s", result.Errors[0].Message)
+	}
+	if !result.JobComplete {
+		return errors.New("BigQuery merge did not finish within a minute; it may still complete, check the job history")
+	}
+	return nil
+}
+
+var errNotFound = errors.New("not found")
+
+func (c *bigqueryClient) call(method, path string, body, out any) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, bigqueryAPI+path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return newStatusError(resp.StatusCode, "BigQuery %s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
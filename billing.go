@@ -0,0 +1,339 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// client is someone projects are billed to, from a [client "Name"] section
+// of the rules file:
+//
+//	[client "Acme"]
+//	name = Acme Corporation Ltd.
+//	address = 1 Main Street
+//	address = Springfield
+//	rate = 120
+//	currency = EUR
+//	round = 15m
+type client struct {
+	name        string
+	billingName string   // name on invoices, default the section name
+	address     []string // one line per address key
+	email       string
+	rate        int64  // default hourly rate of its projects, in cents
+	currency    string // of its rates, default INVOICE_CURRENCY
+	round       time.Duration
+	roundMode   string  // up, nearest or down
+	tax         float64 // percent added to invoices, e.g. 20 for VAT
+	aliases     []string
+	archived    bool
+}
+
+var clients []client
+
+func lookupClient(name string) (client, bool) {
+	for _, c := range clients {
+		if strings.EqualFold(c.name, name) {
+			return c, true
+		}
+	}
+	for _, c := range clients {
+		for _, a := range c.aliases {
+			if strings.EqualFold(a, name) {
+				return c, true
+			}
+		}
+	}
+	return client{}, false
+}
+
+func parseClient(path string, sec ruleSection) (client, []error) {
+	c := client{name: sec.name, billingName: sec.name, roundMode: "up"}
+	var errs []error
+	for _, k := range sec.keys {
+		where := fmt.Sprintf("%s:%d", path, k.line)
+		var err error
+		switch k.key {
+		case "name":
+			c.billingName = k.value
+		case "address":
+			c.address = append(c.address, k.value)
+		case "email":
+			c.email = k.value
+		case "rate":
+			c.rate, err = parseMoney(k.value)
+		case "currency":
+			c.currency, err = parseCurrency(k.value)
+		case "round":
+			c.round, err = parsePositiveDuration(k.value)
+		case "round_mode":
+			c.roundMode = strings.ToLower(k.value)
+			if c.roundMode != "up" && c.roundMode != "nearest" && c.roundMode != "down" {
+				err = fmt.Errorf("expected up, nearest or down")
+			}
+		case "tax":
+			c.tax, err = strconv.ParseFloat(strings.TrimSuffix(k.value, "%"), 64)
+			if err != nil || c.tax < 0 {
+				err = fmt.Errorf("invalid percentage %q", k.value)
+			}
+		case "alias":
+			c.aliases = append(c.aliases, k.value)
+		case "archived":
+			c.archived, err = parseBool(k.value)
+		default:
+			keys := []string{"name", "address", "email", "rate", "currency", "round", "round_mode", "tax", "alias", "archived"}
+			msg := fmt.Sprintf("%s: unknown client key %q", where, k.key)
+			if guess := closestMatch(k.key, keys); guess != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", guess)
+			}
+			errs = append(errs, errors.New(msg))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", where, k.key, err))
+		}
+	}
+	return c, errs
+}
+
+// parseMoney reads an amount like "120" or "87.50" into cents.
+func parseMoney(input string) (int64, error) {
+	f, err := strconv.ParseFloat(strings.ReplaceAll(input, ",", ""), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid amount %q, expected e.g. 120 or 87.50", input)
+	}
+	return int64(math.Round(f * 100)), nil
+}
+
+// currencyCode is the currency the client's rates are in.
+func (c client) currencyCode() string {
+	if c.currency != "" {
+		return c.currency
+	}
+	return invoiceCurrency
+}
+
+// parseCurrency reads an ISO 4217 code such as EUR.
+func parseCurrency(input string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(input))
+	if len(code) != 3 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("invalid currency %q, expected a code like USD or EUR", input)
+	}
+	return code, nil
+}
+
+// parseExchangeRates reads EXCHANGE_RATES, e.g. "EUR=0.92, GBP=0.79": how
+// much of each currency one unit of INVOICE_CURRENCY buys.
+func parseExchangeRates(input string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	if strings.TrimSpace(input) == "" {
+		return rates, nil
+	}
+	for _, part := range strings.Split(input, ",") {
+		code, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid exchange rate %q, expected e.g. EUR=0.92", strings.TrimSpace(part))
+		}
+		code, err := parseCurrency(code)
+		if err != nil {
+			return nil, err
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q for %s", strings.TrimSpace(value), code)
+		}
+		rates[code] = rate
+	}
+	return rates, nil
+}
+
+// exchangeRate is the price of one unit of from in to, from EXCHANGE_RATES.
+func exchangeRate(from, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	perBase := func(code string) (float64, error) {
+		if code == invoiceCurrency {
+			return 1, nil
+		}
+		if rate, ok := exchangeRates[code]; ok {
+			return rate, nil
+		}
+		return 0, fmt.Errorf("no exchange rate for %s; add it to EXCHANGE_RATES", code)
+	}
+	fromRate, err := perBase(from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := perBase(to)
+	if err != nil {
+		return 0, err
+	}
+	return toRate / fromRate, nil
+}
+
+// formatMoney writes cents as 1,234.50.
+func formatMoney(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	whole := strconv.FormatInt(cents/100, 10)
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	return fmt.Sprintf("%s%s.%02d", sign, whole, cents%100)
+}
+
+// roundBillable rounds a day's time on a project to the client's increment.
+func roundBillable(d time.Duration, c client) time.Duration {
+	return roundDuration(d, c.round, c.roundMode)
+}
+
+// roundDuration rounds d up, to the nearest or down to a multiple of
+// increment; an increment of 0 leaves it as is.
+func roundDuration(d, increment time.Duration, mode string) time.Duration {
+	if increment <= 0 || d <= 0 {
+		return d
+	}
+	switch mode {
+	case "down":
+		return d.Truncate(increment)
+	case "nearest":
+		return d.Round(increment)
+	}
+	if r := d.Truncate(increment); r < d {
+		return r + increment
+	}
+	return d
+}
+
+// billedAmount is the price of d at an hourly rate, to the cent.
+func billedAmount(d time.Duration, rate int64) int64 {
+	return int64(math.Round(d.Hours() * float64(rate)))
+}
+
+// billableUsage totals each of the client's billable projects over the
+// spans, counting only time inside a project's allowed hours and rounding
+// each project's time per day.
+func billableUsage(spans []Span, c client) map[string]time.Duration {
+	totals := make(map[string]time.Duration)
+	for name, days := range billableDays(spans, c) {
+		for _, d := range days {
+			totals[name] += d
+		}
+	}
+	return totals
+}
+
+// billableDays is billableUsage per project and day (YYYY-MM-DD), each day
+// already rounded.
+func billableDays(spans []Span, c client) map[string]map[string]time.Duration {
+	perDay := make(map[string]map[string]time.Duration)
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		if s.Project == "" {
+			s.Project = projectFor(s)
+		}
+		p, ok := lookupProject(s.Project)
+		if !ok || !p.billable() || !strings.EqualFold(p.client, c.name) {
+			continue
+		}
+		day := s.Start.Format("2006-01-02")
+		if perDay[p.name] == nil {
+			perDay[p.name] = make(map[string]time.Duration)
+		}
+		perDay[p.name][day] += p.allowedTime(s)
+	}
+	for _, days := range perDay {
+		for day, d := range days {
+			days[day] = roundBillable(d, c)
+		}
+	}
+	return perDay
+}
+
+// billable reports whether the project's time is billed to its client.
+func (p project) billable() bool {
+	return p.client != "" && !p.nonBillable
+}
+
+// hourlyRate is the project's rate, or its client's.
+func (p project) hourlyRate(c client) int64 {
+	if p.rate > 0 {
+		return p.rate
+	}
+	return c.rate
+}
+
+// runRevenue handles "revenue [-month YYYY-MM | -year YYYY]": what each
+// client's time, fees and expenses in the period are worth.
+func runRevenue(args []string) error {
+	fs := flag.NewFlagSet("revenue", flag.ExitOnError)
+	monthStr := fs.String("month", "", "month (YYYY-MM, default this month)")
+	yearStr := fs.String("year", "", "whole year (YYYY) instead of a month")
+	currency := fs.String("currency", invoiceCurrency, "currency to total in, converting with EXCHANGE_RATES")
+	fs.Parse(args)
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 1, 0)
+	var err error
+	switch {
+	case *yearStr != "":
+		if from, err = time.ParseInLocation("2006", *yearStr, time.Local); err != nil {
+			return fmt.Errorf("invalid year %q, expected YYYY", *yearStr)
+		}
+		to = from.AddDate(1, 0, 0)
+	case *monthStr != "":
+		if from, err = time.ParseInLocation("2006-01", *monthStr, time.Local); err != nil {
+			return fmt.Errorf("invalid month %q, expected YYYY-MM", *monthStr)
+		}
+		to = from.AddDate(0, 1, 0)
+	}
+	code, err := parseCurrency(*currency)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-24s %10s %12s %12s %12s %12s\n", "Client ("+code+")", "Hours", "Time", "Fees", "Expenses", "Total")
+	var sums [4]int64
+	var hours time.Duration
+	for _, c := range clients {
+		lines, err := clientBill(c, from, to, code)
+		if err != nil {
+			return fmt.Errorf("%s: %v", c.name, err)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		var row [4]int64 // time, fees, expenses, total
+		var h time.Duration
+		for _, l := range lines {
+			switch l.kind {
+			case chargeFee:
+				row[1] += l.amount
+			case chargeExpense:
+				row[2] += l.amount
+			default:
+				row[0] += l.amount
+				h += l.hours
+			}
+			row[3] += l.amount
+		}
+		for i := range row {
+			sums[i] += row[i]
+		}
+		hours += h
+		fmt.Printf("%-24s %10.2f %12s %12s %12s %12s\n", c.name, h.Hours(), formatMoney(row[0]), formatMoney(row[1]), formatMoney(row[2]), formatMoney(row[3]))
+	}
+	fmt.Printf("%-24s %10.2f %12s %12s %12s %12s\n", "Total", hours.Hours(), formatMoney(sums[0]), formatMoney(sums[1]), formatMoney(sums[2]), formatMoney(sums[3]))
+	return nil
+}
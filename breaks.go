@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// breakGrace is how long a reminder waits for a break before it counts as
+// skipped, and the default snooze.
+const breakGrace = 15 * time.Minute
+
+// breakEvent records what became of a break reminder.
+type breakEvent struct {
+	Reminded time.Time `json:"reminded"`
+	Outcome  string    `json:"outcome"` // taken, skipped or snoozed
+}
+
+func breaksPath(day time.Time) string {
+	return filepath.Join(logs, fmt.Sprintf("focus_tracker_%s_breaks.jsonl", day.Format("2006-01-02")))
+}
+
+func breakSnoozePath() string {
+	return filepath.Join(logs, "break_snooze")
+}
+
+// breakRun is the tracker's reminder state.
+var breakRun struct {
+	activeSince time.Time
+	remindedAt  time.Time
+}
+
+// breakTick is called from the tracker loop with the current idle time. A
+// stretch of idleness of at least BREAK_GAP counts as a break.
+func breakTick(now time.Time, idle time.Duration) {
+	if breakAfter == 0 {
+		return
+	}
+	if breakRun.activeSince.IsZero() || idle >= breakGap {
+		if idle >= breakGap && !breakRun.remindedAt.IsZero() {
+			recordBreak(breakRun.remindedAt, "taken")
+			breakRun.remindedAt = time.Time{}
+		}
+		breakRun.activeSince = now
+		return
+	}
+
+	if !breakRun.remindedAt.IsZero() {
+		switch {
+		case snoozedUntil().After(now):
+			recordBreak(breakRun.remindedAt, "snoozed")
+		case now.Sub(breakRun.remindedAt) >= breakGrace:
+			recordBreak(breakRun.remindedAt, "skipped")
+		default:
+			return
+		}
+		breakRun.remindedAt = time.Time{}
+	}
+
+	if now.Sub(breakRun.activeSince) >= breakAfter && !snoozedUntil().After(now) {
+		notify("Time for a break", fmt.Sprintf("You have been at it for %s. Run \"focus-tracker breaks snooze\" to postpone.",
+			shortDuration(now.Sub(breakRun.activeSince))))
+		breakRun.remindedAt = now
+	}
+}
+
+func recordBreak(reminded time.Time, outcome string) {
+	if err := appendJSONLine(breaksPath(reminded), breakEvent{Reminded: reminded, Outcome: outcome}); err != nil {
+		fmt.Printf("%s Could not record break reminder: %v\n", glyphs.warn, err)
+	}
+}
+
+func snoozedUntil() time.Time {
+	data, err := os.ReadFile(breakSnoozePath())
+	if err != nil {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339, string(data))
+	return t
+}
+
+// runBreaks handles "breaks snooze [15m]" and "breaks [-day D]" statistics.
+func runBreaks(args []string) error {
+	if len(args) > 0 && args[0] == "snooze" {
+		d := breakGrace
+		if len(args) > 1 {
+			var err error
+			if d, err = parsePositiveDuration(args[1]); err != nil {
+				return err
+			}
+		}
+		until := time.Now().Add(d)
+		if err := os.WriteFile(breakSnoozePath(), []byte(until.Format(time.RFC3339)), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Break reminders snoozed until %s.\n", until.Format("15:04"))
+		return nil
+	}
+
+	fs := flag.NewFlagSet("breaks", flag.ExitOnError)
+	dayStr := fs.String("day", "", "day to show (YYYY-MM-DD, today or yesterday)")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return errors.New("usage: breaks [-day YYYY-MM-DD] | breaks snooze [duration]")
+	}
+	day, err := parseDay(*dayStr)
+	if err != nil {
+		return err
+	}
+	events, err := readBreaks(day)
+	if err != nil {
+		return err
+	}
+	writeBreakStats(os.Stdout, events)
+	return nil
+}
+
+func readBreaks(day time.Time) ([]breakEvent, error) {
+	f, err := os.Open(breaksPath(day))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []breakEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e breakEvent
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			events = append(events, e)
+		}
+	}
+	return events, scanner.Err()
+}
+
+func writeBreakStats(w io.Writer, events []breakEvent) {
+	counts := make(map[string]int)
+	for _, e := range events {
+		counts[e.Outcome]++
+	}
+	fmt.Fprintf(w, "Break reminders: %d (%d taken, %d skipped, %d snoozed)\n",
+		len(events), counts["taken"], counts["skipped"], counts["snoozed"])
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// With BROWSER_URLS the tracker asks Safari, Chrome, Edge and Arc for the
+// URL of their active tab and records its domain with the span, since a
+// browser's window title rarely says which site it was. Only the domain is
+// kept, never the path or query.
+
+// urlDomain returns the host of a web page URL without a leading "www.",
+// or "" for anything else, e.g. about:blank, file: or chrome: pages.
+func urlDomain(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// writeDomains is the report section with browser time per domain.
+func writeDomains(w io.Writer, spans []Span) {
+	totals := make(map[string]time.Duration)
+	for _, s := range spans {
+		if s.Domain != "" && !s.Away() {
+			totals[s.Domain] += s.Duration()
+		}
+	}
+	if len(totals) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nDomains\n")
+	for i, domain := range sortedByDuration(totals) {
+		if i == 10 {
+			break
+		}
+		fmt.Fprintf(w, "  %s: %s\n", domain, shortDuration(totals[domain]))
+	}
+}
@@ -0,0 +1,26 @@
+//go:build darwin
+
+package main
+
+// tabURLScripts asks each supported browser for the URL of its active tab.
+// macOS asks once per browser to allow the tracker to control it.
+var tabURLScripts = map[string]string{
+	"Safari":         `tell application "Safari" to get URL of front document`,
+	"Google Chrome":  `tell application "Google Chrome" to get URL of active tab of front window`,
+	"Microsoft Edge": `tell application "Microsoft Edge" to get URL of active tab of front window`,
+	"Arc":            `tell application "Arc" to get URL of active tab of front window`,
+}
+
+// activeTabURL returns the URL shown by the frontmost browser, or "" when
+// the app is not a supported browser or has no window.
+func activeTabURL(app string) string {
+	script, ok := tabURLScripts[app]
+	if !ok {
+		return ""
+	}
+	out, err := runAppleScript(script)
+	if err != nil {
+		return ""
+	}
+	return out
+}
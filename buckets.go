@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Totals maps app -> window title -> focused time.
+type Totals map[string]map[string]time.Duration
+
+// add credits a span, allocating the map on first use.
+func (t Totals) add(s Span) Totals {
+	if t == nil {
+		t = make(Totals)
+	}
+	if _, ok := t[s.App]; !ok {
+		t[s.App] = make(map[string]time.Duration)
+	}
+	t[s.App][s.Title] += s.Duration()
+	return t
+}
+
+// outsideBucket is a named slice of outside-hours time: "weekend", "holiday",
+// "vacation", or a daily time window such as evening=17:00-22:00.
+type outsideBucket struct {
+	name   string
+	window window
+}
+
+// parseOutsideBuckets reads "weekend, holiday, early=05:00-08:00, night=22:00-05:00".
+func parseOutsideBuckets(input string) ([]outsideBucket, error) {
+	var result []outsideBucket
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, spec, hasWindow := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || strings.ContainsAny(name, " /") {
+			return nil, fmt.Errorf("invalid bucket name %q", name)
+		}
+		b := outsideBucket{name: name}
+		switch {
+		case name == "weekend" || name == "holiday" || name == "vacation":
+			if hasWindow {
+				return nil, fmt.Errorf("bucket %q is defined by the calendar and takes no time window", name)
+			}
+		case !hasWindow:
+			return nil, fmt.Errorf("bucket %q needs a time window, e.g. %s=17:00-22:00", name, name)
+		default:
+			w, err := parseWindow(strings.TrimSpace(spec))
+			if err != nil {
+				return nil, fmt.Errorf("bucket %q: %v", name, err)
+			}
+			b.window = w
+		}
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+const (
+	lockedApp = "Locked screen"
+	lunchApp  = "Lunch"
+	pausedApp = "Paused"
+)
+
+// Away reports whether the span is time away from the computer, or time
+// the user paused tracking for.
+func (s Span) Away() bool {
+	return s.App == lockedApp || s.App == lunchApp || s.App == pausedApp
+}
+
+// isLunch recognizes the midday lock/idle gap by its start and length.
+func isLunch(s Span) bool {
+	if lunchWindow == nil || s.App != lockedApp {
+		return false
+	}
+	d := s.Duration()
+	return lunchWindow.contains(s.Start) && d >= lunchMin && d <= lunchMax
+}
+
+// classify sets the project, the contexts, the work flag and, for outside hours, the first
+// matching bucket from OUTSIDE_BUCKETS, judged by when the span started.
+// A lunch break gets a bucket of its own so it counts neither as work nor
+// as outside hours.
+func classify(s Span) Span {
+	s.Bucket = ""
+	if isLunch(s) {
+		s.App, s.Work, s.Bucket = lunchApp, false, "lunch"
+		return s
+	}
+	if s.Project = manualProject(); s.Project == "" {
+		s.Project = projectFor(s)
+	}
+	s.Contexts = contextsFor(s)
+	s.Category = categoryFor(s)
+	s.Issue = issueFor(s)
+	s.Work = isWorkHour(s.Start)
+	if s.Work {
+		return s
+	}
+	for _, b := range outsideBuckets {
+		var match bool
+		switch b.name {
+		case "holiday", "vacation":
+			match = dayOff(s.Start) == b.name
+		case "weekend":
+			match = !isWorkday(s.Start.Weekday())
+		default:
+			match = b.window.contains(s.Start)
+		}
+		if match {
+			s.Bucket = b.name
+			return s
+		}
+	}
+	return s
+}
+
+// Suffix names the summary file the span belongs to.
+func (s Span) Suffix() string {
+	switch {
+	case s.Bucket == "lunch":
+		return "_lunch"
+	case s.Work:
+		return ""
+	case s.Bucket != "":
+		return "_outside_" + s.Bucket
+	}
+	return "_outside"
+}
+
+// Suffixes lists every summary file the span is counted in: its bucket and
+// one per context.
+func (s Span) Suffixes() []string {
+	suffixes := []string{s.Suffix()}
+	for _, name := range s.Contexts {
+		suffixes = append(suffixes, contextSuffix(name))
+	}
+	return suffixes
+}
+
+// bucketLabel turns a summary suffix back into a human name.
+func bucketLabel(suffix string) string {
+	switch {
+	case suffix == "":
+		return "work hours"
+	case suffix == "_outside":
+		return "outside hours"
+	case strings.HasPrefix(suffix, "_context_"):
+		return "context " + contextName(suffix)
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(suffix, "_outside"), "_")
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// calendarEvent is a VEVENT occurrence from an iCalendar file.
+type calendarEvent struct {
+	Summary   string
+	Start     time.Time
+	End       time.Time
+	AllDay    bool
+	Attendees int
+	Text      string // location and description, used for meeting detection
+}
+
+// icsEvent is a raw VEVENT before recurrence expansion.
+type icsEvent struct {
+	calendarEvent
+	rrule   map[string]string
+	exdates map[int64]bool
+}
+
+// openCalendar opens a local .ics file or fetches an http(s) URL.
+func openCalendar(source string) (io.ReadCloser, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "webcal://") {
+		resp, err := httpClient.Get(strings.Replace(source, "webcal://", "https://", 1))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("fetching %s: %s", source, resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return os.Open(source)
+}
+
+// calendarEvents returns the events of the calendar overlapping [from, to),
+// with daily and weekly recurrences expanded, sorted by start.
+func calendarEvents(source string, from, to time.Time) ([]calendarEvent, error) {
+	r, err := openCalendar(source)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	raw, err := parseICS(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	var events []calendarEvent
+	for _, ev := range raw {
+		for _, occ := range ev.occurrences(to) {
+			if occ.Start.Before(to) && occ.End.After(from) {
+				events = append(events, occ)
+			}
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+// parseICS reads the VEVENTs of an iCalendar stream.
+func parseICS(r io.Reader) ([]icsEvent, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Long lines are folded with a leading space or tab.
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var events []icsEvent
+	var cur *icsEvent
+	var duration time.Duration
+	for _, line := range lines {
+		nameParams, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, params := parseICSParams(nameParams)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			cur = &icsEvent{exdates: make(map[int64]bool)}
+			duration = 0
+		case name == "END" && value == "VEVENT" && cur != nil:
+			if cur.End.IsZero() {
+				switch {
+				case duration > 0:
+					cur.End = cur.Start.Add(duration)
+				case cur.AllDay:
+					cur.End = cur.Start.AddDate(0, 0, 1)
+				default:
+					cur.End = cur.Start
+				}
+			}
+			if !cur.Start.IsZero() {
+				events = append(events, *cur)
+			}
+			cur = nil
+		case cur == nil:
+			continue
+		case name == "SUMMARY":
+			cur.Summary = unescapeICS(value)
+		case name == "LOCATION" || name == "DESCRIPTION":
+			cur.Text += " " + unescapeICS(value)
+		case name == "ATTENDEE":
+			cur.Attendees++
+		case name == "DTSTART":
+			t, allDay, err := parseICSTime(value, params)
+			if err != nil {
+				return nil, err
+			}
+			cur.Start, cur.AllDay = t, allDay
+		case name == "DTEND":
+			t, _, err := parseICSTime(value, params)
+			if err != nil {
+				return nil, err
+			}
+			cur.End = t
+		case name == "DURATION":
+			duration = parseICSDuration(value)
+		case name == "RRULE":
+			cur.rrule = make(map[string]string)
+			for _, part := range strings.Split(value, ";") {
+				if k, v, ok := strings.Cut(part, "="); ok {
+					cur.rrule[k] = v
+				}
+			}
+		case name == "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				if t, _, err := parseICSTime(v, params); err == nil {
+					cur.exdates[t.Unix()] = true
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+func parseICSParams(s string) (string, map[string]string) {
+	parts := strings.Split(s, ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params
+}
+
+// parseICSTime handles UTC ("...Z"), floating/TZID local times and all-day dates.
+func parseICSTime(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t.Local(), false, err
+	}
+	loc := time.Local
+	if tz := params["TZID"]; tz != "" {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t.Local(), false, err
+}
+
+// parseICSDuration reads durations like "PT1H30M" or "P1D".
+func parseICSDuration(value string) time.Duration {
+	var total time.Duration
+	num := ""
+	for _, r := range strings.TrimPrefix(strings.TrimPrefix(value, "-"), "P") {
+		if r >= '0' && r <= '9' {
+			num += string(r)
+			continue
+		}
+		n, _ := strconv.Atoi(num)
+		num = ""
+		switch r {
+		case 'W':
+			total += time.Duration(n) * 7 * 24 * time.Hour
+		case 'D':
+			total += time.Duration(n) * 24 * time.Hour
+		case 'H':
+			total += time.Duration(n) * time.Hour
+		case 'M':
+			total += time.Duration(n) * time.Minute
+		case 'S':
+			total += time.Duration(n) * time.Second
+		}
+	}
+	return total
+}
+
+func unescapeICS(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// occurrences expands DAILY and WEEKLY recurrence rules up to the given time.
+// Other frequencies only yield the first occurrence.
+func (ev icsEvent) occurrences(until time.Time) []calendarEvent {
+	freq := ev.rrule["FREQ"]
+	if freq != "DAILY" && freq != "WEEKLY" {
+		return []calendarEvent{ev.calendarEvent}
+	}
+
+	interval, _ := strconv.Atoi(ev.rrule["INTERVAL"])
+	if interval < 1 {
+		interval = 1
+	}
+	count, _ := strconv.Atoi(ev.rrule["COUNT"])
+	if v := ev.rrule["UNTIL"]; v != "" {
+		if t, _, err := parseICSTime(v, nil); err == nil && t.Before(until) {
+			until = t.Add(time.Second)
+		}
+	}
+	byDay := make(map[time.Weekday]bool)
+	for _, d := range strings.Split(ev.rrule["BYDAY"], ",") {
+		if wd, ok := icsWeekdays[d]; ok {
+			byDay[wd] = true
+		}
+	}
+	if freq == "WEEKLY" && len(byDay) == 0 {
+		byDay[ev.Start.Weekday()] = true
+	}
+
+	length := ev.End.Sub(ev.Start)
+	var result []calendarEvent
+	n := 0
+	for day := ev.Start; day.Before(until); day = day.AddDate(0, 0, 1) {
+		daysIn := int(day.Sub(ev.Start).Hours()/24 + 0.5)
+		switch freq {
+		case "DAILY":
+			if daysIn%interval != 0 || (len(byDay) > 0 && !byDay[day.Weekday()]) {
+				continue
+			}
+		case "WEEKLY":
+			weekStart := ev.Start.AddDate(0, 0, -int(ev.Start.Weekday()))
+			if int(day.Sub(weekStart).Hours()/24+0.5)/7%interval != 0 || !byDay[day.Weekday()] {
+				continue
+			}
+		}
+		n++
+		if count > 0 && n > count {
+			break
+		}
+		if ev.exdates[day.Unix()] {
+			continue
+		}
+		occ := ev.calendarEvent
+		occ.Start, occ.End = day, day.Add(length)
+		result = append(result, occ)
+	}
+	return result
+}
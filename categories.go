@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// category is the kind of activity a span is, e.g. Communication or Coding,
+// independent of the project it is for. Categories are checked in file
+// order and the first match wins, so specific rules go before broad ones:
+//
+//	[category "Code review"]
+//	title = (?i)pull request
+//
+//	[category "Coding"]
+//	bundle_id = ^com\.(microsoft\.VSCode|googlecode\.iterm2)$
+//	clockify_project = Website
+//	clockify_task = Development
+type category struct {
+	name     string
+	app      *regexp.Regexp
+	bundleID *regexp.Regexp
+	title    *regexp.Regexp
+	// clockifyProject and clockifyTask are where sync clockify books the
+	// category's time
+	clockifyProject, clockifyTask string
+}
+
+var categories []category
+
+// matches reports whether every pattern set on the category matches the span.
+func (c category) matches(s Span) bool {
+	if c.app != nil && !c.app.MatchString(s.App) {
+		return false
+	}
+	if c.bundleID != nil && !c.bundleID.MatchString(s.BundleID) {
+		return false
+	}
+	if c.title != nil && !c.title.MatchString(s.Title) {
+		return false
+	}
+	return true
+}
+
+// categoryFor returns the first category in file order matching the span.
+func categoryFor(s Span) string {
+	for _, c := range categories {
+		if c.matches(s) {
+			return c.name
+		}
+	}
+	return ""
+}
+
+func hasCategory(name string) bool {
+	_, ok := lookupCategory(name)
+	return ok
+}
+
+func lookupCategory(name string) (category, bool) {
+	for _, c := range categories {
+		if strings.EqualFold(c.name, name) {
+			return c, true
+		}
+	}
+	return category{}, false
+}
+
+func parseCategory(path string, sec ruleSection) (category, []error) {
+	c := category{name: sec.name}
+	var errs []error
+	for _, k := range sec.keys {
+		where := fmt.Sprintf("%s:%d", path, k.line)
+		var err error
+		switch k.key {
+		case "app":
+			c.app, err = regexp.Compile(k.value)
+		case "bundle_id":
+			c.bundleID, err = regexp.Compile(k.value)
+		case "title":
+			c.title, err = regexp.Compile(k.value)
+		case "clockify_project":
+			c.clockifyProject = k.value
+		case "clockify_task":
+			c.clockifyTask = k.value
+		default:
+			msg := fmt.Sprintf("%s: unknown category key %q", where, k.key)
+			if guess := closestMatch(k.key, []string{"app", "bundle_id", "title", "clockify_project", "clockify_task"}); guess != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", guess)
+			}
+			errs = append(errs, errors.New(msg))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", where, k.key, err))
+		}
+	}
+	if c.app == nil && c.bundleID == nil && c.title == nil {
+		errs = append(errs, fmt.Errorf("%s:%d: category %q needs an app, bundle_id or title pattern", path, sec.line, sec.name))
+	}
+	if c.clockifyTask != "" && c.clockifyProject == "" {
+		errs = append(errs, fmt.Errorf("%s:%d: category %q has a clockify_task but no clockify_project", path, sec.line, sec.name))
+	}
+	return c, errs
+}
+
+// writeCategories is the report section with time per category.
+func writeCategories(w io.Writer, spans []Span) {
+	if len(categories) == 0 {
+		return
+	}
+	totals := make(map[string]time.Duration)
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		name := s.Category
+		if name == "" {
+			name = "(uncategorized)"
+		}
+		totals[name] += s.Duration()
+	}
+	if len(totals) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nBy category\n")
+	for _, name := range sortedByDuration(totals) {
+		fmt.Fprintf(w, "  %s: %s\n", name, shortDuration(totals[name]))
+	}
+}
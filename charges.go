@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	chargeFee     = "fee"     // a fixed price, e.g. a milestone; taxed like time
+	chargeExpense = "expense" // passed on to the client without tax
+)
+
+// charge is a non-hourly item billed to a client, kept in
+// LOG_PATH/charges.jsonl.
+type charge struct {
+	ID          int       `json:"id"`
+	Date        time.Time `json:"date"`
+	Kind        string    `json:"kind"`
+	Client      string    `json:"client"`
+	Project     string    `json:"project,omitempty"`
+	Description string    `json:"description"`
+	Amount      int64     `json:"amount_cents"`
+	Currency    string    `json:"currency"`
+}
+
+func chargesPath() string {
+	return filepath.Join(logs, "charges.jsonl")
+}
+
+func readCharges() ([]charge, error) {
+	f, err := os.Open(chargesPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var charges []charge
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ch charge
+		if json.Unmarshal(scanner.Bytes(), &ch) == nil {
+			charges = append(charges, ch)
+		}
+	}
+	return charges, scanner.Err()
+}
+
+func writeCharges(charges []charge) error {
+	var b strings.Builder
+	for _, ch := range charges {
+		line, err := json.Marshal(ch)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	return writeFileAtomic(chargesPath(), b.String())
+}
+
+// clientCharges returns the client's charges dated in [from, to).
+func clientCharges(c client, from, to time.Time) ([]charge, error) {
+	all, err := readCharges()
+	if err != nil {
+		return nil, err
+	}
+	var result []charge
+	for _, ch := range all {
+		if cc, ok := lookupClient(ch.Client); ok && cc.name == c.name && !ch.Date.Before(from) && ch.Date.Before(to) {
+			result = append(result, ch)
+		}
+	}
+	return result, nil
+}
+
+// runCharge handles "charge add|list|remove".
+func runCharge(args []string) error {
+	usage := errors.New("usage: charge add -client NAME [-project NAME] [-expense] [-date YYYY-MM-DD] [-currency CODE] AMOUNT DESCRIPTION | list [-client NAME] [-month YYYY-MM] | remove ID")
+	if len(args) == 0 {
+		return usage
+	}
+	charges, err := readCharges()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("charge add", flag.ExitOnError)
+		clientName := fs.String("client", "", "client to bill")
+		projectName := fs.String("project", "", "project the charge belongs to")
+		expense := fs.Bool("expense", false, "an expense passed on without tax, rather than a fixed fee")
+		dateStr := fs.String("date", "", "date of the charge (YYYY-MM-DD, default today)")
+		currency := fs.String("currency", "", "currency of the amount (default the client's)")
+		fs.Parse(args[1:])
+		if fs.NArg() < 2 {
+			return usage
+		}
+		c, ok := lookupClient(*clientName)
+		if !ok {
+			return fmt.Errorf("unknown client %q", *clientName)
+		}
+		ch := charge{Kind: chargeFee, Client: c.name, Currency: c.currencyCode()}
+		if *expense {
+			ch.Kind = chargeExpense
+		}
+		if *projectName != "" {
+			p, ok := lookupProject(*projectName)
+			if !ok {
+				return fmt.Errorf("unknown project %q", *projectName)
+			}
+			ch.Project = p.name
+		}
+		if ch.Date, err = parseDay(*dateStr); err != nil {
+			return err
+		}
+		if *currency != "" {
+			if ch.Currency, err = parseCurrency(*currency); err != nil {
+				return err
+			}
+		}
+		if ch.Amount, err = parseMoney(fs.Arg(0)); err != nil {
+			return err
+		}
+		ch.Description = strings.Join(fs.Args()[1:], " ")
+		if err := checkUnlocked(ch.Date, ch.Date.AddDate(0, 0, 1)); err != nil {
+			return err
+		}
+		for _, old := range charges {
+			if old.ID >= ch.ID {
+				ch.ID = old.ID + 1
+			}
+		}
+		if ch.ID == 0 {
+			ch.ID = 1
+		}
+		if err := writeCharges(append(charges, ch)); err != nil {
+			return err
+		}
+		audit("charge", "added %s %d for %s on %s: %s %s %s", ch.Kind, ch.ID, c.name, ch.Date.Format("2006-01-02"), formatMoney(ch.Amount), ch.Currency, ch.Description)
+		fmt.Printf("%s Added %s %d for %s: %s %s %s\n", glyphs.ok, ch.Kind, ch.ID, c.name, formatMoney(ch.Amount), ch.Currency, ch.Description)
+		return nil
+
+	case "list":
+		fs := flag.NewFlagSet("charge list", flag.ExitOnError)
+		clientName := fs.String("client", "", "only this client's charges")
+		monthStr := fs.String("month", "", "only charges in this month (YYYY-MM)")
+		fs.Parse(args[1:])
+		if len(charges) == 0 {
+			fmt.Println("No charges.")
+			return nil
+		}
+		for _, ch := range charges {
+			if *clientName != "" {
+				if c, ok := lookupClient(ch.Client); !ok || !strings.EqualFold(c.name, canonicalClient(*clientName)) {
+					continue
+				}
+			}
+			if *monthStr != "" && ch.Date.Format("2006-01") != *monthStr {
+				continue
+			}
+			on := ch.Client
+			if ch.Project != "" {
+				on += " / " + canonicalProject(ch.Project)
+			}
+			fmt.Printf("%4d  %s  %-7s  %12s %s  %s  %s\n", ch.ID, ch.Date.Format("2006-01-02"), ch.Kind, formatMoney(ch.Amount), ch.Currency, on, ch.Description)
+		}
+		return nil
+
+	case "remove":
+		if len(args) != 2 {
+			return usage
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid charge ID %q", args[1])
+		}
+		for i, ch := range charges {
+			if ch.ID == id {
+				if err := checkUnlocked(ch.Date, ch.Date.AddDate(0, 0, 1)); err != nil {
+					return err
+				}
+				if err := writeCharges(append(charges[:i:i], charges[i+1:]...)); err != nil {
+					return err
+				}
+				audit("charge", "removed %s %d for %s on %s: %s %s %s", ch.Kind, ch.ID, ch.Client, ch.Date.Format("2006-01-02"), formatMoney(ch.Amount), ch.Currency, ch.Description)
+				fmt.Printf("%s Removed %s %d: %s\n", glyphs.ok, ch.Kind, ch.ID, ch.Description)
+				return nil
+			}
+		}
+		return fmt.Errorf("no charge %d", id)
+	}
+	return usage
+}
+
+// canonicalClient maps a client name or alias to the client's name.
+func canonicalClient(name string) string {
+	if c, ok := lookupClient(name); ok {
+		return c.name
+	}
+	return name
+}
+
+// billLine is one priced line of a client's bill.
+type billLine struct {
+	description string
+	kind        string        // "time", chargeFee or chargeExpense
+	hours       time.Duration // for time
+	rate        int64         // for time
+	amount      int64
+}
+
+// clientBill prices the client's billable time and charges in [from, to)
+// in currency, converting with EXCHANGE_RATES.
+func clientBill(c client, from, to time.Time, currency string) ([]billLine, error) {
+	conversion, err := exchangeRate(c.currencyCode(), currency)
+	if err != nil {
+		return nil, err
+	}
+	spans, err := readSpanRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var lines []billLine
+	usage := billableUsage(spans, c)
+	for _, name := range sortedKeys(usage) {
+		p, _ := lookupProject(name)
+		rate := p.hourlyRate(c)
+		if rate == 0 {
+			return nil, fmt.Errorf("project %q has no rate; set rate in its [project] or [client] section", name)
+		}
+		// Rates are converted before pricing so each line adds up on the invoice
+		rate = int64(math.Round(float64(rate) * conversion))
+		lines = append(lines, billLine{
+			description: name,
+			kind:        "time",
+			hours:       usage[name],
+			rate:        rate,
+			amount:      billedAmount(usage[name], rate),
+		})
+	}
+
+	charges, err := clientCharges(c, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range charges {
+		rate, err := exchangeRate(ch.Currency, currency)
+		if err != nil {
+			return nil, err
+		}
+		desc := ch.Description
+		if ch.Project != "" {
+			desc = canonicalProject(ch.Project) + ": " + desc
+		}
+		if ch.Kind == chargeExpense {
+			desc = "Expense: " + desc
+		}
+		lines = append(lines, billLine{
+			description: desc,
+			kind:        ch.Kind,
+			amount:      int64(math.Round(float64(ch.Amount) * rate)),
+		})
+	}
+	return lines, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+const barWidth = 24
+
+var (
+	barEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+	sparkTicks = []string{" ", "▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+	asciiTicks = []string{" ", ".", ".", ":", ":", "-", "=", "+", "#"}
+)
+
+// bar draws d as a horizontal bar scaled so max fills barWidth cells, with
+// eighth-cell precision (whole cells only in ASCII mode).
+func bar(d, max time.Duration) string {
+	if max <= 0 || d <= 0 {
+		return ""
+	}
+	eighths := int(float64(d) / float64(max) * barWidth * 8)
+	if asciiOutput {
+		return strings.Repeat("#", (eighths+4)/8)
+	}
+	if eighths == 0 {
+		eighths = 1 // keep tiny entries visible
+	}
+	return strings.Repeat("█", eighths/8) + barEighths[eighths%8]
+}
+
+// hourlyActivity is the time at the computer (not away) in each hour of day.
+// Coarse spans of pruned days have no hour and are left out.
+func hourlyActivity(spans []Span, day time.Time) [24]time.Duration {
+	var hours [24]time.Duration
+	for _, s := range spans {
+		if s.Away() || s.Coarse {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			from := time.Date(day.Year(), day.Month(), day.Day(), h, 0, 0, 0, day.Location())
+			hours[h] += overlap(s.Start, s.End, from, from.Add(time.Hour))
+		}
+	}
+	return hours
+}
+
+// sparkline draws one character per hour, a full block being a full hour.
+func sparkline(hours [24]time.Duration) string {
+	ticks := sparkTicks
+	if asciiOutput {
+		ticks = asciiTicks
+	}
+	var b strings.Builder
+	for _, d := range hours {
+		i := int(float64(d) / float64(time.Hour) * float64(len(ticks)-1))
+		if d > 0 && i == 0 {
+			i = 1
+		}
+		if i > len(ticks)-1 {
+			i = len(ticks) - 1
+		}
+		b.WriteString(ticks[i])
+	}
+	return b.String()
+}
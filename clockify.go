@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const clockifyAPI = "https://api.clockify.me/api/v1"
+
+// Spans go to Clockify as time entries in CLOCKIFY_WORKSPACE_ID. The
+// clockify_project and clockify_task of a span's category say where its
+// time is booked; without them it goes to the Clockify project named like
+// its rules project, if there is one. As with Toggl, a span's external ID
+// is its start time to the second, so a day can be synced again without
+// duplicating entries.
+
+// clockifyTarget is where a span's time goes in Clockify, by name.
+type clockifyTarget struct {
+	project, task string
+	category      string // that named the project, if any
+	billable      bool
+}
+
+func clockifyTargetFor(s Span) clockifyTarget {
+	var t clockifyTarget
+	if p, ok := lookupProject(s.Project); ok {
+		t.project, t.billable = p.name, p.billable()
+	}
+	if c, ok := lookupCategory(s.Category); ok && c.clockifyProject != "" {
+		t.project, t.task, t.category = c.clockifyProject, c.clockifyTask, c.name
+	}
+	return t
+}
+
+func (t clockifyTarget) String() string {
+	switch {
+	case t.project == "":
+		return "no project"
+	case t.task != "":
+		return t.project + " / " + t.task
+	}
+	return t.project
+}
+
+// syncClockify creates time entries for the day's spans that have none
+// yet. With -dry-run it lists them instead, with where each would go.
+func syncClockify(day time.Time, spans []Span) error {
+	if clockifyToken == "" || clockifyWorkspace == "" {
+		return errors.New("CLOCKIFY_TOKEN and CLOCKIFY_WORKSPACE_ID must be set")
+	}
+	var wanted []Span
+	for _, s := range withContexts(spans) {
+		if clockifyWanted(s) {
+			wanted = append(wanted, s)
+		}
+	}
+	if len(wanted) == 0 {
+		fmt.Printf("%s Clockify: nothing to send for %s\n", glyphs.ok, day.Format("2006-01-02"))
+		return nil
+	}
+	existing, err := clockifyEntryStarts(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf("clockify: %w", err)
+	}
+	if syncDryRun {
+		fmt.Printf("Clockify entries for %s:\n", day.Format("2006-01-02"))
+	}
+	var created, skipped int
+	for _, s := range wanted {
+		t := clockifyTargetFor(s)
+		if syncDryRun {
+			note := ""
+			if existing[s.Start.Unix()] {
+				note = "  (already there)"
+			} else if _, _, err := clockifyResolve(t); err != nil {
+				note = "  (" + err.Error() + ")"
+			}
+			fmt.Printf("  %s-%s %7s  %s -> %s%s\n", s.Start.Format("15:04"), s.End.Format("15:04"),
+				shortDuration(s.Duration()), clockifyDescription(s), t, note)
+		}
+		if existing[s.Start.Unix()] {
+			skipped++
+			continue
+		}
+		if !syncDryRun {
+			if err := clockifyCreate(s, t); err != nil {
+				return fmt.Errorf("clockify: %s %s: %w", s.Start.Format("2006-01-02 15:04"), s.App, err)
+			}
+		}
+		created++
+	}
+	format := "%s Clockify: %d new time entries for %s, %d already there\n"
+	if syncDryRun {
+		format = "%s Clockify: %d time entries would be new for %s, %d already there\n"
+	}
+	fmt.Printf(format, glyphs.ok, created, day.Format("2006-01-02"), skipped)
+	return nil
+}
+
+// clockifyRecord is the tracker's live sink, like togglRecord.
+func clockifyRecord(span Span) {
+	if !clockifyWanted(span) {
+		return
+	}
+	go func() {
+		if err := clockifyCreate(span, clockifyTargetFor(span)); err != nil {
+			queueDaySync("clockify", "Clockify", span, err)
+		}
+	}()
+}
+
+// clockifyWanted reports whether a span goes to Clockify: work-hours,
+// project or mapped category time of at least CLOCKIFY_MIN_SPAN, and with
+// CLOCKIFY_MAPPED_ONLY only time whose category names a Clockify project.
+func clockifyWanted(s Span) bool {
+	if s.Away() || s.Bucket == "lunch" || s.Duration() < clockifyMinSpan {
+		return false
+	}
+	t := clockifyTargetFor(s)
+	if clockifyMappedOnly {
+		return t.category != ""
+	}
+	return s.Work || t.project != ""
+}
+
+func clockifyDescription(s Span) string {
+	if s.Title == "" {
+		return s.App
+	}
+	return s.App + ": " + s.Title
+}
+
+// clockifyEntry is a time entry as the Clockify API takes it.
+type clockifyEntry struct {
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	Description string `json:"description"`
+	ProjectID   string `json:"projectId,omitempty"`
+	TaskID      string `json:"taskId,omitempty"`
+	Billable    bool   `json:"billable"`
+}
+
+func clockifyCreate(s Span, t clockifyTarget) error {
+	projectID, taskID, err := clockifyResolve(t)
+	if err != nil {
+		return err
+	}
+	e := clockifyEntry{
+		Start:       clockifyTime(s.Start),
+		End:         clockifyTime(s.End),
+		Description: clockifyDescription(s),
+		ProjectID:   projectID,
+		TaskID:      taskID,
+		Billable:    t.billable,
+	}
+	return clockifyRequest(http.MethodPost, "/workspaces/"+clockifyWorkspace+"/time-entries", e, nil)
+}
+
+// clockifyTime is the UTC timestamp format the API expects.
+func clockifyTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// clockifyEntryStarts returns the start times (Unix seconds) of your
+// entries in the workspace that start in [from, to).
+func clockifyEntryStarts(from, to time.Time) (map[int64]bool, error) {
+	userID, err := clockifyUserID()
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{"start": {clockifyTime(from)}, "end": {clockifyTime(to)}, "page-size": {"1000"}}
+	var entries []struct {
+		TimeInterval struct {
+			Start time.Time `json:"start"`
+		} `json:"timeInterval"`
+	}
+	path := fmt.Sprintf("/workspaces/%s/user/%s/time-entries?%s", clockifyWorkspace, userID, q.Encode())
+	if err := clockifyRequest(http.MethodGet, path, nil, &entries); err != nil {
+		return nil, err
+	}
+	starts := make(map[int64]bool)
+	for _, e := range entries {
+		starts[e.TimeInterval.Start.Unix()] = true
+	}
+	return starts, nil
+}
+
+// clockifyCache holds the user and the workspace's project and task IDs.
+// Projects and tasks are fetched again once an hour, so ones added in
+// Clockify while the tracker runs are picked up.
+var clockifyCache struct {
+	sync.Mutex
+	userID   string
+	projects map[string]string            // lower-cased name to ID
+	tasks    map[string]map[string]string // by project ID, lower-cased name to ID
+	fetched  time.Time
+}
+
+func clockifyUserID() (string, error) {
+	clockifyCache.Lock()
+	defer clockifyCache.Unlock()
+	if clockifyCache.userID == "" {
+		var user struct {
+			ID string `json:"id"`
+		}
+		if err := clockifyRequest(http.MethodGet, "/user", nil, &user); err != nil {
+			return "", err
+		}
+		clockifyCache.userID = user.ID
+	}
+	return clockifyCache.userID, nil
+}
+
+// clockifyResolve looks up the IDs of the target's project and task. A
+// project or task named by a category has to exist; a project named like a
+// rules project is left out if there is none.
+func clockifyResolve(t clockifyTarget) (projectID, taskID string, err error) {
+	if t.project == "" {
+		return "", "", nil
+	}
+	clockifyCache.Lock()
+	defer clockifyCache.Unlock()
+	if clockifyCache.projects == nil || time.Since(clockifyCache.fetched) > time.Hour {
+		var projects []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		path := "/workspaces/" + clockifyWorkspace + "/projects?archived=false&page-size=5000"
+		if err := clockifyRequest(http.MethodGet, path, nil, &projects); err != nil {
+			return "", "", err
+		}
+		clockifyCache.projects = make(map[string]string)
+		clockifyCache.tasks = make(map[string]map[string]string)
+		for _, p := range projects {
+			clockifyCache.projects[strings.ToLower(p.Name)] = p.ID
+		}
+		clockifyCache.fetched = time.Now()
+	}
+	projectID = clockifyCache.projects[strings.ToLower(t.project)]
+	if projectID == "" {
+		if t.category != "" {
+			return "", "", fmt.Errorf("no Clockify project %q, the clockify_project of category %q", t.project, t.category)
+		}
+		return "", "", nil
+	}
+	if t.task == "" {
+		return projectID, "", nil
+	}
+	if clockifyCache.tasks[projectID] == nil {
+		var tasks []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		path := fmt.Sprintf("/workspaces/%s/projects/%s/tasks?page-size=5000", clockifyWorkspace, projectID)
+		if err := clockifyRequest(http.MethodGet, path, nil, &tasks); err != nil {
+			return "", "", err
+		}
+		clockifyCache.tasks[projectID] = make(map[string]string)
+		for _, task := range tasks {
+			clockifyCache.tasks[projectID][strings.ToLower(task.Name)] = task.ID
+		}
+	}
+	if taskID = clockifyCache.tasks[projectID][strings.ToLower(t.task)]; taskID == "" {
+		return "", "", fmt.Errorf("no task %q in Clockify project %q, the clockify_task of category %q", t.task, t.project, t.category)
+	}
+	return projectID, taskID, nil
+}
+
+func clockifyRequest(method, path string, body, out any) error {
+	return apiRequest(method, clockifyAPI+path, func(req *http.Request) {
+		req.Header.Set("X-Api-Key", clockifyToken)
+	}, body, out)
+}
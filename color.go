@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// colorTheme maps the roles of console text to ANSI SGR codes; an empty
+// code leaves the text alone.
+type colorTheme struct {
+	work, outside, lunch, over, top, dim string
+}
+
+var colorThemes = map[string]colorTheme{
+	"default": {work: "32", outside: "34", lunch: "33", over: "31", top: "1", dim: "2"},
+	"bright":  {work: "92;1", outside: "96;1", lunch: "93;1", over: "91;1", top: "1;4", dim: "37"},
+	"mono":    {over: "1;4", top: "1", dim: "2"},
+}
+
+// colors is the active theme; nil disables color. It is set in main once
+// the flags are parsed.
+var colors *colorTheme
+
+// setupColors enables the theme for terminal output unless NO_COLOR is set
+// (https://no-color.org) or the output is not a terminal.
+func setupColors(theme string, out *os.File) {
+	colors = nil
+	if theme == "off" || os.Getenv("NO_COLOR") != "" || !isTerminal(out) {
+		return
+	}
+	t := colorThemes[theme]
+	colors = &t
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func paint(code, text string) string {
+	if colors == nil || code == "" || text == "" {
+		return text
+	}
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
+
+// paintBucket colors text by the summary bucket it belongs to.
+func paintBucket(suffix, text string) string {
+	if colors == nil {
+		return text
+	}
+	switch {
+	case suffix == "":
+		return paint(colors.work, text)
+	case suffix == "_lunch":
+		return paint(colors.lunch, text)
+	case strings.HasPrefix(suffix, "_outside"):
+		return paint(colors.outside, text)
+	}
+	return text
+}
+
+func paintOver(text string) string {
+	if colors == nil {
+		return text
+	}
+	return paint(colors.over, text)
+}
+
+func paintTop(text string) string {
+	if colors == nil {
+		return text
+	}
+	return paint(colors.top, text)
+}
+
+func paintDim(text string) string {
+	if colors == nil {
+		return text
+	}
+	return paint(colors.dim, text)
+}
+
+// parseColorTheme accepts a theme name or "off".
+func parseColorTheme(input string) (string, error) {
+	input = strings.ToLower(input)
+	if _, ok := colorThemes[input]; ok || input == "off" {
+		return input, nil
+	}
+	return "", fmt.Errorf("unknown theme %q, expected %s or off", input, strings.Join(sortedKeys(colorThemes), ", "))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func runCommand(name string, args []string) error {
+	switch name {
+	case "track":
+		return runTrack(args)
+	case "config":
+		return runConfig(args)
+	case "report":
+		return runReport(args)
+	case "browse":
+		return runBrowse(args)
+	case "plan":
+		return runPlan(args)
+	case "compliance":
+		return runCompliance(args)
+	case "pomodoro":
+		return runPomodoro(args)
+	case "breaks":
+		return runBreaks(args)
+	case "service":
+		return runService(args)
+	case "login-item":
+		return runLoginItem(args)
+	case "install-daemon":
+		return runInstallDaemon(args)
+	case "uninstall-daemon":
+		return runUninstallDaemon(args)
+	case "pause":
+		return runPause(args)
+	case "resume":
+		return runResume(args)
+	case "ctl":
+		return runCtl(args)
+	case "tag":
+		return runTag(args)
+	case "note":
+		return runNote(args)
+	case "project":
+		return runProject(args)
+	case "menubar":
+		return runMenubar(args)
+	case "widget":
+		return runWidget(args)
+	case "quick":
+		return runQuick(args)
+	case "status":
+		return runStatus(args)
+	case "diag":
+		return runDiag(args)
+	case "site":
+		return runSite(args)
+	case "org":
+		return runOrgExport(args)
+	case "standup":
+		return runStandup(args)
+	case "obsidian":
+		return runObsidian(args)
+	case "screentime":
+		return runScreenTime(args)
+	case "activitywatch":
+		return runActivityWatch(args)
+	case "outbox":
+		return runOutboxCommand(args)
+	case "schedule":
+		return runSchedule(args)
+	case "export":
+		return runExport(args)
+	case "query":
+		return runQuery(args)
+	case "dataset":
+		return runDataset(args)
+	case "client":
+		return runClient(args)
+	case "charge":
+		return runCharge(args)
+	case "revenue":
+		return runRevenue(args)
+	case "lock":
+		return runLock(args)
+	case "unlock":
+		return runUnlock(args)
+	case "audit":
+		return runAudit(args)
+	case "timesheet":
+		return runTimesheet(args)
+	case "invoice":
+		return runInvoice(args)
+	case "screenshots":
+		return runScreenshots(args)
+	case "idle":
+		return runIdle(args)
+	case "ask":
+		return runAsk(args)
+	case "apps":
+		return runApps(args)
+	case "prune":
+		return runPrune(args)
+	case "sync":
+		return runSync(args)
+	}
+	return fmt.Errorf("unknown command %q", name)
+}
+
+// outputFile opens path for writing, or returns stdout for "" and "-".
+func outputFile(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	colors = nil // no escape codes in files
+	return os.Create(path)
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// dayFlags registers the -day and -o flags shared by the export commands.
+func dayFlags(fs *flag.FlagSet) (day, out *string) {
+	day = fs.String("day", "", "day to export (YYYY-MM-DD, today or yesterday)")
+	out = fs.String("o", "", "output file (default stdout)")
+	return
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// dayActivity is the active (non-away) time of one calendar day.
+type dayActivity struct {
+	day          time.Time
+	first, last  time.Time
+	worked       time.Duration
+	longestBreak time.Duration
+}
+
+// runCompliance checks tracked time against EU Working Time Directive style
+// rules: minimum daily rest, the weekly average over a reference period and
+// rest breaks on long days.
+func runCompliance(args []string) error {
+	fs := flag.NewFlagSet("compliance", flag.ExitOnError)
+	toStr := fs.String("to", "", "last day of the reference period (YYYY-MM-DD, default today)")
+	weeks := fs.Int("weeks", 17, "reference period in weeks for the weekly average")
+	rest := fs.Duration("rest", 11*time.Hour, "minimum daily rest between working days")
+	weekly := fs.Duration("weekly", 48*time.Hour, "maximum average working time per week")
+	longDay := fs.Duration("long-day", 6*time.Hour, "working time after which a rest break is required")
+	breakMin := fs.Duration("break", 30*time.Minute, "minimum rest break on long days")
+	workOnly := fs.Bool("work-only", false, "count only time inside work hours (default counts all active time)")
+	outPath := fs.String("o", "", "output file (default stdout)")
+	fs.Parse(args)
+	if *weeks < 1 {
+		return fmt.Errorf("-weeks must be at least 1, got %d", *weeks)
+	}
+
+	to, err := parseDay(*toStr)
+	if err != nil {
+		return err
+	}
+	from := to.AddDate(0, 0, -7**weeks+1)
+
+	var days []dayActivity
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		spans, err := readSpans(day)
+		if err != nil {
+			return err
+		}
+		if a, ok := activity(day, spans, *workOnly); ok {
+			days = append(days, a)
+		}
+	}
+
+	w, err := outputFile(*outPath)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	violations := 0
+	fmt.Fprintf(w, "Working-time compliance %s .. %s (%d weeks)\n", from.Format("2006-01-02"), to.Format("2006-01-02"), *weeks)
+	fmt.Fprintf(w, "----------------------------------------\n")
+
+	// Weekly average over the reference period
+	perWeek := make(map[string]time.Duration)
+	var total time.Duration
+	for _, d := range days {
+		y, wk := d.day.ISOWeek()
+		perWeek[fmt.Sprintf("%d-W%02d", y, wk)] += d.worked
+		total += d.worked
+	}
+	avg := total / time.Duration(*weeks)
+	status := "OK"
+	if avg > *weekly {
+		status = paintOver(glyphs.warn + " over the limit")
+		violations++
+	}
+	fmt.Fprintf(w, "Weekly average: %s (limit %s) %s\n", shortDuration(avg), shortDuration(*weekly), status)
+	for _, week := range sortedKeys(perWeek) {
+		if perWeek[week] > *weekly {
+			fmt.Fprintf(w, "  %s: %s (allowed if the average stays below the limit)\n", week, shortDuration(perWeek[week]))
+		}
+	}
+
+	// Daily rest between consecutive working days
+	var shortRests []string
+	for i := 1; i < len(days); i++ {
+		prev, cur := days[i-1], days[i]
+		if cur.day.Sub(prev.day) > 24*time.Hour+time.Hour { // not consecutive (allow for DST)
+			continue
+		}
+		if gap := cur.first.Sub(prev.last); gap < *rest {
+			shortRests = append(shortRests, fmt.Sprintf("  %s -> %s: %s (%s - %s)",
+				prev.day.Format("2006-01-02"), cur.day.Format("2006-01-02"), shortDuration(gap),
+				prev.last.Format("15:04"), cur.first.Format("15:04")))
+		}
+	}
+	fmt.Fprintf(w, "\nDaily rest below %s: %d\n", shortDuration(*rest), len(shortRests))
+	for _, line := range shortRests {
+		fmt.Fprintln(w, paintOver(line))
+	}
+	violations += len(shortRests)
+
+	// Rest breaks on long days
+	var noBreak []string
+	for _, d := range days {
+		if d.worked > *longDay && d.longestBreak < *breakMin {
+			noBreak = append(noBreak, fmt.Sprintf("  %s: %s worked, longest break %s",
+				d.day.Format("2006-01-02"), shortDuration(d.worked), shortDuration(d.longestBreak)))
+		}
+	}
+	fmt.Fprintf(w, "\nDays over %s without a %s break: %d\n", shortDuration(*longDay), shortDuration(*breakMin), len(noBreak))
+	for _, line := range noBreak {
+		fmt.Fprintln(w, paintOver(line))
+	}
+	violations += len(noBreak)
+
+	summary := fmt.Sprintf("Violations: %d", violations)
+	if violations > 0 {
+		summary = paintOver(summary)
+	}
+	fmt.Fprintf(w, "\n%s\n", summary)
+	return nil
+}
+
+// activity summarizes a day's active spans; ok is false for days without any.
+// The coarse spans of pruned days have no times of day and are left out.
+func activity(day time.Time, spans []Span, workOnly bool) (dayActivity, bool) {
+	var active []Span
+	for _, s := range spans {
+		if s.Away() || s.Coarse || (workOnly && !s.Work) {
+			continue
+		}
+		active = append(active, s)
+	}
+	if len(active) == 0 {
+		return dayActivity{}, false
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Start.Before(active[j].Start) })
+
+	a := dayActivity{day: day, first: active[0].Start, last: active[0].End}
+	for _, s := range active {
+		a.worked += s.Duration()
+		if gap := s.Start.Sub(a.last); gap > a.longestBreak {
+			a.longestBreak = gap
+		}
+		if s.End.After(a.last) {
+			a.last = s.End
+		}
+	}
+	return a, true
+}
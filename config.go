@@ -0,0 +1,1303 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+}
+
+var (
+	idleTreshold  int
+	idleAuto      bool
+	idleCalibrate bool
+	workdaysSet   map[time.Weekday]bool
+	workStart     TimeOfDay
+	workEnd       TimeOfDay
+	workHours     []window                  // WORK_HOURS; nil uses WORK_START to WORK_END
+	workSchedule  map[time.Weekday][]window // WORK_SCHEDULE; nil uses WORK_DAYS and WORK_HOURS
+	logs          string
+	asciiOutput   bool
+	themeName     string
+
+	workHoursOnly bool
+	quietHours    []window
+
+	outsideBuckets []outsideBucket
+	holidays       map[string]bool
+	vacationDays   map[string]bool
+	holidaysICS    string
+
+	rulesFile string
+
+	breakAfter time.Duration
+	breakGap   time.Duration
+
+	usageLimits []usageLimit
+
+	crashReportURL string
+	watchdogStall  time.Duration
+	scheduledJobs  []*scheduledJob
+
+	outboxMaxAttempts int
+	metricsAddr       string
+	dashboardAddr     string
+	controlSocket     string // "" when off
+	controlAddr       string
+
+	hotkeyPause  string
+	hotkeyTag    string
+	hotkeyNote   string
+	hotkeyWidget string
+
+	lunchWindow *window
+	lunchMin    time.Duration
+	lunchMax    time.Duration
+
+	calendarSource string
+	meetingApps    map[string]bool
+
+	obsidianVault     string
+	obsidianDailyNote string
+
+	notionToken    string
+	notionDatabase string
+
+	influxURL    string
+	influxToken  string
+	influxOrg    string
+	influxBucket string
+
+	togglToken        string
+	togglWorkspace    int64
+	togglProjectsOnly bool
+	togglMinSpan      time.Duration
+
+	clockifyToken      string
+	clockifyWorkspace  string
+	clockifyMappedOnly bool
+	clockifyMinSpan    time.Duration
+
+	harvestToken       string
+	harvestAccount     string
+	harvestDefaultTask string
+	harvestRound       time.Duration
+	harvestRoundMode   string
+
+	jiraProjects   *regexp.Regexp // JIRA_PROJECTS; nil matches any key
+	jiraURL        string
+	jiraEmail      string
+	jiraToken      string
+	tempoToken     string
+	jiraMinWorklog time.Duration
+
+	activityWatchURL string
+
+	bigqueryCredentials string
+	bigqueryDataset     string
+	bigqueryTable       string
+
+	snowflakeAccount   string
+	snowflakeUser      string
+	snowflakeKey       string
+	snowflakeDatabase  string
+	snowflakeSchema    string
+	snowflakeWarehouse string
+	snowflakeRole      string
+	snowflakeTable     string
+
+	invoiceFrom         string
+	invoicePayment      string
+	invoiceDueDays      int
+	invoiceNumberFormat string
+	invoiceTemplatePath string
+	invoiceCurrency     string
+	exchangeRates       map[string]float64
+
+	timesheetEmployee   string
+	timesheetName       string
+	timesheetCostCenter string
+	timesheetActivity   string
+	timesheetColumns    []timesheetColumn
+	timesheetDateFormat string
+	timesheetDelimiter  rune
+
+	detailRetention retention
+
+	nowPlayingApps []string
+
+	sharingPrivacy bool
+
+	focusEvents bool
+
+	browserURLs bool
+
+	sqlitePath string // STORE=sqlite://..., "" for the journal alone
+
+	screenshotInterval   time.Duration
+	screenshotSize       int
+	screenshotPassphrase string
+
+	gapPrompt     time.Duration
+	gapCategories []string
+)
+
+// setting describes one configuration key. The same key is accepted in the
+// config file and as an environment variable; the environment wins.
+type setting struct {
+	key string
+	def string
+	set func(value string) error
+}
+
+var settings = []setting{
+	{"IDLE_TIME", "120", func(v string) (err error) {
+		// "auto" tunes the threshold from recorded pauses, starting at 120
+		if idleAuto = v == "auto"; idleAuto {
+			idleTreshold = 120
+			return nil
+		}
+		idleTreshold, err = parseIdleTreshold(v)
+		return
+	}},
+	{"IDLE_CALIBRATE", "false", func(v string) (err error) {
+		idleCalibrate, err = parseBool(v)
+		return
+	}},
+	{"STORE", "journal", func(v string) (err error) {
+		sqlitePath, err = parseStore(v)
+		return
+	}},
+	{"FOCUS_EVENTS", "true", func(v string) (err error) {
+		focusEvents, err = parseBool(v)
+		return
+	}},
+	{"BROWSER_URLS", "true", func(v string) (err error) {
+		browserURLs, err = parseBool(v)
+		return
+	}},
+	{"WORK_DAYS", "Mon,Tue,Wed,Thu,Fri", func(v string) (err error) {
+		workdaysSet, err = parseWorkdays(v)
+		return
+	}},
+	{"WORK_START", "08:00", func(v string) (err error) {
+		workStart, err = parseTimeOfDay(v)
+		return
+	}},
+	{"WORK_END", "17:00", func(v string) (err error) {
+		workEnd, err = parseTimeOfDay(v)
+		return
+	}},
+	{"WORK_HOURS", "", func(v string) (err error) {
+		if strings.TrimSpace(v) == "" {
+			workHours = nil
+			return nil
+		}
+		workHours, err = parseWorkWindows(v)
+		return
+	}},
+	{"WORK_SCHEDULE", "", func(v string) (err error) {
+		workSchedule, err = parseWorkSchedule(v)
+		return
+	}},
+	{"WORK_HOURS_ONLY", "false", func(v string) (err error) {
+		workHoursOnly, err = parseBool(v)
+		return
+	}},
+	{"QUIET_HOURS", "", func(v string) (err error) {
+		quietHours, err = parseWindows(v)
+		return
+	}},
+	{"OUTSIDE_BUCKETS", "", func(v string) (err error) {
+		outsideBuckets, err = parseOutsideBuckets(v)
+		return
+	}},
+	{"HOLIDAYS", "", func(v string) (err error) {
+		holidays, err = parseDates(v)
+		return
+	}},
+	{"VACATION", "", func(v string) (err error) {
+		vacationDays, err = parseDates(v)
+		return
+	}},
+	{"HOLIDAYS_ICS", "", func(v string) error {
+		holidaysICS = v
+		return nil
+	}},
+	{"LUNCH_WINDOW", "11:00-14:00", func(v string) error {
+		if v == "off" {
+			lunchWindow = nil
+			return nil
+		}
+		w, err := parseWindow(v)
+		lunchWindow = &w
+		return err
+	}},
+	{"LUNCH_MIN", "20m", func(v string) (err error) {
+		lunchMin, err = parsePositiveDuration(v)
+		return
+	}},
+	{"LUNCH_MAX", "90m", func(v string) (err error) {
+		lunchMax, err = parsePositiveDuration(v)
+		return
+	}},
+	{"BREAK_AFTER", "90m", func(v string) (err error) {
+		if v == "off" {
+			breakAfter = 0
+			return nil
+		}
+		breakAfter, err = parsePositiveDuration(v)
+		return
+	}},
+	{"BREAK_GAP", "5m", func(v string) (err error) {
+		breakGap, err = parsePositiveDuration(v)
+		return
+	}},
+	{"LIMITS", "", func(v string) (err error) {
+		usageLimits, err = parseLimits(v)
+		return
+	}},
+	{"CRASH_REPORT_URL", "", func(v string) error {
+		crashReportURL = v
+		return nil
+	}},
+	{"WATCHDOG_STALL", "5m", func(v string) (err error) {
+		if v == "off" {
+			watchdogStall = 0
+			return nil
+		}
+		watchdogStall, err = parsePositiveDuration(v)
+		return
+	}},
+	{"OUTBOX_MAX_ATTEMPTS", "10", func(v string) (err error) {
+		outboxMaxAttempts, err = strconv.Atoi(v)
+		if err == nil && outboxMaxAttempts < 1 {
+			err = fmt.Errorf("must be at least 1")
+		}
+		return
+	}},
+	{"SCHEDULE", "", func(v string) (err error) {
+		scheduledJobs, err = parseSchedule(v)
+		return
+	}},
+	{"METRICS_ADDR", "", func(v string) error {
+		metricsAddr = v
+		return nil
+	}},
+	{"CONTROL_SOCKET", "~/.worktimer.sock", func(v string) error {
+		controlSocket = ""
+		if v != "off" {
+			controlSocket = expandHome(v)
+		}
+		return nil
+	}},
+	{"DASHBOARD_ADDR", "", func(v string) error {
+		dashboardAddr = v
+		return nil
+	}},
+	{"CONTROL_ADDR", "", func(v string) error {
+		controlAddr = v
+		return nil
+	}},
+	{"HOTKEY_PAUSE", "cmd+ctrl+p", func(v string) (err error) {
+		hotkeyPause, err = parseHotkey(v)
+		return
+	}},
+	{"HOTKEY_TAG", "cmd+ctrl+t", func(v string) (err error) {
+		hotkeyTag, err = parseHotkey(v)
+		return
+	}},
+	{"HOTKEY_NOTE", "cmd+ctrl+n", func(v string) (err error) {
+		hotkeyNote, err = parseHotkey(v)
+		return
+	}},
+	{"HOTKEY_WIDGET", "cmd+ctrl+w", func(v string) (err error) {
+		hotkeyWidget, err = parseHotkey(v)
+		return
+	}},
+	{"RULES_FILE", "", func(v string) error {
+		rulesFile = v
+		return nil
+	}},
+	{"CALENDAR_ICS", "", func(v string) error {
+		calendarSource = v
+		return nil
+	}},
+	{"MEETING_APPS", "zoom.us,Microsoft Teams,Microsoft Teams (work or school),Webex,FaceTime", func(v string) error {
+		meetingApps = parseNameSet(v)
+		return nil
+	}},
+	{"LOG_PATH", "/var/logs", func(v string) error {
+		logs = v
+		return nil
+	}},
+	{"ASCII_OUTPUT", "false", func(v string) (err error) {
+		asciiOutput, err = parseBool(v)
+		return
+	}},
+	{"COLOR_THEME", "default", func(v string) (err error) {
+		themeName, err = parseColorTheme(v)
+		return
+	}},
+	{"OBSIDIAN_VAULT", "", func(v string) error {
+		obsidianVault = v
+		return nil
+	}},
+	{"OBSIDIAN_DAILY_NOTE", "YYYY-MM-DD.md", func(v string) error {
+		if !strings.Contains(v, "DD") {
+			return fmt.Errorf("daily note pattern %q has no DD day placeholder", v)
+		}
+		obsidianDailyNote = v
+		return nil
+	}},
+	{"NOTION_TOKEN", "", func(v string) error {
+		notionToken = v
+		return nil
+	}},
+	{"NOTION_DATABASE_ID", "", func(v string) error {
+		notionDatabase = strings.ReplaceAll(v, "-", "")
+		return nil
+	}},
+	{"INFLUX_URL", "", func(v string) error {
+		influxURL = v
+		return nil
+	}},
+	{"INFLUX_TOKEN", "", func(v string) error {
+		influxToken = v
+		return nil
+	}},
+	{"INFLUX_ORG", "", func(v string) error {
+		influxOrg = v
+		return nil
+	}},
+	{"INFLUX_BUCKET", "", func(v string) error {
+		influxBucket = v
+		return nil
+	}},
+	{"TOGGL_TOKEN", "", func(v string) error {
+		togglToken = v
+		return nil
+	}},
+	{"TOGGL_WORKSPACE_ID", "", func(v string) (err error) {
+		if v == "" {
+			togglWorkspace = 0
+			return nil
+		}
+		togglWorkspace, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || togglWorkspace <= 0 {
+			return fmt.Errorf("invalid workspace ID %q, expected a number", v)
+		}
+		return nil
+	}},
+	{"TOGGL_PROJECTS_ONLY", "false", func(v string) (err error) {
+		togglProjectsOnly, err = parseBool(v)
+		return
+	}},
+	{"TOGGL_MIN_SPAN", "1m", func(v string) (err error) {
+		togglMinSpan, err = parsePositiveDuration(v)
+		return
+	}},
+	{"CLOCKIFY_TOKEN", "", func(v string) error {
+		clockifyToken = v
+		return nil
+	}},
+	{"CLOCKIFY_WORKSPACE_ID", "", func(v string) error {
+		clockifyWorkspace = v
+		return nil
+	}},
+	{"CLOCKIFY_MAPPED_ONLY", "false", func(v string) (err error) {
+		clockifyMappedOnly, err = parseBool(v)
+		return
+	}},
+	{"CLOCKIFY_MIN_SPAN", "1m", func(v string) (err error) {
+		clockifyMinSpan, err = parsePositiveDuration(v)
+		return
+	}},
+	{"HARVEST_TOKEN", "", func(v string) error {
+		harvestToken = v
+		return nil
+	}},
+	{"HARVEST_ACCOUNT_ID", "", func(v string) error {
+		harvestAccount = v
+		return nil
+	}},
+	{"HARVEST_TASK", "", func(v string) error {
+		harvestDefaultTask = v
+		return nil
+	}},
+	{"HARVEST_ROUND", "", func(v string) (err error) {
+		harvestRound = 0
+		if v != "" {
+			harvestRound, err = parsePositiveDuration(v)
+		}
+		return
+	}},
+	{"HARVEST_ROUND_MODE", "nearest", func(v string) error {
+		harvestRoundMode = strings.ToLower(v)
+		if harvestRoundMode != "up" && harvestRoundMode != "nearest" && harvestRoundMode != "down" {
+			return fmt.Errorf("expected up, nearest or down")
+		}
+		return nil
+	}},
+	{"JIRA_PROJECTS", "", parseJiraProjects},
+	{"JIRA_URL", "", func(v string) error {
+		jiraURL = v
+		return nil
+	}},
+	{"JIRA_EMAIL", "", func(v string) error {
+		jiraEmail = v
+		return nil
+	}},
+	{"JIRA_TOKEN", "", func(v string) error {
+		jiraToken = v
+		return nil
+	}},
+	{"TEMPO_TOKEN", "", func(v string) error {
+		tempoToken = v
+		return nil
+	}},
+	{"JIRA_MIN_WORKLOG", "5m", func(v string) (err error) {
+		jiraMinWorklog, err = parsePositiveDuration(v)
+		return
+	}},
+	{"ACTIVITYWATCH_URL", "http://localhost:5600", func(v string) error {
+		activityWatchURL = v
+		return nil
+	}},
+	{"BIGQUERY_CREDENTIALS", "", func(v string) error {
+		bigqueryCredentials = v
+		return nil
+	}},
+	{"BIGQUERY_DATASET", "", func(v string) error {
+		bigqueryDataset = v
+		return nil
+	}},
+	{"BIGQUERY_TABLE", "focus_spans", func(v string) error {
+		bigqueryTable = v
+		return nil
+	}},
+	{"SNOWFLAKE_ACCOUNT", "", func(v string) error {
+		snowflakeAccount = strings.ToUpper(v)
+		return nil
+	}},
+	{"SNOWFLAKE_USER", "", func(v string) error {
+		snowflakeUser = strings.ToUpper(v)
+		return nil
+	}},
+	{"SNOWFLAKE_PRIVATE_KEY", "", func(v string) error {
+		snowflakeKey = v
+		return nil
+	}},
+	{"SNOWFLAKE_DATABASE", "", func(v string) error {
+		snowflakeDatabase = v
+		return nil
+	}},
+	{"SNOWFLAKE_SCHEMA", "PUBLIC", func(v string) error {
+		snowflakeSchema = v
+		return nil
+	}},
+	{"SNOWFLAKE_WAREHOUSE", "", func(v string) error {
+		snowflakeWarehouse = v
+		return nil
+	}},
+	{"SNOWFLAKE_ROLE", "", func(v string) error {
+		snowflakeRole = v
+		return nil
+	}},
+	{"SNOWFLAKE_TABLE", "FOCUS_SPANS", func(v string) error {
+		snowflakeTable = v
+		return nil
+	}},
+	{"INVOICE_FROM", "", func(v string) error {
+		invoiceFrom = v
+		return nil
+	}},
+	{"INVOICE_PAYMENT", "", func(v string) error {
+		invoicePayment = v
+		return nil
+	}},
+	{"INVOICE_DUE_DAYS", "30", func(v string) (err error) {
+		invoiceDueDays, err = strconv.Atoi(v)
+		if err == nil && invoiceDueDays < 0 {
+			err = fmt.Errorf("must not be negative")
+		}
+		return
+	}},
+	{"INVOICE_NUMBER_FORMAT", "{year}-{seq}", func(v string) error {
+		if !strings.Contains(v, "{seq}") {
+			return fmt.Errorf("must contain {seq}")
+		}
+		invoiceNumberFormat = v
+		return nil
+	}},
+	{"INVOICE_TEMPLATE", "", func(v string) error {
+		invoiceTemplatePath = v
+		return nil
+	}},
+	{"INVOICE_CURRENCY", "USD", func(v string) (err error) {
+		invoiceCurrency, err = parseCurrency(v)
+		return
+	}},
+	{"EXCHANGE_RATES", "", func(v string) (err error) {
+		exchangeRates, err = parseExchangeRates(v)
+		return
+	}},
+	{"RETENTION", "off", func(v string) (err error) {
+		detailRetention, err = parseRetention(v)
+		return
+	}},
+	{"GAP_PROMPT", "off", func(v string) (err error) {
+		if v == "off" {
+			gapPrompt = 0
+			return nil
+		}
+		gapPrompt, err = parsePositiveDuration(v)
+		return
+	}},
+	{"GAP_CATEGORIES", "Meeting,Commute,Off", func(v string) (err error) {
+		gapCategories, err = parseGapCategories(v)
+		return
+	}},
+	{"SCREEN_SHARE_PRIVACY", "true", func(v string) (err error) {
+		sharingPrivacy, err = parseBool(v)
+		return
+	}},
+	{"SCREENSHOTS", "off", func(v string) (err error) {
+		if v == "off" {
+			screenshotInterval = 0
+			return nil
+		}
+		screenshotInterval, err = parsePositiveDuration(v)
+		return
+	}},
+	{"SCREENSHOT_SIZE", "480", func(v string) (err error) {
+		screenshotSize, err = strconv.Atoi(v)
+		if err != nil || screenshotSize < 64 {
+			return fmt.Errorf("invalid screenshot size %q, expected pixels of at least 64", v)
+		}
+		return nil
+	}},
+	{"SCREENSHOT_KEY", "", func(v string) error {
+		screenshotPassphrase = v
+		return nil
+	}},
+	{"NOW_PLAYING", "off", func(v string) (err error) {
+		nowPlayingApps, err = parseNowPlaying(v)
+		return
+	}},
+	{"TIMESHEET_EMPLOYEE", "", func(v string) error {
+		timesheetEmployee = v
+		return nil
+	}},
+	{"TIMESHEET_NAME", "", func(v string) error {
+		timesheetName = v
+		return nil
+	}},
+	{"TIMESHEET_COST_CENTER", "", func(v string) error {
+		timesheetCostCenter = v
+		return nil
+	}},
+	{"TIMESHEET_ACTIVITY", "", func(v string) error {
+		timesheetActivity = v
+		return nil
+	}},
+	{"TIMESHEET_COLUMNS", "Date=date,Cost center=cost_center,Hours=hours,Projects=projects", func(v string) (err error) {
+		timesheetColumns, err = parseTimesheetColumns(v)
+		return
+	}},
+	{"TIMESHEET_DATE_FORMAT", "YYYY-MM-DD", func(v string) (err error) {
+		timesheetDateFormat, err = parseDateFormat(v)
+		return
+	}},
+	{"TIMESHEET_DELIMITER", ",", func(v string) error {
+		switch v {
+		case ",", ";":
+			timesheetDelimiter = rune(v[0])
+		case "tab", "\\t":
+			timesheetDelimiter = '\t'
+		default:
+			return fmt.Errorf("expected , or ; or tab")
+		}
+		return nil
+	}},
+}
+
+// configDir holds config.yaml and rules.conf.
+func configDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "worktimer")
+}
+
+// expandHome resolves a leading "~/" to the home directory.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// Default location of the config file, overridable with CONFIG_PATH.
+func defaultConfigPath() string {
+	if dir := configDir(); dir != "" {
+		return filepath.Join(dir, "config.yaml")
+	}
+	return ""
+}
+
+// loadConfig applies defaults, then the config file, then environment
+// variables, then command line flags (keyed by setting). Every problem found
+// is reported, not just the first one.
+func loadConfig(flags map[string]string) error {
+	var errs []error
+	configValues = make(map[string]configValue)
+	for _, s := range settings {
+		if err := applySetting(s, s.def, "default"); err != nil {
+			panic(fmt.Sprintf("bad default for %s: %v", s.key, err))
+		}
+	}
+
+	path, explicit := os.LookupEnv("CONFIG_PATH")
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	if path != "" {
+		errs = append(errs, readConfigFile(path, explicit)...)
+	}
+
+	for _, s := range settings {
+		v := os.Getenv(s.key)
+		if v == "" {
+			continue
+		}
+		if err := applySetting(s, v, "environment"); err != nil {
+			errs = append(errs, fmt.Errorf("environment %s: %v", s.key, err))
+		}
+	}
+
+	for _, s := range settings {
+		v, ok := flags[s.key]
+		if !ok {
+			continue
+		}
+		if err := applySetting(s, v, "flag"); err != nil {
+			errs = append(errs, fmt.Errorf("flag -%s: %v", flagName(s.key), err))
+		}
+	}
+
+	if lunchMin > lunchMax {
+		errs = append(errs, fmt.Errorf("LUNCH_MIN (%v) is longer than LUNCH_MAX (%v)", lunchMin, lunchMax))
+	}
+
+	if path, explicit := rulesPath(); path != "" {
+		errs = append(errs, loadRules(path, explicit)...)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("configuration error:\n  %w", joinErrors(errs))
+	}
+	return nil
+}
+
+// commandLineSettings are the settings given as flags, kept for reloads.
+var commandLineSettings map[string]string
+
+// restartSettings are only read when the tracker starts, by the servers
+// and background jobs it starts then.
+var restartSettings = []string{"METRICS_ADDR", "DASHBOARD_ADDR", "CONTROL_ADDR", "CONTROL_SOCKET",
+	"SCHEDULE", "WATCHDOG_STALL", "RETENTION", "NOW_PLAYING", "SCREEN_SHARE_PRIVACY", "SCREENSHOTS",
+	"GAP_PROMPT", "ASCII_OUTPUT", "COLOR_THEME"}
+
+// startupValues are the settings the tracker started with, set on the
+// first reload.
+var startupValues map[string]configValue
+
+// reloadConfig reads the config file, environment and rules file again,
+// for SIGHUP and "ctl reload", and names the changed settings that need a
+// restart. When anything is wrong the previous settings and rules stay in
+// effect, so a typo does not leave the tracker half configured.
+func reloadConfig() (needRestart []string, err error) {
+	previous, previousRules, previousLogs := configValues, currentRules(), logs
+	if startupValues == nil {
+		startupValues = configValues
+	}
+	err = loadConfig(commandLineSettings)
+	if err == nil && logs != previousLogs {
+		// The tracker moves its lock along before touching the new LOG_PATH
+		previousLock := trackerLock
+		if err = lockTracker(); err == nil {
+			err = migrateStore()
+		}
+		if trackerLock != previousLock {
+			if err != nil {
+				trackerLock.Close()
+				trackerLock = previousLock
+			} else if previousLock != nil {
+				previousLock.Close()
+			}
+		}
+	}
+	if err != nil {
+		configValues = make(map[string]configValue)
+		for _, s := range settings {
+			if v, ok := previous[s.key]; ok {
+				applySetting(s, v.value, v.source)
+			}
+		}
+		previousRules.restore()
+		return nil, err
+	}
+	for _, key := range restartSettings {
+		if configValues[key].value != startupValues[key].value {
+			needRestart = append(needRestart, key)
+		}
+	}
+	return needRestart, nil
+}
+
+// flagName is the command line flag for a setting: IDLE_TIME is -idle-time.
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// settingFlags defines a flag for every setting on fs, except secrets,
+// which would show up in the process list. After fs.Parse the returned
+// function gives the ones set on the command line, keyed by setting.
+func settingFlags(fs *flag.FlagSet) func() map[string]string {
+	values := make(map[string]*string)
+	keys := make(map[string]string)
+	for _, s := range settings {
+		if isSecretKey(s.key) {
+			continue
+		}
+		name := flagName(s.key)
+		values[name] = fs.String(name, "", fmt.Sprintf("%s, overriding the config file and environment (default %q)", s.key, s.def))
+		keys[name] = s.key
+	}
+	return func() map[string]string {
+		given := make(map[string]string)
+		fs.Visit(func(f *flag.Flag) {
+			if v, ok := values[f.Name]; ok {
+				given[keys[f.Name]] = *v
+			}
+		})
+		return given
+	}
+}
+
+// rulesPath is RULES_FILE, or rules.conf in the config directory; explicit
+// reports whether it was set, so a missing file is an error.
+func rulesPath() (path string, explicit bool) {
+	if rulesFile != "" {
+		return rulesFile, true
+	}
+	if configDir() != "" {
+		return filepath.Join(configDir(), "rules.conf"), false
+	}
+	return "", false
+}
+
+// configValue is the effective raw value of a setting and where it came from.
+type configValue struct {
+	value, source string
+}
+
+var configValues map[string]configValue
+
+func applySetting(s setting, value, source string) error {
+	if err := s.set(value); err != nil {
+		return err
+	}
+	configValues[s.key] = configValue{value, source}
+	return nil
+}
+
+// isSecretKey reports settings that must never be printed or uploaded:
+// those named like NOTION_TOKEN or SNOWFLAKE_PRIVATE_KEY, but not HOTKEY_*.
+func isSecretKey(key string) bool {
+	for _, word := range []string{"TOKEN", "SECRET", "PASSWORD", "KEY"} {
+		if key == word || strings.HasSuffix(key, "_"+word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedConfig lists the effective settings with secrets blanked out.
+func redactedConfig() []string {
+	var lines []string
+	for _, s := range settings {
+		v := configValues[s.key]
+		value := v.value
+		switch {
+		case isSecretKey(s.key) && value != "":
+			value = "(redacted)"
+		case strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "webcal://"):
+			// private calendar feeds and webhooks carry their secret in the path
+			if u, err := url.Parse(value); err == nil {
+				value = u.Scheme + "://" + u.Host + "/(redacted)"
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s (%s)", s.key, value, v.source))
+	}
+	return lines
+}
+
+func joinErrors(errs []error) error {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "\n  "))
+}
+
+// readConfigFile parses a flat YAML file of "KEY: value" lines.
+func readConfigFile(path string, mustExist bool) []error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && !mustExist {
+			return nil
+		}
+		return []error{err}
+	}
+	defer f.Close()
+
+	var errs []error
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" || line == "---" {
+			continue
+		}
+		where := fmt.Sprintf("%s:%d", path, lineNo)
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: expected \"KEY: value\", got %q", where, line))
+			continue
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = unquote(strings.TrimSpace(value))
+
+		s, found := lookupSetting(key)
+		if !found {
+			msg := fmt.Sprintf("%s: unknown key %q", where, key)
+			if guess := closestMatch(key, settingKeys()); guess != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", guess)
+			}
+			errs = append(errs, errors.New(msg))
+			continue
+		}
+		if prev, dup := seen[key]; dup {
+			errs = append(errs, fmt.Errorf("%s: %s already set on line %d", where, key, prev))
+			continue
+		}
+		seen[key] = lineNo
+		if value == "" {
+			continue
+		}
+		if err := applySetting(s, value, where); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", where, key, err))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", path, err))
+	}
+	return errs
+}
+
+// stripComment drops a trailing "# comment" that is not inside quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func settingKeys() []string {
+	keys := make([]string, 0, len(settings))
+	for _, s := range settings {
+		keys = append(keys, s.key)
+	}
+	return keys
+}
+
+// closestMatch suggests a known word for a likely typo such as WROK_START.
+func closestMatch(word string, candidates []string) string {
+	best, bestDist := "", 3
+	keys := append([]string(nil), candidates...)
+	sort.Strings(keys)
+	for _, k := range keys {
+		if d := editDistance(word, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Damerau-Levenshtein distance, so swapped letters count once.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := 0; j <= len(b); j++ {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func parseBool(input string) (bool, error) {
+	val, err := strconv.ParseBool(input)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean %q, expected true or false", input)
+	}
+	return val, nil
+}
+
+func parseWorkdays(input string) (map[time.Weekday]bool, error) {
+	result := make(map[time.Weekday]bool)
+	parts := strings.Split(input, ",")
+	for _, p := range parts {
+		day, err := parseWeekday(p)
+		if err != nil {
+			return nil, err
+		}
+		result[day] = true
+	}
+	return result, nil
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday,
+	"fri": time.Friday, "sat": time.Saturday, "sun": time.Sunday,
+}
+
+func parseWeekday(input string) (time.Weekday, error) {
+	day, ok := weekdayNames[strings.TrimSpace(strings.ToLower(input))]
+	if !ok {
+		return 0, fmt.Errorf("unknown weekday %q, expected Mon, Tue, Wed, Thu, Fri, Sat or Sun", strings.TrimSpace(input))
+	}
+	return day, nil
+}
+
+// parseWorkSchedule reads per-weekday work windows, e.g.
+// "Mon-Thu 09:00-18:00; Fri 08:00-16:00; Sat 10:00-12:00,14:00-16:00".
+// Days may be a range or a comma separated list; days not listed are off.
+// An empty schedule returns nil.
+func parseWorkSchedule(input string) (map[time.Weekday][]window, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+	result := make(map[time.Weekday][]window)
+	for _, entry := range strings.Split(input, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		daysPart, windowsPart, ok := strings.Cut(entry, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule entry %q, expected days and windows such as \"Mon-Fri 09:00-17:00\"", entry)
+		}
+		windows, err := parseWorkWindows(windowsPart)
+		if err != nil {
+			return nil, err
+		}
+		var days []time.Weekday
+		for _, part := range strings.Split(daysPart, ",") {
+			from, to, isRange := strings.Cut(part, "-")
+			first, err := parseWeekday(from)
+			if err != nil {
+				return nil, err
+			}
+			last := first
+			if isRange {
+				if last, err = parseWeekday(to); err != nil {
+					return nil, err
+				}
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				days = append(days, d)
+				if d == last {
+					break
+				}
+			}
+		}
+		for _, d := range days {
+			if _, ok := result[d]; ok {
+				return nil, fmt.Errorf("%s is listed twice in the schedule", d)
+			}
+			result[d] = windows
+		}
+	}
+	return result, nil
+}
+
+func parseTimeOfDay(input string) (TimeOfDay, error) {
+	t, err := time.Parse("15:04", input)
+	if err != nil {
+		return TimeOfDay{}, fmt.Errorf("invalid time %q, expected HH:MM", input)
+	}
+	return TimeOfDay{t.Hour(), t.Minute()}, nil
+}
+
+// window is a daily time range; end before start means it runs past midnight.
+type window struct {
+	start TimeOfDay
+	end   TimeOfDay
+}
+
+// parseWindow reads "HH:MM-HH:MM".
+func parseWindow(input string) (window, error) {
+	from, to, ok := strings.Cut(input, "-")
+	if !ok {
+		return window{}, fmt.Errorf("invalid time window %q, expected HH:MM-HH:MM", input)
+	}
+	start, err := parseTimeOfDay(strings.TrimSpace(from))
+	if err != nil {
+		return window{}, err
+	}
+	end, err := parseTimeOfDay(strings.TrimSpace(to))
+	if err != nil {
+		return window{}, err
+	}
+	return window{start, end}, nil
+}
+
+// parseWindows reads a comma separated list of "HH:MM-HH:MM" windows.
+func parseWindows(input string) ([]window, error) {
+	var result []window
+	for _, part := range strings.Split(input, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		w, err := parseWindow(part)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, w)
+	}
+	return result, nil
+}
+
+// parseWorkWindows reads one day's work windows, "08:00-12:00, 17:00-21:00",
+// sorted by start. Windows of the same day may not overlap, including a
+// last window that runs past midnight into the first.
+func parseWorkWindows(input string) ([]window, error) {
+	windows, err := parseWindows(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no work window in %q, expected HH:MM-HH:MM", input)
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].start.minutes() < windows[j].start.minutes() })
+	for i := 1; i < len(windows); i++ {
+		prev := windows[i-1]
+		end := prev.end.minutes()
+		if crossesMidnight(prev.start, prev.end) {
+			end += 24 * 60
+		}
+		if windows[i].start.minutes() < end {
+			return nil, fmt.Errorf("work windows %s and %s overlap", prev, windows[i])
+		}
+	}
+	// The last window may run past midnight into the first one
+	if last := windows[len(windows)-1]; len(windows) > 1 && crossesMidnight(last.start, last.end) && last.end.minutes() > windows[0].start.minutes() {
+		return nil, fmt.Errorf("work windows %s and %s overlap", last, windows[0])
+	}
+	return windows, nil
+}
+
+func (w window) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	start := w.start.Hour*60 + w.start.Minute
+	end := w.end.Hour*60 + w.end.Minute
+	if start <= end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
+
+func (w window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.start.Hour, w.start.Minute, w.end.Hour, w.end.Minute)
+}
+
+// parseHotkey reads "cmd+shift+p" into SwiftBar's "CMD+SHIFT+P"; "off"
+// disables the hotkey.
+func parseHotkey(input string) (string, error) {
+	if input == "off" || input == "" {
+		return "", nil
+	}
+	parts := strings.Split(strings.ToLower(input), "+")
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if i == len(parts)-1 {
+			if len([]rune(p)) != 1 && !(len(p) >= 2 && p[0] == 'f' && strings.Trim(p[1:], "0123456789") == "") {
+				return "", fmt.Errorf("invalid key %q, expected a single character or F1-F20", p)
+			}
+			parts[i] = strings.ToUpper(p)
+			continue
+		}
+		switch p {
+		case "cmd", "command":
+			parts[i] = "CMD"
+		case "ctrl", "control":
+			parts[i] = "CTRL"
+		case "opt", "option", "alt":
+			parts[i] = "OPTION"
+		case "shift":
+			parts[i] = "SHIFT"
+		default:
+			return "", fmt.Errorf("invalid modifier %q, expected cmd, ctrl, opt or shift", p)
+		}
+	}
+	if len(parts) < 2 {
+		return "", fmt.Errorf("hotkey %q needs at least one modifier, e.g. cmd+ctrl+p", input)
+	}
+	return strings.Join(parts, "+"), nil
+}
+
+// parsePositiveDuration reads Go durations such as "20m" or "1h30m".
+func parsePositiveDuration(input string) (time.Duration, error) {
+	d, err := time.ParseDuration(input)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q, expected e.g. 20m or 1h30m", input)
+	}
+	return d, nil
+}
+
+// parseNameSet reads a comma separated list of names, compared case-insensitively.
+func parseNameSet(input string) map[string]bool {
+	result := make(map[string]bool)
+	for _, part := range strings.Split(input, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result[strings.ToLower(part)] = true
+		}
+	}
+	return result
+}
+
+// parseDates reads comma separated YYYY-MM-DD dates and inclusive
+// YYYY-MM-DD..YYYY-MM-DD ranges.
+func parseDates(input string) (map[string]bool, error) {
+	result := make(map[string]bool)
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "..")
+		first, err := time.Parse("2006-01-02", strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", strings.TrimSpace(from))
+		}
+		last := first
+		if isRange {
+			if last, err = time.Parse("2006-01-02", strings.TrimSpace(to)); err != nil {
+				return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", strings.TrimSpace(to))
+			}
+			if last.Before(first) || last.Sub(first) > 366*24*time.Hour {
+				return nil, fmt.Errorf("invalid range %q, expected an end after the start and at most a year long", part)
+			}
+		}
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			result[d.Format("2006-01-02")] = true
+		}
+	}
+	return result, nil
+}
+
+func parseIdleTreshold(input string) (int, error) {
+	val, err := strconv.Atoi(input)
+	if err != nil || val <= 0 {
+		return 0, fmt.Errorf("invalid number of seconds %q, expected a positive integer", input)
+	}
+	return val, nil
+}
+
+// runConfig handles "config show": the effective settings, where each came
+// from, secrets redacted.
+func runConfig(args []string) error {
+	if len(args) != 1 || args[0] != "show" {
+		return errors.New("usage: config show")
+	}
+	path, explicit := os.LookupEnv("CONFIG_PATH")
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	fmt.Printf("# config file: %s\n", path)
+	if rules, _ := rulesPath(); rules != "" {
+		fmt.Printf("# rules file: %s\n", rules)
+	}
+	for _, line := range redactedConfig() {
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// workContext is an independent way of slicing the same span stream, e.g.
+// an employer, a side project and personal time. Unlike projects, a span can
+// belong to several contexts at once; each gets its own summary log.
+type workContext struct {
+	name     string
+	app      *regexp.Regexp
+	title    *regexp.Regexp
+	projects map[string]bool
+	hours    []window
+	days     map[time.Weekday]bool
+}
+
+var contexts []workContext
+
+// matches reports whether the span started inside the context's schedule and
+// matches every pattern set on it.
+func (c workContext) matches(s Span) bool {
+	if c.days != nil && !c.days[s.Start.Weekday()] {
+		return false
+	}
+	if len(c.hours) > 0 {
+		inside := false
+		for _, w := range c.hours {
+			inside = inside || w.contains(s.Start)
+		}
+		if !inside {
+			return false
+		}
+	}
+	if c.app != nil && !c.app.MatchString(s.App) {
+		return false
+	}
+	if c.title != nil && !c.title.MatchString(s.Title) {
+		return false
+	}
+	if c.projects != nil && !c.projects[strings.ToLower(s.Project)] {
+		return false
+	}
+	return true
+}
+
+// contextsFor lists every context the span belongs to, in file order.
+func contextsFor(s Span) []string {
+	var names []string
+	for _, c := range contexts {
+		if c.matches(s) {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+func hasContext(name string) bool {
+	for _, c := range contexts {
+		if strings.EqualFold(c.name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// contextSlug turns a context name into the part used in file names.
+func contextSlug(name string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(name)), "-")
+}
+
+func contextSuffix(name string) string {
+	return "_context_" + contextSlug(name)
+}
+
+// contextName maps a summary suffix back to the context's name.
+func contextName(suffix string) string {
+	slug := strings.TrimPrefix(suffix, "_context_")
+	for _, c := range contexts {
+		if contextSlug(c.name) == slug {
+			return c.name
+		}
+	}
+	return slug
+}
+
+func parseContext(path string, sec ruleSection) (workContext, []error) {
+	c := workContext{name: sec.name}
+	var errs []error
+	if contextSlug(sec.name) == "" {
+		errs = append(errs, fmt.Errorf("%s:%d: context name %q needs a letter or digit", path, sec.line, sec.name))
+	}
+	for _, k := range sec.keys {
+		where := fmt.Sprintf("%s:%d", path, k.line)
+		var err error
+		switch k.key {
+		case "app":
+			c.app, err = regexp.Compile(k.value)
+		case "title":
+			c.title, err = regexp.Compile(k.value)
+		case "projects":
+			c.projects = parseNameSet(k.value)
+		case "hours":
+			c.hours, err = parseWindows(k.value)
+		case "days":
+			c.days, err = parseWorkdays(k.value)
+		default:
+			msg := fmt.Sprintf("%s: unknown context key %q", where, k.key)
+			if guess := closestMatch(k.key, []string{"app", "title", "projects", "hours", "days"}); guess != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", guess)
+			}
+			errs = append(errs, errors.New(msg))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", where, k.key, err))
+		}
+	}
+	return c, errs
+}
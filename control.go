@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Pausing and the manual project are shared with the running tracker through
+// files in LOG_PATH, like the pomodoro state.
+
+func pausePath() string {
+	return filepath.Join(logs, "paused")
+}
+
+func manualProjectPath() string {
+	return filepath.Join(logs, "project")
+}
+
+// pausedByUser reports whether "pause" was run and not yet resumed.
+func pausedByUser() bool {
+	_, err := os.Stat(pausePath())
+	return err == nil
+}
+
+func setPaused(paused bool) error {
+	if paused {
+		return os.WriteFile(pausePath(), nil, 0644)
+	}
+	if err := os.Remove(pausePath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func runPause(args []string) error {
+	if err := setPaused(true); err != nil {
+		return err
+	}
+	fmt.Printf("%s Tracking paused until \"resume\".\n", glyphs.ok)
+	return nil
+}
+
+func runResume(args []string) error {
+	if err := setPaused(false); err != nil {
+		return err
+	}
+	fmt.Printf("%s Tracking resumed.\n", glyphs.ok)
+	return nil
+}
+
+// setManualProject records name as the manual project, spelled as in the
+// rules file when it is defined there; "" clears it. It reports whether the
+// project is defined.
+func setManualProject(name string) (string, bool, error) {
+	if name == "" {
+		if err := os.Remove(manualProjectPath()); err != nil && !os.IsNotExist(err) {
+			return "", false, err
+		}
+		return "", true, nil
+	}
+	known := false
+	for _, p := range projects {
+		if strings.EqualFold(p.name, name) {
+			name, known = p.name, true
+		}
+	}
+	return name, known, os.WriteFile(manualProjectPath(), []byte(name+"\n"), 0644)
+}
+
+// manualProject is the project set with "project set", which overrides the
+// rules for everything recorded until it is cleared.
+func manualProject() string {
+	data, err := os.ReadFile(manualProjectPath())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// runProject handles "project set NAME", "project clear", "project list"
+// and "project status", and the edits of [project] sections.
+func runProject(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: project set NAME | clear | list | status | add NAME [flags] | edit NAME [flags] | rename OLD NEW | archive NAME | unarchive NAME")
+	}
+	switch args[0] {
+	case "set":
+		name := strings.TrimSpace(strings.Join(args[1:], " "))
+		if name == "" {
+			return errors.New("usage: project set NAME")
+		}
+		name, known, err := setManualProject(name)
+		if err != nil {
+			return err
+		}
+		if !known {
+			fmt.Printf("%s %q is not defined in the rules file; using it anyway.\n", glyphs.warn, name)
+		}
+		fmt.Printf("%s Recording time on %s until \"project clear\".\n", glyphs.ok, name)
+	case "clear":
+		if _, _, err := setManualProject(""); err != nil {
+			return err
+		}
+		fmt.Printf("%s Projects are matched by the rules again.\n", glyphs.ok)
+	case "list":
+		for _, p := range projects {
+			line := p.name
+			if p.client != "" {
+				line += "  client: " + p.client
+				if c, ok := lookupClient(p.client); ok && p.hourlyRate(c) > 0 {
+					line += fmt.Sprintf(", %s %s/h", formatMoney(p.hourlyRate(c)), c.currencyCode())
+				}
+				if !p.billable() {
+					line += ", not billable"
+				}
+			}
+			if p.archived {
+				line += "  " + paintOver("archived")
+			}
+			fmt.Println(line)
+		}
+	case "status":
+		if name := manualProject(); name != "" {
+			fmt.Printf("Project set manually: %s\n", name)
+		} else {
+			fmt.Println("Projects are matched by the rules.")
+		}
+	case "add", "edit", "rename", "archive", "unarchive":
+		return editSection("project", projectFlags, args)
+	default:
+		return fmt.Errorf("unknown project action %q", args[0])
+	}
+	return nil
+}
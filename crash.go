@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recentEvents is a small ring buffer of what the tracker did last, for
+// crash reports. It holds app names and durations but never window titles.
+var recentEvents struct {
+	sync.Mutex
+	lines []string
+	next  int
+}
+
+const maxRecentEvents = 50
+
+func noteEvent(format string, args ...any) {
+	line := time.Now().Format("15:04:05 ") + fmt.Sprintf(format, args...)
+	recentEvents.Lock()
+	defer recentEvents.Unlock()
+	if len(recentEvents.lines) < maxRecentEvents {
+		recentEvents.lines = append(recentEvents.lines, line)
+		return
+	}
+	recentEvents.lines[recentEvents.next] = line
+	recentEvents.next = (recentEvents.next + 1) % maxRecentEvents
+}
+
+// recentEventLines returns the buffered events, oldest first.
+func recentEventLines() []string {
+	recentEvents.Lock()
+	defer recentEvents.Unlock()
+	return append(append([]string(nil), recentEvents.lines[recentEvents.next:]...), recentEvents.lines[:recentEvents.next]...)
+}
+
+// writeCrashReport saves a recovered panic to LOG_PATH/crash and, if
+// CRASH_REPORT_URL is set, uploads it.
+func writeCrashReport(r any) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Focus Tracker crash report\n")
+	fmt.Fprintf(&b, "Time: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Panic: %v\n", r)
+	fmt.Fprintf(&b, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "\nConfig:\n  %s\n", strings.Join(redactedConfig(), "\n  "))
+	fmt.Fprintf(&b, "\nRecent events:\n  %s\n", strings.Join(recentEventLines(), "\n  "))
+	fmt.Fprintf(&b, "\nStack:\n%s", debug.Stack())
+
+	dir := filepath.Join(logs, "crash")
+	path := filepath.Join(dir, "crash_"+time.Now().Format("2006-01-02_15-04-05")+".txt")
+	err := os.MkdirAll(dir, 0755)
+	if err == nil {
+		err = os.WriteFile(path, b.Bytes(), 0644)
+	}
+	if err != nil {
+		fmt.Printf("%s Recovered from a crash (%v), could not write report: %v\n", glyphs.warn, r, err)
+		os.Stdout.Write(b.Bytes())
+	} else {
+		fmt.Printf("%s Recovered from a crash (%v), report written to %s\n", glyphs.warn, r, path)
+	}
+
+	if crashReportURL != "" {
+		go uploadCrashReport(b.Bytes())
+	}
+}
+
+// uploadCrashReport sends the report, queueing it in the outbox when the
+// endpoint cannot be reached.
+func uploadCrashReport(report []byte) {
+	err := postCrashReport(report)
+	if err == nil {
+		return
+	}
+	if retryable(err) && enqueue(outboxCrash, string(report), err) == nil {
+		fmt.Printf("%s Could not upload crash report, will retry: %v\n", glyphs.warn, err)
+		return
+	}
+	fmt.Printf("%s Could not upload crash report: %v\n", glyphs.warn, err)
+}
+
+func postCrashReport(report []byte) error {
+	if crashReportURL == "" {
+		return errors.New("CRASH_REPORT_URL is not set")
+	}
+	resp, err := httpClient.Post(crashReportURL, "text/plain; charset=utf-8", bytes.NewReader(report))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return newStatusError(resp.StatusCode, "crash report upload: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// spanCSVHeader is the first line of the span-level CSV export.
+var spanCSVHeader = []string{"start", "end", "app", "title", "category", "work", "project", "seconds"}
+
+// writeCSVExport writes the spans of the days [from, to] as CSV, one row
+// per span, or with summary the hours per day and app with a column per
+// app, largest first, and a total. Away time is left out of the summary.
+func writeCSVExport(w io.Writer, from, to time.Time, summary bool) error {
+	var rows [][]string
+	perDay := make(map[string]map[string]time.Duration)
+	apps := make(map[string]time.Duration)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		spans, err := readSpans(day)
+		if err != nil {
+			return err
+		}
+		key := day.Format("2006-01-02")
+		perDay[key] = make(map[string]time.Duration)
+		for _, s := range withContexts(spans) {
+			rows = append(rows, []string{
+				s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339), s.App, s.Title, s.Category,
+				strconv.FormatBool(s.Work), s.Project, strconv.Itoa(int(s.Duration().Seconds())),
+			})
+			if !s.Away() {
+				perDay[key][s.App] += s.Duration()
+				apps[s.App] += s.Duration()
+			}
+		}
+	}
+	if !summary {
+		return writeCSV(w, spanCSVHeader, rows)
+	}
+
+	columns := sortedByDuration(apps)
+	header := append([]string{"date"}, columns...)
+	header = append(header, "total")
+	rows = nil
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		row := []string{key}
+		var total time.Duration
+		for _, app := range columns {
+			row = append(row, csvHours(perDay[key][app]))
+			total += perDay[key][app]
+		}
+		rows = append(rows, append(row, csvHours(total)))
+	}
+	return writeCSV(w, header, rows)
+}
+
+// csvHours is a duration as decimal hours, which spreadsheets can sum.
+func csvHours(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours(), 'f', 2, 64)
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	return cw.Error()
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The running tracker listens on CONTROL_SOCKET, a Unix domain socket only
+// its user can open, for one-line commands from "focus-tracker ctl". Each
+// connection carries one command and its reply; a reply starting with
+// "error: " is a failure.
+
+// loopTasks run on the tracking loop between two ticks, where the totals
+// may be touched. Sending one wakes the loop, so the next tick follows
+// right away.
+var loopTasks = make(chan func(buckets map[string]Totals))
+
+// runInLoop runs task on the tracking loop and waits for it.
+func runInLoop(task func(buckets map[string]Totals)) error {
+	done := make(chan struct{})
+	select {
+	case loopTasks <- func(b map[string]Totals) { task(b); close(done) }:
+	case <-time.After(30 * time.Second):
+		return errors.New("the tracking loop did not answer within 30s")
+	}
+	<-done
+	return nil
+}
+
+// controlListener is the socket while this tracker serves it.
+var controlListener struct {
+	sync.Mutex
+	l    net.Listener
+	path string
+}
+
+// closeControlSocket stops listening and removes the socket file, if this
+// tracker created it.
+func closeControlSocket() {
+	controlListener.Lock()
+	defer controlListener.Unlock()
+	if controlListener.l == nil {
+		return
+	}
+	// Closing a Unix listener unlinks its socket already; removing it here
+	// as well does not depend on that
+	controlListener.l.Close()
+	os.Remove(controlListener.path)
+	controlListener.l = nil
+}
+
+// serveControlSocket accepts control commands until the tracker exits. A
+// leftover socket from a tracker that died is replaced; one that answers
+// belongs to another tracker and is left alone.
+func serveControlSocket(path string) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		fmt.Printf("%s Another tracker is listening on %s; control commands go to it.\n", glyphs.warn, path)
+		return
+	}
+	os.Remove(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		fmt.Printf("%s Could not open control socket %s: %v\n", glyphs.warn, path, err)
+		return
+	}
+	os.Chmod(path, 0600)
+	controlListener.Lock()
+	controlListener.l, controlListener.path = l, path
+	controlListener.Unlock()
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			fmt.Printf("%s Control socket %s stopped: %v\n", glyphs.warn, path, err)
+			return
+		}
+		go handleControlConn(conn)
+	}
+}
+
+func handleControlConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	reply, err := controlCommand(strings.Fields(line))
+	if err != nil {
+		reply = "error: " + err.Error()
+	}
+	fmt.Fprintln(conn, reply)
+}
+
+// controlCommand carries out one command from the socket.
+func controlCommand(fields []string) (string, error) {
+	if len(fields) == 0 {
+		return "", errors.New("empty command")
+	}
+	args := fields[1:]
+	switch fields[0] {
+	case "pause", "resume":
+		if err := setPaused(fields[0] == "pause"); err != nil {
+			return "", err
+		}
+		if err := runInLoop(func(map[string]Totals) {}); err != nil {
+			return "", err
+		}
+		if fields[0] == "pause" {
+			return "Tracking paused.", nil
+		}
+		return "Tracking resumed.", nil
+	case "flush":
+		if err := runInLoop(saveAll); err != nil {
+			return "", err
+		}
+		return "Summaries saved.", nil
+	case "annotate":
+		text := strings.Join(args, " ")
+		if text == "" {
+			return "", errors.New("usage: annotate TEXT")
+		}
+		now := time.Now()
+		if err := appendAnnotation(annotation{Start: now, End: now, Note: text}); err != nil {
+			return "", err
+		}
+		return "Note saved at " + now.Format("15:04") + ".", nil
+	case "status":
+		return controlStatus(time.Now())
+	case "reload":
+		return reloadInLoop()
+	}
+	return "", fmt.Errorf("unknown command %q (pause, resume, flush, annotate TEXT, status, reload)", fields[0])
+}
+
+// reloadInLoop reloads the configuration between two ticks, so the loop
+// never sees it half applied.
+func reloadInLoop() (string, error) {
+	var needRestart []string
+	var err error
+	if lerr := runInLoop(func(map[string]Totals) { needRestart, err = reloadConfig() }); lerr != nil {
+		return "", lerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(needRestart) > 0 {
+		return fmt.Sprintf("Configuration reloaded; restart the tracker to apply %s.", strings.Join(needRestart, ", ")), nil
+	}
+	return "Configuration reloaded.", nil
+}
+
+// controlStatus is the current focus and today's totals.
+func controlStatus(now time.Time) (string, error) {
+	today, _ := parseDay("")
+	sum, err := summarizeDays(today, today, now)
+	if err != nil {
+		return "", err
+	}
+	current := "Paused"
+	if pausedByUser() {
+		current = "Paused until resumed"
+	}
+	if c := currentFocus(now); c.Tracking {
+		bucket := "outside hours"
+		if c.Work {
+			bucket = "work hours"
+		}
+		current = fmt.Sprintf("%s for %s (%s)", c.App, shortDuration(time.Duration(c.ElapsedSeconds)*time.Second), bucket)
+		if c.Project != "" {
+			current += ", project " + c.Project
+		}
+	}
+	return fmt.Sprintf("%s\nToday: %s in work hours, %s outside", current,
+		shortDuration(time.Duration(sum.WorkSeconds)*time.Second), shortDuration(time.Duration(sum.OutsideSeconds)*time.Second)), nil
+}
+
+// runCtl sends a command to the running tracker's control socket.
+func runCtl(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: ctl pause | resume | flush | annotate TEXT | status | reload")
+	}
+	if controlSocket == "" {
+		return errors.New("CONTROL_SOCKET is off")
+	}
+	conn, err := net.DialTimeout("unix", controlSocket, 2*time.Second)
+	if err != nil {
+		return fmt.Errorf("no tracker is listening on %s: %v", controlSocket, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	if _, err := fmt.Fprintln(conn, strings.Join(args, " ")); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		return err
+	}
+	reply := strings.TrimRight(string(data), "\n")
+	if msg, failed := strings.CutPrefix(reply, "error: "); failed {
+		return errors.New(msg)
+	}
+	fmt.Println(reply)
+	return nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"net/http"
+	"time"
+)
+
+// dashboardDays is how many days up to the shown one the work vs outside
+// bars cover.
+const dashboardDays = 14
+
+// dashboardPage is one day of the web dashboard.
+type dashboardPage struct {
+	Date          time.Time
+	Today         bool
+	Work, Outside time.Duration
+	Apps          []siteGroup
+	Timeline      template.HTML
+	Pie           template.HTML
+	Bars          template.HTML
+	Prev, Next    string
+}
+
+// serveDashboard runs the web dashboard on DASHBOARD_ADDR. Pages are
+// rendered on the server with inline SVG charts, so it needs no files or
+// scripts besides the binary. Like the control endpoint it has no
+// authentication, is meant to be bound to localhost and only answers
+// requests for a loopback host.
+func serveDashboard(addr string) {
+	mux := http.NewServeMux()
+	dashboardRoutes(mux)
+	if err := http.ListenAndServe(addr, localOnly(mux)); err != nil {
+		fmt.Printf("%s Dashboard on %s stopped: %v\n", glyphs.warn, addr, err)
+	}
+}
+
+// dashboardRoutes registers GET /?day=YYYY-MM-DD, today by default.
+func dashboardRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		day, err := parseDay(r.URL.Query().Get("day"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		page, err := buildDashboard(day, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var b bytes.Buffer
+		if err := dashboardTemplate.Execute(&b, page); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(b.Bytes())
+	})
+}
+
+// liveSpans is a day's journal plus, for today, the span the tracker is
+// timing right now.
+func liveSpans(day, now time.Time) ([]Span, error) {
+	spans, err := readSpans(day)
+	if err != nil {
+		return nil, err
+	}
+	spans = withContexts(spans)
+	if today, _ := parseDay(""); day.Equal(today) {
+		if s, ok := currentSpan(now); ok && !s.Away() {
+			spans = append(spans, s)
+		}
+	}
+	return spans, nil
+}
+
+func buildDashboard(day, now time.Time) (dashboardPage, error) {
+	today, _ := parseDay("")
+	page := dashboardPage{Date: day, Today: day.Equal(today)}
+	page.Prev = day.AddDate(0, 0, -1).Format("2006-01-02")
+	if day.Before(today) {
+		page.Next = day.AddDate(0, 0, 1).Format("2006-01-02")
+	}
+	spans, err := liveSpans(day, now)
+	if err != nil {
+		return page, err
+	}
+	apps := make(map[string]time.Duration)
+	for _, s := range spans {
+		switch {
+		case s.Away():
+			continue
+		case s.Work:
+			page.Work += s.Duration()
+		default:
+			page.Outside += s.Duration()
+		}
+		apps[s.App] += s.Duration()
+	}
+	for _, app := range sortedByDuration(apps) {
+		page.Apps = append(page.Apps, siteGroup{app, apps[app]})
+	}
+
+	var b bytes.Buffer
+	writeTimelineSVG(&b, day, spans, nil)
+	page.Timeline = template.HTML(b.String())
+	b.Reset()
+	writePieSVG(&b, page.Apps)
+	page.Pie = template.HTML(b.String())
+
+	var days []dayTotals
+	for d := day.AddDate(0, 0, 1-dashboardDays); !d.After(day); d = d.AddDate(0, 0, 1) {
+		t := dayTotals{day: d}
+		if d.Equal(day) {
+			t.work, t.outside = page.Work, page.Outside
+		} else {
+			spans, err := liveSpans(d, now)
+			if err != nil {
+				return page, err
+			}
+			for _, s := range spans {
+				switch {
+				case s.Away():
+				case s.Work:
+					t.work += s.Duration()
+				default:
+					t.outside += s.Duration()
+				}
+			}
+		}
+		days = append(days, t)
+	}
+	b.Reset()
+	writeWorkBarsSVG(&b, days)
+	page.Bars = template.HTML(b.String())
+	return page, nil
+}
+
+// writePieSVG draws time per app as a pie with a legend; apps past the
+// palette share one "Other apps" slice.
+func writePieSVG(w io.Writer, apps []siteGroup) {
+	const (
+		size = 220
+		r    = 100
+		rowH = 20
+	)
+	slices := apps
+	if len(apps) > len(timelinePalette) {
+		slices = append([]siteGroup(nil), apps[:len(timelinePalette)-1]...)
+		var rest time.Duration
+		for _, g := range apps[len(timelinePalette)-1:] {
+			rest += g.Total
+		}
+		slices = append(slices, siteGroup{"Other apps", rest})
+	}
+	var total time.Duration
+	for _, g := range slices {
+		total += g.Total
+	}
+	height := size
+	if h := len(slices)*rowH + 20; h > height {
+		height = h
+	}
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="520" height="%d" viewBox="0 0 520 %d" font-family="-apple-system, Helvetica, Arial, sans-serif" font-size="12">`+"\n", height, height)
+	if total <= 0 {
+		fmt.Fprintf(w, `<text x="10" y="20" fill="#555555">Nothing tracked.</text></svg>`+"\n")
+		return
+	}
+	cx, cy := float64(size/2), float64(size/2)
+	point := func(frac float64) (float64, float64) {
+		a := 2*math.Pi*frac - math.Pi/2
+		return cx + r*math.Cos(a), cy + r*math.Sin(a)
+	}
+	var at float64
+	for i, g := range slices {
+		frac := float64(g.Total) / float64(total)
+		title := fmt.Sprintf("<title>%s %s (%.0f%%)</title>", svgEscape(g.Name), shortDuration(g.Total), frac*100)
+		if frac >= 0.9999 {
+			fmt.Fprintf(w, `<circle cx="%.1f" cy="%.1f" r="%d" fill="%s">%s</circle>`+"\n", cx, cy, r, timelinePalette[i], title)
+		} else {
+			x0, y0 := point(at)
+			x1, y1 := point(at + frac)
+			large := 0
+			if frac > 0.5 {
+				large = 1
+			}
+			fmt.Fprintf(w, `<path d="M%.1f,%.1f L%.1f,%.1f A%d,%d 0 %d 1 %.1f,%.1f Z" fill="%s" stroke="#ffffff">%s</path>`+"\n",
+				cx, cy, x0, y0, r, r, large, x1, y1, timelinePalette[i], title)
+		}
+		at += frac
+		y := 10 + i*rowH
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`+"\n", size+20, y, timelinePalette[i])
+		fmt.Fprintf(w, `<text x="%d" y="%d">%s  %s</text>`+"\n", size+38, y+11, svgEscape(g.Name), shortDuration(g.Total))
+	}
+	fmt.Fprintln(w, "</svg>")
+}
+
+// dayTotals is one bar of the work vs outside chart.
+type dayTotals struct {
+	day           time.Time
+	work, outside time.Duration
+}
+
+// writeWorkBarsSVG draws a stacked bar per day, work hours at the bottom
+// and outside hours on top; each bar links to its day.
+func writeWorkBarsSVG(w io.Writer, days []dayTotals) {
+	const (
+		width  = 960
+		height = 200
+		margin = 20
+		chartH = 140
+	)
+	var max time.Duration
+	for _, d := range days {
+		if d.work+d.outside > max {
+			max = d.work + d.outside
+		}
+	}
+	if max < time.Hour {
+		max = time.Hour
+	}
+	slot := float64(width-2*margin) / float64(len(days))
+	barW := slot * 0.6
+	scale := chartH / max.Seconds()
+	bottom := float64(margin + chartH)
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="-apple-system, Helvetica, Arial, sans-serif" font-size="11">`+"\n",
+		width, height, width, height)
+	for i, d := range days {
+		x := margin + float64(i)*slot + (slot-barW)/2
+		workH, outH := d.work.Seconds()*scale, d.outside.Seconds()*scale
+		fmt.Fprintf(w, `<a href="/?day=%s">`, d.day.Format("2006-01-02"))
+		fmt.Fprintf(w, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s work hours</title></rect>`,
+			x, bottom-workH, barW, workH, timelinePalette[0], shortDuration(d.work))
+		fmt.Fprintf(w, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s outside hours</title></rect>`,
+			x, bottom-workH-outH, barW, outH, timelinePalette[1], shortDuration(d.outside))
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle" fill="#555555">%s</text></a>`+"\n",
+			x+barW/2, bottom+16, d.day.Format("Mon 2"))
+		if total := d.work + d.outside; total > 0 {
+			fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", x+barW/2, bottom-workH-outH-4, shortDuration(total))
+		}
+	}
+	fmt.Fprintf(w, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/><text x="%d" y="%d">work hours</text>`+"\n",
+		margin, height-18, timelinePalette[0], margin+18, height-7)
+	fmt.Fprintf(w, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/><text x="%d" y="%d">outside hours</text>`+"\n",
+		margin+110, height-18, timelinePalette[1], margin+128, height-7)
+	fmt.Fprintln(w, "</svg>")
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(siteFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Focus tracker &middot; {{date .Date}}</title>
+{{if .Today}}<meta http-equiv="refresh" content="60">{{end}}` + siteStyle + `</head>
+<body>
+<p><a href="/?day={{.Prev}}">&larr; {{.Prev}}</a>{{if .Next}} &middot; <a href="/?day={{.Next}}">{{.Next}} &rarr;</a> &middot; <a href="/">Today</a>{{end}}</p>
+<h1>{{date .Date}}</h1>
+<p>{{dur .Work}} work, {{dur .Outside}} outside hours</p>
+{{.Timeline}}
+<h2>Apps</h2>
+{{.Pie}}
+<h2>Work vs outside hours</h2>
+{{.Bars}}
+</body></html>
+`))
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// datasetVersion is the published version of the dataset layout. It only
+// changes when a column is renamed, retyped or removed; new columns are
+// appended without a version change.
+const datasetVersion = 1
+
+// annotationColumns is the layout of the annotations table.
+var annotationColumns = []warehouseColumn{
+	{name: "start_time", duckdb: "TIMESTAMPTZ"},
+	{name: "end_time", duckdb: "TIMESTAMPTZ"},
+	{name: "tag", duckdb: "VARCHAR"},
+	{name: "note", duckdb: "VARCHAR"},
+}
+
+// runDataset writes the journal as a directory of month-partitioned CSV
+// files with a schema file and DuckDB views, so the data can be queried in
+// place:
+//
+//	dir/schema.json
+//	dir/views.sql
+//	dir/spans/month=2024-06/spans.csv
+//	dir/annotations/month=2024-06/annotations.csv
+//
+// Every month touched by the range is rewritten whole, so running it again
+// (e.g. nightly) keeps the directory in sync with the journal.
+func runDataset(args []string) error {
+	fs := flag.NewFlagSet("dataset", flag.ExitOnError)
+	out := fs.String("o", "focus-dataset", "directory to write the dataset to")
+	fromStr := fs.String("from", "", "first day to include (YYYY-MM-DD, default: the oldest journal)")
+	toStr := fs.String("to", "", "last day to include (YYYY-MM-DD, default: today)")
+	fs.Parse(args)
+
+	from := time.Time{}
+	if *fromStr != "" {
+		var err error
+		if from, err = parseDay(*fromStr); err != nil {
+			return err
+		}
+	}
+	to, err := parseDay(*toStr)
+	if err != nil {
+		return err
+	}
+
+	days, err := journalDays("spans")
+	if err != nil {
+		return err
+	}
+	touched := make(map[string]bool)
+	for _, day := range days {
+		if !day.Before(from) && !day.After(to) {
+			touched[day.Format("2006-01")] = true
+		}
+	}
+	// A touched month is rebuilt from all its days, not just those in range
+	months := make(map[string][]time.Time)
+	for _, day := range days {
+		if month := day.Format("2006-01"); touched[month] {
+			months[month] = append(months[month], day)
+		}
+	}
+
+	for _, month := range sortedKeys(months) {
+		var spanRows, annotationRows [][]string
+		for _, day := range months[month] {
+			spans, err := readSpans(day)
+			if err != nil {
+				return err
+			}
+			for _, s := range withContexts(spans) {
+				spanRows = append(spanRows, warehouseRow(s))
+			}
+			annotations, err := readAnnotations(day)
+			if err != nil {
+				return err
+			}
+			for _, a := range annotations {
+				annotationRows = append(annotationRows, []string{a.Start.Format(warehouseTime), a.End.Format(warehouseTime), a.Tag, a.Note})
+			}
+		}
+		if err := writePartition(*out, "spans", month, warehouseColumns, spanRows); err != nil {
+			return err
+		}
+		if err := writePartition(*out, "annotations", month, annotationColumns, annotationRows); err != nil {
+			return err
+		}
+	}
+	if err := writeDatasetSchema(*out); err != nil {
+		return err
+	}
+	fmt.Printf("%s Updated %s (%s); load %s in duckdb to query it.\n", glyphs.ok, *out, strings.Join(sortedKeys(months), ", "), filepath.Join(*out, "views.sql"))
+	return nil
+}
+
+// journalDays lists the days that have a journal of the kind ("spans" or
+// "annotations"), oldest first.
+func journalDays(kind string) ([]time.Time, error) {
+	paths, err := filepath.Glob(filepath.Join(logs, "focus_tracker_*_"+kind+".jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var days []time.Time
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "focus_tracker_"), "_"+kind+".jsonl")
+		if day, err := time.ParseInLocation("2006-01-02", name, time.Local); err == nil {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days, nil
+}
+
+// writePartition replaces one month's CSV file of a table.
+func writePartition(dir, table, month string, columns []warehouseColumn, rows [][]string) error {
+	partition := filepath.Join(dir, table, "month="+month)
+	if err := os.MkdirAll(partition, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(partition, table+".csv")
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+	w.Write(header)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// writeDatasetSchema writes schema.json, describing the layout for other
+// tools, and views.sql, which defines DuckDB views over the partitions.
+func writeDatasetSchema(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	tables := []struct {
+		name    string
+		columns []warehouseColumn
+	}{{"spans", warehouseColumns}, {"annotations", annotationColumns}}
+
+	type column struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	schema := struct {
+		Version   int                 `json:"schema_version"`
+		Partition string              `json:"partition"`
+		Tables    map[string][]column `json:"tables"`
+	}{datasetVersion, "month", make(map[string][]column)}
+
+	var views strings.Builder
+	fmt.Fprintf(&views, "-- Focus tracker dataset, schema version %d\n", datasetVersion)
+	for _, t := range tables {
+		var types []string
+		for _, col := range t.columns {
+			schema.Tables[t.name] = append(schema.Tables[t.name], column{col.name, col.duckdb})
+			types = append(types, fmt.Sprintf("'%s': '%s'", col.name, col.duckdb))
+		}
+		glob := filepath.Join(abs, t.name, "*", "*.csv")
+		fmt.Fprintf(&views, "CREATE OR REPLACE VIEW %s AS SELECT * FROM read_csv('%s', header = true, hive_partitioning = true, columns = {%s});\n",
+			t.name, strings.ReplaceAll(glob, "'", "''"), strings.Join(types, ", "))
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "schema.json"), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "views.sql"), []byte(views.String()), 0644)
+}
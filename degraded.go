@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	degradeAfter      = 2 * time.Minute
+	degradedWarnEvery = time.Hour
+)
+
+// titlesDegraded is set while window titles cannot be read and the tracker
+// only records app-level time.
+var titlesDegraded atomic.Bool
+
+// titleHealth tracks failed title reads. A single app without windows fails
+// too, so degraded mode needs failures across two apps for degradeAfter.
+var titleHealth struct {
+	failingSince time.Time
+	failingApps  map[string]bool
+	lastWarning  time.Time
+}
+
+// observeTitle records one title read and reports whether titles are
+// currently usable.
+func observeTitle(now time.Time, app string, err error) bool {
+	if err == nil {
+		if titlesDegraded.Load() {
+			fmt.Printf("%s Window titles are readable again.\n", glyphs.ok)
+			noteEvent("titles: recovered")
+			titlesDegraded.Store(false)
+		}
+		titleHealth.failingSince, titleHealth.failingApps = time.Time{}, nil
+		return true
+	}
+
+	if titleHealth.failingApps == nil {
+		titleHealth.failingSince, titleHealth.failingApps = now, make(map[string]bool)
+	}
+	titleHealth.failingApps[app] = true
+	if !titlesDegraded.Load() && now.Sub(titleHealth.failingSince) >= degradeAfter && len(titleHealth.failingApps) >= 2 {
+		titlesDegraded.Store(true)
+		noteEvent("titles: degraded (%v)", err)
+	}
+	if titlesDegraded.Load() && now.Sub(titleHealth.lastWarning) >= degradedWarnEvery {
+		fmt.Printf("%s Cannot read window titles (%v); tracking app time only. Check Accessibility permissions.\n", glyphs.warn, err)
+		titleHealth.lastWarning = now
+	}
+	return !titlesDegraded.Load()
+}
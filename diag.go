@@ -0,0 +1,176 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runDiag handles "diag bundle", which zips up what is needed to debug a
+// problem report: redacted config, platform and permission checks, crash
+// reports and a sample of recent spans.
+func runDiag(args []string) error {
+	if len(args) == 0 || args[0] != "bundle" {
+		return errors.New("usage: diag bundle [-o file.zip] [-anonymize] [-days 3]")
+	}
+	fs := flag.NewFlagSet("diag bundle", flag.ExitOnError)
+	out := fs.String("o", "focus-tracker-diag-"+time.Now().Format("20060102-150405")+".zip", "zip file to write")
+	anonymize := fs.Bool("anonymize", false, "replace window titles, app paths, project and context names, domains, issues and tracks with hashes")
+	days := fs.Int("days", 3, "days of recent spans to include")
+	fs.Parse(args[1:])
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+
+	add := func(name string, write func(w io.Writer) error) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		return write(w)
+	}
+
+	if err := add("config.txt", func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, strings.Join(redactedConfig(), "\n"))
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := add("platform.txt", writePlatformInfo); err != nil {
+		return err
+	}
+	if err := add("permissions.txt", writePermissionChecks); err != nil {
+		return err
+	}
+	if err := add("spans.jsonl", func(w io.Writer) error {
+		return writeSpanSample(w, *days, *anonymize)
+	}); err != nil {
+		return err
+	}
+
+	crashes, _ := filepath.Glob(filepath.Join(logs, "crash", "crash_*.txt"))
+	sort.Strings(crashes)
+	if len(crashes) > 5 {
+		crashes = crashes[len(crashes)-5:]
+	}
+	for _, path := range crashes {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if err := add("crash/"+filepath.Base(path), func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%s Diagnostics written to %s\n", glyphs.ok, *out)
+	if !*anonymize {
+		fmt.Println("It contains recent window titles; use -anonymize before sharing it publicly.")
+	}
+	return nil
+}
+
+func writePlatformInfo(w io.Writer) error {
+	exe, _ := os.Executable()
+	fmt.Fprintf(w, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "Executable: %s\n", exe)
+	fmt.Fprintf(w, "Log path: %s\n", logs)
+	for _, cmd := range [][]string{{"sw_vers"}, {"uname", "-a"}} {
+		out, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput()
+		if err != nil {
+			fmt.Fprintf(w, "%s: %v\n", strings.Join(cmd, " "), err)
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n%s\n", strings.Join(cmd, " "), strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// writePermissionChecks runs each probe once and reports whether it works.
+func writePermissionChecks(w io.Writer) error {
+	appSource, titleSource, idleSource := desktop.sources()
+	focused, err := desktop.focus()
+	if err != nil || focused.app == "" {
+		fmt.Fprintf(w, "Frontmost app (%s): FAILED %v\n", appSource, err)
+	} else {
+		fmt.Fprintf(w, "Frontmost app (%s): ok (%s, %s, %s %s)\n", appSource, focused.app, focused.bundleID, focused.path, appVersion(focused.path))
+		if focused.titleErr != nil {
+			fmt.Fprintf(w, "Window title (%s): FAILED %v\n", titleSource, focused.titleErr)
+		} else {
+			fmt.Fprintf(w, "Window title (%s): ok\n", titleSource)
+		}
+	}
+	fmt.Fprintf(w, "Idle time (%s): %ds\n", idleSource, desktop.idleSeconds())
+
+	if err := os.MkdirAll(logs, 0755); err != nil {
+		fmt.Fprintf(w, "Log directory writable: FAILED %v\n", err)
+	} else if f, err := os.CreateTemp(logs, ".diag-*"); err != nil {
+		fmt.Fprintf(w, "Log directory writable: FAILED %v\n", err)
+	} else {
+		f.Close()
+		os.Remove(f.Name())
+		fmt.Fprintf(w, "Log directory writable: ok\n")
+	}
+	return nil
+}
+
+// writeSpanSample copies the last days of spans, optionally anonymized.
+func writeSpanSample(w io.Writer, days int, anonymize bool) error {
+	enc := json.NewEncoder(w)
+	today, _ := parseDay("")
+	for i := days - 1; i >= 0; i-- {
+		spans, err := readSpans(today.AddDate(0, 0, -i))
+		if err != nil {
+			return err
+		}
+		for _, s := range spans {
+			if anonymize {
+				s.Title = hashText(s.Title)
+				s.Project = hashText(s.Project)
+				// App paths name the user, e.g. /Users/jane/Applications
+				s.AppPath = hashText(s.AppPath)
+				for i, c := range s.Contexts {
+					s.Contexts[i] = hashText(c)
+				}
+				s.NowPlaying = hashText(s.NowPlaying)
+				s.Domain = hashText(s.Domain)
+				s.Issue = hashText(s.Issue)
+			}
+			if err := enc.Encode(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hashText replaces text with a short stable hash, keeping "" empty.
+func hashText(s string) string {
+	if s == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s))
+	return "#" + hex.EncodeToString(sum[:])[:10]
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A window counts as being edited while its document has unsaved changes
+// and there was keyboard or mouse input within editingIdle. Time in it is
+// credited to the span's EditingSeconds; the rest of the span is reading.
+const editingIdle = 30 * time.Second
+
+// stripEditMarker removes the unsaved-changes markers some apps put in the
+// title, "● " in VS Code and " — Edited" in TextEdit and Pages, so saving
+// does not start a new span.
+func stripEditMarker(title string) (string, bool) {
+	if t := strings.TrimPrefix(title, "● "); t != title {
+		return t, true
+	}
+	if t := strings.TrimSuffix(title, " — Edited"); t != title {
+		return t, true
+	}
+	return title, false
+}
+
+// writeEditing splits each app's time into editing and reading, for apps
+// where any editing was seen.
+func writeEditing(w io.Writer, spans []Span) {
+	total := make(map[string]time.Duration)
+	editing := make(map[string]time.Duration)
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		total[s.App] += s.Duration()
+		editing[s.App] += s.Editing()
+	}
+	var apps []string
+	for _, app := range sortedByDuration(editing) {
+		if editing[app] >= time.Minute {
+			apps = append(apps, app)
+		}
+	}
+	if len(apps) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nEditing vs reading\n")
+	for _, app := range apps {
+		e := editing[app]
+		fmt.Fprintf(w, "  %s: %s editing, %s reading (%.0f%% editing)\n",
+			app, shortDuration(e), shortDuration(total[app]-e), 100*e.Seconds()/total[app].Seconds())
+	}
+}
+
+// Editing is the part of the span spent changing the document.
+func (s Span) Editing() time.Duration {
+	e := time.Duration(s.EditingSeconds) * time.Second
+	if d := s.Duration(); e > d {
+		return d
+	}
+	return e
+}
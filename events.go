@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// focusListeners are the /events clients. Each has a small buffer; a client
+// that falls behind misses events rather than holding up the tracker.
+var focusListeners struct {
+	sync.Mutex
+	chans map[chan apiCurrent]bool
+}
+
+func listenFocus() chan apiCurrent {
+	ch := make(chan apiCurrent, 16)
+	focusListeners.Lock()
+	if focusListeners.chans == nil {
+		focusListeners.chans = make(map[chan apiCurrent]bool)
+	}
+	focusListeners.chans[ch] = true
+	focusListeners.Unlock()
+	return ch
+}
+
+func unlistenFocus(ch chan apiCurrent) {
+	focusListeners.Lock()
+	delete(focusListeners.chans, ch)
+	focusListeners.Unlock()
+}
+
+// publishFocus sends a focus change to every /events client.
+func publishFocus(c apiCurrent) {
+	focusListeners.Lock()
+	defer focusListeners.Unlock()
+	for ch := range focusListeners.chans {
+		select {
+		case ch <- c:
+		default:
+		}
+	}
+}
+
+// jsonOutput receives the events of "track -json", one JSON object per
+// line; nil without -json.
+var jsonOutput struct {
+	sync.Mutex
+	enc *json.Encoder
+}
+
+// trackEvent is one line of "track -json": a focus change, with the same
+// fields as /current, or a finished span as journaled.
+type trackEvent struct {
+	Event string      `json:"event"`
+	Time  time.Time   `json:"time"`
+	Focus *apiCurrent `json:"focus,omitempty"`
+	Span  *Span       `json:"span,omitempty"`
+}
+
+func emitJSON(e trackEvent) {
+	jsonOutput.Lock()
+	defer jsonOutput.Unlock()
+	if jsonOutput.enc != nil {
+		jsonOutput.enc.Encode(e)
+	}
+}
+
+// eventRoutes registers GET /events, a Server-Sent Events stream with one
+// "focus" event per focus change, starting with the current focus.
+func eventRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		ch := listenFocus()
+		defer unlistenFocus(ch)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		send := func(c apiCurrent) error {
+			data, _ := json.Marshal(c)
+			_, err := fmt.Fprintf(w, "event: focus\ndata: %s\n\n", data)
+			flusher.Flush()
+			return err
+		}
+		if send(currentFocus(time.Now())) != nil {
+			return
+		}
+		// A comment now and then keeps proxies from closing an idle stream
+		keepAlive := time.NewTicker(30 * time.Second)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case c := <-ch:
+				if send(c) != nil {
+					return
+				}
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
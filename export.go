@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// runExport handles "export -stream": the journal's spans for a day, a week
+// or a range of days as JSON lines, written as they are read so the output
+// can be piped into jq. With -follow it keeps running and prints spans as
+// the tracker journals them. "export -format csv" writes the spans, or the
+// hours per day and app, as CSV instead. -o names the file, as for the
+// other exports; the default is stdout.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	stream := fs.Bool("stream", false, "write spans as JSON lines")
+	format := fs.String("format", "", "write CSV instead: csv")
+	summary := fs.Bool("summary", false, "with -format csv, write the hours per day and app instead of the spans")
+	dayStr, outPath := dayFlags(fs)
+	weekStr := fs.String("week", "", "export an ISO week instead of a day (YYYY-Www, e.g. 2024-W19)")
+	fromStr := fs.String("from", "", "export the days from this one instead of a single day (YYYY-MM-DD, today or yesterday)")
+	toStr := fs.String("to", "", "last day exported with -from (default today)")
+	follow := fs.Bool("follow", false, "keep running and write new spans as they are recorded")
+	fs.Parse(args)
+	if *stream == (*format != "") {
+		return errors.New("usage: export -stream [-day D | -week YYYY-Www | -from D -to D] [-follow] [-o FILE] | export -format csv [-summary] [-day D | -week YYYY-Www | -from D -to D] [-o FILE]")
+	}
+	if *format != "" && *format != "csv" {
+		return fmt.Errorf("unknown format %q, expected csv", *format)
+	}
+	if *summary && *format == "" {
+		return errors.New("-summary only works with -format csv")
+	}
+
+	var from, to time.Time // both included
+	var err error
+	if *weekStr != "" || *fromStr != "" || *toStr != "" {
+		if *dayStr != "" || *weekStr != "" && *fromStr+*toStr != "" {
+			return errors.New("use one of -day, -week or -from")
+		}
+		var end time.Time
+		if from, end, _, err = parseReportPeriod(*weekStr, "", *fromStr, *toStr); err != nil {
+			return err
+		}
+		to = end.AddDate(0, 0, -1)
+	} else {
+		if from, err = parseDay(*dayStr); err != nil {
+			return err
+		}
+		to = from
+	}
+	if *format != "" && *follow {
+		return errors.New("-follow only works with -stream")
+	}
+	today, _ := parseDay("")
+	if *follow && !to.Equal(today) {
+		return errors.New("-follow continues from today, so the last day must be today")
+	}
+
+	out, err := outputFile(*outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if *format != "" {
+		return writeCSVExport(out, from, to, *summary)
+	}
+
+	w := bufio.NewWriter(out)
+	var offset int64
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if offset, err = streamSpans(w, spansPath(day), 0); err != nil {
+			return err
+		}
+	}
+	if !*follow {
+		return nil
+	}
+	day := to
+	for {
+		time.Sleep(time.Second)
+		if now, _ := parseDay(""); !now.Equal(day) {
+			// Finish the old day's journal before moving on
+			if _, err := streamSpans(w, spansPath(day), offset); err != nil {
+				return err
+			}
+			day, offset = now, 0
+		}
+		if offset, err = streamSpans(w, spansPath(day), offset); err != nil {
+			return err
+		}
+	}
+}
+
+// streamSpans writes the complete journal lines after offset and returns
+// the offset to continue from.
+func streamSpans(w *bufio.Writer, path string, offset int64) (int64, error) {
+	enc := json.NewEncoder(w)
+	var encodeErr error
+	offset, err := scanJournal(path, offset, func(s Span) bool {
+		encodeErr = enc.Encode(withContexts([]Span{s})[0])
+		return encodeErr == nil
+	})
+	if err == nil {
+		err = encodeErr
+	}
+	if err != nil {
+		return offset, err
+	}
+	return offset, w.Flush()
+}
+
+// scanJournal calls fn for each complete span line of the journal after
+// offset until fn returns false, and returns the offset just past the last
+// line handed to fn. A line still being written is left for the next call;
+// a missing journal has no spans.
+func scanJournal(path string, offset int64, fn func(Span) bool) (int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return offset, nil
+	} else if err != nil {
+		return offset, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return offset, nil
+		} else if err != nil {
+			return offset, err
+		}
+		offset += int64(len(line))
+		var s Span
+		if json.Unmarshal(bytes.TrimSpace(line), &s) != nil {
+			continue
+		}
+		s.Project = canonicalProject(s.Project)
+		if !fn(s) {
+			return offset, nil
+		}
+	}
+}
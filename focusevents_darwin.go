@@ -0,0 +1,151 @@
+//go:build darwin
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With FOCUS_EVENTS the tracker does not start osascript on every tick.
+// One JavaScript for Automation helper runs for the tracker's lifetime: it
+// subscribes to NSWorkspace's didActivateApplication notification, reads
+// the front window's title over Apple events (no process per read) and
+// writes a line only when app, title or edited state changed, plus a
+// heartbeat. The tracker answers focus probes from the last line.
+//
+// Titles are still polled every two seconds, in cgo builds too: the
+// tracker registers no Accessibility observers (AXObserver) for them.
+
+// helperStale is how long without a line the helper counts as hung; the
+// tracker polls until it answers again.
+const helperStale = 45 * time.Second
+
+var helper struct {
+	sync.Mutex
+	started bool
+	last    focusedWindow
+	at      time.Time
+}
+
+// helperFocus returns the focus the helper reported last, starting it on
+// first use; ok is false until it has answered or when it went quiet.
+func helperFocus() (focusedWindow, bool) {
+	helper.Lock()
+	defer helper.Unlock()
+	if !helper.started {
+		helper.started = true
+		go runFocusHelper()
+	}
+	if time.Since(helper.at) > helperStale {
+		return focusedWindow{}, false
+	}
+	return helper.last, true
+}
+
+// focusLine is one line from the helper.
+type focusLine struct {
+	App      string `json:"app"`
+	BundleID string `json:"bundle_id"`
+	Path     string `json:"path"`
+	Title    string `json:"title"`
+	Edited   bool   `json:"edited"`
+	Error    string `json:"error"`
+}
+
+// runFocusHelper keeps the helper running, restarting it when it exits,
+// e.g. after the watchdog cancelled the provider context.
+func runFocusHelper() {
+	for {
+		if err := focusHelperOnce(); err != nil {
+			noteEvent("focus helper stopped: %v", err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func focusHelperOnce() error {
+	cmd := exec.CommandContext(providerContext(), "osascript", "-l", "JavaScript", "-e", focusHelperScript)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var l focusLine
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			continue
+		}
+		w := focusedWindow{app: l.App, bundleID: l.BundleID, path: strings.TrimSuffix(l.Path, "/")}
+		if l.Error != "" {
+			w.titleErr = errors.New(l.Error)
+		} else {
+			var marked bool
+			w.title, marked = stripEditMarker(l.Title)
+			w.edited = l.Edited || marked
+		}
+		// VS Code calls itself "Code"; keep the name polling has always recorded
+		if w.bundleID == "com.microsoft.VSCode" {
+			w.app = "Visual Studio Code"
+			w.title = strings.TrimSuffix(w.title, " — Visual Studio Code")
+		}
+		helper.Lock()
+		helper.last, helper.at = w, time.Now()
+		helper.Unlock()
+	}
+	return cmd.Wait()
+}
+
+const focusHelperScript = `
+ObjC.import('Cocoa');
+var se = Application('System Events');
+var out = $.NSFileHandle.fileHandleWithStandardOutput;
+var front = null, last = '', beat = 0;
+function activated(app) {
+	front = {app: ObjC.unwrap(app.localizedName) || '', bundle_id: ObjC.unwrap(app.bundleIdentifier) || '',
+		path: app.bundleURL.isNil() ? '' : ObjC.unwrap(app.bundleURL.path), pid: app.processIdentifier};
+	last = '';
+	check();
+}
+function check() {
+	if (!front) return;
+	var title = '', edited = false, error = '';
+	try {
+		var w = se.processes.whose({unixId: front.pid})[0].windows[0];
+		title = String(w.attributes['AXTitle'].value() || '');
+		try { edited = w.attributes['AXEdited'].value() === true; } catch (e) {}
+	} catch (e) {
+		error = String(e);
+	}
+	var line = JSON.stringify({app: front.app, bundle_id: front.bundle_id, path: front.path, title: title, edited: edited, error: error});
+	if (line === last && ++beat < 15) return;
+	last = line;
+	beat = 0;
+	out.writeData($(line + '\n').dataUsingEncoding($.NSUTF8StringEncoding));
+}
+ObjC.registerSubclass({
+	name: 'FocusTrackerObserver',
+	methods: {
+		'activated:': {
+			types: ['void', ['id']],
+			implementation: function(n) { activated(n.userInfo.objectForKey($.NSWorkspaceApplicationKey)); }
+		}
+	}
+});
+var observer = $.FocusTrackerObserver.alloc.init;
+$.NSWorkspace.sharedWorkspace.notificationCenter.addObserverSelectorNameObject(observer, 'activated:', $.NSWorkspaceDidActivateApplicationNotification, $());
+activated($.NSWorkspace.sharedWorkspace.frontmostApplication);
+while (true) {
+	// Notifications are delivered while the run loop runs
+	$.NSRunLoop.currentRunLoop.runUntilDate($.NSDate.dateWithTimeIntervalSinceNow(2));
+	check();
+}
+`
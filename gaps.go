@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gapOff is the category for time the Mac was off or unattended; it is
+// recorded as away, like a locked screen.
+const gapOff = "Off"
+
+// parseGapCategories reads GAP_CATEGORIES, a comma separated list.
+func parseGapCategories(input string) ([]string, error) {
+	var categories []string
+	for _, part := range strings.Split(input, ",") {
+		if name := strings.TrimSpace(part); name != "" {
+			categories = append(categories, name)
+		}
+	}
+	if len(categories) == 0 {
+		return nil, errors.New("no gap categories")
+	}
+	return categories, nil
+}
+
+// startupGap is the stretch between the last span journaled today and now,
+// if the tracker was not running for at least GAP_PROMPT. Gaps overnight
+// are not asked about.
+func startupGap(now time.Time) (from time.Time, ok bool) {
+	today, _ := parseDay("")
+	spans, err := readSpans(today)
+	if err != nil || len(spans) == 0 {
+		return time.Time{}, false
+	}
+	for _, s := range spans {
+		if s.End.After(from) {
+			from = s.End
+		}
+	}
+	return from, now.Sub(from) >= gapPrompt
+}
+
+// gapSpan is the manual entry for a classified gap.
+func gapSpan(from, to time.Time, category string) Span {
+	if strings.EqualFold(category, gapOff) {
+		return Span{Start: from, End: to, App: lockedApp, Title: gapOff, Manual: true}
+	}
+	return Span{Start: from, End: to, App: category, Manual: true}
+}
+
+// askGapCategory asks what the gap was: on the terminal when the tracker
+// runs in one, otherwise in a dialog that gives up after ten minutes. ""
+// means the gap is left as it is.
+func askGapCategory(from, to time.Time) (string, error) {
+	prompt := fmt.Sprintf("The tracker was not running %s-%s (%s). What was it?",
+		from.Format("15:04"), to.Format("15:04"), shortDuration(to.Sub(from)))
+	if isTerminal(os.Stdin) {
+		fmt.Println(prompt)
+		for i, c := range gapCategories {
+			fmt.Printf("  %d) %s\n", i+1, c)
+		}
+		fmt.Print("Number, another name, or Enter to skip: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimSpace(line)
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(gapCategories) {
+			return gapCategories[n-1], nil
+		}
+		return line, nil
+	}
+	items := make([]string, len(gapCategories))
+	for i, c := range gapCategories {
+		items[i] = `"` + appleScriptEscape(c) + `"`
+	}
+	script := fmt.Sprintf(`set r to choose from list {%s} with prompt "%s" with title "Focus Tracker" giving up after 600
+if r is false then return ""
+return item 1 of r`, strings.Join(items, ", "), appleScriptEscape(prompt))
+	return runAppleScript(script)
+}
+
+// classifyStartupGap asks about a gap since the tracker last ran today and
+// hands the entry to the tracker loop, which records it.
+func classifyStartupGap(entries chan<- Span) {
+	now := time.Now()
+	from, ok := startupGap(now)
+	if !ok {
+		return
+	}
+	category, err := askGapCategory(from, now)
+	if err != nil {
+		fmt.Printf("%s Could not ask about the gap since %s: %v\n", glyphs.warn, from.Format("15:04"), err)
+		return
+	}
+	if category == "" {
+		return
+	}
+	entries <- gapSpan(from, now, category)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Grafana reads the tracker's data directly through two kinds of endpoint:
+//
+//   - /grafana/search and /grafana/query speak the SimpleJSON protocol of
+//     the "JSON" datasource plugin (simpod-json-datasource);
+//   - GET /grafana/totals returns the same time-bucketed totals for the
+//     Infinity or JSON API plugins.
+//
+// Values are seconds of focus per interval.
+
+const maxGrafanaPoints = 10000
+
+// grafanaSeries is one named series of [value, unix ms] pairs.
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+func grafanaRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/grafana/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok") // the datasource's connection test
+	})
+	mux.HandleFunc("/grafana/search", grafanaSearch)
+	mux.HandleFunc("/grafana/metrics", grafanaSearch)
+	mux.HandleFunc("/grafana/query", grafanaQuery)
+	mux.HandleFunc("/grafana/totals", grafanaTotals)
+}
+
+// grafanaSearch lists the targets: buckets, apps seen in the last 30 days
+// and projects. "apps" and "projects" expand to one series each.
+func grafanaSearch(w http.ResponseWriter, r *http.Request) {
+	targets := []string{"work", "outside", "apps", "projects"}
+	now := time.Now()
+	spans, _ := readSpanRange(now.AddDate(0, 0, -30), now)
+	apps := make(map[string]bool)
+	for _, s := range spans {
+		if !s.Away() {
+			apps[s.App] = true
+		}
+	}
+	for _, app := range sortedKeys(apps) {
+		targets = append(targets, "app:"+app)
+	}
+	for _, p := range projects {
+		targets = append(targets, "project:"+p.name)
+	}
+	writeJSON(w, targets)
+}
+
+func grafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Range struct {
+			From time.Time `json:"from"`
+			To   time.Time `json:"to"`
+		} `json:"range"`
+		IntervalMs int64 `json:"intervalMs"`
+		Targets    []struct {
+			Target string `json:"target"`
+		} `json:"targets"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, to := req.Range.From.Local(), req.Range.To.Local()
+	interval, err := grafanaInterval(from, to, time.Duration(req.IntervalMs)*time.Millisecond)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	spans, err := readSpanRange(from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	spans = withContexts(spans)
+
+	result := []grafanaSeries{}
+	for _, t := range req.Targets {
+		switch {
+		case t.Target == "apps":
+			result = append(result, grafanaGrouped(spans, from, to, interval, func(s Span) string { return s.App })...)
+		case t.Target == "projects":
+			result = append(result, grafanaGrouped(spans, from, to, interval, func(s Span) string { return s.Project })...)
+		default:
+			keep := grafanaFilter(t.Target)
+			var matching []Span
+			for _, s := range spans {
+				if keep(s) {
+					matching = append(matching, s)
+				}
+			}
+			result = append(result, grafanaSeries{t.Target, timeBuckets(matching, from, to, interval)})
+		}
+	}
+	writeJSON(w, result)
+}
+
+// grafanaTotals answers GET /grafana/totals?from=&to=&interval=1h&by=app
+// where from and to are RFC 3339 times or YYYY-MM-DD days (default: today)
+// and by is bucket, app or project.
+func grafanaTotals(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	today, _ := parseDay("")
+	from, to := today, today.AddDate(0, 0, 1)
+	var err error
+	if v := q.Get("from"); v != "" {
+		if from, err = parseTimeParam(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = parseTimeParam(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	step := time.Hour
+	if v := q.Get("interval"); v != "" {
+		if step, err = time.ParseDuration(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	interval, err := grafanaInterval(from, to, step)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	spans, err := readSpanRange(from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	spans = withContexts(spans)
+
+	var group func(Span) string
+	switch q.Get("by") {
+	case "", "bucket":
+		group = func(s Span) string { return bucketLabel(s.Suffix()) }
+	case "app":
+		group = func(s Span) string { return s.App }
+	case "project":
+		group = func(s Span) string { return s.Project }
+	default:
+		http.Error(w, "by must be bucket, app or project", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"from":     from,
+		"to":       to,
+		"interval": interval.String(),
+		"series":   grafanaGrouped(spans, from, to, interval, group),
+	})
+}
+
+// parseTimeParam reads an RFC 3339 time or a day.
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Local(), nil
+	}
+	return parseDay(v)
+}
+
+// grafanaInterval checks the range and widens the interval so the number
+// of points stays bounded.
+func grafanaInterval(from, to time.Time, interval time.Duration) (time.Duration, error) {
+	if !to.After(from) {
+		return 0, fmt.Errorf("empty time range")
+	}
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	for to.Sub(from)/interval > maxGrafanaPoints {
+		interval *= 2
+	}
+	return interval, nil
+}
+
+// grafanaFilter turns a target into a span filter.
+func grafanaFilter(target string) func(Span) bool {
+	switch {
+	case target == "work":
+		return func(s Span) bool { return s.Work && !s.Away() }
+	case target == "outside":
+		return func(s Span) bool { return !s.Work && !s.Away() }
+	case strings.HasPrefix(target, "app:"):
+		app := strings.TrimPrefix(target, "app:")
+		return func(s Span) bool { return s.App == app }
+	case strings.HasPrefix(target, "project:"):
+		project := strings.TrimPrefix(target, "project:")
+		return func(s Span) bool { return strings.EqualFold(s.Project, project) }
+	}
+	return func(Span) bool { return false }
+}
+
+// grafanaGrouped makes a series per group, skipping away time and spans
+// without a group.
+func grafanaGrouped(spans []Span, from, to time.Time, interval time.Duration, group func(Span) string) []grafanaSeries {
+	groups := make(map[string][]Span)
+	for _, s := range spans {
+		if name := group(s); name != "" && !s.Away() {
+			groups[name] = append(groups[name], s)
+		}
+	}
+	result := []grafanaSeries{}
+	for _, name := range sortedKeys(groups) {
+		result = append(result, grafanaSeries{name, timeBuckets(groups[name], from, to, interval)})
+	}
+	return result
+}
+
+// timeBuckets sums the spans' overlap with each interval from..to, as
+// [seconds, unix ms of the interval start] pairs.
+func timeBuckets(spans []Span, from, to time.Time, interval time.Duration) [][2]float64 {
+	start := from.Truncate(interval)
+	if interval%(24*time.Hour) == 0 {
+		// Whole days start at local midnight, not UTC midnight
+		start = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	}
+	points := [][2]float64{}
+	for t := start; t.Before(to); t = t.Add(interval) {
+		var sum time.Duration
+		for _, s := range spans {
+			sum += overlap(s.Start, s.End, t, t.Add(interval))
+		}
+		points = append(points, [2]float64{sum.Seconds(), float64(t.UnixMilli())})
+	}
+	return points
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
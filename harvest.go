@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const harvestAPI = "https://api.harvestapp.com/v2"
+
+// Project time goes to Harvest as one time entry per Harvest project, task
+// and day. The hours are the day's time on the project within its allowed
+// hours, rounded by HARVEST_ROUND as a client's are on invoices. A
+// project's harvest_project and harvest_task say where its time goes,
+// defaulting to the Harvest project of the same name and HARVEST_TASK.
+// Entries carry an external reference naming the day, project and task, so
+// syncing a day again updates them to match, and removes those no longer
+// tracked, instead of adding more.
+
+// harvestRefGroup marks the external references of our entries.
+const harvestRefGroup = "focus-tracker"
+
+// harvestRow is the time of one or more rules projects booked to a Harvest
+// project and task on a day.
+type harvestRow struct {
+	projectID, taskID int64
+	project, task     string // Harvest names, for messages
+	time              time.Duration
+	apps              map[string]time.Duration
+}
+
+func (r *harvestRow) ref(day string) string {
+	return fmt.Sprintf("%s/%s/%d/%d", harvestRefGroup, day, r.projectID, r.taskID)
+}
+
+func (r *harvestRow) hours() float64 {
+	return math.Round(r.time.Hours()*100) / 100
+}
+
+// notes lists the apps the time was spent in, largest first.
+func (r *harvestRow) notes() string {
+	var parts []string
+	for _, app := range sortedByDuration(r.apps) {
+		parts = append(parts, app+" "+shortDuration(r.apps[app]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// syncHarvest makes the day's Harvest entries match its project time. With
+// -dry-run it lists what it would change instead.
+func syncHarvest(day time.Time, spans []Span) error {
+	if harvestToken == "" || harvestAccount == "" {
+		return errors.New("HARVEST_TOKEN and HARVEST_ACCOUNT_ID must be set")
+	}
+	rows, unmapped, err := harvestRows(spans)
+	if err != nil {
+		return fmt.Errorf("harvest: %w", err)
+	}
+	date := day.Format("2006-01-02")
+	existing, err := harvestEntries(date)
+	if err != nil {
+		return fmt.Errorf("harvest: %w", err)
+	}
+
+	if syncDryRun {
+		fmt.Printf("Harvest entries for %s:\n", date)
+	}
+	var created, updated, deleted int
+	for _, r := range rows {
+		if r.hours() == 0 {
+			continue
+		}
+		ref := r.ref(date)
+		e, found := existing[ref]
+		delete(existing, ref)
+		body := map[string]any{"task_id": r.taskID, "hours": r.hours(), "notes": r.notes()}
+		action := "unchanged"
+		switch {
+		case !found:
+			action = "create"
+			body["project_id"] = r.projectID
+			body["spent_date"] = date
+			body["external_reference"] = map[string]string{"id": ref, "group_id": harvestRefGroup}
+			if !syncDryRun {
+				err = harvestRequest(http.MethodPost, "/time_entries", body, nil)
+			}
+			created++
+		case math.Abs(e.Hours-r.hours()) >= 0.005 || e.Notes != r.notes():
+			action = fmt.Sprintf("update from %.2fh", e.Hours)
+			if !syncDryRun {
+				err = harvestRequest(http.MethodPatch, fmt.Sprintf("/time_entries/%d", e.ID), body, nil)
+			}
+			updated++
+		}
+		if err != nil {
+			return fmt.Errorf("harvest: %s / %s: %w", r.project, r.task, err)
+		}
+		if syncDryRun {
+			fmt.Printf("  %-30s %6.2fh  %s\n", r.project+" / "+r.task, r.hours(), action)
+		}
+	}
+	for _, ref := range sortedKeys(existing) {
+		e := existing[ref]
+		if syncDryRun {
+			fmt.Printf("  %-30s %6.2fh  delete, no longer tracked\n", e.Project.Name+" / "+e.Task.Name, e.Hours)
+		} else if err := harvestRequest(http.MethodDelete, fmt.Sprintf("/time_entries/%d", e.ID), nil, nil); err != nil {
+			return fmt.Errorf("harvest: %s / %s: %w", e.Project.Name, e.Task.Name, err)
+		}
+		deleted++
+	}
+
+	for _, name := range unmapped {
+		fmt.Printf("%s Harvest: no project %q assigned to you, so its time was left out; set harvest_project on it\n", glyphs.warn, name)
+	}
+	verb := ""
+	if syncDryRun {
+		verb = "would be "
+	}
+	fmt.Printf("%s Harvest: %d entries %screated, %d %supdated and %d %sdeleted for %s\n", glyphs.ok, created, verb, updated, verb, deleted, verb, date)
+	return nil
+}
+
+// harvestRows totals the spans per Harvest project and task, rounding each
+// rules project's time. It also returns the projects with no Harvest
+// project of their name.
+func harvestRows(spans []Span) ([]*harvestRow, []string, error) {
+	perProject := make(map[string]time.Duration)
+	apps := make(map[string]map[string]time.Duration)
+	for _, s := range withContexts(spans) {
+		if s.Away() {
+			continue
+		}
+		p, ok := lookupProject(s.Project)
+		if !ok {
+			continue
+		}
+		d := p.allowedTime(s)
+		if d <= 0 {
+			continue
+		}
+		if apps[p.name] == nil {
+			apps[p.name] = make(map[string]time.Duration)
+		}
+		perProject[p.name] += d
+		apps[p.name][s.App] += d
+	}
+	if len(perProject) == 0 {
+		return nil, nil, nil
+	}
+	assignments, err := harvestAssignments()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byKey := make(map[string]*harvestRow)
+	var rows []*harvestRow
+	var unmapped []string
+	for _, name := range sortedKeys(perProject) {
+		p, _ := lookupProject(name)
+		target := p.harvestProject
+		if target == "" {
+			target = p.name
+		}
+		a, ok := assignments[strings.ToLower(target)]
+		if !ok {
+			if p.harvestProject != "" {
+				return nil, nil, fmt.Errorf("no Harvest project %q assigned to you, the harvest_project of project %q", target, name)
+			}
+			unmapped = append(unmapped, name)
+			continue
+		}
+		task, err := a.taskFor(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		key := fmt.Sprintf("%d/%d", a.id, task.ID)
+		row := byKey[key]
+		if row == nil {
+			row = &harvestRow{projectID: a.id, taskID: task.ID, project: a.name, task: task.Name, apps: make(map[string]time.Duration)}
+			byKey[key] = row
+			rows = append(rows, row)
+		}
+		row.time += roundDuration(perProject[name], harvestRound, harvestRoundMode)
+		for app, d := range apps[name] {
+			row.apps[app] += d
+		}
+	}
+	return rows, unmapped, nil
+}
+
+// harvestAssignment is a Harvest project you can book time to, with its
+// tasks by lower-cased name.
+type harvestAssignment struct {
+	id    int64
+	name  string
+	tasks map[string]harvestTask
+}
+
+type harvestTask struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// taskFor picks the task for a rules project's time: its harvest_task,
+// else HARVEST_TASK if the Harvest project has it, else the Harvest
+// project's only task.
+func (a harvestAssignment) taskFor(p project) (harvestTask, error) {
+	if p.harvestTask != "" {
+		if t, ok := a.tasks[strings.ToLower(p.harvestTask)]; ok {
+			return t, nil
+		}
+		return harvestTask{}, fmt.Errorf("no task %q in Harvest project %q, the harvest_task of project %q; its tasks are %s", p.harvestTask, a.name, p.name, a.taskNames())
+	}
+	if t, ok := a.tasks[strings.ToLower(harvestDefaultTask)]; ok && harvestDefaultTask != "" {
+		return t, nil
+	}
+	if len(a.tasks) == 1 {
+		for _, t := range a.tasks {
+			return t, nil
+		}
+	}
+	return harvestTask{}, fmt.Errorf("set harvest_task on project %q or HARVEST_TASK; the tasks of Harvest project %q are %s", p.name, a.name, a.taskNames())
+}
+
+func (a harvestAssignment) taskNames() string {
+	var names []string
+	for _, t := range a.tasks {
+		names = append(names, t.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// harvestCache holds your user ID and project assignments for the run.
+var harvestCache struct {
+	sync.Mutex
+	userID      int64
+	assignments map[string]harvestAssignment // by lower-cased project name
+}
+
+func harvestAssignments() (map[string]harvestAssignment, error) {
+	harvestCache.Lock()
+	defer harvestCache.Unlock()
+	if harvestCache.assignments != nil {
+		return harvestCache.assignments, nil
+	}
+	var resp struct {
+		ProjectAssignments []struct {
+			IsActive bool `json:"is_active"`
+			Project  struct {
+				ID   int64  `json:"id"`
+				Name string `json:"name"`
+			} `json:"project"`
+			TaskAssignments []struct {
+				IsActive bool        `json:"is_active"`
+				Task     harvestTask `json:"task"`
+			} `json:"task_assignments"`
+		} `json:"project_assignments"`
+	}
+	if err := harvestRequest(http.MethodGet, "/users/me/project_assignments?per_page=2000", nil, &resp); err != nil {
+		return nil, err
+	}
+	assignments := make(map[string]harvestAssignment)
+	for _, pa := range resp.ProjectAssignments {
+		if !pa.IsActive {
+			continue
+		}
+		a := harvestAssignment{id: pa.Project.ID, name: pa.Project.Name, tasks: make(map[string]harvestTask)}
+		for _, ta := range pa.TaskAssignments {
+			if ta.IsActive {
+				a.tasks[strings.ToLower(ta.Task.Name)] = ta.Task
+			}
+		}
+		assignments[strings.ToLower(a.name)] = a
+	}
+	harvestCache.assignments = assignments
+	return assignments, nil
+}
+
+func harvestUserID() (int64, error) {
+	harvestCache.Lock()
+	defer harvestCache.Unlock()
+	if harvestCache.userID == 0 {
+		var user struct {
+			ID int64 `json:"id"`
+		}
+		if err := harvestRequest(http.MethodGet, "/users/me", nil, &user); err != nil {
+			return 0, err
+		}
+		harvestCache.userID = user.ID
+	}
+	return harvestCache.userID, nil
+}
+
+// harvestEntry is one of your Harvest time entries as the API returns it.
+type harvestEntry struct {
+	ID      int64   `json:"id"`
+	Hours   float64 `json:"hours"`
+	Notes   string  `json:"notes"`
+	Project struct {
+		Name string `json:"name"`
+	} `json:"project"`
+	Task struct {
+		Name string `json:"name"`
+	} `json:"task"`
+	ExternalReference *struct {
+		ID      string `json:"id"`
+		GroupID string `json:"group_id"`
+	} `json:"external_reference"`
+}
+
+// harvestEntries returns your entries of the day that a sync created, by
+// external reference.
+func harvestEntries(date string) (map[string]harvestEntry, error) {
+	userID, err := harvestUserID()
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{"user_id": {strconv.FormatInt(userID, 10)}, "from": {date}, "to": {date}, "per_page": {"2000"}}
+	var resp struct {
+		TimeEntries []harvestEntry `json:"time_entries"`
+	}
+	if err := harvestRequest(http.MethodGet, "/time_entries?"+q.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	entries := make(map[string]harvestEntry)
+	for _, e := range resp.TimeEntries {
+		if e.ExternalReference != nil && e.ExternalReference.GroupID == harvestRefGroup {
+			entries[e.ExternalReference.ID] = e
+		}
+	}
+	return entries, nil
+}
+
+func harvestRequest(method, path string, body, out any) error {
+	return apiRequest(method, harvestAPI+path, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+harvestToken)
+		req.Header.Set("Harvest-Account-Id", harvestAccount)
+		req.Header.Set("User-Agent", "focus-tracker (https://github.com/ZonCen/Work_timer)")
+	}, body, out)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Days off have no work hours even when their weekday does: HOLIDAYS and
+// the all-day events of HOLIDAYS_ICS are holidays, VACATION days are
+// vacation. Their time goes to the outside-hours summary, or to the
+// "holiday" and "vacation" buckets when OUTSIDE_BUCKETS lists them.
+
+// icsHolidays caches the all-day events of HOLIDAYS_ICS for a day, so the
+// calendar is fetched once a day rather than per span.
+var icsHolidays struct {
+	sync.Mutex
+	source  string
+	fetched time.Time
+	days    map[string]bool
+	warned  bool
+}
+
+// holidayICSDays returns the dates covered by all-day events in the
+// calendar from a year ago to a year ahead. On a failed fetch the last
+// good copy is kept and a warning printed once.
+func holidayICSDays(source string) map[string]bool {
+	icsHolidays.Lock()
+	defer icsHolidays.Unlock()
+	if icsHolidays.source == source && time.Since(icsHolidays.fetched) < 24*time.Hour {
+		return icsHolidays.days
+	}
+	now := time.Now()
+	events, err := calendarEvents(source, now.AddDate(-1, 0, 0), now.AddDate(1, 0, 0))
+	icsHolidays.source, icsHolidays.fetched = source, now
+	if err != nil {
+		if !icsHolidays.warned {
+			fmt.Printf("%s Could not read HOLIDAYS_ICS: %v\n", glyphs.warn, err)
+			icsHolidays.warned = true
+		}
+		return icsHolidays.days
+	}
+	days := make(map[string]bool)
+	for _, ev := range events {
+		if !ev.AllDay {
+			continue
+		}
+		// DTEND of an all-day event is the day after the last one
+		for d := ev.Start; d.Before(ev.End); d = d.AddDate(0, 0, 1) {
+			days[d.Format("2006-01-02")] = true
+		}
+	}
+	icsHolidays.days, icsHolidays.warned = days, false
+	return days
+}
+
+// dayOff reports why the day of t has no work hours: "holiday", "vacation",
+// or "" for a normal day.
+func dayOff(t time.Time) string {
+	date := t.Format("2006-01-02")
+	switch {
+	case holidays[date]:
+		return "holiday"
+	case vacationDays[date]:
+		return "vacation"
+	case holidaysICS != "" && holidayICSDays(holidaysICS)[date]:
+		return "holiday"
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// htmlTopWindows is how many windows the table of "report -format html"
+// lists.
+const htmlTopWindows = 20
+
+// htmlReport is the page of "report -format html": a standalone file with
+// inline styles and SVG charts, so it can be mailed or attached as is.
+type htmlReport struct {
+	Heading       string
+	Work, Outside time.Duration
+	Timelines     []htmlTimeline
+	Categories    template.HTML
+	Windows       []htmlWindow
+}
+
+type htmlTimeline struct {
+	Date time.Time
+	SVG  template.HTML
+}
+
+// htmlWindow is one row of the top windows table.
+type htmlWindow struct {
+	App, Title string
+	Total      time.Duration
+	Percent    int // of the tracked time
+}
+
+// writeHTMLReport writes the days [from, to) as an HTML page with a
+// timeline per day, a bar per day stacked by category and the windows that
+// took the most time. Away time is left out.
+func writeHTMLReport(w io.Writer, label string, from, to time.Time, spans []Span) error {
+	page := htmlReport{Heading: reportHeading(label, from, to)}
+	windows := make(map[string]time.Duration) // by app and title, split by a NUL
+	var days []time.Time
+	var tracked time.Duration
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+		var daySpans []Span
+		for _, s := range spans {
+			if s.Away() || s.Start.Before(d) || !s.Start.Before(d.AddDate(0, 0, 1)) {
+				continue
+			}
+			daySpans = append(daySpans, s)
+			if s.Work {
+				page.Work += s.Duration()
+			} else {
+				page.Outside += s.Duration()
+			}
+			windows[s.App+"\x00"+s.Title] += s.Duration()
+			tracked += s.Duration()
+		}
+		if len(daySpans) == 0 {
+			continue
+		}
+		var b bytes.Buffer
+		writeTimelineSVG(&b, d, daySpans, nil)
+		page.Timelines = append(page.Timelines, htmlTimeline{d, template.HTML(b.String())})
+	}
+
+	var b bytes.Buffer
+	writeCategoryBarsSVG(&b, days, spans)
+	page.Categories = template.HTML(b.String())
+
+	for i, key := range sortedByDuration(windows) {
+		if i == htmlTopWindows {
+			break
+		}
+		app, title, _ := strings.Cut(key, "\x00")
+		page.Windows = append(page.Windows, htmlWindow{app, title, windows[key], int(100 * windows[key] / tracked)})
+	}
+	return htmlReportTemplate.Execute(w, page)
+}
+
+// writeCategoryBarsSVG draws a bar per day stacked by category, the largest
+// category at the bottom. Categories past the palette share one "Other
+// categories" color.
+func writeCategoryBarsSVG(w io.Writer, days []time.Time, spans []Span) {
+	const (
+		width   = 960
+		margin  = 20
+		chartH  = 160
+		legendW = 160
+		rowH    = 18
+	)
+	perDay := make(map[string]map[string]time.Duration)
+	totals := make(map[string]time.Duration)
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		name := s.Category
+		if name == "" {
+			name = "(uncategorized)"
+		}
+		day := s.Start.Format("2006-01-02")
+		if perDay[day] == nil {
+			perDay[day] = make(map[string]time.Duration)
+		}
+		perDay[day][name] += s.Duration()
+		totals[name] += s.Duration()
+	}
+	legend := sortedByDuration(totals)
+	if len(legend) > len(timelinePalette) {
+		other := "Other categories"
+		for _, name := range legend[len(timelinePalette)-1:] {
+			for _, m := range perDay {
+				if d, ok := m[name]; ok {
+					m[other] += d
+				}
+			}
+		}
+		legend = append(legend[:len(timelinePalette)-1:len(timelinePalette)-1], other)
+	}
+
+	var max time.Duration
+	for _, m := range perDay {
+		var sum time.Duration
+		for _, name := range legend {
+			sum += m[name]
+		}
+		if sum > max {
+			max = sum
+		}
+	}
+	if max < time.Hour {
+		max = time.Hour
+	}
+	perRow := (width - 2*margin) / legendW
+	legendRows := (len(legend) + perRow - 1) / perRow
+	height := margin + chartH + 24 + legendRows*rowH
+	slot := float64(width-2*margin) / float64(len(days))
+	barW := slot * 0.6
+	scale := chartH / max.Seconds()
+	bottom := float64(margin + chartH)
+	dayLayout := "Mon 2"
+	if len(days) > 14 {
+		dayLayout = "2"
+	}
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="-apple-system, Helvetica, Arial, sans-serif" font-size="11">`+"\n",
+		width, height, width, height)
+	for i, d := range days {
+		x := margin + float64(i)*slot + (slot-barW)/2
+		y := bottom
+		m := perDay[d.Format("2006-01-02")]
+		var total time.Duration
+		for j, name := range legend {
+			h := m[name].Seconds() * scale
+			if h <= 0 {
+				continue
+			}
+			y -= h
+			total += m[name]
+			fmt.Fprintf(w, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s %s</title></rect>`+"\n",
+				x, y, barW, h, timelinePalette[j], svgEscape(name), shortDuration(m[name]))
+		}
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle" fill="#555555">%s</text>`+"\n", x+barW/2, bottom+16, d.Format(dayLayout))
+		if total > 0 && len(days) <= 14 {
+			fmt.Fprintf(w, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", x+barW/2, y-4, shortDuration(total))
+		}
+	}
+	for i, name := range legend {
+		x := margin + i%perRow*legendW
+		y := margin + chartH + 24 + i/perRow*rowH
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/><text x="%d" y="%d">%s</text>`+"\n",
+			x, y, timelinePalette[i], x+18, y+11, svgEscape(name))
+	}
+	fmt.Fprintln(w, "</svg>")
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(siteFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Focus report for {{.Heading}}</title>` + siteStyle + `</head>
+<body>
+<h1>Focus report for {{.Heading}}</h1>
+<p>{{dur .Work}} work, {{dur .Outside}} outside hours</p>
+{{if .Timelines}}<h2>Timeline</h2>
+{{range .Timelines}}{{if gt (len $.Timelines) 1}}<h3>{{date .Date}}</h3>
+{{end}}{{.SVG}}
+{{end}}<h2>By category</h2>
+{{.Categories}}
+<h2>Top windows</h2>
+<table>
+<tr><th>App</th><th>Window</th><th>Time</th><th>Share</th></tr>
+{{range .Windows}}<tr><td>{{.App}}</td><td>{{if .Title}}{{.Title}}{{else}}(no title){{end}}</td><td class="num">{{dur .Total}}</td><td class="num">{{.Percent}}%</td></tr>
+{{end}}</table>
+{{else}}<p>Nothing tracked in this period.</p>
+{{end}}</body></html>
+`))
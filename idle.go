@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// idlePause is one stretch without keyboard or mouse input that ended with
+// the user coming back, recorded while calibrating.
+type idlePause struct {
+	Start   time.Time `json:"start"`
+	Seconds int       `json:"seconds"`
+}
+
+// Pauses shorter than minIdlePause are typing rhythm, not pauses.
+const minIdlePause = 10
+
+func idlePausesPath() string {
+	return filepath.Join(logs, "idle_pauses.jsonl")
+}
+
+// idleRun follows the idle time between passes of the tracker loop.
+var idleRun struct {
+	last int // idle seconds at the previous pass
+	at   time.Time
+}
+
+// idleTick records a pause when input resumes after one, while calibrating.
+func idleTick(now time.Time, idle int) {
+	if !idleCalibrate && !idleAuto {
+		return
+	}
+	// After a gap in the passes (paused tracking, sleep) the pause is unknown
+	if now.Sub(idleRun.at) < 30*time.Second && idle < idleRun.last && idleRun.last >= minIdlePause {
+		p := idlePause{Start: now.Add(-time.Duration(idleRun.last) * time.Second), Seconds: idleRun.last}
+		if err := appendJSONLine(idlePausesPath(), p); err != nil {
+			fmt.Printf("%s Could not record idle pause: %v\n", glyphs.warn, err)
+		}
+	}
+	idleRun.last, idleRun.at = idle, now
+}
+
+func readIdlePauses(since time.Time) ([]idlePause, error) {
+	f, err := os.Open(idlePausesPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var pauses []idlePause
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var p idlePause
+		if json.Unmarshal(scanner.Bytes(), &p) == nil && !p.Start.Before(since) {
+			pauses = append(pauses, p)
+		}
+	}
+	return pauses, scanner.Err()
+}
+
+// idleBands are the times of day with their own threshold.
+var idleBands = []struct {
+	name     string
+	from, to int // hours
+}{
+	{"night", 0, 6},
+	{"morning", 6, 12},
+	{"afternoon", 12, 18},
+	{"evening", 18, 24},
+}
+
+func idleBand(t time.Time) int {
+	for i, b := range idleBands {
+		if t.Hour() >= b.from && t.Hour() < b.to {
+			return i
+		}
+	}
+	return 0
+}
+
+// idleAdvice is the calibration of one band of the day.
+type idleAdvice struct {
+	pauses    int
+	median    int
+	threshold int // 0 without enough pauses
+}
+
+// Bands with fewer pauses keep IDLE_TIME's fallback.
+const minCalibrationPauses = 30
+
+// adviseIdle suggests a threshold per band: the 90th percentile of pauses
+// under ten minutes, so the short pauses of reading and thinking stay focus
+// time while longer ones count as away. It is rounded up to 30 seconds and
+// kept between one and ten minutes.
+func adviseIdle(pauses []idlePause) []idleAdvice {
+	short := make([][]int, len(idleBands))
+	advice := make([]idleAdvice, len(idleBands))
+	for _, p := range pauses {
+		b := idleBand(p.Start.Local())
+		advice[b].pauses++
+		if p.Seconds < 600 {
+			short[b] = append(short[b], p.Seconds)
+		}
+	}
+	for b, secs := range short {
+		if len(secs) == 0 {
+			continue
+		}
+		sort.Ints(secs)
+		advice[b].median = secs[len(secs)/2]
+		if advice[b].pauses < minCalibrationPauses {
+			continue
+		}
+		p90 := secs[int(math.Ceil(0.9*float64(len(secs))))-1]
+		t := (p90 + 29) / 30 * 30
+		if t < 60 {
+			t = 60
+		}
+		if t > 600 {
+			t = 600
+		}
+		advice[b].threshold = t
+	}
+	return advice
+}
+
+// Calibration period for IDLE_TIME=auto and "idle recommend".
+const idleCalibrationDays = 14
+
+// autoIdle caches the thresholds of IDLE_TIME=auto, recomputed every few
+// hours from the last two weeks of pauses.
+var autoIdle struct {
+	sync.Mutex
+	advice []idleAdvice
+	at     time.Time
+}
+
+// idleThresholdAt is the idle time in seconds after which the screen counts
+// as locked at now.
+func idleThresholdAt(now time.Time) int {
+	if !idleAuto {
+		return idleTreshold
+	}
+	autoIdle.Lock()
+	defer autoIdle.Unlock()
+	if autoIdle.advice == nil || now.Sub(autoIdle.at) > 6*time.Hour {
+		pauses, err := readIdlePauses(now.AddDate(0, 0, -idleCalibrationDays))
+		if err != nil {
+			fmt.Printf("%s Could not read idle pauses: %v\n", glyphs.warn, err)
+		}
+		autoIdle.advice, autoIdle.at = adviseIdle(pauses), now
+	}
+	if t := autoIdle.advice[idleBand(now)].threshold; t > 0 {
+		return t
+	}
+	return idleTreshold
+}
+
+// runIdle handles "idle [recommend] [-days 14]": the pauses recorded while
+// calibrating and a suggested IDLE_TIME per time of day.
+func runIdle(args []string) error {
+	if len(args) > 0 && args[0] == "recommend" {
+		args = args[1:]
+	}
+	fs := flag.NewFlagSet("idle", flag.ExitOnError)
+	days := fs.Int("days", idleCalibrationDays, "days of pauses to analyze")
+	fs.Parse(args)
+
+	pauses, err := readIdlePauses(time.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		return err
+	}
+	if len(pauses) == 0 {
+		fmt.Println("No idle pauses recorded. Set IDLE_CALIBRATE=true (or IDLE_TIME=auto) and let the tracker run for a couple of weeks.")
+		return nil
+	}
+	current := idleTreshold
+	fmt.Printf("Idle pauses over the last %d days (IDLE_TIME %ds)\n", *days, current)
+	fmt.Printf("%-16s %6s %7s %9s  %s\n", "", "Pauses", "Median", "Suggested", "Effect")
+	for b, a := range adviseIdle(pauses) {
+		band := idleBands[b]
+		label := fmt.Sprintf("%s %02d-%02d", band.name, band.from, band.to)
+		if a.pauses == 0 {
+			continue
+		}
+		if a.threshold == 0 {
+			fmt.Printf("%-16s %6d %6ds %9s  %s\n", label, a.pauses, a.median, "-", fmt.Sprintf("needs %d pauses", minCalibrationPauses))
+			continue
+		}
+		// Pauses between the two thresholds change sides
+		changed := 0
+		for _, p := range pauses {
+			if idleBand(p.Start.Local()) == b && (p.Seconds > current) != (p.Seconds > a.threshold) {
+				changed++
+			}
+		}
+		effect := "no change"
+		switch {
+		case a.threshold > current:
+			effect = fmt.Sprintf("%d pauses count as reading instead of away", changed)
+		case a.threshold < current:
+			effect = fmt.Sprintf("%d pauses count as away instead of reading", changed)
+		}
+		fmt.Printf("%-16s %6d %6ds %8ds  %s\n", label, a.pauses, a.median, a.threshold, effect)
+	}
+	if !idleAuto {
+		fmt.Println("\nSet IDLE_TIME=auto to apply the suggestions per time of day, or pick one value for IDLE_TIME.")
+	}
+	return nil
+}
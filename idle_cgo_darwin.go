@@ -0,0 +1,26 @@
+//go:build darwin && cgo
+
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics
+#include <CoreGraphics/CoreGraphics.h>
+
+static double secondsSinceInput(void) {
+	return CGEventSourceSecondsSinceLastEventType(kCGEventSourceStateCombinedSessionState, kCGAnyInputEventType);
+}
+*/
+import "C"
+
+// idleBackend names where idle time comes from, for the startup line and diag.
+const idleBackend = "CoreGraphics"
+
+// nativeIdleSeconds asks CoreGraphics for the time since the last keyboard,
+// mouse or trackpad event, without starting a process.
+func nativeIdleSeconds() (int, bool) {
+	s := float64(C.secondsSinceInput())
+	if s < 0 {
+		return 0, false
+	}
+	return int(s), true
+}
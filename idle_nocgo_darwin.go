@@ -0,0 +1,12 @@
+//go:build darwin && !cgo
+
+package main
+
+// idleBackend names where idle time comes from, for the startup line and diag.
+const idleBackend = "ioreg"
+
+// nativeIdleSeconds is unavailable without cgo (CGO_ENABLED=0 or a
+// cross-compiled binary); idle time falls back to ioreg.
+func nativeIdleSeconds() (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Focus time goes to InfluxDB (v2 write API) as two measurements:
+//
+//	focus_span,app=Xcode,bucket=work,project=Side seconds=1520i,title="main.go" <start>
+//	focus_hour,app=Xcode,bucket=work seconds=2400i <hour start>
+//
+// Points are keyed by their timestamp and tags, so writing a day again
+// overwrites instead of duplicating.
+
+// syncInflux writes a day's spans and hourly totals.
+func syncInflux(day time.Time, spans []Span) error {
+	spans = withContexts(spans) // fills in projects of older spans
+	lines := influxSpanLines(spans)
+	lines = append(lines, influxHourLines(spans, day, day.AddDate(0, 0, 1))...)
+	if err := influxWrite(lines); err != nil {
+		return err
+	}
+	fmt.Printf("%s Wrote %d points for %s to InfluxDB bucket %s\n", glyphs.ok, len(lines), day.Format("2006-01-02"), influxBucket)
+	return nil
+}
+
+// influxLastHour is the last hour whose totals the tracker has written.
+var influxLastHour time.Time
+
+// influxRecord is the tracker's live sink: it writes each span as it is
+// recorded and, once an hour is over, that hour's totals. Writes happen in
+// the background; failed ones go to the outbox.
+func influxRecord(span Span) {
+	lines := influxSpanLines([]Span{span})
+	hour := span.End.Truncate(time.Hour)
+	if !influxLastHour.IsZero() && hour.After(influxLastHour) {
+		from := influxLastHour
+		day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+		if spans, err := readSpans(day); err == nil {
+			lines = append(lines, influxHourLines(spans, from, hour)...)
+		}
+	}
+	if influxLastHour.IsZero() || hour.After(influxLastHour) {
+		influxLastHour = hour
+	}
+	go func() {
+		err := influxWrite(lines)
+		if err == nil {
+			return
+		}
+		if retryable(err) && enqueue(outboxInflux, lines, err) == nil {
+			fmt.Printf("%s Could not write to InfluxDB, will retry: %v\n", glyphs.warn, err)
+			return
+		}
+		fmt.Printf("%s Could not write to InfluxDB: %v\n", glyphs.warn, err)
+	}()
+}
+
+func influxSpanLines(spans []Span) []string {
+	var lines []string
+	for _, s := range spans {
+		if s.Duration() <= 0 {
+			continue
+		}
+		line := "focus_span," + influxTags(s) +
+			fmt.Sprintf(" seconds=%di", int64(s.Duration().Seconds()))
+		if s.Title != "" {
+			line += ",title=" + influxString(s.Title)
+		}
+		lines = append(lines, line+fmt.Sprintf(" %d", s.Start.UnixNano()))
+	}
+	return lines
+}
+
+// influxHourLines totals the spans per hour, app and bucket between from
+// and to (whole hours). Coarse spans have no hour and are left out.
+func influxHourLines(spans []Span, from, to time.Time) []string {
+	var lines []string
+	for hour := from.Truncate(time.Hour); hour.Before(to); hour = hour.Add(time.Hour) {
+		totals := make(map[string]time.Duration)
+		for _, s := range spans {
+			if s.Coarse {
+				continue
+			}
+			if d := overlap(s.Start, s.End, hour, hour.Add(time.Hour)); d > 0 {
+				totals[influxTags(Span{App: s.App, Work: s.Work, Bucket: s.Bucket})] += d
+			}
+		}
+		for _, tags := range sortedKeys(totals) {
+			lines = append(lines, fmt.Sprintf("focus_hour,%s seconds=%di %d", tags, int64(totals[tags].Seconds()), hour.UnixNano()))
+		}
+	}
+	return lines
+}
+
+// influxTags renders the span's app, bucket and project, sorted by key as
+// InfluxDB prefers.
+func influxTags(s Span) string {
+	tags := map[string]string{"app": s.App, "bucket": strings.TrimPrefix(s.Suffix(), "_")}
+	if tags["bucket"] == "" {
+		tags["bucket"] = "work"
+	}
+	if s.Project != "" {
+		tags["project"] = s.Project
+	}
+	keys := sortedKeys(tags)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v := tags[k]; v != "" {
+			parts = append(parts, k+"="+influxEscape(v))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// influxEscape escapes a tag value: commas, equal signs and spaces.
+func influxEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `, "\n", " ").Replace(s)
+}
+
+// influxString quotes a string field value.
+func influxString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
+
+func influxWrite(lines []string) error {
+	if influxURL == "" || influxBucket == "" {
+		return errors.New("INFLUX_URL and INFLUX_BUCKET must be set")
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	q := url.Values{"bucket": {influxBucket}, "precision": {"ns"}}
+	if influxOrg != "" {
+		q.Set("org", influxOrg)
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(influxURL, "/")+"/api/v2/write?"+q.Encode(),
+		strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	if influxToken != "" {
+		req.Header.Set("Authorization", "Token "+influxToken)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return newStatusError(resp.StatusCode, "InfluxDB write: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
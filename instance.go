@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errLocked is returned by openLocked when another process holds the lock.
+var errLocked = errors.New("locked by another process")
+
+func trackerLockPath() string {
+	return filepath.Join(logs, "tracker.lock")
+}
+
+// trackerLock stays open while the tracker runs. The system drops the lock
+// when the process ends, even when it crashes, so it never goes stale.
+var trackerLock *os.File
+
+// lockTracker makes sure only one tracker records to LOG_PATH, since two
+// would both write the day's journal and summaries and count every second
+// twice. The lock file holds the pid of the tracker holding it.
+func lockTracker() error {
+	f, err := openLocked(trackerLockPath())
+	if errors.Is(err, errLocked) {
+		holder := "another tracker"
+		if data, err := os.ReadFile(trackerLockPath()); err == nil {
+			if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+				holder = fmt.Sprintf("another tracker (pid %d)", pid)
+			}
+		}
+		msg := fmt.Sprintf("%s is already recording to %s; stop it first", holder, logs)
+		if controlSocket != "" {
+			msg += ", or send it commands with \"focus-tracker ctl\""
+		}
+		return errors.New(msg)
+	}
+	if err != nil {
+		// Tracking goes on without the lock, as it does when the journal
+		// cannot be written
+		fmt.Printf("%s Could not lock %s, so a second tracker would not be stopped: %v\n", glyphs.warn, trackerLockPath(), err)
+		return nil
+	}
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	trackerLock = f
+	return nil
+}
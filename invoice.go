@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// invoiceRecord is one issued invoice in LOG_PATH/invoices/invoices.jsonl,
+// used for numbering: regenerating a client's invoice for the same month
+// keeps its number.
+type invoiceRecord struct {
+	Number   string    `json:"number"`
+	Client   string    `json:"client"`
+	Period   string    `json:"period"` // YYYY-MM
+	Issued   time.Time `json:"issued"`
+	Total    int64     `json:"total_cents"`
+	Currency string    `json:"currency"`
+	File     string    `json:"file"`
+}
+
+// invoiceData is what the invoice template renders.
+type invoiceData struct {
+	Number      string
+	Issued, Due time.Time
+	PeriodStart time.Time
+	PeriodEnd   time.Time // last day of the period
+	From        []string
+	Payment     []string
+	To          invoiceParty
+	Lines       []invoiceLine
+	Subtotal    string
+	TaxRate     float64
+	Tax         string
+	Total       string
+	Currency    string
+	Conversion  string // e.g. "1 EUR = 1.0870 USD" when converted
+}
+
+type invoiceParty struct {
+	Name    string
+	Address []string
+	Email   string
+}
+
+type invoiceLine struct {
+	Description string
+	Hours       string
+	Rate        string
+	Amount      string
+}
+
+func invoicesDir() string {
+	return filepath.Join(logs, "invoices")
+}
+
+func invoiceLedgerPath() string {
+	return filepath.Join(invoicesDir(), "invoices.jsonl")
+}
+
+// runInvoice handles "invoice -client NAME [-month YYYY-MM]": an HTML or
+// PDF invoice of the client's billable hours in the month.
+func runInvoice(args []string) error {
+	fs := flag.NewFlagSet("invoice", flag.ExitOnError)
+	clientName := fs.String("client", "", "client to invoice, a [client] section of the rules file")
+	monthStr := fs.String("month", "", "month to invoice (YYYY-MM, default last month)")
+	format := fs.String("format", "html", "html (printed from a browser, laid out by INVOICE_TEMPLATE) or pdf")
+	out := fs.String("o", "", "output file (default LOG_PATH/invoices/NUMBER.html or .pdf)")
+	number := fs.String("number", "", "invoice number (default the next in INVOICE_NUMBER_FORMAT)")
+	dateStr := fs.String("date", "", "issue date (YYYY-MM-DD, default today)")
+	currency := fs.String("currency", "", "currency to bill in, converting with EXCHANGE_RATES (default the client's)")
+	fs.Parse(args)
+	if *format != "html" && *format != "pdf" {
+		return fmt.Errorf("unknown format %q, expected html or pdf", *format)
+	}
+
+	c, ok := lookupClient(*clientName)
+	if !ok {
+		return fmt.Errorf("unknown client %q; clients are [client \"Name\"] sections of the rules file", *clientName)
+	}
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.Local)
+	if *monthStr != "" {
+		var err error
+		if start, err = time.ParseInLocation("2006-01", *monthStr, time.Local); err != nil {
+			return fmt.Errorf("invalid month %q, expected YYYY-MM", *monthStr)
+		}
+	}
+	issued, err := parseDay(*dateStr)
+	if err != nil {
+		return err
+	}
+	end := start.AddDate(0, 1, 0)
+	billIn := c.currencyCode()
+	if *currency != "" {
+		if billIn, err = parseCurrency(*currency); err != nil {
+			return err
+		}
+	}
+	lines, err := clientBill(c, start, end, billIn)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("nothing to bill %s in %s; projects are billed when their section has client = %s", c.name, start.Format("2006-01"), c.name)
+	}
+
+	data := invoiceData{
+		Issued:      issued,
+		Due:         issued.AddDate(0, 0, invoiceDueDays),
+		PeriodStart: start,
+		PeriodEnd:   end.AddDate(0, 0, -1),
+		From:        splitLines(invoiceFrom),
+		Payment:     splitLines(invoicePayment),
+		To:          invoiceParty{Name: c.billingName, Address: c.address, Email: c.email},
+		TaxRate:     c.tax,
+		Currency:    billIn,
+	}
+	if billIn != c.currencyCode() {
+		conversion, _ := exchangeRate(c.currencyCode(), billIn)
+		data.Conversion = fmt.Sprintf("1 %s = %.4f %s", c.currencyCode(), conversion, billIn)
+	}
+	var subtotal, taxable int64
+	for _, l := range lines {
+		line := invoiceLine{Description: l.description, Amount: formatMoney(l.amount)}
+		if l.kind == "time" {
+			line.Hours = strconv.FormatFloat(l.hours.Hours(), 'f', 2, 64)
+			line.Rate = formatMoney(l.rate)
+		}
+		data.Lines = append(data.Lines, line)
+		subtotal += l.amount
+		if l.kind != chargeExpense {
+			taxable += l.amount
+		}
+	}
+	tax := int64(float64(taxable)*c.tax/100 + 0.5)
+	data.Subtotal, data.Tax, data.Total = formatMoney(subtotal), formatMoney(tax), formatMoney(subtotal+tax)
+
+	ledger, err := readInvoiceLedger()
+	if err != nil {
+		return err
+	}
+	period := start.Format("2006-01")
+	data.Number = *number
+	if data.Number == "" {
+		data.Number = nextInvoiceNumber(ledger, c.name, period, issued)
+	}
+
+	write := writeInvoicePDF
+	if *format == "html" {
+		tmpl, err := invoiceTemplate()
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer, data invoiceData) error { return tmpl.Execute(w, data) }
+	}
+	path := *out
+	if path == "" {
+		if err := os.MkdirAll(invoicesDir(), 0755); err != nil {
+			return err
+		}
+		path = filepath.Join(invoicesDir(), strings.ReplaceAll(data.Number, "/", "-")+"."+*format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	err = recordInvoice(ledger, invoiceRecord{
+		Number: data.Number, Client: c.name, Period: period, Issued: issued,
+		Total: subtotal + tax, Currency: data.Currency, File: path,
+	})
+	if err != nil {
+		return err
+	}
+	audit("invoice", "%s for %s, %s: %s %s", data.Number, c.name, period, data.Total, data.Currency)
+	fmt.Printf("%s Invoice %s for %s: %s %s, written to %s\n", glyphs.ok, data.Number, c.billingName, data.Total, data.Currency, path)
+	return nil
+}
+
+// nextInvoiceNumber reuses the number of an earlier invoice for the same
+// client and period, or formats the next in the issue year's sequence.
+func nextInvoiceNumber(ledger []invoiceRecord, clientName, period string, issued time.Time) string {
+	seq := 1
+	for _, r := range ledger {
+		if c, ok := lookupClient(r.Client); ok && c.name == clientName && r.Period == period {
+			return r.Number
+		}
+		if r.Issued.Year() == issued.Year() {
+			seq++
+		}
+	}
+	return strings.NewReplacer(
+		"{year}", issued.Format("2006"),
+		"{month}", issued.Format("01"),
+		"{seq}", fmt.Sprintf("%03d", seq),
+	).Replace(invoiceNumberFormat)
+}
+
+func readInvoiceLedger() ([]invoiceRecord, error) {
+	f, err := os.Open(invoiceLedgerPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ledger []invoiceRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r invoiceRecord
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			ledger = append(ledger, r)
+		}
+	}
+	return ledger, scanner.Err()
+}
+
+// recordInvoice adds the invoice to the ledger, replacing an earlier
+// version with the same number.
+func recordInvoice(ledger []invoiceRecord, r invoiceRecord) error {
+	kept := []invoiceRecord{r}
+	for _, old := range ledger {
+		if old.Number != r.Number {
+			kept = append(kept, old)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Issued.Before(kept[j].Issued) })
+	if err := os.MkdirAll(invoicesDir(), 0755); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, rec := range kept {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	tmp := invoiceLedgerPath() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, invoiceLedgerPath())
+}
+
+// splitLines splits a setting like "Jane Doe; 1 Main St; Springfield".
+func splitLines(v string) []string {
+	var lines []string
+	for _, line := range strings.Split(v, ";") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+var invoiceFuncs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("January 2, 2006") },
+}
+
+// invoiceTemplate is INVOICE_TEMPLATE if set, or the built-in layout.
+func invoiceTemplate() (*template.Template, error) {
+	if invoiceTemplatePath == "" {
+		return template.New("invoice").Funcs(invoiceFuncs).Parse(defaultInvoiceTemplate)
+	}
+	data, err := os.ReadFile(invoiceTemplatePath)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(invoiceTemplatePath)).Funcs(invoiceFuncs).Parse(string(data))
+	if err != nil {
+		return nil, errors.New("INVOICE_TEMPLATE: " + err.Error())
+	}
+	return tmpl, nil
+}
+
+const defaultInvoiceTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font: 14px/1.5 -apple-system, "Helvetica Neue", sans-serif; color: #222; max-width: 760px; margin: 40px auto; padding: 0 20px; }
+h1 { font-size: 28px; margin: 0 0 4px; }
+.parties { display: flex; justify-content: space-between; margin: 32px 0; }
+.parties div { white-space: pre-line; }
+.label { color: #777; font-size: 12px; text-transform: uppercase; letter-spacing: .05em; }
+table { width: 100%; border-collapse: collapse; margin-top: 16px; }
+th, td { padding: 8px 6px; border-bottom: 1px solid #ddd; text-align: left; }
+th.num, td.num { text-align: right; }
+tfoot td { border: none; }
+tfoot tr.total td { font-weight: bold; font-size: 16px; border-top: 2px solid #222; }
+.payment { margin-top: 40px; white-space: pre-line; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>Invoice</h1>
+<div>No. {{.Number}} &middot; issued {{date .Issued}} &middot; due {{date .Due}}</div>
+<div class="parties">
+<div><span class="label">From</span>
+{{range .From}}{{.}}
+{{end}}</div>
+<div><span class="label">Bill to</span>
+{{.To.Name}}
+{{range .To.Address}}{{.}}
+{{end}}{{with .To.Email}}{{.}}{{end}}</div>
+</div>
+<div>Services {{date .PeriodStart}} &ndash; {{date .PeriodEnd}}</div>
+<table>
+<thead><tr><th>Description</th><th class="num">Hours</th><th class="num">Rate</th><th class="num">Amount ({{.Currency}})</th></tr></thead>
+<tbody>
+{{range .Lines}}<tr><td>{{.Description}}</td><td class="num">{{.Hours}}</td><td class="num">{{.Rate}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}</tbody>
+<tfoot>
+{{if .TaxRate}}<tr><td colspan="3" class="num">Subtotal</td><td class="num">{{.Subtotal}}</td></tr>
+<tr><td colspan="3" class="num">Tax {{.TaxRate}}%</td><td class="num">{{.Tax}}</td></tr>
+{{end}}<tr class="total"><td colspan="3" class="num">Total</td><td class="num">{{.Total}} {{.Currency}}</td></tr>
+</tfoot>
+</table>
+{{with .Conversion}}<p>Rates converted at {{.}}.</p>
+{{end}}{{with .Payment}}<div class="payment"><span class="label">Payment</span>
+{{range .}}{{.}}
+{{end}}</div>{{end}}
+</body>
+</html>
+`
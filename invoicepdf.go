@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// writeInvoicePDF lays out an invoice like the built-in HTML template, for
+// "invoice -format pdf": the number and dates, both parties, one row per
+// line with the totals below, and the payment details.
+func writeInvoicePDF(w io.Writer, data invoiceData) error {
+	const (
+		left  = pdfMargin
+		right = pdfPageWidth - pdfMargin
+		half  = (right - left) / 2
+	)
+	date := invoiceFuncs["date"].(func(time.Time) string)
+	var d pdfDoc
+	d.text(left, d.next(24), 24, true, "Invoice")
+	d.text(left, d.next(16), 10, false, fmt.Sprintf("No. %s · issued %s · due %s", data.Number, date(data.Issued), date(data.Due)))
+
+	// From on the left, Bill to on the right, line by line
+	to := append([]string{data.To.Name}, data.To.Address...)
+	if data.To.Email != "" {
+		to = append(to, data.To.Email)
+	}
+	d.next(16)
+	y := d.next(14)
+	d.text(left, y, 8, true, "FROM")
+	d.text(left+half, y, 8, true, "BILL TO")
+	for i := 0; i < len(data.From) || i < len(to); i++ {
+		y := d.next(13)
+		if i < len(data.From) {
+			d.text(left, y, 10, false, pdfFit(data.From[i], 10, half-10))
+		}
+		if i < len(to) {
+			d.text(left+half, y, 10, false, pdfFit(to[i], 10, half))
+		}
+	}
+
+	d.next(16)
+	d.text(left, d.next(14), 10, false, fmt.Sprintf("Services %s – %s", date(data.PeriodStart), date(data.PeriodEnd)))
+	y = d.next(20)
+	d.text(left, y, 9, true, "Description")
+	d.textRight(left+300, y, 9, true, "Hours")
+	d.textRight(left+380, y, 9, true, "Rate")
+	d.textRight(right, y, 9, true, "Amount ("+data.Currency+")")
+	d.line(left, y-4, right, y-4)
+	for _, l := range data.Lines {
+		y := d.next(16)
+		d.text(left, y, 9, false, pdfFit(l.Description, 9, 240))
+		d.textRight(left+300, y, 9, false, l.Hours)
+		d.textRight(left+380, y, 9, false, l.Rate)
+		d.textRight(right, y, 9, false, l.Amount)
+		d.line(left, y-5, right, y-5)
+	}
+	if data.TaxRate != 0 {
+		y := d.next(18)
+		d.textRight(left+380, y, 9, false, "Subtotal")
+		d.textRight(right, y, 9, false, data.Subtotal)
+		y = d.next(14)
+		d.textRight(left+380, y, 9, false, fmt.Sprintf("Tax %g%%", data.TaxRate))
+		d.textRight(right, y, 9, false, data.Tax)
+	}
+	y = d.next(20)
+	d.line(left+250, y+12, right, y+12)
+	d.textRight(left+380, y, 11, true, "Total")
+	d.textRight(right, y, 11, true, data.Total+" "+data.Currency)
+
+	if data.Conversion != "" {
+		d.next(10)
+		d.text(left, d.next(14), 9, false, "Rates converted at "+data.Conversion+".")
+	}
+	if len(data.Payment) > 0 {
+		d.next(24)
+		d.text(left, d.next(14), 8, true, "PAYMENT")
+		for _, line := range data.Payment {
+			d.text(left, d.next(13), 10, false, pdfFit(line, 10, right-left))
+		}
+	}
+	_, err := d.WriteTo(w)
+	return err
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tempoAPI = "https://api.tempo.io/4"
+
+// Spans are attributed to the Jira issue whose key (ABC-123) is in the
+// browser's URL or, failing that, the window title, where editors and
+// terminals show the branch name. "sync jira" posts the day's time on each
+// issue as one worklog, to Jira or, with TEMPO_TOKEN, to Tempo. Which
+// worklog was posted for which issue and day is kept in worklogs.json next
+// to the logs, so syncing a day again updates those worklogs instead of
+// adding more.
+
+// issuePattern matches issue keys: those of JIRA_PROJECTS in any case, or
+// else anything shaped like one that is not in notIssueKeys.
+var issuePattern = regexp.MustCompile(`\b[A-Z][A-Z0-9_]+-[1-9][0-9]*\b`)
+
+// notIssueKeys are prefixes of names like UTF-8 and SHA-256 that look like
+// issue keys.
+var notIssueKeys = map[string]bool{
+	"AES": true, "CVE": true, "COVID": true, "GPT": true, "HTTP": true, "ISO": true,
+	"PEP": true, "RFC": true, "RSA": true, "SHA": true, "SSL": true, "TLS": true, "UTF": true,
+}
+
+func parseJiraProjects(v string) error {
+	var keys []string
+	for _, k := range strings.Split(v, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, regexp.QuoteMeta(k))
+		}
+	}
+	jiraProjects = nil
+	if len(keys) > 0 {
+		jiraProjects = regexp.MustCompile(`(?i)\b(?:` + strings.Join(keys, "|") + `)-[1-9][0-9]*\b`)
+	}
+	return nil
+}
+
+// issueKey returns the first issue key in text, upper-cased, or "".
+func issueKey(text string) string {
+	if jiraProjects != nil {
+		return strings.ToUpper(jiraProjects.FindString(text))
+	}
+	for _, key := range issuePattern.FindAllString(text, -1) {
+		if !notIssueKeys[key[:strings.LastIndexByte(key, '-')]] {
+			return key
+		}
+	}
+	return ""
+}
+
+// urlIssue returns the issue key in the path or query of a web page URL,
+// such as .../browse/ABC-123 or ...?selectedIssue=ABC-123.
+func urlIssue(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	query, _ := url.QueryUnescape(u.RawQuery)
+	return issueKey(u.Path + " " + query)
+}
+
+// issueFor is the issue a span was about, from its URL or its title.
+func issueFor(s Span) string {
+	if s.Issue != "" || s.Away() || s.Bucket == "lunch" {
+		return s.Issue
+	}
+	return issueKey(s.Title)
+}
+
+// writeIssues is the report section with time per Jira issue.
+func writeIssues(w io.Writer, spans []Span) {
+	totals := make(map[string]time.Duration)
+	for _, s := range spans {
+		if s.Issue != "" && !s.Away() {
+			totals[s.Issue] += s.Duration()
+		}
+	}
+	if len(totals) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nIssues\n")
+	for i, issue := range sortedByDuration(totals) {
+		if i == 10 {
+			break
+		}
+		fmt.Fprintf(w, "  %s: %s\n", issue, shortDuration(totals[issue]))
+	}
+}
+
+// jiraWorklog is a day's time on an issue.
+type jiraWorklog struct {
+	issue   string
+	started time.Time // of the first span
+	time    time.Duration
+	apps    map[string]time.Duration
+}
+
+// seconds is the time to the minute, the finest Jira shows.
+func (l *jiraWorklog) seconds() int {
+	return int(l.time.Round(time.Minute).Seconds())
+}
+
+// comment lists the apps the time was spent in, largest first.
+func (l *jiraWorklog) comment() string {
+	var parts []string
+	for _, app := range sortedByDuration(l.apps) {
+		parts = append(parts, app+" "+shortDuration(l.apps[app]))
+	}
+	return "Tracked in " + strings.Join(parts, ", ")
+}
+
+// jiraWorklogs totals the spans per issue, leaving out issues with less
+// than JIRA_MIN_WORKLOG.
+func jiraWorklogs(spans []Span) []*jiraWorklog {
+	byIssue := make(map[string]*jiraWorklog)
+	for _, s := range withContexts(spans) {
+		if s.Issue == "" || s.Away() || s.Bucket == "lunch" {
+			continue
+		}
+		l := byIssue[s.Issue]
+		if l == nil {
+			l = &jiraWorklog{issue: s.Issue, started: s.Start, apps: make(map[string]time.Duration)}
+			byIssue[s.Issue] = l
+		}
+		l.time += s.Duration()
+		l.apps[s.App] += s.Duration()
+	}
+	var worklogs []*jiraWorklog
+	for _, issue := range sortedKeys(byIssue) {
+		if l := byIssue[issue]; l.time >= jiraMinWorklog {
+			worklogs = append(worklogs, l)
+		}
+	}
+	return worklogs
+}
+
+// worklogRecord is a worklog posted for an issue and day.
+type worklogRecord struct {
+	ID      string `json:"id"`
+	Seconds int    `json:"seconds"`
+}
+
+func worklogLedgerPath() string {
+	return filepath.Join(logs, "worklogs.json")
+}
+
+// readWorklogLedger returns the posted worklogs by "service day issue".
+func readWorklogLedger() (map[string]worklogRecord, error) {
+	ledger := make(map[string]worklogRecord)
+	data, err := os.ReadFile(worklogLedgerPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &ledger); err != nil {
+		return nil, fmt.Errorf("%s: %w", worklogLedgerPath(), err)
+	}
+	return ledger, nil
+}
+
+func writeWorklogLedger(ledger map[string]worklogRecord) error {
+	data, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := worklogLedgerPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, worklogLedgerPath())
+}
+
+// syncJira makes the day's worklogs match its time per issue: new issues
+// get a worklog, changed ones are updated and those no longer tracked are
+// deleted. With -dry-run it lists what it would change instead.
+func syncJira(day time.Time, spans []Span) error {
+	if jiraURL == "" || jiraToken == "" {
+		return errors.New("JIRA_URL and JIRA_TOKEN must be set")
+	}
+	service := "Jira"
+	if tempoToken != "" {
+		service = "Tempo"
+	}
+	ledger, err := readWorklogLedger()
+	if err != nil {
+		return err
+	}
+	date := day.Format("2006-01-02")
+	prefix := strings.ToLower(service) + " " + date + " "
+	stale := make(map[string]bool)
+	for key := range ledger {
+		if strings.HasPrefix(key, prefix) {
+			stale[strings.TrimPrefix(key, prefix)] = true
+		}
+	}
+
+	if syncDryRun {
+		fmt.Printf("%s worklogs for %s:\n", service, date)
+	}
+	var created, updated, deleted int
+	var missing []string
+	for _, l := range jiraWorklogs(spans) {
+		delete(stale, l.issue)
+		rec, found := ledger[prefix+l.issue]
+		if found && rec.Seconds == l.seconds() {
+			if syncDryRun {
+				fmt.Printf("  %-12s %7s  unchanged\n", l.issue, shortDuration(l.time))
+			}
+			continue
+		}
+		if syncDryRun {
+			action := "create"
+			if found {
+				action = "update from " + shortDuration(time.Duration(rec.Seconds)*time.Second)
+			}
+			fmt.Printf("  %-12s %7s  %s\n", l.issue, shortDuration(l.time), action)
+		} else {
+			id, err := postWorklog(l, rec.ID)
+			var status *statusError
+			if errors.As(err, &status) && status.code == http.StatusNotFound {
+				missing = append(missing, l.issue)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", strings.ToLower(service), l.issue, err)
+			}
+			ledger[prefix+l.issue] = worklogRecord{ID: id, Seconds: l.seconds()}
+			if err := writeWorklogLedger(ledger); err != nil {
+				return err
+			}
+		}
+		if found {
+			updated++
+		} else {
+			created++
+		}
+	}
+	for _, issue := range sortedKeys(stale) {
+		if syncDryRun {
+			fmt.Printf("  %-12s %7s  delete, no longer tracked\n", issue, shortDuration(time.Duration(ledger[prefix+issue].Seconds)*time.Second))
+		} else {
+			err := deleteWorklog(issue, ledger[prefix+issue].ID)
+			var status *statusError
+			if err != nil && !(errors.As(err, &status) && status.code == http.StatusNotFound) {
+				return fmt.Errorf("%s: %s: %w", strings.ToLower(service), issue, err)
+			}
+			delete(ledger, prefix+issue)
+			if err := writeWorklogLedger(ledger); err != nil {
+				return err
+			}
+		}
+		deleted++
+	}
+
+	for _, issue := range missing {
+		fmt.Printf("%s %s: no issue %s, or you cannot see it, so its time was left out\n", glyphs.warn, service, issue)
+	}
+	verb := ""
+	if syncDryRun {
+		verb = "would be "
+	}
+	fmt.Printf("%s %s: %d worklogs %screated, %d %supdated and %d %sdeleted for %s\n", glyphs.ok, service, created, verb, updated, verb, deleted, verb, date)
+	return nil
+}
+
+// postWorklog creates the worklog, or updates the one with the given ID,
+// and returns its ID.
+func postWorklog(l *jiraWorklog, id string) (string, error) {
+	if tempoToken != "" {
+		return postTempoWorklog(l, id)
+	}
+	body := map[string]any{
+		"started":          l.started.Format("2006-01-02T15:04:05.000-0700"),
+		"timeSpentSeconds": l.seconds(),
+		"comment":          l.comment(),
+	}
+	path := "/rest/api/2/issue/" + l.issue + "/worklog"
+	method := http.MethodPost
+	if id != "" {
+		path, method = path+"/"+id, http.MethodPut
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	err := jiraRequest(method, path, body, &out)
+	var status *statusError
+	if id != "" && errors.As(err, &status) && status.code == http.StatusNotFound {
+		// The worklog was deleted in Jira; post it again
+		return postWorklog(l, "")
+	}
+	return out.ID, err
+}
+
+func postTempoWorklog(l *jiraWorklog, id string) (string, error) {
+	issueID, err := jiraIssueID(l.issue)
+	if err != nil {
+		return "", err
+	}
+	accountID, err := jiraAccountID()
+	if err != nil {
+		return "", err
+	}
+	body := map[string]any{
+		"issueId":          issueID,
+		"authorAccountId":  accountID,
+		"startDate":        l.started.Format("2006-01-02"),
+		"startTime":        l.started.Format("15:04:05"),
+		"timeSpentSeconds": l.seconds(),
+		"description":      l.comment(),
+	}
+	path, method := "/worklogs", http.MethodPost
+	if id != "" {
+		path, method = path+"/"+id, http.MethodPut
+	}
+	var out struct {
+		ID int64 `json:"tempoWorklogId"`
+	}
+	err = tempoRequest(method, path, body, &out)
+	var status *statusError
+	if id != "" && errors.As(err, &status) && status.code == http.StatusNotFound {
+		return postTempoWorklog(l, "")
+	}
+	return strconv.FormatInt(out.ID, 10), err
+}
+
+func deleteWorklog(issue, id string) error {
+	if tempoToken != "" {
+		return tempoRequest(http.MethodDelete, "/worklogs/"+id, nil, nil)
+	}
+	return jiraRequest(http.MethodDelete, "/rest/api/2/issue/"+issue+"/worklog/"+id, nil, nil)
+}
+
+// jiraIssueID is the numeric ID of an issue, which Tempo takes instead of
+// its key.
+func jiraIssueID(key string) (int64, error) {
+	var issue struct {
+		ID string `json:"id"`
+	}
+	if err := jiraRequest(http.MethodGet, "/rest/api/2/issue/"+key+"?fields=summary", nil, &issue); err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(issue.ID, 10, 64)
+}
+
+var jiraAccount string
+
+// jiraAccountID is your Atlassian account ID, the author of Tempo worklogs.
+func jiraAccountID() (string, error) {
+	if jiraAccount == "" {
+		var me struct {
+			AccountID string `json:"accountId"`
+		}
+		if err := jiraRequest(http.MethodGet, "/rest/api/2/myself", nil, &me); err != nil {
+			return "", err
+		}
+		jiraAccount = me.AccountID
+	}
+	return jiraAccount, nil
+}
+
+// jiraRequest calls Jira, with JIRA_EMAIL and JIRA_TOKEN as the API token
+// of Jira Cloud or JIRA_TOKEN alone as a personal access token of Jira
+// Server.
+func jiraRequest(method, path string, body, out any) error {
+	return apiRequest(method, strings.TrimRight(jiraURL, "/")+path, func(req *http.Request) {
+		if jiraEmail != "" {
+			req.SetBasicAuth(jiraEmail, jiraToken)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+jiraToken)
+		}
+	}, body, out)
+}
+
+func tempoRequest(method, path string, body, out any) error {
+	return apiRequest(method, tempoAPI+path, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+tempoToken)
+	}, body, out)
+}
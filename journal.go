@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Span is one uninterrupted stretch of focus on a single window.
+type Span struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	App      string    `json:"app"`
+	BundleID string    `json:"bundle_id,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Work     bool      `json:"work"`
+	Bucket   string    `json:"bucket,omitempty"` // outside-hours sub-bucket, e.g. "evening"
+	Project  string    `json:"project,omitempty"`
+	Contexts []string  `json:"contexts,omitempty"`
+	// AppPath and AppVersion are where the app bundle lives and its version
+	AppPath    string `json:"app_path,omitempty"`
+	AppVersion string `json:"app_version,omitempty"`
+	// EditingSeconds is how much of the span the document was being edited
+	EditingSeconds int `json:"editing_seconds,omitempty"`
+	// Screenshots names the screenshots taken during the span, with SCREENSHOTS
+	Screenshots []string `json:"screenshots,omitempty"`
+	// Manual spans were entered by hand, e.g. to classify a gap at startup
+	Manual bool `json:"manual,omitempty"`
+	// Domain is the site open in the browser's active tab, with BROWSER_URLS
+	Domain string `json:"domain,omitempty"`
+	// Category is the first [category] rule the span matched
+	Category string `json:"category,omitempty"`
+	// NowPlaying is the track that played longest during the span, with NOW_PLAYING
+	NowPlaying string `json:"now_playing,omitempty"`
+	// Issue is the Jira issue key in the span's URL or title
+	Issue string `json:"issue,omitempty"`
+	// Coarse spans were merged by retention. They keep the day's totals, but
+	// their start and end are not times of day, so timelines and per-hour
+	// views skip them.
+	Coarse bool `json:"coarse,omitempty"`
+}
+
+func (s Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Spans are journaled per day (by start time) as JSON lines next to the summaries.
+func spansPath(day time.Time) string {
+	return filepath.Join(logs, fmt.Sprintf("focus_tracker_%s_spans.jsonl", day.Format("2006-01-02")))
+}
+
+func appendSpan(span Span) error {
+	return appendJSONLine(spansPath(span.Start), span)
+}
+
+// appendJSONLine appends v as a single JSON line to path.
+func appendJSONLine(path string, v any) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(v)
+}
+
+// readSpans returns the journaled spans for a day; a missing journal is not an error.
+func readSpans(day time.Time) ([]Span, error) {
+	f, err := os.Open(spansPath(day))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var spans []Span
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var s Span
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue // a torn last line after a crash
+		}
+		s.Project = canonicalProject(s.Project)
+		spans = append(spans, s)
+	}
+	return spans, scanner.Err()
+}
+
+// readSpanRange returns the journaled spans overlapping [from, to).
+func readSpanRange(from, to time.Time) ([]Span, error) {
+	var result []Span
+	// Start a day early for spans running past midnight into the range
+	day := time.Date(from.Year(), from.Month(), from.Day()-1, 0, 0, 0, 0, from.Location())
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		spans, err := readSpans(day)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range spans {
+			if s.End.After(from) && s.Start.Before(to) {
+				result = append(result, s)
+			}
+		}
+	}
+	return result, nil
+}
+
+// parseDay accepts YYYY-MM-DD, "today" or "yesterday"; empty means today.
+func parseDay(input string) (time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch input {
+	case "", "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", input, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid day %q, expected YYYY-MM-DD", input)
+	}
+	return day, nil
+}
+
+// totalsFromSpans rebuilds the per-bucket totals kept by the tracker loop.
+func totalsFromSpans(spans []Span) map[string]Totals {
+	buckets := make(map[string]Totals)
+	for _, s := range spans {
+		for _, suffix := range s.Suffixes() {
+			buckets[suffix] = buckets[suffix].add(s)
+		}
+	}
+	return buckets
+}
+
+// loadJournalTotals rebuilds today's buckets from the span journal, which
+// the summary files are derived from. It reports false when the journal has
+// nothing for today, e.g. for logs written before it existed.
+func loadJournalTotals(buckets map[string]Totals) (bool, error) {
+	spans, err := readSpans(time.Now())
+	if err != nil || len(spans) == 0 {
+		return false, err
+	}
+	for suffix, totals := range totalsFromSpans(spans) {
+		buckets[suffix] = totals
+	}
+	return true, nil
+}
+
+// The span in progress is checkpointed to LOG_PATH/open_span.json while it
+// runs, so a crash or kill loses at most checkpointEvery of it. At the next
+// start it is journaled, ending at its last checkpoint.
+const checkpointEvery = 15 * time.Second
+
+func openSpanPath() string {
+	return filepath.Join(logs, "open_span.json")
+}
+
+func checkpointSpan(span Span) error {
+	data, err := json.Marshal(span)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(openSpanPath(), string(data))
+}
+
+func clearCheckpoint() {
+	os.Remove(openSpanPath())
+}
+
+// recoverOpenSpan journals the span that was open when the tracker last
+// stopped, unless it was journaled already, and returns it.
+func recoverOpenSpan() (Span, bool, error) {
+	var span Span
+	data, err := os.ReadFile(openSpanPath())
+	if os.IsNotExist(err) {
+		return span, false, nil
+	} else if err != nil {
+		return span, false, err
+	}
+	defer clearCheckpoint()
+	if err := json.Unmarshal(data, &span); err != nil || span.Duration() <= 0 {
+		return span, false, nil
+	}
+	spans, err := readSpans(span.Start)
+	if err != nil {
+		return span, false, err
+	}
+	for _, s := range spans {
+		if s.Start.Equal(span.Start) && s.App == span.App {
+			return span, false, nil
+		}
+	}
+	span = classify(span)
+	return span, true, journalSpan(span)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// launchdLabel names the LaunchAgent of "install-daemon".
+const launchdLabel = "com.github.zoncen.focus-tracker"
+
+func launchAgentPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist")
+}
+
+// launchdDomain is the per-user GUI domain LaunchAgents run in.
+func launchdDomain() string {
+	return fmt.Sprintf("gui/%d", os.Getuid())
+}
+
+// runInstallDaemon writes a LaunchAgent that runs the tracker at login and
+// restarts it when it crashes, and loads it right away. On Linux it installs
+// the systemd user unit of "service install" instead.
+func runInstallDaemon(args []string) error {
+	if runtime.GOOS == "linux" {
+		return runService(append([]string{"install"}, args...))
+	}
+	fs := flag.NewFlagSet("install-daemon", flag.ExitOnError)
+	logPath := fs.String("log-path", "", "LOG_PATH for the daemon (default: the configured one, or ~/Library/Logs/focus-tracker)")
+	fs.Parse(args)
+	if runtime.GOOS != "darwin" {
+		return errors.New("install-daemon needs macOS launchd, or systemd on Linux")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	if *logPath == "" {
+		*logPath = logs
+		if configValues["LOG_PATH"].source == "default" {
+			home, _ := os.UserHomeDir()
+			*logPath = filepath.Join(home, "Library", "Logs", "focus-tracker")
+		}
+	}
+	if err := os.MkdirAll(*logPath, 0755); err != nil {
+		return err
+	}
+	env := map[string]string{"LOG_PATH": *logPath}
+	if path, ok := os.LookupEnv("CONFIG_PATH"); ok {
+		env["CONFIG_PATH"] = path
+	}
+
+	plist := launchAgentPlist(exe, *logPath, env)
+	if err := os.MkdirAll(filepath.Dir(launchAgentPath()), 0755); err != nil {
+		return err
+	}
+	// Replacing a loaded agent needs it unloaded first
+	exec.Command("launchctl", "bootout", launchdDomain()+"/"+launchdLabel).Run()
+	if err := os.WriteFile(launchAgentPath(), []byte(plist), 0644); err != nil {
+		return err
+	}
+	if out, err := exec.Command("launchctl", "bootstrap", launchdDomain(), launchAgentPath()).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl bootstrap: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	fmt.Printf("%s Installed %s and started the tracker.\n", glyphs.ok, launchAgentPath())
+	fmt.Printf("It starts at login and restarts after a crash. Logs: %s\n", *logPath)
+	if registered, err := loginItemRegistered(filepath.Base(exe)); err == nil && registered {
+		fmt.Printf("%s %s is also a login item; run \"login-item remove\" so it is not started twice.\n", glyphs.warn, filepath.Base(exe))
+	}
+	return nil
+}
+
+// runUninstallDaemon stops the tracker started by launchd and removes the
+// LaunchAgent. The logs stay.
+func runUninstallDaemon(args []string) error {
+	if len(args) > 0 {
+		return errors.New("usage: uninstall-daemon")
+	}
+	if runtime.GOOS == "linux" {
+		return runService([]string{"uninstall"})
+	}
+	if runtime.GOOS != "darwin" {
+		return errors.New("uninstall-daemon needs macOS launchd, or systemd on Linux")
+	}
+	if _, err := os.Stat(launchAgentPath()); os.IsNotExist(err) {
+		fmt.Println("No LaunchAgent is installed.")
+		return nil
+	}
+	exec.Command("launchctl", "bootout", launchdDomain()+"/"+launchdLabel).Run()
+	if err := os.Remove(launchAgentPath()); err != nil {
+		return err
+	}
+	fmt.Printf("%s Stopped the tracker and removed %s.\n", glyphs.ok, launchAgentPath())
+	return nil
+}
+
+// launchAgentPlist runs "track" at load, restarts it when it exits with an
+// error or is killed, and sends its output to LOG_PATH. A clean exit, e.g.
+// after SIGTERM, is not restarted.
+func launchAgentPlist(exe, logPath string, env map[string]string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", launchdLabel)
+	fmt.Fprintf(&b, "\t<key>ProgramArguments</key>\n\t<array>\n\t\t<string>%s</string>\n\t\t<string>track</string>\n\t</array>\n", plistEscape(exe))
+	b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+	for _, key := range sortedKeys(env) {
+		fmt.Fprintf(&b, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", key, plistEscape(env[key]))
+	}
+	b.WriteString("\t</dict>\n")
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	b.WriteString("\t<key>ThrottleInterval</key>\n\t<integer>10</integer>\n")
+	b.WriteString("\t<key>ProcessType</key>\n\t<string>Interactive</string>\n")
+	fmt.Fprintf(&b, "\t<key>StandardOutPath</key>\n\t<string>%s</string>\n", plistEscape(filepath.Join(logPath, "focus-tracker.out.log")))
+	fmt.Fprintf(&b, "\t<key>StandardErrorPath</key>\n\t<string>%s</string>\n", plistEscape(filepath.Join(logPath, "focus-tracker.err.log")))
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+func plistEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// usageLimit is a soft daily limit for an app or project, e.g. Slack=1h.
+type usageLimit struct {
+	name  string
+	limit time.Duration
+}
+
+// parseLimits reads "Slack=1h, Twitter=15m, Client A=4h".
+func parseLimits(input string) ([]usageLimit, error) {
+	var result []usageLimit
+	for _, part := range strings.Split(input, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid limit %q, expected NAME=DURATION like Slack=1h", strings.TrimSpace(part))
+		}
+		d, err := parsePositiveDuration(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, fmt.Errorf("limit for %s: %v", name, err)
+		}
+		result = append(result, usageLimit{name: name, limit: d})
+	}
+	return result, nil
+}
+
+// limitApplies reports whether the span counts toward the limit: a limit
+// names either an app or a project.
+func limitApplies(l usageLimit, s Span) bool {
+	if s.App == "" || s.Away() {
+		return false
+	}
+	return strings.EqualFold(l.name, s.App) || (s.Project != "" && strings.EqualFold(l.name, s.Project))
+}
+
+// limitRun is today's usage per limit as seen by the tracker.
+var limitRun struct {
+	day      string
+	used     map[string]time.Duration
+	notified map[string]bool
+}
+
+func resetLimits(day string) {
+	limitRun.day = day
+	limitRun.used = make(map[string]time.Duration)
+	limitRun.notified = make(map[string]bool)
+}
+
+// loadLimitUsage counts what today's journal already holds, so a restart
+// does not forget the time spent before it.
+func loadLimitUsage(now time.Time) {
+	resetLimits(now.Format("2006-01-02"))
+	spans, err := readSpans(now)
+	if err != nil {
+		return
+	}
+	for _, s := range spans {
+		countTowardLimits(s)
+	}
+	for _, l := range usageLimits {
+		limitRun.notified[l.name] = limitRun.used[l.name] > l.limit
+	}
+}
+
+// countTowardLimits adds a finished, classified span to today's usage.
+func countTowardLimits(s Span) {
+	if limitRun.day != s.Start.Format("2006-01-02") {
+		resetLimits(s.Start.Format("2006-01-02"))
+	}
+	for _, l := range usageLimits {
+		if limitApplies(l, s) {
+			limitRun.used[l.name] += s.Duration()
+		}
+	}
+}
+
+// limitTick notifies once per day and limit when the usage, including the
+// span still in progress, goes over the limit.
+func limitTick(now time.Time, current Span) {
+	if len(usageLimits) == 0 {
+		return
+	}
+	if limitRun.day != now.Format("2006-01-02") {
+		resetLimits(now.Format("2006-01-02"))
+	}
+	current.Project = projectFor(current)
+	for _, l := range usageLimits {
+		if limitRun.notified[l.name] {
+			continue
+		}
+		used := limitRun.used[l.name]
+		if limitApplies(l, current) {
+			used += current.Duration()
+		}
+		if used > l.limit {
+			limitRun.notified[l.name] = true
+			fmt.Printf("%s Daily limit for %s exceeded (%s of %s)\n", glyphs.warn, l.name, shortDuration(used), shortDuration(l.limit))
+			notify("Daily limit reached", fmt.Sprintf("%s: %s of %s today", l.name, shortDuration(used), shortDuration(l.limit)))
+		}
+	}
+}
+
+// trackedOverLimits lists the limits exceeded by today's finished spans.
+func trackedOverLimits() []string {
+	var lines []string
+	for _, l := range usageLimits {
+		if used := limitRun.used[l.name]; used > l.limit {
+			lines = append(lines, fmt.Sprintf("%s: %s of %s (%s over)", l.name, shortDuration(used), shortDuration(l.limit), shortDuration(used-l.limit)))
+		}
+	}
+	return lines
+}
+
+// overLimits lists the limits exceeded by the given day's spans.
+func overLimits(spans []Span) []string {
+	var lines []string
+	for _, l := range usageLimits {
+		var used time.Duration
+		for _, s := range spans {
+			if s.Project == "" {
+				s.Project = projectFor(s)
+			}
+			if limitApplies(l, s) {
+				used += s.Duration()
+			}
+		}
+		if used > l.limit {
+			lines = append(lines, fmt.Sprintf("%s: %s of %s (%s over)", l.name, shortDuration(used), shortDuration(l.limit), shortDuration(used-l.limit)))
+		}
+	}
+	return lines
+}
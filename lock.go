@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// periodLock protects the days From..To (inclusive) from changes, e.g.
+// after they were invoiced or a timesheet for them was submitted.
+type periodLock struct {
+	ID     int       `json:"id"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+	Locked time.Time `json:"locked"`
+}
+
+func locksPath() string {
+	return filepath.Join(logs, "locks.json")
+}
+
+func readLocks() ([]periodLock, error) {
+	data, err := os.ReadFile(locksPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var locks []periodLock
+	if err := json.Unmarshal(data, &locks); err != nil {
+		return nil, fmt.Errorf("%s: %v", locksPath(), err)
+	}
+	return locks, nil
+}
+
+func writeLocks(locks []periodLock) error {
+	data, err := json.MarshalIndent(locks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(locksPath(), string(data)+"\n")
+}
+
+// checkUnlocked returns an error if [from, to) touches a locked day. Every
+// command that changes recorded time or charges calls it first.
+func checkUnlocked(from, to time.Time) error {
+	locks, err := readLocks()
+	if err != nil {
+		return err
+	}
+	for _, l := range locks {
+		if from.Before(l.To.AddDate(0, 0, 1)) && to.After(l.From) {
+			return fmt.Errorf("%s to %s is locked (lock %d%s); unlock it first with: unlock %d",
+				l.From.Format("2006-01-02"), l.To.Format("2006-01-02"), l.ID, lockReason(l), l.ID)
+		}
+	}
+	return nil
+}
+
+func lockReason(l periodLock) string {
+	if l.Reason == "" {
+		return ""
+	}
+	return ": " + l.Reason
+}
+
+// runLock handles "lock [-month YYYY-MM | -from D -to D] [-reason TEXT]"
+// and "lock list".
+func runLock(args []string) error {
+	locks, err := readLocks()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 && args[0] == "list" {
+		if len(locks) == 0 {
+			fmt.Println("No locked periods.")
+		}
+		for _, l := range locks {
+			fmt.Printf("%3d  %s to %s  locked %s%s\n", l.ID, l.From.Format("2006-01-02"), l.To.Format("2006-01-02"), l.Locked.Format("2006-01-02 15:04"), lockReason(l))
+		}
+		return nil
+	}
+
+	fs := flag.NewFlagSet("lock", flag.ExitOnError)
+	monthStr := fs.String("month", "", "lock a whole month (YYYY-MM)")
+	fromStr := fs.String("from", "", "first day to lock (YYYY-MM-DD)")
+	toStr := fs.String("to", "", "last day to lock (YYYY-MM-DD, default yesterday)")
+	reason := fs.String("reason", "", "why, e.g. \"invoiced as 2024-007\" or \"timesheet approved\"")
+	fs.Parse(args)
+
+	var l periodLock
+	switch {
+	case *monthStr != "":
+		start, err := time.ParseInLocation("2006-01", *monthStr, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid month %q, expected YYYY-MM", *monthStr)
+		}
+		l.From, l.To = start, start.AddDate(0, 1, -1)
+	case *fromStr != "":
+		if l.From, err = parseDay(*fromStr); err != nil {
+			return err
+		}
+		if *toStr == "" {
+			*toStr = "yesterday"
+		}
+		if l.To, err = parseDay(*toStr); err != nil {
+			return err
+		}
+	default:
+		return errors.New("usage: lock -month YYYY-MM | -from YYYY-MM-DD [-to YYYY-MM-DD] [-reason TEXT] | list")
+	}
+	if l.To.Before(l.From) {
+		return errors.New("-to is before -from")
+	}
+	// The tracker keeps writing today, so only days that are over can be locked
+	if today, _ := parseDay(""); !l.To.Before(today) {
+		return fmt.Errorf("only past days can be locked; %s is not over yet", l.To.Format("2006-01-02"))
+	}
+	l.Reason, l.Locked = *reason, time.Now()
+	for _, old := range locks {
+		if old.ID >= l.ID {
+			l.ID = old.ID + 1
+		}
+	}
+	if l.ID == 0 {
+		l.ID = 1
+	}
+	if err := writeLocks(append(locks, l)); err != nil {
+		return err
+	}
+	audit("lock", "%d %s to %s%s", l.ID, l.From.Format("2006-01-02"), l.To.Format("2006-01-02"), lockReason(l))
+	fmt.Printf("%s Locked %s to %s (lock %d)\n", glyphs.ok, l.From.Format("2006-01-02"), l.To.Format("2006-01-02"), l.ID)
+	return nil
+}
+
+// runUnlock handles "unlock ID [-reason TEXT]".
+func runUnlock(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: unlock ID [-reason TEXT]")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid lock ID %q", args[0])
+	}
+	fs := flag.NewFlagSet("unlock", flag.ExitOnError)
+	reason := fs.String("reason", "", "why the period is reopened")
+	fs.Parse(args[1:])
+
+	locks, err := readLocks()
+	if err != nil {
+		return err
+	}
+	for i, l := range locks {
+		if l.ID != id {
+			continue
+		}
+		if err := writeLocks(append(locks[:i:i], locks[i+1:]...)); err != nil {
+			return err
+		}
+		detail := fmt.Sprintf("%d %s to %s", l.ID, l.From.Format("2006-01-02"), l.To.Format("2006-01-02"))
+		if *reason != "" {
+			detail += ": " + *reason
+		}
+		audit("unlock", "%s", detail)
+		fmt.Printf("%s Unlocked %s to %s\n", glyphs.ok, l.From.Format("2006-01-02"), l.To.Format("2006-01-02"))
+		return nil
+	}
+	return fmt.Errorf("no lock %d; see lock list", id)
+}
+
+// auditEntry is one line of LOG_PATH/audit.jsonl, the record of changes to
+// locks, charges, invoices and the rules made from the command line.
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Action string    `json:"action"`
+	Detail string    `json:"detail"`
+}
+
+func auditPath() string {
+	return filepath.Join(logs, "audit.jsonl")
+}
+
+// audit appends to the audit trail. A failure is reported but does not
+// undo the change it records.
+func audit(action, format string, args ...any) {
+	e := auditEntry{Time: time.Now(), Action: action, Detail: fmt.Sprintf(format, args...)}
+	if u, err := user.Current(); err == nil {
+		e.User = u.Username
+	}
+	if err := appendJSONLine(auditPath(), e); err != nil {
+		fmt.Fprintf(os.Stderr, "%s Audit trail: %v\n", glyphs.warn, err)
+	}
+}
+
+// runAudit handles "audit [-n 50]", printing the latest entries.
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	n := fs.Int("n", 50, "number of entries")
+	fs.Parse(args)
+
+	f, err := os.Open(auditPath())
+	if os.IsNotExist(err) {
+		fmt.Println("The audit trail is empty.")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(entries) > *n {
+		entries = entries[len(entries)-*n:]
+	}
+	for _, e := range entries {
+		fmt.Printf("%s  %-10s %-8s %s\n", e.Time.Format("2006-01-02 15:04:05"), e.User, e.Action, strings.TrimSpace(e.Detail))
+	}
+	return nil
+}
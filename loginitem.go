@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runLoginItem registers the tracker binary as a macOS login item through
+// System Events. SMAppService would be the modern API, but it only manages
+// helpers inside an app bundle and this is a bare executable.
+func runLoginItem(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: login-item add | remove | status")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	name := filepath.Base(exe)
+
+	switch args[0] {
+	case "add":
+		if registered, err := loginItemRegistered(name); err != nil {
+			return err
+		} else if registered {
+			fmt.Printf("%s is already a login item.\n", name)
+			return nil
+		}
+		script := fmt.Sprintf(`tell application "System Events" to make login item at end with properties {path:"%s", name:"%s", hidden:true}`,
+			appleScriptEscape(exe), appleScriptEscape(name))
+		if _, err := runAppleScript(script); err != nil {
+			return fmt.Errorf("could not add login item (is Automation access to System Events allowed?): %w", err)
+		}
+		fmt.Printf("%s Added %s as a login item; it starts with your next login.\n", glyphs.ok, exe)
+	case "remove":
+		script := fmt.Sprintf(`tell application "System Events" to delete (every login item whose name is "%s")`, appleScriptEscape(name))
+		if _, err := runAppleScript(script); err != nil {
+			return fmt.Errorf("could not remove login item: %w", err)
+		}
+		fmt.Printf("Removed %s from the login items.\n", name)
+	case "status":
+		registered, err := loginItemRegistered(name)
+		if err != nil {
+			return err
+		}
+		if registered {
+			fmt.Printf("%s is registered as a login item.\n", name)
+		} else {
+			fmt.Printf("%s is not a login item.\n", name)
+		}
+	default:
+		return fmt.Errorf("unknown login-item command %q", args[0])
+	}
+	return nil
+}
+
+func loginItemRegistered(name string) (bool, error) {
+	out, err := runAppleScript(`tell application "System Events" to get the name of every login item`)
+	if err != nil {
+		return false, fmt.Errorf("could not list login items: %w", err)
+	}
+	for _, item := range strings.Split(out, ", ") {
+		if item == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
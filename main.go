@@ -3,6 +3,9 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
@@ -16,141 +19,86 @@ import (
 	"time"
 )
 
-type TimeOfDay struct {
-	Hour   int
-	Minute int
+// glyphSet holds the decorative characters used in console messages.
+type glyphSet struct {
+	loaded   string
+	ok       string
+	warn     string
+	crumb    string // between a day and the app opened in it
+	arrow    string // from a menu to the item in it
+	ellipsis string // at the end of shortened text
 }
 
 var (
-	idleTreshold = parseIdleTreshold(os.Getenv("IDLE_TIME"), 120)
-	workdaysSet  = parseWorkdays(os.Getenv("WORK_DAYS"))
-	workStart    = parseTimeOfDay(os.Getenv("WORK_START"), TimeOfDay{8, 0})
-	workEnd      = parseTimeOfDay(os.Getenv("WORK_END"), TimeOfDay{17, 0})
-	logs         = parseLogPath(os.Getenv("LOG_PATH"), "/var/logs")
+	unicodeGlyphs = glyphSet{loaded: "↻", ok: "✅", warn: "⚠️", crumb: "›", arrow: "→", ellipsis: "…"}
+	asciiGlyphs   = glyphSet{loaded: "*", ok: "+", warn: "!", crumb: ">", arrow: "->", ellipsis: "..."}
+	glyphs        = unicodeGlyphs
 )
 
-func parseLogPath(input string, def string) string {
-	if input == "" {
-		return def
-	}
-	return input
-}
-
-func parseWorkdays(input string) map[time.Weekday]bool {
-	result := make(map[time.Weekday]bool)
-	if input == "" {
-		input = "Mon,Tue,Wed,Thu,Fri"
-	}
-	parts := strings.Split(input, ",")
-	for _, p := range parts {
-		switch strings.TrimSpace(strings.ToLower(p)) {
-		case "mon":
-			result[time.Monday] = true
-		case "tue":
-			result[time.Tuesday] = true
-		case "wed":
-			result[time.Wednesday] = true
-		case "thu":
-			result[time.Thursday] = true
-		case "fri":
-			result[time.Friday] = true
-		case "sat":
-			result[time.Saturday] = true
-		case "sun":
-			result[time.Sunday] = true
-		}
-	}
-	return result
-}
-
-func parseTimeOfDay(input string, def TimeOfDay) TimeOfDay {
-	if input == "" {
-		return def
-	}
-	t, err := time.Parse("15:04", input)
-	if err != nil {
-		return def
-	}
-	return TimeOfDay{t.Hour(), t.Minute()}
-}
-
-func parseIdleTreshold(input string, def int) int {
-	if input == "" {
-		return def
-	}
-	val, err := strconv.Atoi(input)
-	if err != nil {
-		fmt.Println("Could not convert idleTreshold to int", err)
-		return def
-	}
-	return val
-}
-
 func runAppleScript(script string) (string, error) {
-	cmd := exec.Command("osascript", "-e", script)
+	cmd := exec.CommandContext(providerContext(), "osascript", "-e", script)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	err := cmd.Run()
 	return strings.TrimSpace(out.String()), err
 }
 
-func getFrontAppInfo() (appName, bundleID string, err error) {
-	appName, err = runAppleScript(`tell application "System Events" to get name of first process whose frontmost is true`)
-	if err != nil {
-		return
-	}
-	bundleID, _ = runAppleScript(`id of application (path to frontmost application as text)`)
-	return
-}
-
-func getWindowTitle(appProcessName string) (string, error) {
-	script := fmt.Sprintf(`tell application "System Events" to tell process "%s" to get value of attribute "AXTitle" of window 1`, appProcessName)
-	return runAppleScript(script)
-}
-
-func getIdleSeconds() int {
-	cmd := exec.Command("bash", "-c", `ioreg -c IOHIDSystem | awk '/HIDIdleTime/ {print int($NF/1000000000); exit}'`)
-	out, err := cmd.Output()
-	if err != nil {
-		return 0
-	}
-	idleStr := strings.TrimSpace(string(out))
-	idle, _ := strconv.Atoi(idleStr)
-	return idle
-}
-
-// Work hours: Mon–Fri, 08:00–17:00
+// isWorkHour reports whether now falls in one of the work windows of the
+// configured schedule (WORK_SCHEDULE, WORK_HOURS or WORK_START and
+// WORK_END on WORK_DAYS); holidays and vacation days have none.
 func isWorkHour(now time.Time) bool {
-	// If it's an overnight window, the "workday" check is a bit subjective.
-	// Here we treat the day by the *start* segment: e.g., 22:00–23:59 belongs to today's workday,
-	// and 00:00–06:00 belongs to *the same* window continuing into tomorrow.
-	// If you want to restrict weekdays strictly, keep this simple check:
-	if !workdaysSet[now.Weekday()] {
-		// If window crosses midnight, allow early-morning segment to count toward the *previous* day's workday.
-		if crossesMidnight(workStart, workEnd) {
-			yesterday := now.AddDate(0, 0, -1).Weekday()
-			if !workdaysSet[yesterday] {
-				return false
+	m := now.Hour()*60 + now.Minute()
+	for _, w := range workDayWindows(now) {
+		if !crossesMidnight(w.start, w.end) {
+			if w.contains(now) {
+				return true
 			}
-		} else {
-			return false
+		} else if m >= w.start.minutes() {
+			// Only the evening part; the rest is tomorrow morning
+			return true
 		}
 	}
+	// The early-morning part of a window past midnight belongs to the day it started
+	for _, w := range workDayWindows(now.AddDate(0, 0, -1)) {
+		if crossesMidnight(w.start, w.end) && m < w.end.minutes() {
+			return true
+		}
+	}
+	return false
+}
 
-	start := time.Date(now.Year(), now.Month(), now.Day(), workStart.Hour, workStart.Minute, 0, 0, now.Location())
-	end := time.Date(now.Year(), now.Month(), now.Day(), workEnd.Hour, workEnd.Minute, 0, 0, now.Location())
-
-	if !crossesMidnight(workStart, workEnd) {
-		return !now.Before(start) && now.Before(end)
+// workWindows lists the day's work windows, sorted by start: from
+// WORK_SCHEDULE if set, else WORK_HOURS (or WORK_START to WORK_END) on
+// WORK_DAYS.
+func workWindows(day time.Weekday) []window {
+	if workSchedule != nil {
+		return workSchedule[day]
 	}
+	if !workdaysSet[day] {
+		return nil
+	}
+	if workHours != nil {
+		return workHours
+	}
+	return []window{{workStart, workEnd}}
+}
 
-	endTomorrow := end.Add(24 * time.Hour)
-	if now.Hour() >= workStart.Hour || (now.Hour() == workStart.Hour && now.Minute() >= workStart.Minute) {
-		return true
+// workDayWindows is workWindows for a date: none on holidays and
+// vacation days.
+func workDayWindows(day time.Time) []window {
+	if dayOff(day) != "" {
+		return nil
 	}
+	return workWindows(day.Weekday())
+}
+
+// isWorkday reports whether the weekday has any work hours.
+func isWorkday(day time.Weekday) bool {
+	return len(workWindows(day)) > 0
+}
 
-	nowPlusDay := now.Add(24 * time.Hour)
-	return nowPlusDay.Before(endTomorrow)
+func (t TimeOfDay) minutes() int {
+	return t.Hour*60 + t.Minute
 }
 
 func crossesMidnight(a, b TimeOfDay) bool {
@@ -190,8 +138,46 @@ func parseDuration(s string) time.Duration {
 	return total
 }
 
+// Split a summary entry like "Safari: 1h2m3s" into name and duration.
+// Titles may contain colons themselves, durations never do, so cut at the last one.
+// Logs written before the plain format used "App — 1h2m3s" headers.
+func splitSummaryEntry(line string) (name, dur string, ok bool) {
+	if i := strings.LastIndex(line, ":"); i >= 0 {
+		return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), true
+	}
+	if name, dur, ok = strings.Cut(line, "—"); ok {
+		return strings.TrimSpace(name), strings.TrimSpace(dur), true
+	}
+	return "", "", false
+}
+
+// shortDuration formats d rounded to minutes without the trailing "0s", e.g. "5h12m".
+func shortDuration(d time.Duration) string {
+	if d <= 0 {
+		return "0m"
+	}
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "<1m"
+	}
+	return strings.TrimSuffix(d.String(), "0s")
+}
+
+// Load every summary written today ("", "_outside", "_outside_evening", ...) into buckets
+func loadExistingLogs(buckets map[string]Totals) {
+	prefix := filepath.Join(logs, "focus_tracker_"+time.Now().Format("2006-01-02"))
+	matches, _ := filepath.Glob(prefix + "*.log")
+	for _, logPath := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(logPath, prefix), ".log")
+		if buckets[suffix] == nil {
+			buckets[suffix] = make(Totals)
+		}
+		readExistingLog(buckets[suffix], suffix)
+	}
+}
+
 // Read an existing log and merge totals into the given map
-func readExistingLog(totals map[string]map[string]time.Duration, suffix string) {
+func readExistingLog(totals Totals, suffix string) {
 	dateStr := time.Now().Format("2006-01-02")
 	logPath := filepath.Join(logs, fmt.Sprintf("focus_tracker_%s%s.log", dateStr, suffix))
 	f, err := os.Open(logPath)
@@ -203,159 +189,512 @@ func readExistingLog(totals map[string]map[string]time.Duration, suffix string)
 	scanner := bufio.NewScanner(f)
 	var currentApp string
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "Focus Summary") {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "Focus Summary") || strings.Trim(line, "-") == "" {
 			continue
 		}
 
-		if strings.Contains(line, "—") && !strings.HasPrefix(line, "-") {
+		if !strings.HasPrefix(raw, " ") {
 			// App line — header only, do not import as data
-			parts := strings.SplitN(line, "—", 2)
-			if len(parts) == 2 {
-				currentApp = strings.TrimSpace(parts[0])
+			if app, _, ok := splitSummaryEntry(line); ok {
+				currentApp = app
 			}
-			continue // ✅ skip adding duration here
+			continue
 		}
 
-		if strings.HasPrefix(line, "-") {
+		if strings.HasPrefix(line, "- ") && currentApp != "" {
 			// Title line: "- title: duration"
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 && currentApp != "" {
-				title := strings.TrimSpace(strings.TrimPrefix(parts[0], "-"))
-				durStr := strings.TrimSpace(parts[1])
-				d := parseDuration(durStr)
-				if _, ok := totals[currentApp]; !ok {
-					totals[currentApp] = make(map[string]time.Duration)
-				}
-				totals[currentApp][title] += d
+			title, durStr, ok := splitSummaryEntry(strings.TrimPrefix(line, "- "))
+			if !ok {
+				continue
+			}
+			if title == "(no title)" {
+				title = ""
 			}
+			d := parseDuration(durStr)
+			if _, ok := totals[currentApp]; !ok {
+				totals[currentApp] = make(map[string]time.Duration)
+			}
+			totals[currentApp][title] += d
+		}
+	}
+	fmt.Printf("%s Loaded previous totals from %s\n", glyphs.loaded, logPath)
+}
+
+// recordSpan credits a finished focus span to its work or outside-hours bucket
+// and appends it to the day's span journal. It returns the classified span.
+func recordSpan(buckets map[string]Totals, span Span) Span {
+	span = classify(span)
+	for _, suffix := range span.Suffixes() {
+		buckets[suffix] = buckets[suffix].add(span)
+	}
+	if screenshotInterval > 0 {
+		span.Screenshots = screenshotsDuring(span.End)
+	}
+	if len(nowPlayingApps) > 0 && !span.Away() {
+		span.NowPlaying = playingDuring(span.Start, span.End)
+	}
+	countTowardLimits(span)
+	countSpan(span)
+	noteEvent("%s %s for %v", span.Suffix(), span.App, span.Duration().Round(time.Second))
+
+	if err := journalSpan(span); err != nil {
+		fmt.Printf("%s %v\n", glyphs.warn, err)
+	}
+	if influxURL != "" {
+		influxRecord(span)
+	}
+	if togglToken != "" && togglWorkspace != 0 {
+		togglRecord(span)
+	}
+	if clockifyToken != "" && clockifyWorkspace != "" {
+		clockifyRecord(span)
+	}
+	shown := span
+	if screenSharing.Load() {
+		shown.Title = ""
+	}
+	emitJSON(trackEvent{Event: "span", Time: span.End, Span: &shown})
+	return span
+}
+
+// journalSpan writes a finished span to the journal and, with STORE, queues
+// it for SQLite.
+func journalSpan(span Span) error {
+	if err := appendSpan(span); err != nil {
+		return fmt.Errorf("could not append to span journal: %v", err)
+	}
+	if sqlitePath != "" {
+		queueSQLiteSpan(span)
+	}
+	return nil
+}
+
+// printSpan is the live log line for a finished span, its duration colored
+// by bucket.
+func printSpan(s Span) {
+	if screenSharing.Load() {
+		s.Title = ""
+	}
+	fmt.Printf("%s [%s]: active for %s\n", s.App, paintDim(s.Title), paintBucket(s.Suffix(), s.Duration().Round(time.Second).String()))
+}
+
+// bucketsDay is the day the tracking loop's totals belong to. The loop moves
+// it on at midnight, after saving the day that ended.
+var bucketsDay = startOfDay(time.Now())
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// saveAll writes every bucket's summary and updates the integrations that mirror them.
+func saveAll(buckets map[string]Totals) {
+	if err := flushSQLite(); err != nil {
+		fmt.Printf("%s Could not write spans to SQLite: %v\n", glyphs.warn, err)
+	}
+	for _, suffix := range sortedKeys(buckets) {
+		saveSummaryToFile(buckets[suffix], suffix)
+	}
+	noteSave()
+	if obsidianVault != "" {
+		if err := updateObsidianNote(bucketsDay, buckets, trackedOverLimits()); err != nil {
+			fmt.Printf("%s Could not update Obsidian daily note: %v\n", glyphs.warn, err)
 		}
 	}
-	fmt.Printf("↻ Loaded previous totals from %s\n", logPath)
 }
 
 // Save the totals to a file (normal or outside hours)
-func saveSummaryToFile(totals map[string]map[string]time.Duration, suffix string) {
+func saveSummaryToFile(totals Totals, suffix string) {
 	if len(totals) == 0 {
 		return
 	}
 
-	dateStr := time.Now().Format("2006-01-02")
+	dateStr := bucketsDay.Format("2006-01-02")
 	filename := fmt.Sprintf("focus_tracker_%s%s.log", dateStr, suffix)
 	logPath := filepath.Join(logs, filename)
 
-	writeSummary := func(w io.Writer) {
-		fmt.Fprintf(w, "Focus Summary for %s (%s)\n", dateStr, suffix)
-		fmt.Fprintf(w, "----------------------------------------\n")
-
-		for app, titleMap := range totals {
-			var totalApp time.Duration
-			for _, d := range titleMap {
-				totalApp += d
-			}
-			fmt.Fprintf(w, "%s — %v\n", app, totalApp.Round(time.Second))
-			for title, d := range titleMap {
-				if title == "" {
-					title = "(no title)"
-				}
-				fmt.Fprintf(w, "  - %s: %v\n", title, d.Round(time.Second))
-			}
-		}
-		fmt.Fprintln(w)
-	}
-
 	// Try writing to file
 	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
-		fmt.Printf("⚠️ Could not write log file %s: %v\n", logPath, err)
+		fmt.Printf("%s Could not write log file %s: %v\n", glyphs.warn, logPath, err)
 		fmt.Println("---- Printing summary to stdout instead ----")
-		writeSummary(os.Stdout)
+		writeSummary(os.Stdout, totals, dateStr, suffix)
 		return
 	}
 	defer f.Close()
 
-	writeSummary(f)
-	fmt.Printf("✅ Summary written to %s\n", logPath)
+	writeSummary(f, totals, dateStr, suffix)
+	fmt.Printf("%s Summary written to %s\n", glyphs.ok, logPath)
+}
+
+// writeSummary writes totals in the summary file format.
+func writeSummary(w io.Writer, totals Totals, dateStr, suffix string) {
+	fmt.Fprintf(w, "Focus Summary for %s (%s)\n", dateStr, suffix)
+	fmt.Fprintf(w, "----------------------------------------\n")
+
+	for app, titleMap := range totals {
+		var totalApp time.Duration
+		for _, d := range titleMap {
+			totalApp += d
+		}
+		fmt.Fprintf(w, "%s: %v\n", app, totalApp.Round(time.Second))
+		for title, d := range titleMap {
+			if title == "" {
+				title = "(no title)"
+			}
+			fmt.Fprintf(w, "  - %s: %v\n", title, d.Round(time.Second))
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// dumpSummaries saves every summary file and prints the totals, for
+// SIGUSR1. The span being timed is not in them until it ends.
+func dumpSummaries(buckets map[string]Totals) {
+	fmt.Println("\n=== Summary so far ===")
+	saveAll(buckets)
+	dateStr := bucketsDay.Format("2006-01-02")
+	for _, suffix := range sortedKeys(buckets) {
+		if len(buckets[suffix]) > 0 {
+			writeSummary(os.Stdout, buckets[suffix], dateStr, suffix)
+		}
+	}
 }
 
 func main() {
-	var lastApp, lastTitle string
+	ascii := flag.Bool("ascii", false, "use plain ASCII in console output (also ASCII_OUTPUT=1)")
+	flag.BoolVar(ascii, "plain", false, "alias for -ascii")
+	noColor := flag.Bool("no-color", false, "disable colored output (also NO_COLOR=1 or COLOR_THEME=off)")
+	configPath := flag.String("config", "", "config file (also CONFIG_PATH; default ~/.config/worktimer/config.yaml)")
+	givenSettings := settingFlags(flag.CommandLine)
+	flag.Parse()
+	if *configPath != "" {
+		os.Setenv("CONFIG_PATH", *configPath)
+	}
+
+	commandLineSettings = givenSettings()
+	if err := loadConfig(commandLineSettings); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *ascii {
+		asciiOutput = true
+	}
+	if asciiOutput {
+		glyphs = asciiGlyphs
+	}
+	if *noColor {
+		themeName = "off"
+	}
+	setupColors(themeName, os.Stdout)
+
+	// Without a command the tracker runs, as it always has
+	command, args := "track", []string(nil)
+	if flag.NArg() > 0 {
+		command, args = flag.Arg(0), flag.Args()[1:]
+	}
+	// The tracker migrates LOG_PATH once it holds the lock
+	if command != "track" {
+		if err := checkStore(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if err := runCommand(command, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// runTrack runs the tracking loop until the process is stopped.
+func runTrack(args []string) error {
+	fs := flag.NewFlagSet("track", flag.ExitOnError)
+	jsonLines := fs.Bool("json", false, "write focus changes and finished spans to stdout as JSON lines, and everything else to stderr")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return errors.New("usage: track [-json]")
+	}
+	if *jsonLines {
+		jsonOutput.enc = json.NewEncoder(os.Stdout)
+		os.Stdout = os.Stderr
+	}
+	if err := lockTracker(); err != nil {
+		return err
+	}
+	if err := migrateStore(); err != nil {
+		return err
+	}
+
+	var lastApp, lastBundleID, lastAppPath, lastAppVersion, lastTitle, lastDomain, lastIssue string
+	var editing time.Duration // of the current span
+	lastTick := time.Now()
+	var lastCheckpoint time.Time
 	lastSwitch := time.Now()
 
-	workTotals := make(map[string]map[string]time.Duration)
-	outsideTotals := make(map[string]map[string]time.Duration)
+	// Totals per summary file suffix: "" for work hours, "_outside..." otherwise
+	buckets := make(map[string]Totals)
 
-	// Load previous sessions for today
-	readExistingLog(workTotals, "")
-	readExistingLog(outsideTotals, "_outside")
+	if span, ok, err := recoverOpenSpan(); err != nil {
+		fmt.Printf("%s Could not recover the span open when the tracker stopped: %v\n", glyphs.warn, err)
+	} else if ok {
+		fmt.Printf("%s Recovered %s for %s, open when the tracker stopped.\n", glyphs.loaded, span.App, span.Duration().Round(time.Second))
+	}
+
+	// Load previous sessions for today: from SQLite with STORE, else the
+	// journal, else the summary files of versions without one
+	loaded, err := loadSQLiteTotals(buckets)
+	if err != nil {
+		fmt.Printf("%s Could not read today's spans from SQLite: %v\n", glyphs.warn, err)
+	}
+	if !loaded {
+		if loaded, err = loadJournalTotals(buckets); err != nil {
+			fmt.Printf("%s Could not read today's span journal: %v\n", glyphs.warn, err)
+		}
+	}
+	if !loaded {
+		loadExistingLogs(buckets)
+	}
+	seedTodayTotals(buckets)
+	loadLimitUsage(time.Now())
 
 	fmt.Println("Tracking focus... Press Ctrl+C to stop.")
+	appSource, titleSource, idleSource := desktop.sources()
+	fmt.Println(paintDim(fmt.Sprintf("Apps from %s, titles from %s, idle time from %s.", appSource, titleSource, idleSource)))
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	if dumpSignal != nil {
+		dump := make(chan os.Signal, 1)
+		signal.Notify(dump, dumpSignal)
+		go func() {
+			for range dump {
+				runInLoop(dumpSummaries)
+			}
+		}()
+	}
+	if reloadSignal != nil {
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, reloadSignal)
+		go func() {
+			for range reload {
+				if msg, err := reloadInLoop(); err != nil {
+					fmt.Printf("%s Configuration not reloaded: %v\n", glyphs.warn, err)
+				} else {
+					fmt.Println(paintDim(msg))
+				}
+			}
+		}()
+	}
+	if pauseSignal != nil {
+		toggle := make(chan os.Signal, 1)
+		signal.Notify(toggle, pauseSignal)
+		go func() {
+			for range toggle {
+				if err := setPaused(!pausedByUser()); err != nil {
+					fmt.Printf("%s %v\n", glyphs.warn, err)
+				}
+				runInLoop(func(map[string]Totals) {})
+			}
+		}()
+	}
 
+	// The final save runs on the loop, which owns the buckets, and the
+	// tracker exits once it is done
 	go func() {
 		<-sig
 		fmt.Println("\n\n=== Final Summary ===")
-		saveSummaryToFile(workTotals, "")
-		saveSummaryToFile(outsideTotals, "_outside")
+		if err := runInLoop(saveAll); err != nil {
+			fmt.Printf("%s Totals not saved: %v\n", glyphs.warn, err)
+		}
+		closeControlSocket()
 		os.Exit(0)
 	}()
 
 	lastKnownTitle := make(map[string]string)
 
-	for {
-		idle := getIdleSeconds()
+	go publishMetrics(30 * time.Second)
+	if metricsAddr != "" {
+		go serveMetrics(metricsAddr)
+	}
+	if dashboardAddr != "" {
+		go serveDashboard(dashboardAddr)
+	}
+	if controlAddr != "" {
+		go serveControl(controlAddr)
+	}
+	if controlSocket != "" {
+		go serveControlSocket(controlSocket)
+	}
+
+	if len(scheduledJobs) > 0 {
+		go runScheduler(scheduledJobs)
+	}
+	go runOutbox()
+	if len(nowPlayingApps) > 0 {
+		go watchNowPlaying(nowPlayingApps)
+	}
+	if detailRetention.enabled() || anyProjectRetention() {
+		go runPruneLoop()
+	}
+
+	if sharingPrivacy {
+		go watchScreenSharing()
+	}
+	if screenshotInterval > 0 {
+		go takeScreenshots(screenshotInterval)
+	}
+	gapEntries := make(chan Span, 1)
+	if gapPrompt > 0 {
+		go classifyStartupGap(gapEntries)
+	}
+
+	probeOK()
+	if watchdogStall > 0 {
+		go watchdog(watchdogStall)
+	}
+
+	pausedFor := ""
+	var pauseStart time.Time // of a pause by the user, recorded as a Paused span on resume
+
+	// rollOver ends the day at midnight: the open span and pause are split
+	// there, the day is saved under its own date and the totals start over.
+	rollOver := func(midnight time.Time) {
+		if lastApp != "" && lastSwitch.Before(midnight) {
+			recordSpan(buckets, Span{
+				Start: lastSwitch, End: midnight, App: lastApp, BundleID: lastBundleID,
+				AppPath: lastAppPath, AppVersion: lastAppVersion, Title: lastTitle, Domain: lastDomain, Issue: lastIssue,
+				EditingSeconds: int(editing.Seconds()),
+			})
+			lastSwitch, editing = midnight, 0
+			noteFocus(classify(Span{Start: midnight, End: midnight, App: lastApp, Title: lastTitle, Domain: lastDomain, Issue: lastIssue}), midnight)
+		}
+		if pausedFor == "paused" && pauseStart.Before(midnight) {
+			recordSpan(buckets, Span{Start: pauseStart, End: midnight, App: pausedApp})
+			pauseStart = midnight
+		}
+		saveAll(buckets)
+		for suffix := range buckets {
+			delete(buckets, suffix)
+		}
+		bucketsDay = midnight
+		lastCheckpoint = time.Time{}
+		fmt.Println(paintDim(fmt.Sprintf("New day %s; the totals start over.", midnight.Format("2006-01-02"))))
+	}
+
+	// One pass of the tracker loop; returns how long to sleep before the next.
+	// A panic is recovered and written to a crash report so tracking goes on.
+	tick := func() (sleep time.Duration) {
+		defer func() {
+			if r := recover(); r != nil {
+				writeCrashReport(r)
+				sleep = 5 * time.Second
+			}
+		}()
+
 		now := time.Now()
+		for next := bucketsDay.AddDate(0, 0, 1); !now.Before(next); next = bucketsDay.AddDate(0, 0, 1) {
+			rollOver(next)
+		}
+
+		select {
+		case entry := <-gapEntries:
+			printSpan(recordSpan(buckets, entry))
+			saveAll(buckets)
+		default:
+		}
+
+		// Outside the allowed tracking time nothing is probed or recorded
+		if reason := trackingBlocked(now); reason != "" {
+			probeOK()
+			if lastApp != "" {
+				recordSpan(buckets, Span{
+					Start: lastSwitch, End: now, App: lastApp, BundleID: lastBundleID,
+					AppPath: lastAppPath, AppVersion: lastAppVersion, Title: lastTitle, Domain: lastDomain, Issue: lastIssue,
+					EditingSeconds: int(editing.Seconds()),
+				})
+				lastApp, lastBundleID, lastAppPath, lastAppVersion, lastTitle, lastDomain, lastIssue = "", "", "", "", "", "", ""
+				editing = 0
+				clearCheckpoint()
+			}
+			if pausedFor != reason {
+				if pausedFor == "paused" {
+					printSpan(recordSpan(buckets, Span{Start: pauseStart, End: now, App: pausedApp}))
+				}
+				if reason == "paused" {
+					pauseStart = now
+				}
+				noteFocus(Span{}, now)
+				fmt.Println(paintDim(fmt.Sprintf("Tracking paused (%s).", reason)))
+				saveAll(buckets)
+				pausedFor = reason
+			}
+			return 10 * time.Second
+		}
+		if pausedFor == "paused" {
+			printSpan(recordSpan(buckets, Span{Start: pauseStart, End: now, App: pausedApp}))
+		}
+		if pausedFor != "" {
+			fmt.Println(paintDim("Tracking resumed."))
+			pausedFor = ""
+		}
+
+		idle := desktop.idleSeconds()
+
+		pomodoroTick(now, Span{App: lastApp, Title: lastTitle})
+		breakTick(now, time.Duration(idle)*time.Second)
+		idleTick(now, idle)
+		limitTick(now, Span{Start: lastSwitch, End: now, App: lastApp, Title: lastTitle})
 
 		// Locked screen handling
-		if idle > idleTreshold {
-			if lastApp != "Locked screen" {
-				duration := time.Since(lastSwitch)
+		if idle > idleThresholdAt(now) {
+			probeOK()
+			if lastApp != lockedApp {
 				if lastApp != "" {
-					var totals map[string]map[string]time.Duration
-					if isWorkHour(lastSwitch) {
-						totals = workTotals
-					} else {
-						totals = outsideTotals
-					}
-					if _, ok := totals[lastApp]; !ok {
-						totals[lastApp] = make(map[string]time.Duration)
-					}
-					totals[lastApp][lastTitle] += duration
+					span := recordSpan(buckets, Span{
+						Start: lastSwitch, End: now, App: lastApp, BundleID: lastBundleID,
+						AppPath: lastAppPath, AppVersion: lastAppVersion, Title: lastTitle, Domain: lastDomain, Issue: lastIssue,
+						EditingSeconds: int(editing.Seconds()),
+					})
+					printSpan(span)
 				}
 
 				lockStart := now.Format("15:04:05")
 				lockStart = strings.ReplaceAll(lockStart, ":", "-")
-				fmt.Printf("%s [%s]: active for %v\n", lastApp, lastTitle, duration.Round(time.Second))
 
-				lastApp = "Locked screen"
+				lastApp = lockedApp
+				lastBundleID, lastAppPath, lastAppVersion, lastDomain, lastIssue = "", "", "", "", ""
 				lastTitle = lockStart
 				lastSwitch = now
+				editing = 0
+				noteFocus(Span{App: lastApp}, now)
 			}
-			time.Sleep(5 * time.Second)
-			continue
+			return 5 * time.Second
 		}
 
-		appName, bundleID, err := getFrontAppInfo()
+		probeStart := time.Now()
+		w, err := desktop.focus()
+		appName, bundleID, appPath := w.app, w.bundleID, w.path
+		observeProbe(time.Since(probeStart), err)
 		if err != nil || appName == "" {
-			time.Sleep(2 * time.Second)
-			continue
+			return 2 * time.Second
 		}
-
-		// Handle VS Code's Electron quirk
-		appProcessName := appName
-		if bundleID == "com.microsoft.VSCode" {
-			appName = "Visual Studio Code"
-			appProcessName = "Electron"
-		}
-
-		title, _ := getWindowTitle(appProcessName)
-		if appName == "Visual Studio Code" {
-			title = strings.TrimSuffix(title, " — Visual Studio Code")
+		probeOK()
+		if !allowedApp(appName, bundleID) {
+			appName, bundleID, appPath = otherApp, "", ""
+			w = focusedWindow{app: otherApp}
 		}
 
-		if title == "" {
+		edited := w.edited
+		title, domain := redactWindow(appName, bundleID, w.title, urlDomain(w.url))
+		if !observeTitle(now, appName, w.titleErr) {
+			// Degraded: a cached title would misattribute time, so record the app only
+			title = ""
+		} else if screenSharing.Load() {
+			// Titles could be anything on a shared screen; keep them out of the logs
+			title, domain = "", ""
+		} else if title == "" {
 			// use cached last known title if available
 			if prev, ok := lastKnownTitle[appName]; ok && prev != "" {
 				title = prev
@@ -364,35 +703,72 @@ func main() {
 			// update cache with new non-empty title
 			lastKnownTitle[appName] = title
 		}
+		// An issue key in the URL, unless the domain was redacted or hidden
+		issue := ""
+		if domain != "" && domain == urlDomain(w.url) {
+			issue = urlIssue(w.url)
+		}
+
+		if edited && appName == lastApp && title == lastTitle && time.Duration(idle)*time.Second < editingIdle {
+			editing += now.Sub(lastTick)
+		}
+		lastTick = now
 
 		// Focus changed
-		if appName != lastApp || title != lastTitle {
-			duration := time.Since(lastSwitch)
+		if appName != lastApp || title != lastTitle || domain != lastDomain || issue != lastIssue {
 			if lastApp != "" {
-				var totals map[string]map[string]time.Duration
-				if isWorkHour(lastSwitch) {
-					totals = workTotals
-				} else {
-					totals = outsideTotals
-				}
-				if _, ok := totals[lastApp]; !ok {
-					totals[lastApp] = make(map[string]time.Duration)
-				}
-				totals[lastApp][lastTitle] += duration
-				fmt.Printf("%s [%s]: active for %v\n", lastApp, lastTitle, duration.Round(time.Second))
+				span := recordSpan(buckets, Span{
+					Start: lastSwitch, End: now, App: lastApp, BundleID: lastBundleID,
+					AppPath: lastAppPath, AppVersion: lastAppVersion, Title: lastTitle, Domain: lastDomain, Issue: lastIssue,
+					EditingSeconds: int(editing.Seconds()),
+				})
+				printSpan(span)
 			}
 
 			lastApp = appName
+			lastBundleID = bundleID
+			if appPath != lastAppPath || lastAppVersion == "" {
+				lastAppVersion = appVersion(appPath)
+			}
+			lastAppPath = appPath
 			lastTitle = title
+			lastDomain = domain
+			lastIssue = issue
 			lastSwitch = now
+			editing = 0
+			noteFocus(classify(Span{Start: now, End: now, App: lastApp, Title: lastTitle, Domain: lastDomain, Issue: lastIssue}), now)
+		}
+
+		if now.Sub(lastCheckpoint) >= checkpointEvery {
+			err := checkpointSpan(Span{
+				Start: lastSwitch, End: now, App: lastApp, BundleID: lastBundleID,
+				AppPath: lastAppPath, AppVersion: lastAppVersion, Title: lastTitle, Domain: lastDomain, Issue: lastIssue,
+				EditingSeconds: int(editing.Seconds()),
+			})
+			if err != nil {
+				noteEvent("checkpoint failed: %v", err)
+			}
+			if err := flushSQLite(); err != nil {
+				noteEvent("writing spans to SQLite failed: %v", err)
+			}
+			lastCheckpoint = now
 		}
 
 		// Autosave every 10 minutes
 		if now.Minute()%10 == 0 && now.Second() < 2 {
-			saveSummaryToFile(workTotals, "")
-			saveSummaryToFile(outsideTotals, "_outside")
+			saveAll(buckets)
 		}
 
-		time.Sleep(2 * time.Second)
+		return 2 * time.Second
+	}
+
+	for {
+		wait := time.NewTimer(tick())
+		select {
+		case <-wait.C:
+		case task := <-loopTasks:
+			wait.Stop()
+			task(buckets)
+		}
 	}
 }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runMenubar prints the menu for SwiftBar or xbar, which run it as a plugin
+// and turn the output into a menu bar item. "menubar open-report" is the
+// action behind the menu's report entry.
+func runMenubar(args []string) error {
+	if len(args) > 0 && args[0] == "open-report" {
+		return openTodaysReport()
+	}
+	if len(args) > 0 {
+		return errors.New("usage: menubar [open-report]")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	action := func(label, shortcut string, params ...string) {
+		line := label + " | bash=" + menubarQuote(exe)
+		for i, p := range params {
+			line += fmt.Sprintf(" param%d=%s", i+1, menubarQuote(p))
+		}
+		if shortcut != "" {
+			line += " shortcut=" + shortcut
+		}
+		fmt.Println(line + " terminal=false refresh=true")
+	}
+
+	now := time.Now()
+	status, running, _ := readStatus()
+	work, outside := todaysTotals(now, status, running)
+
+	icon := "⏱"
+	if asciiOutput {
+		icon = "Focus"
+	}
+	if running && status.Sharing {
+		fmt.Println(icon)
+		fmt.Println("---")
+		fmt.Println("Screen sharing: details hidden until it ends")
+		return nil
+	}
+	switch {
+	case !running:
+		fmt.Printf("%s off\n", icon)
+	case pausedByUser():
+		fmt.Printf("%s paused\n", icon)
+	default:
+		fmt.Printf("%s %s\n", icon, shortDuration(work))
+	}
+	fmt.Println("---")
+	if running && status.App != "" {
+		fmt.Printf("%s: %s\n", menubarText(status.App), shortDuration(now.Sub(status.Since)))
+	} else if !running {
+		fmt.Println("Tracker is not running")
+	}
+	fmt.Printf("Today: %s work, %s outside hours\n", shortDuration(work), shortDuration(outside))
+	fmt.Println("---")
+	if pausedByUser() {
+		action("Resume tracking", hotkeyPause, "resume")
+	} else {
+		action("Pause tracking", hotkeyPause, "pause")
+	}
+	action("Tag last 30 minutes"+glyphs.ellipsis, hotkeyTag, "tag")
+	action("Add note"+glyphs.ellipsis, hotkeyNote, "note")
+	action("Show/hide widget", hotkeyWidget, "widget", "toggle")
+	current := manualProject()
+	if current == "" {
+		fmt.Println("Switch project")
+	} else {
+		fmt.Printf("Project: %s\n", menubarText(current))
+	}
+	for _, p := range projects {
+		action("--"+menubarText(p.name), "", "project", "set", p.name)
+	}
+	if current != "" {
+		action("--Match by rules", "", "project", "clear")
+	}
+	action("Open today's report", "", "menubar", "open-report")
+	return nil
+}
+
+// todaysTotals sums today's journal plus the span the tracker is timing.
+func todaysTotals(now time.Time, status trackerStatus, running bool) (work, outside time.Duration) {
+	today, _ := parseDay("")
+	spans, _ := readSpans(today)
+	if running && status.App != "" && status.App != lockedApp {
+		spans = append(spans, classify(Span{Start: status.Since, End: now, App: status.App}))
+	}
+	for _, s := range spans {
+		switch {
+		case s.Away():
+		case s.Work:
+			work += s.Duration()
+		default:
+			outside += s.Duration()
+		}
+	}
+	return work, outside
+}
+
+func openTodaysReport() error {
+	path := filepath.Join(os.TempDir(), "focus-tracker-report-"+time.Now().Format("2006-01-02")+".txt")
+	if err := runReport([]string{"-o", path}); err != nil {
+		return err
+	}
+	return exec.Command("open", path).Run()
+}
+
+// menubarText keeps "|" out of menu titles, where it starts the parameters.
+func menubarText(s string) string {
+	return strings.ReplaceAll(s, "|", "¦")
+}
+
+func menubarQuote(s string) string {
+	if !strings.ContainsAny(s, " \"") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
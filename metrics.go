@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxProbeSamples = 500
+
+// metrics holds the tracker's own operational numbers. They are written to
+// LOG_PATH/status.json for the status command and served on METRICS_ADDR.
+var metrics struct {
+	sync.Mutex
+	started     time.Time
+	latencies   []time.Duration // ring buffer of the latest probe latencies
+	next        int
+	probes      int
+	probeErrors int
+	day         string
+	spansToday  int
+	workToday   time.Duration
+	outToday    time.Duration
+	lastSave    time.Time
+	app         string // currently focused app, "" while paused
+	project     string
+	since       time.Time
+	focus       Span // the classified span being timed, for the API
+}
+
+// trackerStatus is the snapshot written to status.json.
+type trackerStatus struct {
+	PID         int       `json:"pid"`
+	Started     time.Time `json:"started"`
+	Updated     time.Time `json:"updated"`
+	Probes      int       `json:"probes"`
+	ProbeErrors int       `json:"probe_errors"`
+	ProbeP50    float64   `json:"probe_p50_ms"`
+	ProbeP90    float64   `json:"probe_p90_ms"`
+	ProbeP99    float64   `json:"probe_p99_ms"`
+	SpansToday  int       `json:"spans_today"`
+	WorkToday   int       `json:"work_today_seconds"`
+	OutToday    int       `json:"outside_today_seconds"`
+	LastSave    time.Time `json:"last_save,omitempty"`
+	HeapBytes   uint64    `json:"heap_bytes"`
+	SysBytes    uint64    `json:"sys_bytes"`
+	Goroutines  int       `json:"goroutines"`
+	Degraded    bool      `json:"titles_degraded"`
+	Sharing     bool      `json:"screen_sharing,omitempty"`
+	App         string    `json:"app,omitempty"`
+	Project     string    `json:"project,omitempty"`
+	Since       time.Time `json:"since,omitempty"`
+}
+
+func statusPath() string {
+	return filepath.Join(logs, "status.json")
+}
+
+func observeProbe(latency time.Duration, err error) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.probes++
+	if err != nil {
+		metrics.probeErrors++
+	}
+	if len(metrics.latencies) < maxProbeSamples {
+		metrics.latencies = append(metrics.latencies, latency)
+		return
+	}
+	metrics.latencies[metrics.next] = latency
+	metrics.next = (metrics.next + 1) % maxProbeSamples
+}
+
+func countSpan(span Span) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	day := span.End.Format("2006-01-02")
+	if day != metrics.day {
+		metrics.day, metrics.spansToday, metrics.workToday, metrics.outToday = day, 0, 0, 0
+	}
+	metrics.spansToday++
+	switch {
+	case span.Away():
+	case span.Work:
+		metrics.workToday += span.Duration()
+	default:
+		metrics.outToday += span.Duration()
+	}
+}
+
+// seedTodayTotals starts today's totals from the summaries loaded at startup.
+func seedTodayTotals(buckets map[string]Totals) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.day = time.Now().Format("2006-01-02")
+	for suffix, totals := range buckets {
+		if suffix != "" && !strings.HasPrefix(suffix, "_outside") {
+			continue
+		}
+		for app, titles := range totals {
+			if app == lockedApp || app == pausedApp {
+				continue
+			}
+			for _, d := range titles {
+				if suffix == "" {
+					metrics.workToday += d
+				} else {
+					metrics.outToday += d
+				}
+			}
+		}
+	}
+}
+
+// noteFocus records what the tracker is currently timing; an empty span
+// means tracking is paused.
+func noteFocus(current Span, since time.Time) {
+	metrics.Lock()
+	metrics.app, metrics.project, metrics.since = current.App, current.Project, since
+	metrics.focus = current
+	metrics.Unlock()
+	c := currentFocus(since)
+	publishFocus(c)
+	emitJSON(trackEvent{Event: "focus", Time: time.Now(), Focus: &c})
+	select {
+	case statusChanged <- struct{}{}:
+	default:
+	}
+}
+
+// statusChanged asks publishMetrics to rewrite status.json right away, so
+// readers such as the quick commands see a focus change immediately.
+var statusChanged = make(chan struct{}, 1)
+
+func noteSave() {
+	metrics.Lock()
+	metrics.lastSave = time.Now()
+	metrics.Unlock()
+}
+
+func currentStatus() trackerStatus {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	metrics.Lock()
+	defer metrics.Unlock()
+	sorted := append([]time.Duration(nil), metrics.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	spans, work, outside := metrics.spansToday, metrics.workToday, metrics.outToday
+	if metrics.day != time.Now().Format("2006-01-02") {
+		spans, work, outside = 0, 0, 0
+	}
+	return trackerStatus{
+		PID:         os.Getpid(),
+		Started:     metrics.started,
+		Updated:     time.Now(),
+		Probes:      metrics.probes,
+		ProbeErrors: metrics.probeErrors,
+		ProbeP50:    percentileMillis(sorted, 50),
+		ProbeP90:    percentileMillis(sorted, 90),
+		ProbeP99:    percentileMillis(sorted, 99),
+		SpansToday:  spans,
+		WorkToday:   int(work.Seconds()),
+		OutToday:    int(outside.Seconds()),
+		LastSave:    metrics.lastSave,
+		HeapBytes:   mem.HeapAlloc,
+		SysBytes:    mem.Sys,
+		Goroutines:  runtime.NumGoroutine(),
+		Degraded:    titlesDegraded.Load(),
+		Sharing:     screenSharing.Load(),
+		App:         metrics.app,
+		Project:     metrics.project,
+		Since:       metrics.since,
+	}
+}
+
+// percentileMillis uses the nearest-rank method on sorted samples.
+func percentileMillis(sorted []time.Duration, p int) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p + 99) / 100
+	if i > 0 {
+		i--
+	}
+	return float64(sorted[i].Microseconds()) / 1000
+}
+
+// publishMetrics writes status.json every interval while the tracker runs.
+func publishMetrics(interval time.Duration) {
+	metrics.Lock()
+	metrics.started = time.Now()
+	metrics.since = metrics.started
+	metrics.Unlock()
+	ticker := time.NewTicker(interval)
+	for {
+		if err := writeStatus(currentStatus()); err != nil {
+			fmt.Printf("%s Could not write %s: %v\n", glyphs.warn, statusPath(), err)
+		}
+		select {
+		case <-ticker.C:
+		case <-statusChanged:
+		}
+	}
+}
+
+func writeStatus(status trackerStatus) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := statusPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, statusPath())
+}
+
+// serveMetrics exposes the status in the Prometheus text format on /metrics.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		s := currentStatus()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintf(w, "focus_tracker_start_time_seconds %d\n", s.Started.Unix())
+		fmt.Fprintf(w, "focus_tracker_probes_total %d\n", s.Probes)
+		fmt.Fprintf(w, "focus_tracker_probe_errors_total %d\n", s.ProbeErrors)
+		fmt.Fprintf(w, "focus_tracker_probe_latency_seconds{quantile=\"0.5\"} %g\n", s.ProbeP50/1000)
+		fmt.Fprintf(w, "focus_tracker_probe_latency_seconds{quantile=\"0.9\"} %g\n", s.ProbeP90/1000)
+		fmt.Fprintf(w, "focus_tracker_probe_latency_seconds{quantile=\"0.99\"} %g\n", s.ProbeP99/1000)
+		fmt.Fprintf(w, "focus_tracker_spans_today %d\n", s.SpansToday)
+		if !s.LastSave.IsZero() {
+			fmt.Fprintf(w, "focus_tracker_last_save_time_seconds %d\n", s.LastSave.Unix())
+		}
+		fmt.Fprintf(w, "focus_tracker_heap_bytes %d\n", s.HeapBytes)
+		fmt.Fprintf(w, "focus_tracker_sys_bytes %d\n", s.SysBytes)
+		fmt.Fprintf(w, "focus_tracker_goroutines %d\n", s.Goroutines)
+		degraded := 0
+		if s.Degraded {
+			degraded = 1
+		}
+		fmt.Fprintf(w, "focus_tracker_titles_degraded %d\n", degraded)
+	})
+	if err := http.ListenAndServe(addr, localOnly(mux)); err != nil {
+		fmt.Printf("%s Metrics endpoint on %s stopped: %v\n", glyphs.warn, addr, err)
+	}
+}
+
+// readStatus loads status.json and reports whether the tracker that wrote
+// it is still running.
+func readStatus() (trackerStatus, bool, error) {
+	var s trackerStatus
+	data, err := os.ReadFile(statusPath())
+	if os.IsNotExist(err) {
+		return s, false, errors.New("the tracker has not run with this LOG_PATH yet")
+	} else if err != nil {
+		return s, false, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, false, fmt.Errorf("%s: %v", statusPath(), err)
+	}
+	// A stale file or a dead pid means the tracker stopped without cleaning up
+	running := time.Since(s.Updated) <= 2*time.Minute && processAlive(s.PID)
+	return s, running, nil
+}
+
+// runStatus reports whether the tracker is running, from its status.json.
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	verbose := fs.Bool("verbose", false, "also show probe latency, errors and memory use")
+	fs.Parse(args)
+
+	s, running, err := readStatus()
+	if err != nil {
+		return err
+	}
+	switch {
+	case !running:
+		fmt.Printf("Not running (last seen %s).\n", s.Updated.Format("2006-01-02 15:04"))
+	case s.App == "":
+		fmt.Printf("Paused since %s (pid %d).\n", s.Since.Format("15:04"), s.PID)
+	default:
+		fmt.Printf("Tracking since %s (pid %d).\n", s.Started.Format("2006-01-02 15:04"), s.PID)
+		fmt.Printf("Current: %s for %s\n", s.App, shortDuration(time.Since(s.Since)))
+	}
+	if s.Degraded {
+		fmt.Printf("%s Window titles cannot be read; recording app time only.\n", glyphs.warn)
+	}
+	work, outside := todaysTotals(time.Now(), s, running)
+	fmt.Printf("Today: %s in work hours, %s outside\n", shortDuration(work), shortDuration(outside))
+	fmt.Printf("Spans recorded today: %d\n", s.SpansToday)
+	if s.LastSave.IsZero() {
+		fmt.Println("Last save: never")
+	} else {
+		fmt.Printf("Last save: %s\n", s.LastSave.Format("15:04:05"))
+	}
+	if *verbose {
+		fmt.Printf("Probes: %d (%d errors)\n", s.Probes, s.ProbeErrors)
+		fmt.Printf("Probe latency: p50 %.0fms, p90 %.0fms, p99 %.0fms\n", s.ProbeP50, s.ProbeP90, s.ProbeP99)
+		fmt.Printf("Memory: %.1f MB heap, %.1f MB from the OS\n", float64(s.HeapBytes)/(1<<20), float64(s.SysBytes)/(1<<20))
+		fmt.Printf("Goroutines: %d\n", s.Goroutines)
+	}
+	return nil
+}
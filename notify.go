@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// notify shows a macOS notification; failures are only logged since a
+// missed notification must never stop tracking.
+func notify(title, message string) {
+	if screenSharing.Load() {
+		noteEvent("notification %q held back during screen sharing", title)
+		return
+	}
+	script := fmt.Sprintf(`display notification "%s" with title "Focus Tracker" subtitle "%s" sound name "Glass"`,
+		appleScriptEscape(message), appleScriptEscape(title))
+	if _, err := runAppleScript(script); err != nil {
+		fmt.Printf("%s Could not show notification %q: %v\n", glyphs.warn, title, err)
+	}
+}
+
+func appleScriptEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const notionAPI = "https://api.notion.com/v1"
+
+// notionRow is one day/project row of the Notion database.
+type notionRow struct {
+	Date    time.Time
+	Project string
+	Hours   float64
+	Titles  []string
+}
+
+// syncNotion upserts one row per project for the given day, with the time
+// outside projects as "(no project)". The target
+// database needs the properties Name (title), Date (date), Project (text),
+// Hours (number) and Top titles (text).
+func syncNotion(day time.Time, spans []Span) error {
+	if notionToken == "" || notionDatabase == "" {
+		return errors.New("NOTION_TOKEN and NOTION_DATABASE_ID must be set")
+	}
+	for _, row := range notionRows(day, spans) {
+		id, err := notionFindRow(row)
+		if err != nil {
+			return err
+		}
+		props := notionProperties(row)
+		if id == "" {
+			err = notionRequest(http.MethodPost, "/pages", map[string]any{
+				"parent":     map[string]string{"database_id": notionDatabase},
+				"properties": props,
+			}, nil)
+		} else {
+			err = notionRequest(http.MethodPatch, "/pages/"+id, map[string]any{"properties": props}, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("notion: %s %s: %w", row.Project, row.Date.Format("2006-01-02"), err)
+		}
+		fmt.Printf("%s Notion: %s %.2fh\n", glyphs.ok, row.Project, row.Hours)
+	}
+	return nil
+}
+
+func notionRows(day time.Time, spans []Span) []notionRow {
+	titles := make(map[string]map[string]time.Duration)
+	for _, s := range withContexts(spans) {
+		if s.Away() {
+			continue
+		}
+		project := s.Project
+		if project == "" {
+			project = "(no project)"
+		}
+		if titles[project] == nil {
+			titles[project] = make(map[string]time.Duration)
+		}
+		titles[project][s.Title] += s.Duration()
+	}
+
+	var rows []notionRow
+	for _, project := range sortedKeys(titles) {
+		var total time.Duration
+		var top []string
+		for t, d := range titles[project] {
+			total += d
+			if t != "" {
+				top = append(top, t)
+			}
+		}
+		sort.Slice(top, func(i, j int) bool { return titles[project][top[i]] > titles[project][top[j]] })
+		if len(top) > 3 {
+			top = top[:3]
+		}
+		rows = append(rows, notionRow{
+			Date:    day,
+			Project: project,
+			Hours:   math.Round(total.Hours()*100) / 100,
+			Titles:  top,
+		})
+	}
+	return rows
+}
+
+func notionProperties(row notionRow) map[string]any {
+	text := func(s string) []map[string]any {
+		if r := []rune(s); len(r) > 2000 { // Notion's limit per text object, in characters
+			s = string(r[:2000])
+		}
+		return []map[string]any{{"text": map[string]string{"content": s}}}
+	}
+	date := row.Date.Format("2006-01-02")
+	return map[string]any{
+		"Name":       map[string]any{"title": text(row.Project + " " + date)},
+		"Date":       map[string]any{"date": map[string]string{"start": date}},
+		"Project":    map[string]any{"rich_text": text(row.Project)},
+		"Hours":      map[string]any{"number": row.Hours},
+		"Top titles": map[string]any{"rich_text": text(strings.Join(row.Titles, "; "))},
+	}
+}
+
+// notionFindRow returns the page id of an existing row for the same day and project.
+func notionFindRow(row notionRow) (string, error) {
+	query := map[string]any{
+		"filter": map[string]any{"and": []map[string]any{
+			{"property": "Date", "date": map[string]string{"equals": row.Date.Format("2006-01-02")}},
+			{"property": "Project", "rich_text": map[string]string{"equals": row.Project}},
+		}},
+		"page_size": 1,
+	}
+	var resp struct {
+		Results []struct {
+			ID string `json:"id"`
+		} `json:"results"`
+	}
+	if err := notionRequest(http.MethodPost, "/databases/"+notionDatabase+"/query", query, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Results) == 0 {
+		return "", nil
+	}
+	return resp.Results[0].ID, nil
+}
+
+func notionRequest(method, path string, body, out any) error {
+	return apiRequest(method, notionAPI+path, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+notionToken)
+		req.Header.Set("Notion-Version", "2022-06-28")
+	}, body, out)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Now Playing capture is opt-in (NOW_PLAYING): the tracker asks the given
+// players what they are playing every 15 seconds, and each span records the
+// track that played longest during it.
+
+// parseNowPlaying reads "off", "on" (Music and Spotify) or a list of players.
+func parseNowPlaying(input string) ([]string, error) {
+	switch strings.ToLower(strings.TrimSpace(input)) {
+	case "off", "":
+		return nil, nil
+	case "on":
+		return []string{"Music", "Spotify"}, nil
+	}
+	var players []string
+	for _, part := range strings.Split(input, ",") {
+		name := strings.TrimSpace(part)
+		switch strings.ToLower(name) {
+		case "music":
+			players = append(players, "Music")
+		case "spotify":
+			players = append(players, "Spotify")
+		default:
+			return nil, fmt.Errorf("unsupported player %q, expected Music or Spotify", name)
+		}
+	}
+	return players, nil
+}
+
+// nowPlayingScript returns "Artist – Track" if the player is running and
+// playing. Checking "is running" first keeps the script from launching it.
+const nowPlayingScript = `if application "%[1]s" is running then
+	tell application "%[1]s"
+		if player state is playing then return (artist of current track) & " – " & (name of current track)
+	end tell
+end if
+return ""`
+
+// trackChange is when the playing track changed; track is "" for silence.
+type trackChange struct {
+	at    time.Time
+	track string
+}
+
+var nowPlaying struct {
+	sync.Mutex
+	changes []trackChange
+}
+
+// watchNowPlaying samples the players while the tracker runs. While tracking
+// is paused it leaves the players alone and records silence instead.
+func watchNowPlaying(players []string) {
+	for {
+		track := ""
+		if trackingBlocked(time.Now()) == "" {
+			for _, player := range players {
+				out, err := runAppleScript(fmt.Sprintf(nowPlayingScript, player))
+				if err == nil && strings.TrimSpace(out) != "" {
+					track = strings.TrimSpace(out)
+					break
+				}
+			}
+		}
+		now := time.Now()
+		nowPlaying.Lock()
+		if n := len(nowPlaying.changes); n == 0 || nowPlaying.changes[n-1].track != track {
+			nowPlaying.changes = append(nowPlaying.changes, trackChange{now, track})
+		}
+		// Spans are recorded when they end, so a day of history is plenty
+		for len(nowPlaying.changes) > 1 && now.Sub(nowPlaying.changes[1].at) > 24*time.Hour {
+			nowPlaying.changes = nowPlaying.changes[1:]
+		}
+		nowPlaying.Unlock()
+		time.Sleep(15 * time.Second)
+	}
+}
+
+// playingDuring is the track that played longest between start and end.
+func playingDuring(start, end time.Time) string {
+	nowPlaying.Lock()
+	defer nowPlaying.Unlock()
+	played := make(map[string]time.Duration)
+	for i, c := range nowPlaying.changes {
+		if c.track == "" {
+			continue
+		}
+		until := end
+		if i+1 < len(nowPlaying.changes) {
+			until = nowPlaying.changes[i+1].at
+		}
+		played[c.track] += overlap(start, end, c.at, until)
+	}
+	if sorted := sortedByDuration(played); len(sorted) > 0 && played[sorted[0]] > 0 {
+		return sorted[0]
+	}
+	return ""
+}
+
+// writeListening lists the tracks and artists played during focus time.
+func writeListening(w io.Writer, spans []Span) {
+	artists := make(map[string]time.Duration)
+	var focus, withMusic time.Duration
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		focus += s.Duration()
+		if s.NowPlaying == "" {
+			continue
+		}
+		withMusic += s.Duration()
+		artist, _, _ := strings.Cut(s.NowPlaying, " – ")
+		artists[artist] += s.Duration()
+	}
+	if withMusic == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nListening: %s of %s\n", shortDuration(withMusic), shortDuration(focus))
+	for i, artist := range sortedByDuration(artists) {
+		if i == 5 {
+			break
+		}
+		fmt.Fprintf(w, "  %s: %s\n", artist, shortDuration(artists[artist]))
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	obsidianStart = "<!-- focus-tracker:start -->"
+	obsidianEnd   = "<!-- focus-tracker:end -->"
+)
+
+// runObsidian writes a past day's summary into its daily note.
+func runObsidian(args []string) error {
+	fs := flag.NewFlagSet("obsidian", flag.ExitOnError)
+	dayStr := fs.String("day", "", "day to write (YYYY-MM-DD, today or yesterday)")
+	fs.Parse(args)
+
+	if obsidianVault == "" {
+		return errors.New("OBSIDIAN_VAULT is not set")
+	}
+	day, err := parseDay(*dayStr)
+	if err != nil {
+		return err
+	}
+	spans, err := readSpans(day)
+	if err != nil {
+		return err
+	}
+	return updateObsidianNote(day, totalsFromSpans(spans), overLimits(spans))
+}
+
+// obsidianNotePath expands the moment-style YYYY, MM and DD placeholders
+// Obsidian uses for daily note names.
+func obsidianNotePath(day time.Time) string {
+	name := strings.NewReplacer(
+		"YYYY", day.Format("2006"),
+		"MM", day.Format("01"),
+		"DD", day.Format("02"),
+	).Replace(obsidianDailyNote)
+	return filepath.Join(obsidianVault, name)
+}
+
+// updateObsidianNote replaces the marked focus section of the day's note, or
+// appends one if the note has none yet. The note is created if missing.
+func updateObsidianNote(day time.Time, buckets map[string]Totals, over []string) error {
+	path := obsidianNotePath(day)
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	section := obsidianStart + "\n" + obsidianMarkdown(buckets, over) + obsidianEnd
+	start := bytes.Index(content, []byte(obsidianStart))
+	end := bytes.Index(content, []byte(obsidianEnd))
+	switch {
+	case start >= 0 && end > start:
+		content = append(content[:start:start], append([]byte(section), content[end+len(obsidianEnd):]...)...)
+	case len(content) == 0:
+		content = []byte(section + "\n")
+	default:
+		if !bytes.HasSuffix(content, []byte("\n")) {
+			content = append(content, '\n')
+		}
+		content = append(content, []byte("\n"+section+"\n")...)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func obsidianMarkdown(buckets map[string]Totals, over []string) string {
+	type appRow struct {
+		app    string
+		total  time.Duration
+		titles map[string]time.Duration
+	}
+	rows := make(map[string]*appRow)
+	bucketTotals := make(map[string]time.Duration)
+	var outsideTotal time.Duration
+	for suffix, totals := range buckets {
+		for app, titles := range totals {
+			r, ok := rows[app]
+			if !ok {
+				r = &appRow{app: app, titles: make(map[string]time.Duration)}
+				rows[app] = r
+			}
+			for title, d := range titles {
+				r.total += d
+				r.titles[title] += d
+				bucketTotals[suffix] += d
+				if strings.HasPrefix(suffix, "_outside") {
+					outsideTotal += d
+				}
+			}
+		}
+	}
+
+	sorted := make([]*appRow, 0, len(rows))
+	for _, r := range rows {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].total > sorted[j].total })
+
+	var b strings.Builder
+	b.WriteString("### Focus time\n")
+	fmt.Fprintf(&b, "- Work hours: %s\n", shortDuration(bucketTotals[""]))
+	if lunch := bucketTotals["_lunch"]; lunch > 0 {
+		fmt.Fprintf(&b, "- Lunch: %s\n", shortDuration(lunch))
+	}
+	fmt.Fprintf(&b, "- Outside hours: %s\n", shortDuration(outsideTotal))
+	for _, suffix := range sortedKeys(bucketTotals) {
+		if suffix != "" && suffix != "_outside" && suffix != "_lunch" {
+			fmt.Fprintf(&b, "  - %s: %s\n", bucketLabel(suffix), shortDuration(bucketTotals[suffix]))
+		}
+	}
+	for _, line := range over {
+		fmt.Fprintf(&b, "- **Over limit:** %s\n", line)
+	}
+	b.WriteString("\n")
+	b.WriteString("| App | Time | Top windows |\n| --- | ---: | --- |\n")
+	for _, r := range sorted {
+		titles := make([]string, 0, len(r.titles))
+		for t := range r.titles {
+			if t != "" {
+				titles = append(titles, t)
+			}
+		}
+		sort.Slice(titles, func(i, j int) bool { return r.titles[titles[i]] > r.titles[titles[j]] })
+		if len(titles) > 3 {
+			titles = titles[:3]
+		}
+		for i, t := range titles {
+			titles[i] = fmt.Sprintf("%s (%s)", markdownCell(t), shortDuration(r.titles[t]))
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", markdownCell(r.app), shortDuration(r.total), strings.Join(titles, ", "))
+	}
+	return b.String()
+}
+
+// markdownCell keeps pipes and newlines in titles from breaking the table.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
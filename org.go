@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// runOrgExport writes the day's spans as org-mode CLOCK entries, one heading
+// per project with a sub-heading per app and window title.
+func runOrgExport(args []string) error {
+	fs := flag.NewFlagSet("org", flag.ExitOnError)
+	dayStr, outPath := dayFlags(fs)
+	fs.Parse(args)
+
+	day, err := parseDay(*dayStr)
+	if err != nil {
+		return err
+	}
+	spans, err := readSpans(day)
+	if err != nil {
+		return err
+	}
+
+	w, err := outputFile(*outPath)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writeOrg(w, day, withContexts(spans))
+	return nil
+}
+
+func writeOrg(w io.Writer, day time.Time, spans []Span) {
+	grouped := make(map[string]map[string][]Span)
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		project := s.Project
+		if project == "" {
+			project = "(no project)"
+		}
+		heading := s.App
+		if s.Title != "" {
+			heading += ": " + s.Title
+		}
+		if grouped[project] == nil {
+			grouped[project] = make(map[string][]Span)
+		}
+		grouped[project][heading] = append(grouped[project][heading], s)
+	}
+
+	fmt.Fprintf(w, "#+TITLE: Focus %s\n\n", day.Format("2006-01-02"))
+	for _, project := range sortedKeys(grouped) {
+		fmt.Fprintf(w, "* %s\n", project)
+		for _, heading := range sortedKeys(grouped[project]) {
+			fmt.Fprintf(w, "** %s\n:LOGBOOK:\n", heading)
+			// org lists the most recent clock first
+			clocks := grouped[project][heading]
+			for i := len(clocks) - 1; i >= 0; i-- {
+				fmt.Fprintf(w, "CLOCK: %s--%s => %s\n", orgTimestamp(clocks[i].Start), orgTimestamp(clocks[i].End), orgDuration(clocks[i].Start, clocks[i].End))
+			}
+			fmt.Fprintln(w, ":END:")
+		}
+	}
+}
+
+func orgTimestamp(t time.Time) string {
+	return t.Local().Format("[2006-01-02 Mon 15:04]")
+}
+
+// orgDuration matches org's own "=>  1:05" formatting, which counts whole
+// minutes between the two timestamps as displayed.
+func orgDuration(start, end time.Time) string {
+	mins := int(end.Truncate(time.Minute).Sub(start.Truncate(time.Minute)) / time.Minute)
+	return fmt.Sprintf("%2d:%02d", mins/60, mins%60)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Deliveries to integrations that fail on the network are kept in
+// LOG_PATH/outbox, one JSON file each, and retried with exponential
+// backoff by the running tracker. After OUTBOX_MAX_ATTEMPTS they move to
+// LOG_PATH/outbox/dead until retried or dropped by hand.
+
+const (
+	outboxInflux = "influx" // payload: line protocol lines
+	outboxCrash  = "crash"  // payload: crash report text
+	outboxSync   = "sync"   // payload: syncPayload
+)
+
+const (
+	outboxFirstRetry = 30 * time.Second
+	outboxMaxBackoff = 6 * time.Hour
+)
+
+type outboxEntry struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Payload   json.RawMessage `json:"payload"`
+	Created   time.Time       `json:"created"`
+	Attempts  int             `json:"attempts"`
+	NextTry   time.Time       `json:"next_attempt"`
+	LastError string          `json:"last_error"`
+}
+
+type syncPayload struct {
+	Target string `json:"target"`
+	Day    string `json:"day"`
+}
+
+func outboxDir() string {
+	return filepath.Join(logs, "outbox")
+}
+
+func deadLetterDir() string {
+	return filepath.Join(outboxDir(), "dead")
+}
+
+// enqueue saves a delivery that failed with err for later retries.
+func enqueue(kind string, payload any, err error) error {
+	data, merr := json.Marshal(payload)
+	if merr != nil {
+		return merr
+	}
+	now := time.Now()
+	e := outboxEntry{
+		ID:        fmt.Sprintf("%s-%s-%06x", now.Format("20060102-150405"), kind, now.Nanosecond()>>8),
+		Kind:      kind,
+		Payload:   data,
+		Created:   now,
+		Attempts:  1,
+		NextTry:   now.Add(outboxFirstRetry),
+		LastError: err.Error(),
+	}
+	if err := os.MkdirAll(outboxDir(), 0755); err != nil {
+		return err
+	}
+	noteEvent("queued %s delivery", kind)
+	return writeOutboxEntry(outboxDir(), e)
+}
+
+func writeOutboxEntry(dir string, e outboxEntry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, e.ID+".json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func readOutbox(dir string) ([]outboxEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []outboxEntry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var e outboxEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// deliver makes one attempt at an outbox entry.
+func deliver(e outboxEntry) error {
+	switch e.Kind {
+	case outboxInflux:
+		var lines []string
+		if err := json.Unmarshal(e.Payload, &lines); err != nil {
+			return err
+		}
+		return influxWrite(lines)
+	case outboxCrash:
+		var report string
+		if err := json.Unmarshal(e.Payload, &report); err != nil {
+			return err
+		}
+		return postCrashReport([]byte(report))
+	case outboxSync:
+		var p syncPayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return err
+		}
+		day, err := time.ParseInLocation("2006-01-02", p.Day, time.Local)
+		if err != nil {
+			return err
+		}
+		if syncTargets[p.Target] == nil {
+			return fmt.Errorf("unknown sync target %q", p.Target)
+		}
+		return syncDay(p.Target, day)
+	}
+	return fmt.Errorf("unknown outbox entry kind %q", e.Kind)
+}
+
+// retryEntry attempts a queued entry and then removes it, reschedules it,
+// or moves it to the dead letters.
+func retryEntry(e outboxEntry) error {
+	err := deliver(e)
+	path := filepath.Join(outboxDir(), e.ID+".json")
+	if err == nil {
+		return os.Remove(path)
+	}
+	e.Attempts++
+	e.LastError = err.Error()
+	if !retryable(err) || e.Attempts >= outboxMaxAttempts {
+		if merr := os.MkdirAll(deadLetterDir(), 0755); merr != nil {
+			return merr
+		}
+		if werr := writeOutboxEntry(deadLetterDir(), e); werr != nil {
+			return werr
+		}
+		os.Remove(path)
+		fmt.Printf("%s Gave up on %s delivery %s after %d attempts: %v (see focus-tracker outbox)\n", glyphs.warn, e.Kind, e.ID, e.Attempts, err)
+		return err
+	}
+	backoff := outboxFirstRetry << (e.Attempts - 1)
+	if backoff > outboxMaxBackoff || backoff <= 0 {
+		backoff = outboxMaxBackoff
+	}
+	e.NextTry = time.Now().Add(backoff)
+	if werr := writeOutboxEntry(outboxDir(), e); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// runOutbox retries due deliveries while the tracker runs.
+func runOutbox() {
+	for range time.Tick(30 * time.Second) {
+		entries, err := readOutbox(outboxDir())
+		if err != nil {
+			fmt.Printf("%s Outbox: %v\n", glyphs.warn, err)
+			continue
+		}
+		for _, e := range entries {
+			if time.Now().Before(e.NextTry) {
+				continue
+			}
+			if retryEntry(e) == nil {
+				noteEvent("delivered queued %s", e.Kind)
+			}
+		}
+	}
+}
+
+// runOutboxCommand handles "outbox [list | retry [ID] | drop ID]".
+func runOutboxCommand(args []string) error {
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	pending, err := readOutbox(outboxDir())
+	if err != nil {
+		return err
+	}
+	dead, err := readOutbox(deadLetterDir())
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		if len(pending)+len(dead) == 0 {
+			fmt.Println("The outbox is empty.")
+			return nil
+		}
+		for _, e := range pending {
+			fmt.Printf("%s  %s  attempt %d, next %s\n    %s\n", e.ID, outboxLabel(e), e.Attempts, e.NextTry.Format("2006-01-02 15:04"), e.LastError)
+		}
+		for _, e := range dead {
+			fmt.Printf("%s  %s  %s after %d attempts\n    %s\n", e.ID, outboxLabel(e), paintOver("dead"), e.Attempts, e.LastError)
+		}
+		return nil
+	case "retry":
+		var failed int
+		for _, group := range []struct {
+			dir     string
+			entries []outboxEntry
+		}{{outboxDir(), pending}, {deadLetterDir(), dead}} {
+			for _, e := range group.entries {
+				if len(args) > 1 && e.ID != args[1] {
+					continue
+				}
+				if group.dir == deadLetterDir() {
+					// A retried dead letter gets a fresh set of attempts
+					e.Attempts = 0
+					if err := writeOutboxEntry(outboxDir(), e); err != nil {
+						return err
+					}
+					os.Remove(filepath.Join(deadLetterDir(), e.ID+".json"))
+				}
+				if err := retryEntry(e); err != nil {
+					failed++
+					fmt.Printf("%s %s: %v\n", glyphs.warn, e.ID, err)
+				} else {
+					fmt.Printf("%s Delivered %s\n", glyphs.ok, e.ID)
+				}
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d deliveries failed", failed)
+		}
+		return nil
+	case "drop":
+		if len(args) < 2 {
+			return errors.New("usage: outbox drop ID")
+		}
+		for _, dir := range []string{outboxDir(), deadLetterDir()} {
+			if err := os.Remove(filepath.Join(dir, args[1]+".json")); err == nil {
+				fmt.Printf("%s Dropped %s\n", glyphs.ok, args[1])
+				return nil
+			}
+		}
+		return fmt.Errorf("no outbox entry %q", args[1])
+	}
+	return fmt.Errorf("usage: outbox [list | retry [ID] | drop ID]")
+}
+
+func outboxLabel(e outboxEntry) string {
+	switch e.Kind {
+	case outboxSync:
+		var p syncPayload
+		json.Unmarshal(e.Payload, &p)
+		return fmt.Sprintf("sync %s %s", p.Target, p.Day)
+	case outboxInflux:
+		var lines []string
+		json.Unmarshal(e.Payload, &lines)
+		return fmt.Sprintf("influx, %d points", len(lines))
+	}
+	return e.Kind
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var isoWeekPattern = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+
+// parseReportPeriod turns the -week, -month or -from and -to flags of
+// report into the days [from, to) and a label for the heading, empty for a
+// range of days.
+func parseReportPeriod(week, month, fromDay, toDay string) (from, to time.Time, label string, err error) {
+	given := 0
+	for _, flag := range []string{week, month, fromDay + toDay} {
+		if flag != "" {
+			given++
+		}
+	}
+	if given > 1 {
+		return from, to, "", fmt.Errorf("use one of -week, -month or -from")
+	}
+	if fromDay != "" || toDay != "" {
+		if fromDay == "" {
+			return from, to, "", fmt.Errorf("-to needs -from")
+		}
+		if from, err = parseDay(fromDay); err != nil {
+			return from, to, "", err
+		}
+		if to, err = parseDay(toDay); err != nil {
+			return from, to, "", err
+		}
+		if to.Before(from) {
+			return from, to, "", fmt.Errorf("-to is before -from")
+		}
+		return from, to.AddDate(0, 0, 1), "", nil
+	}
+	if month != "" {
+		if from, err = time.ParseInLocation("2006-01", month, time.Local); err != nil {
+			return from, to, "", fmt.Errorf("invalid month %q, expected YYYY-MM", month)
+		}
+		return from, from.AddDate(0, 1, 0), from.Format("January 2006"), nil
+	}
+	if from, err = parseISOWeek(week); err != nil {
+		return from, to, "", err
+	}
+	return from, from.AddDate(0, 0, 7), "week " + week, nil
+}
+
+// parseISOWeek returns the Monday starting an ISO week such as 2024-W19.
+// Week 1 is the week with the year's first Thursday, so it always holds
+// January 4th.
+func parseISOWeek(input string) (time.Time, error) {
+	m := isoWeekPattern.FindStringSubmatch(input)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid week %q, expected YYYY-Www, e.g. 2024-W19", input)
+	}
+	year, _ := strconv.Atoi(m[1])
+	week, _ := strconv.Atoi(m[2])
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local)
+	monday := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+(week-1)*7)
+	if y, w := monday.ISOWeek(); y != year || w != week {
+		return time.Time{}, fmt.Errorf("%d has no week %d", year, week)
+	}
+	return monday, nil
+}
+
+// reportHeading names the days [from, to) of a report, e.g. "2024-05-06",
+// "2024-05-01 to 2024-05-15" or "week 2024-W19 (2024-05-06 to 2024-05-12)".
+func reportHeading(label string, from, to time.Time) string {
+	last := to.AddDate(0, 0, -1)
+	if !last.After(from) {
+		return from.Format("2006-01-02")
+	}
+	days := fmt.Sprintf("%s to %s", from.Format("2006-01-02"), last.Format("2006-01-02"))
+	if label == "" {
+		return days
+	}
+	return fmt.Sprintf("%s (%s)", label, days)
+}
+
+// writePeriodReport summarizes the spans of several days: the work and
+// outside-hours totals, each day's share and the time per app, project,
+// category and domain. Away time is left out of the totals.
+func writePeriodReport(w io.Writer, label string, from, to time.Time, spans []Span) {
+	work := make(map[string]time.Duration)
+	outside := make(map[string]time.Duration)
+	var totalWork, totalOutside, away time.Duration
+	for _, s := range spans {
+		day := s.Start.Format("2006-01-02")
+		switch {
+		case s.Away():
+			away += s.Duration()
+		case s.Work:
+			work[day] += s.Duration()
+			totalWork += s.Duration()
+		default:
+			outside[day] += s.Duration()
+			totalOutside += s.Duration()
+		}
+	}
+
+	fmt.Fprintf(w, "Focus report for %s\n", reportHeading(label, from, to))
+	fmt.Fprintf(w, "----------------------------------------\n")
+	fmt.Fprintf(w, "%s: %s\n", bucketLabel(""), paintBucket("", shortDuration(totalWork)))
+	fmt.Fprintf(w, "%s: %s\n", bucketLabel("_outside"), paintBucket("_outside", shortDuration(totalOutside)))
+	if away > 0 {
+		fmt.Fprintf(w, "away: %s\n", shortDuration(away))
+	}
+
+	var longest time.Duration
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		if t := work[key] + outside[key]; t > longest {
+			longest = t
+		}
+	}
+	fmt.Fprintf(w, "\nBy day\n")
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		line := fmt.Sprintf("  %s  %6s work  %6s outside  %s", d.Format("Mon 2006-01-02"),
+			shortDuration(work[key]), shortDuration(outside[key]), bar(work[key]+outside[key], longest))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+
+	if len(spans) == 0 {
+		return
+	}
+	writeApps(w, spans)
+	writeProjects(w, spans)
+	writeCategories(w, spans)
+	writeDomains(w, spans)
+	writeIssues(w, spans)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// runPlan compares the calendar (the plan) with what was actually tracked.
+func runPlan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	dayStr, outPath := dayFlags(fs)
+	fs.Parse(args)
+
+	if calendarSource == "" {
+		return errors.New("CALENDAR_ICS is not set")
+	}
+	day, err := parseDay(*dayStr)
+	if err != nil {
+		return err
+	}
+	spans, err := readSpans(day)
+	if err != nil {
+		return err
+	}
+	events, err := calendarEvents(calendarSource, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	w, err := outputFile(*outPath)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writePlan(w, day, events, spans)
+	return nil
+}
+
+// isMeetingSpan reports whether the span was spent in a call.
+func isMeetingSpan(s Span) bool {
+	return meetingApps[strings.ToLower(s.App)] || strings.HasPrefix(s.Title, "Meet - ")
+}
+
+// isMeetingEvent guesses whether an event is a call rather than a work block.
+func isMeetingEvent(ev calendarEvent) bool {
+	if ev.Attendees > 0 {
+		return true
+	}
+	text := strings.ToLower(ev.Text)
+	for _, host := range []string{"zoom.us/", "teams.microsoft.com/", "meet.google.com/", "webex.com/"} {
+		if strings.Contains(text, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventProject finds a project named in the event summary.
+func eventProject(ev calendarEvent) string {
+	summary := strings.ToLower(ev.Summary)
+	for _, p := range projects {
+		if strings.Contains(summary, strings.ToLower(p.name)) {
+			return p.name
+		}
+	}
+	return ""
+}
+
+// meetingOverrun is how long meeting spans continued past the event's end.
+func meetingOverrun(ev calendarEvent, spans []Span) time.Duration {
+	end := ev.End
+	for _, s := range spans {
+		if !isMeetingSpan(s) || s.Start.After(end.Add(time.Minute)) || !s.End.After(end) {
+			continue
+		}
+		end = s.End
+	}
+	return end.Sub(ev.End)
+}
+
+func writePlan(w io.Writer, day time.Time, events []calendarEvent, spans []Span) {
+	fmt.Fprintf(w, "Plan vs actual for %s\n", day.Format("2006-01-02"))
+	fmt.Fprintf(w, "----------------------------------------\n")
+
+	var planned, asPlanned time.Duration
+	for _, ev := range events {
+		if ev.AllDay {
+			continue
+		}
+		length := ev.End.Sub(ev.Start)
+		project := eventProject(ev)
+		meeting := project == "" && isMeetingEvent(ev)
+
+		apps := make(map[string]time.Duration)
+		var matched time.Duration
+		for _, s := range spans {
+			d := overlap(s.Start, s.End, ev.Start, ev.End)
+			if d <= 0 {
+				continue
+			}
+			apps[s.App] += d
+			p := s.Project
+			if p == "" {
+				p = projectFor(s)
+			}
+			if (project != "" && p == project) || (meeting && isMeetingSpan(s)) {
+				matched += d
+			}
+		}
+
+		kind := ""
+		switch {
+		case project != "":
+			kind = "project " + project + ", "
+		case meeting:
+			kind = "meeting, "
+		}
+		fmt.Fprintf(w, "%s-%s %s (%s%s planned)\n", ev.Start.Format("15:04"), ev.End.Format("15:04"), ev.Summary, kind, shortDuration(length))
+
+		var parts []string
+		for i, app := range sortedByDuration(apps) {
+			if i == 3 {
+				break
+			}
+			parts = append(parts, fmt.Sprintf("%s %s", app, shortDuration(apps[app])))
+		}
+		if len(parts) == 0 {
+			parts = append(parts, "nothing tracked")
+		}
+		line := "  " + strings.Join(parts, ", ")
+		if project != "" || meeting {
+			planned += length
+			asPlanned += matched
+			line += fmt.Sprintf(" (%d%% as planned)", percent(matched, length))
+		}
+		if meeting {
+			if over := meetingOverrun(ev, spans); over >= time.Minute {
+				line += fmt.Sprintf(" %s ran %s over", glyphs.warn, shortDuration(over))
+			}
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	if planned > 0 {
+		fmt.Fprintf(w, "\nSchedule adherence: %d%% of %s planned time spent as planned\n", percent(asPlanned, planned), shortDuration(planned))
+	}
+}
+
+func percent(part, whole time.Duration) int {
+	if whole <= 0 {
+		return 0
+	}
+	return int(float64(part)/float64(whole)*100 + 0.5)
+}
@@ -0,0 +1,38 @@
+package main
+
+// focusedWindow is what the tracker sees of the frontmost window in one
+// probe. titleErr is set when the app is known but its title could not be
+// read, which feeds degraded mode.
+type focusedWindow struct {
+	app      string
+	bundleID string // macOS bundle ID, or the window class elsewhere
+	path     string // app bundle or executable
+	title    string
+	edited   bool   // the document has unsaved changes
+	url      string // the active tab of a browser, with BROWSER_URLS
+	titleErr error
+}
+
+// platform is how the tracker observes the desktop. Each OS has its own in
+// a platform_<os>.go file, selected by build tags. The backends live in
+// package main like the rest of the tracker: they read its settings
+// (FOCUS_EVENTS, BROWSER_URLS, the allowlist), run their helpers under the
+// watchdog's providerContext and share runAppleScript and stripEditMarker
+// with the notification, gap prompt and editing code, all of which a
+// package of their own would have to get back as exported API.
+type platform interface {
+	// focus reads the frontmost app and window; an error means the app
+	// itself is unknown and the probe failed.
+	focus() (focusedWindow, error)
+	// idleSeconds is the time since the last keyboard or mouse input.
+	idleSeconds() int
+	// sources names what focus, titles and idle time are read with, for
+	// diagnostics.
+	sources() (app, title, idle string)
+	// restart restarts the service the probes talk to when they stall and
+	// names it, or returns "" where there is nothing to restart.
+	restart() string
+}
+
+// desktop is the platform the tracker runs on.
+var desktop platform = newPlatform()
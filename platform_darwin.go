@@ -0,0 +1,117 @@
+//go:build darwin
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// macOS reads the frontmost app and window through System Events and the
+// idle time from the HID system, all without cgo.
+type macOS struct{}
+
+func newPlatform() platform {
+	return macOS{}
+}
+
+func (macOS) sources() (app, title, idle string) {
+	if focusEvents {
+		return "NSWorkspace notifications", "Accessibility", idleBackend
+	}
+	return "Automation: System Events", "Accessibility", idleBackend
+}
+
+// restart quits System Events, which stops answering now and then; macOS
+// relaunches it on the next AppleScript call.
+func (macOS) restart() string {
+	exec.Command("killall", "System Events").Run()
+	return "System Events"
+}
+
+func (macOS) focus() (focusedWindow, error) {
+	w, ok := focusedWindow{}, false
+	if focusEvents {
+		w, ok = helperFocus()
+	}
+	if !ok {
+		var err error
+		if w, err = pollFocus(); err != nil {
+			return w, err
+		}
+	}
+	if browserURLs && allowedApp(w.app, w.bundleID) {
+		w.url = activeTabURL(w.app)
+	}
+	return w, nil
+}
+
+// pollFocus asks System Events for the frontmost app and its window, two
+// osascript runs per call.
+func pollFocus() (focusedWindow, error) {
+	var w focusedWindow
+	var err error
+	w.app, err = runAppleScript(`tell application "System Events" to get name of first process whose frontmost is true`)
+	if err != nil {
+		return w, err
+	}
+	out, _ := runAppleScript(`set p to path to frontmost application
+return (id of application (p as text)) & tab & (POSIX path of p)`)
+	w.bundleID, w.path, _ = strings.Cut(out, "\t")
+	w.path = strings.TrimSuffix(w.path, "/")
+
+	// Handle VS Code's Electron quirk
+	appProcessName := w.app
+	if w.bundleID == "com.microsoft.VSCode" {
+		w.app = "Visual Studio Code"
+		appProcessName = "Electron"
+	}
+
+	w.title, w.edited, w.titleErr = windowState(appProcessName)
+	if w.app == "Visual Studio Code" {
+		w.title = strings.TrimSuffix(w.title, " — Visual Studio Code")
+	}
+	return w, nil
+}
+
+// windowState reads the front window's title and whether its document
+// has unsaved changes (AXEdited, which apps without documents lack).
+func windowState(appProcessName string) (title string, edited bool, err error) {
+	script := fmt.Sprintf(`tell application "System Events" to tell process "%s"
+	set t to value of attribute "AXTitle" of window 1
+	set e to false
+	try
+		set e to value of attribute "AXEdited" of window 1
+	end try
+	return (t as text) & tab & (e as text)
+end tell`, appProcessName)
+	out, err := runAppleScript(script)
+	if err != nil {
+		return "", false, err
+	}
+	title, state, _ := strings.Cut(out, "\t")
+	title, marked := stripEditMarker(title)
+	return title, marked || state == "true", nil
+}
+
+func (macOS) idleSeconds() int {
+	if idle, ok := nativeIdleSeconds(); ok {
+		return idle
+	}
+	return ioregIdleSeconds()
+}
+
+// ioregIdleSeconds reads HIDIdleTime from the I/O registry, for builds
+// without cgo.
+func ioregIdleSeconds() int {
+	cmd := exec.Command("bash", "-c", `ioreg -c IOHIDSystem | awk '/HIDIdleTime/ {print int($NF/1000000000); exit}'`)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0
+	}
+	idleStr := strings.TrimSpace(string(out))
+	idle, _ := strconv.Atoi(idleStr)
+	return idle
+}
@@ -0,0 +1,121 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// x11 reads the active window with xprop (_NET_ACTIVE_WINDOW, set by every
+// EWMH window manager) and the idle time from the XScreenSaver extension
+// with xprintidle, falling back to xssstate.
+type x11 struct{}
+
+// newPlatform picks the backend for the session the tracker runs in.
+func newPlatform() platform {
+	if waylandSession() {
+		return wayland{gnome: isGNOME()}
+	}
+	return x11{}
+}
+
+func (x11) sources() (app, title, idle string) {
+	return "xprop _NET_ACTIVE_WINDOW", "xprop _NET_WM_NAME", "XScreenSaver (xprintidle)"
+}
+
+var (
+	xpropWindowID = regexp.MustCompile(`window id # (0x[0-9a-fA-F]+)`)
+	// xprop prints strings quoted with C escapes, e.g. WM_CLASS(STRING) = "navigator", "firefox"
+	xpropString = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+)
+
+// restart has nothing to do: every probe is a new xprop.
+func (x11) restart() string {
+	return ""
+}
+
+func (x11) focus() (focusedWindow, error) {
+	var w focusedWindow
+	if os.Getenv("DISPLAY") == "" {
+		return w, errors.New("DISPLAY is not set; the X11 backend needs an X session")
+	}
+	out, err := exec.CommandContext(providerContext(), "xprop", "-root", "_NET_ACTIVE_WINDOW").Output()
+	if err != nil {
+		return w, fmt.Errorf("xprop: %v", err)
+	}
+	m := xpropWindowID.FindStringSubmatch(string(out))
+	if m == nil || m[1] == "0x0" {
+		return w, errors.New("no active window")
+	}
+	out, err = exec.CommandContext(providerContext(), "xprop", "-id", m[1], "WM_CLASS", "_NET_WM_PID", "_NET_WM_NAME", "WM_NAME").Output()
+	if err != nil {
+		return w, fmt.Errorf("xprop: %v", err)
+	}
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		name, value, ok := strings.Cut(line, " = ")
+		if !ok {
+			continue
+		}
+		if i := strings.Index(name, "("); i > 0 {
+			name = name[:i]
+		}
+		props[name] = value
+	}
+
+	// The class ("firefox", "Code") names the app; the instance is often lower case
+	var class []string
+	for _, s := range xpropString.FindAllStringSubmatch(props["WM_CLASS"], -1) {
+		class = append(class, xpropUnquote(s[1]))
+	}
+	if len(class) == 0 {
+		return w, errors.New("active window has no WM_CLASS")
+	}
+	w.app, w.bundleID = class[len(class)-1], class[len(class)-1]
+	if pid, err := strconv.Atoi(strings.TrimSpace(props["_NET_WM_PID"])); err == nil {
+		w.path, _ = os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	}
+
+	name := props["_NET_WM_NAME"]
+	if name == "" {
+		name = props["WM_NAME"]
+	}
+	if m := xpropString.FindStringSubmatch(name); m != nil {
+		w.title = xpropUnquote(m[1])
+	} else {
+		w.titleErr = errors.New("active window has no title")
+	}
+	// GTK and Qt editors mark unsaved documents with a leading "*"
+	if t := strings.TrimPrefix(w.title, "*"); t != w.title {
+		w.title, w.edited = t, true
+	}
+	var marked bool
+	w.title, marked = stripEditMarker(w.title)
+	w.edited = w.edited || marked
+	return w, nil
+}
+
+func xpropUnquote(s string) string {
+	if u, err := strconv.Unquote(`"` + s + `"`); err == nil {
+		return u
+	}
+	return s
+}
+
+func (x11) idleSeconds() int {
+	if out, err := exec.Command("xprintidle").Output(); err == nil {
+		ms, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+		return ms / 1000
+	}
+	if out, err := exec.Command("xssstate", "-i").Output(); err == nil {
+		ms, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+		return ms / 1000
+	}
+	return 0
+}
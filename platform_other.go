@@ -0,0 +1,32 @@
+//go:build !darwin && !linux && !windows
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+// unsupported lets the reporting commands run on any OS; only tracking
+// needs a real platform.
+type unsupported struct{}
+
+func newPlatform() platform {
+	return unsupported{}
+}
+
+func (unsupported) sources() (app, title, idle string) {
+	return "unsupported", "unsupported", "unsupported"
+}
+
+func (unsupported) restart() string {
+	return ""
+}
+
+func (unsupported) focus() (focusedWindow, error) {
+	return focusedWindow{}, errors.New("tracking is not supported on " + runtime.GOOS)
+}
+
+func (unsupported) idleSeconds() int {
+	return 0
+}
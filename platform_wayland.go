@@ -0,0 +1,176 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// wayland reads the focused toplevel from the compositor: GNOME Shell through
+// its introspection D-Bus API, wlroots compositors (Sway, Hyprland, river)
+// through the wlr-foreign-toplevel-management protocol as listed by lswt.
+// Idle time comes from org.freedesktop.ScreenSaver, or Mutter's idle
+// monitor on GNOME, which does not implement it.
+type wayland struct {
+	gnome bool
+}
+
+func (p wayland) sources() (app, title, idle string) {
+	if p.gnome {
+		return "GNOME Shell Introspect", "GNOME Shell Introspect", "Mutter IdleMonitor"
+	}
+	return "lswt (wlr-foreign-toplevel)", "lswt (wlr-foreign-toplevel)", "org.freedesktop.ScreenSaver"
+}
+
+// restart leaves the compositor alone; the next probe asks it again.
+func (wayland) restart() string {
+	return ""
+}
+
+func (p wayland) focus() (focusedWindow, error) {
+	if p.gnome {
+		return gnomeFocus()
+	}
+	return wlrFocus()
+}
+
+// busctlCall calls a method on the session bus and decodes the reply data.
+func busctlCall(out interface{}, dest, path, iface, method string) error {
+	data, err := exec.CommandContext(providerContext(), "busctl", "--user", "--json=short", "call", dest, path, iface, method).Output()
+	if err != nil {
+		if e, ok := err.(*exec.ExitError); ok && len(e.Stderr) > 0 {
+			return fmt.Errorf("%s: %s", method, strings.TrimSpace(string(e.Stderr)))
+		}
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	var reply struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	return json.Unmarshal(reply.Data, out)
+}
+
+// gnomeFocus asks GNOME Shell for its windows (a{ta{sv}}) and picks the one
+// with focus. GNOME only answers when introspection is allowed:
+// gsettings set org.gnome.shell introspect true
+func gnomeFocus() (focusedWindow, error) {
+	var w focusedWindow
+	type variant struct {
+		Data json.RawMessage `json:"data"`
+	}
+	var windows []map[string]map[string]variant
+	if err := busctlCall(&windows, "org.gnome.Shell", "/org/gnome/Shell/Introspect", "org.gnome.Shell.Introspect", "GetWindows"); err != nil {
+		return w, err
+	}
+	if len(windows) == 0 {
+		return w, errors.New("GetWindows: empty reply")
+	}
+	for _, props := range windows[0] {
+		var focused bool
+		if v, ok := props["has-focus"]; !ok || json.Unmarshal(v.Data, &focused) != nil || !focused {
+			continue
+		}
+		var appID string
+		if v, ok := props["app-id"]; ok {
+			json.Unmarshal(v.Data, &appID)
+		}
+		w.bundleID = strings.TrimSuffix(appID, ".desktop")
+		w.app = waylandAppName(w.bundleID)
+		if v, ok := props["title"]; ok && json.Unmarshal(v.Data, &w.title) == nil {
+			var marked bool
+			w.title, marked = stripEditMarker(w.title)
+			w.edited = marked
+		} else {
+			w.titleErr = errors.New("focused window has no title")
+		}
+		return w, nil
+	}
+	return w, errors.New("no focused window")
+}
+
+// wlrFocus lists the toplevels with lswt and picks the activated one.
+func wlrFocus() (focusedWindow, error) {
+	var w focusedWindow
+	out, err := exec.CommandContext(providerContext(), "lswt", "-j").Output()
+	if err != nil {
+		return w, fmt.Errorf("lswt: %v", err)
+	}
+	var list struct {
+		Toplevels []struct {
+			Title     *string `json:"title"`
+			AppID     string  `json:"app-id"`
+			Activated bool    `json:"activated"`
+		} `json:"toplevels"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return w, fmt.Errorf("lswt: %v", err)
+	}
+	for _, t := range list.Toplevels {
+		if !t.Activated {
+			continue
+		}
+		w.bundleID = t.AppID
+		w.app = waylandAppName(t.AppID)
+		if t.Title == nil {
+			w.titleErr = errors.New("focused window has no title")
+			return w, nil
+		}
+		w.title = *t.Title
+		if s := strings.TrimPrefix(w.title, "*"); s != w.title {
+			w.title, w.edited = s, true
+		}
+		var marked bool
+		w.title, marked = stripEditMarker(w.title)
+		w.edited = w.edited || marked
+		return w, nil
+	}
+	return w, errors.New("no activated toplevel")
+}
+
+// waylandAppName turns an app ID into a name: "org.gnome.Nautilus" becomes
+// "Nautilus", "firefox" stays as it is.
+func waylandAppName(appID string) string {
+	if i := strings.LastIndex(appID, "."); i >= 0 && i < len(appID)-1 {
+		return appID[i+1:]
+	}
+	return appID
+}
+
+func (p wayland) idleSeconds() int {
+	var ms []uint64
+	if p.gnome {
+		if busctlCall(&ms, "org.gnome.Mutter.IdleMonitor", "/org/gnome/Mutter/IdleMonitor/Core", "org.gnome.Mutter.IdleMonitor", "GetIdletime") == nil && len(ms) == 1 {
+			return int(ms[0] / 1000)
+		}
+		return 0
+	}
+	// KDE, the main implementation, answers in milliseconds
+	if busctlCall(&ms, "org.freedesktop.ScreenSaver", "/org/freedesktop/ScreenSaver", "org.freedesktop.ScreenSaver", "GetSessionIdleTime") == nil && len(ms) == 1 {
+		return int(ms[0] / 1000)
+	}
+	return 0
+}
+
+// waylandSession reports whether the tracker runs in a Wayland session,
+// where xprop only sees XWayland windows.
+func waylandSession() bool {
+	return os.Getenv("WAYLAND_DISPLAY") != "" || os.Getenv("XDG_SESSION_TYPE") == "wayland"
+}
+
+// isGNOME reports whether the desktop is GNOME Shell, going by
+// XDG_CURRENT_DESKTOP ("GNOME", "ubuntu:GNOME").
+func isGNOME() bool {
+	for _, d := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		if strings.EqualFold(d, "GNOME") {
+			return true
+		}
+	}
+	return false
+}
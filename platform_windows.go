@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// win32 reads the foreground window and the last input time straight from
+// user32 and kernel32; no helper processes are spawned.
+type win32 struct{}
+
+func newPlatform() platform {
+	return win32{}
+}
+
+var (
+	user32                         = syscall.NewLazyDLL("user32.dll")
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procGetForegroundWindow        = user32.NewProc("GetForegroundWindow")
+	procGetWindowTextLengthW       = user32.NewProc("GetWindowTextLengthW")
+	procGetWindowTextW             = user32.NewProc("GetWindowTextW")
+	procGetWindowThreadProcessID   = user32.NewProc("GetWindowThreadProcessId")
+	procGetLastInputInfo           = user32.NewProc("GetLastInputInfo")
+	procGetTickCount               = kernel32.NewProc("GetTickCount")
+	procQueryFullProcessImageNameW = kernel32.NewProc("QueryFullProcessImageNameW")
+)
+
+func (win32) sources() (app, title, idle string) {
+	return "GetForegroundWindow", "GetWindowText", "GetLastInputInfo"
+}
+
+// restart has nothing to do: the probes are calls into user32.
+func (win32) restart() string {
+	return ""
+}
+
+func (win32) focus() (focusedWindow, error) {
+	var w focusedWindow
+	hwnd, _, _ := procGetForegroundWindow.Call()
+	if hwnd == 0 {
+		// No foreground window, e.g. on the lock screen or while switching
+		return w, errors.New("no foreground window")
+	}
+
+	var pid uint32
+	procGetWindowThreadProcessID.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+	path, err := processImage(pid)
+	if err != nil {
+		return w, err
+	}
+	w.path = path
+	w.bundleID = strings.ToLower(filepath.Base(path))
+	w.app = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	n, _, _ := procGetWindowTextLengthW.Call(hwnd)
+	buf := make([]uint16, n+1)
+	r, _, e := procGetWindowTextW.Call(hwnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if r == 0 && n > 0 {
+		w.titleErr = e
+		return w, nil
+	}
+	w.title = syscall.UTF16ToString(buf)
+	// Notepad and most Win32 editors put "*" before an unsaved document
+	if t := strings.TrimPrefix(w.title, "*"); t != w.title {
+		w.title, w.edited = t, true
+	}
+	var marked bool
+	w.title, marked = stripEditMarker(w.title)
+	w.edited = w.edited || marked
+	return w, nil
+}
+
+// processImage returns the executable path of a process.
+func processImage(pid uint32) (string, error) {
+	const processQueryLimitedInformation = 0x1000
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer syscall.CloseHandle(h)
+	buf := make([]uint16, syscall.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	r, _, e := procQueryFullProcessImageNameW.Call(uintptr(h), 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+	if r == 0 {
+		return "", e
+	}
+	return syscall.UTF16ToString(buf[:size]), nil
+}
+
+func (win32) idleSeconds() int {
+	info := struct {
+		size uint32
+		time uint32
+	}{size: 8}
+	if r, _, _ := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info))); r == 0 {
+		return 0
+	}
+	now, _, _ := procGetTickCount.Call()
+	// Both are milliseconds since boot in 32 bits; the subtraction survives the wrap after 49 days
+	return int((uint32(now) - info.time) / 1000)
+}
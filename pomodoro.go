@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pomodoroState is the running timer, shared with the tracker through a
+// file in LOG_PATH. Work and break intervals repeat until stopped.
+type pomodoroState struct {
+	Start time.Time     `json:"start"`
+	Work  time.Duration `json:"work"`
+	Break time.Duration `json:"break"`
+}
+
+// pomodoro is a completed work interval.
+type pomodoro struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Project string    `json:"project,omitempty"`
+}
+
+func pomodoroStatePath() string {
+	return filepath.Join(logs, "pomodoro.json")
+}
+
+func pomodorosPath(day time.Time) string {
+	return filepath.Join(logs, fmt.Sprintf("focus_tracker_%s_pomodoros.jsonl", day.Format("2006-01-02")))
+}
+
+// runPomodoro handles "pomodoro start [25/5]", "pomodoro stop" and "pomodoro status".
+func runPomodoro(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: pomodoro start [WORK/BREAK minutes, default 25/5] | stop | status")
+	}
+	switch args[0] {
+	case "start":
+		spec := "25/5"
+		if len(args) > 1 {
+			spec = args[1]
+		}
+		st, err := parsePomodoroSpec(spec)
+		if err != nil {
+			return err
+		}
+		st.Start = time.Now()
+		data, _ := json.Marshal(st)
+		if err := os.WriteFile(pomodoroStatePath(), data, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("%s Pomodoro started: %s work / %s break. The running tracker sends the notifications.\n",
+			glyphs.ok, shortDuration(st.Work), shortDuration(st.Break))
+		return nil
+	case "stop":
+		if err := os.Remove(pomodoroStatePath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		fmt.Println("Pomodoro stopped.")
+		return nil
+	case "status":
+		st, ok := readPomodoroState()
+		if !ok {
+			fmt.Println("No pomodoro running.")
+			return nil
+		}
+		round, onBreak, left := st.phase(time.Now())
+		phase := "work"
+		if onBreak {
+			phase = "break"
+		}
+		fmt.Printf("Pomodoro #%d: %s, %s left\n", round+1, phase, shortDuration(left))
+		return nil
+	}
+	return fmt.Errorf("unknown pomodoro command %q", args[0])
+}
+
+// parsePomodoroSpec reads "25/5" as 25 minutes of work and 5 of break.
+func parsePomodoroSpec(spec string) (pomodoroState, error) {
+	workStr, breakStr, ok := strings.Cut(spec, "/")
+	work, err1 := strconv.Atoi(workStr)
+	brk, err2 := strconv.Atoi(breakStr)
+	if !ok || err1 != nil || err2 != nil || work <= 0 || brk <= 0 {
+		return pomodoroState{}, fmt.Errorf("invalid pomodoro %q, expected WORK/BREAK in minutes like 25/5", spec)
+	}
+	return pomodoroState{Work: time.Duration(work) * time.Minute, Break: time.Duration(brk) * time.Minute}, nil
+}
+
+func readPomodoroState() (pomodoroState, bool) {
+	var st pomodoroState
+	data, err := os.ReadFile(pomodoroStatePath())
+	if err != nil || json.Unmarshal(data, &st) != nil || st.Work <= 0 || st.Break <= 0 {
+		return pomodoroState{}, false
+	}
+	return st, true
+}
+
+// phase returns the zero based round, whether it is a break and the time left in it.
+func (st pomodoroState) phase(now time.Time) (round int, onBreak bool, left time.Duration) {
+	cycle := st.Work + st.Break
+	elapsed := now.Sub(st.Start)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	round = int(elapsed / cycle)
+	into := elapsed % cycle
+	if into < st.Work {
+		return round, false, st.Work - into
+	}
+	return round, true, cycle - into
+}
+
+// pomodoroRun is the tracker's view of the current timer.
+var pomodoroRun struct {
+	start    time.Time
+	round    int
+	onBreak  bool
+	lastTick time.Time
+	projects map[string]time.Duration
+}
+
+// pomodoroTick is called from the tracker loop with the window currently in
+// focus. It credits focused time to projects, notifies on phase changes and
+// records each finished work interval against the project worked on most.
+func pomodoroTick(now time.Time, focus Span) {
+	st, ok := readPomodoroState()
+	if !ok {
+		pomodoroRun.start = time.Time{}
+		return
+	}
+	round, onBreak, _ := st.phase(now)
+	if !pomodoroRun.start.Equal(st.Start) {
+		pomodoroRun.start, pomodoroRun.round, pomodoroRun.onBreak = st.Start, round, onBreak
+		pomodoroRun.lastTick = now
+		pomodoroRun.projects = make(map[string]time.Duration)
+		return
+	}
+
+	if !pomodoroRun.onBreak && focus.App != "" && !focus.Away() {
+		pomodoroRun.projects[projectFor(focus)] += now.Sub(pomodoroRun.lastTick)
+	}
+	pomodoroRun.lastTick = now
+	if round == pomodoroRun.round && onBreak == pomodoroRun.onBreak {
+		return
+	}
+
+	if !pomodoroRun.onBreak {
+		end := st.Start.Add(time.Duration(pomodoroRun.round)*(st.Work+st.Break) + st.Work)
+		if len(pomodoroRun.projects) > 0 {
+			p := pomodoro{Start: end.Add(-st.Work), End: end, Project: sortedByDuration(pomodoroRun.projects)[0]}
+			if err := appendJSONLine(pomodorosPath(p.Start), p); err != nil {
+				fmt.Printf("%s Could not record pomodoro: %v\n", glyphs.warn, err)
+			}
+		}
+		notify("Pomodoro done", fmt.Sprintf("Take a %s break.", shortDuration(st.Break)))
+	} else {
+		notify("Break over", fmt.Sprintf("Next %s of focus starts now.", shortDuration(st.Work)))
+	}
+	pomodoroRun.round, pomodoroRun.onBreak = round, onBreak
+	pomodoroRun.projects = make(map[string]time.Duration)
+}
+
+// readPomodoros returns the pomodoros completed on a day.
+func readPomodoros(day time.Time) ([]pomodoro, error) {
+	f, err := os.Open(pomodorosPath(day))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var result []pomodoro
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var p pomodoro
+		if json.Unmarshal(scanner.Bytes(), &p) == nil {
+			result = append(result, p)
+		}
+	}
+	return result, scanner.Err()
+}
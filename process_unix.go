@@ -0,0 +1,50 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Signals the running tracker handles besides SIGINT and SIGTERM:
+// dumpSignal saves and prints the summaries, pauseSignal toggles pausing
+// and reloadSignal reads the configuration again.
+var (
+	dumpSignal   os.Signal = syscall.SIGUSR1
+	pauseSignal  os.Signal = syscall.SIGUSR2
+	reloadSignal os.Signal = syscall.SIGHUP
+)
+
+// processAlive reports whether a process with the pid exists.
+func processAlive(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
+
+// stopProcess asks the process to quit.
+func stopProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+// detach starts cmd in its own session so it outlives the terminal.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// openLocked opens path, creating it, and takes an exclusive lock on it
+// that lasts until the file is closed or the process ends.
+func openLocked(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
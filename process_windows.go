@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Windows has no user signals; the control socket does their work.
+var dumpSignal, pauseSignal, reloadSignal os.Signal
+
+// processAlive reports whether a process with the pid exists; on Windows
+// FindProcess opens it and fails when there is none.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// stopProcess ends the process. Windows has no SIGTERM, so the process
+// cannot clean up after itself.
+func stopProcess(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+// detach starts cmd without the console, so it outlives the terminal.
+func detach(cmd *exec.Cmd) {
+	const detachedProcess = 0x00000008
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: detachedProcess}
+}
+
+// openLocked opens path, creating it, so that no other process can open it
+// for writing until the file is closed or the process ends. Reading it
+// stays possible.
+func openLocked(path string) (*os.File, error) {
+	const errorSharingViolation syscall.Errno = 32
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, syscall.FILE_SHARE_READ,
+		nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err == errorSharingViolation {
+		return nil, errLocked
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
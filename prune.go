@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retention is how long window titles and individual spans are kept. After
+// that a day's spans are merged into one per app and project, keeping the
+// daily totals every report is built from, and titles are dropped from the
+// summaries.
+type retention struct {
+	years, months, days int
+}
+
+func (r retention) enabled() bool {
+	return r.years > 0 || r.months > 0 || r.days > 0
+}
+
+// cutoff is the first day whose detail is still kept.
+func (r retention) cutoff(today time.Time) time.Time {
+	return today.AddDate(-r.years, -r.months, -r.days)
+}
+
+// parseRetention reads "30d", "12w", "6m", "7y" or "off".
+func parseRetention(input string) (retention, error) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	if input == "off" || input == "" {
+		return retention{}, nil
+	}
+	n, err := strconv.Atoi(input[:len(input)-1])
+	if err != nil || n <= 0 {
+		return retention{}, fmt.Errorf("invalid retention %q, expected e.g. 30d, 12w, 6m, 7y or off", input)
+	}
+	switch input[len(input)-1] {
+	case 'd':
+		return retention{days: n}, nil
+	case 'w':
+		return retention{days: 7 * n}, nil
+	case 'm':
+		return retention{months: n}, nil
+	case 'y':
+		return retention{years: n}, nil
+	}
+	return retention{}, fmt.Errorf("invalid retention %q, expected e.g. 30d, 12w, 6m, 7y or off", input)
+}
+
+// retentionFor is the retention of a project's detail: its own, or
+// RETENTION for projects without one and time outside projects.
+func retentionFor(projectName string) retention {
+	if p, ok := lookupProject(projectName); ok && p.retention != nil {
+		return *p.retention
+	}
+	return detailRetention
+}
+
+// pruneResult counts what a prune changed.
+type pruneResult struct {
+	days, spansBefore, spansAfter, summaries int
+	skippedLocked                            int
+}
+
+// pruneDetail applies the retention rules to every journaled day before
+// today. With dryRun nothing is written.
+func pruneDetail(dryRun bool) (pruneResult, error) {
+	var res pruneResult
+	days, err := journalDays("spans")
+	if err != nil {
+		return res, err
+	}
+	today, _ := parseDay("")
+	for _, day := range days {
+		if !day.Before(today) {
+			continue
+		}
+		expired := func(projectName string) bool {
+			r := retentionFor(projectName)
+			return r.enabled() && day.Before(r.cutoff(today))
+		}
+		spans, err := readSpans(day)
+		if err != nil {
+			return res, err
+		}
+		pruned, changed := coarsenSpans(spans, expired)
+		if !changed {
+			continue
+		}
+		if err := checkUnlocked(day, day.AddDate(0, 0, 1)); err != nil {
+			res.skippedLocked++
+			continue
+		}
+		res.days++
+		res.spansBefore += len(spans)
+		res.spansAfter += len(pruned)
+		if dryRun {
+			continue
+		}
+		if err := writeSpans(day, pruned); err != nil {
+			return res, err
+		}
+		n, err := pruneSummaries(day, expired)
+		if err != nil {
+			return res, err
+		}
+		res.summaries += n
+	}
+	return res, nil
+}
+
+// coarsenSpans merges the spans of projects whose detail expired into one
+// coarse span per app, project, bucket and contexts, without titles or
+// tracks. The merged span starts with the first of its spans and lasts their
+// total, so the spans of a day overlap; only their durations count.
+func coarsenSpans(spans []Span, expired func(project string) bool) ([]Span, bool) {
+	var result []Span
+	merged := make(map[string]int) // key -> index in result
+	changed := false
+	for _, s := range spans {
+		project := s.Project
+		if project == "" {
+			project = projectFor(s)
+		}
+		if !expired(project) {
+			result = append(result, s)
+			continue
+		}
+		key := strings.Join([]string{s.App, s.BundleID, s.AppPath, s.AppVersion, s.Project, strconv.FormatBool(s.Work), s.Bucket, strings.Join(s.Contexts, ",")}, "\x00")
+		i, ok := merged[key]
+		if !ok {
+			changed = changed || !s.Coarse || s.Title != "" || s.NowPlaying != "" || s.Domain != "" || s.Issue != ""
+			s.Title, s.NowPlaying, s.Domain, s.Issue = "", "", "", ""
+			s.Coarse = true
+			merged[key] = len(result)
+			result = append(result, s)
+			continue
+		}
+		changed = true
+		result[i].End = result[i].End.Add(s.Duration())
+		result[i].EditingSeconds += s.EditingSeconds
+		result[i].Screenshots = append(result[i].Screenshots, s.Screenshots...)
+	}
+	return result, changed
+}
+
+// writeSpans replaces a past day's journal.
+func writeSpans(day time.Time, spans []Span) error {
+	var b strings.Builder
+	for _, s := range spans {
+		line, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	return writeFileAtomic(spansPath(day), b.String())
+}
+
+// pruneSummaries folds the titles of expired projects in a day's summary
+// files into "(no title)", returning how many files changed.
+func pruneSummaries(day time.Time, expired func(project string) bool) (int, error) {
+	prefix := filepath.Join(logs, "focus_tracker_"+day.Format("2006-01-02"))
+	paths, err := filepath.Glob(prefix + "*.log")
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return changed, err
+		}
+		var out []string
+		var app string
+		var folded time.Duration
+		dropped := false
+		flush := func() {
+			if folded > 0 {
+				out = append(out, fmt.Sprintf("  - (no title): %v", folded.Round(time.Second)))
+				folded = 0
+			}
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			raw := scanner.Text()
+			line := strings.TrimSpace(raw)
+			if !strings.HasPrefix(raw, " ") {
+				flush()
+				if name, _, ok := splitSummaryEntry(line); ok && !strings.HasPrefix(line, "Focus Summary") {
+					app = name
+				}
+				out = append(out, raw)
+				continue
+			}
+			title, dur, ok := splitSummaryEntry(strings.TrimPrefix(line, "- "))
+			if ok && title != "(no title)" && expired(projectFor(Span{App: app, Title: title})) {
+				folded += parseDuration(dur)
+				dropped = true
+				continue
+			}
+			if ok && title == "(no title)" {
+				folded += parseDuration(dur)
+				continue
+			}
+			out = append(out, raw)
+		}
+		flush()
+		if !dropped {
+			continue
+		}
+		if err := writeFileAtomic(path, strings.Join(out, "\n")+"\n"); err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// runPruneLoop applies the retention rules once a day while the tracker runs.
+func runPruneLoop() {
+	for {
+		res, err := pruneDetail(false)
+		if err != nil {
+			fmt.Printf("%s Retention: %v\n", glyphs.warn, err)
+		} else if res.days > 0 {
+			noteEvent("pruned detail of %d days", res.days)
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+// runPrune handles "prune [-dry-run]".
+func runPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "show what would be pruned without changing anything")
+	fs.Parse(args)
+
+	if !detailRetention.enabled() && !anyProjectRetention() {
+		return errors.New("no retention rules; set RETENTION or retention on projects")
+	}
+	res, err := pruneDetail(*dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "Pruned"
+	if *dryRun {
+		verb = "Would prune"
+	}
+	fmt.Printf("%s %s %d days: %d spans merged into %d", glyphs.ok, verb, res.days, res.spansBefore, res.spansAfter)
+	if !*dryRun {
+		fmt.Printf(", %d summaries without titles", res.summaries)
+	}
+	fmt.Println()
+	if res.skippedLocked > 0 {
+		fmt.Printf("%s %d locked days were left alone\n", glyphs.warn, res.skippedLocked)
+	}
+	return nil
+}
+
+func anyProjectRetention() bool {
+	for _, p := range projects {
+		if p.retention != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// queryTables are the tables the query command can read; "segments" is
+// accepted for spans.
+var queryTables = map[string]func() (*queryTable, error){
+	"spans":       spansTable,
+	"segments":    spansTable,
+	"annotations": annotationsTable,
+}
+
+// runQuery handles query "SELECT ...", running a SQL query over the whole
+// journal without exporting it first.
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	format := fs.String("format", "table", "output format: table, csv or json")
+	out := fs.String("o", "", "output file (default stdout)")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New(`usage: query [-format table|csv|json] "SELECT app, sum(seconds) / 3600 AS hours FROM spans GROUP BY app"`)
+	}
+	if *format != "table" && *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown format %q, expected table, csv or json", *format)
+	}
+
+	q, err := parseQuery(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+	load, ok := queryTables[q.from]
+	if !ok {
+		return fmt.Errorf("unknown table %q; tables are spans and annotations", q.from)
+	}
+	table, err := load()
+	if err != nil {
+		return err
+	}
+	result, err := q.run(table)
+	if err != nil {
+		return err
+	}
+
+	w, err := outputFile(*out)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	switch *format {
+	case "csv":
+		return writeQueryCSV(w, result)
+	case "json":
+		return writeQueryJSON(w, result)
+	}
+	writeQueryTable(w, result)
+	return nil
+}
+
+// spansTable has the columns of the dataset export plus the start hour and
+// weekday, with times in local time.
+func spansTable() (*queryTable, error) {
+	days, err := journalDays("spans")
+	if err != nil {
+		return nil, err
+	}
+	t := &queryTable{columns: []string{"day", "start_time", "end_time", "seconds", "hour", "weekday",
+		"app", "bundle_id", "app_path", "app_version", "title", "editing_seconds", "work", "bucket", "project", "contexts", "manual", "now_playing", "category", "domain", "issue"}}
+	for _, day := range days {
+		spans, err := readSpans(day)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range withContexts(spans) {
+			start := s.Start.Local()
+			t.rows = append(t.rows, []any{
+				start.Format("2006-01-02"),
+				start.Format("2006-01-02 15:04:05"),
+				s.End.Local().Format("2006-01-02 15:04:05"),
+				math.Round(s.Duration().Seconds()),
+				float64(start.Hour()),
+				start.Weekday().String()[:3],
+				s.App, s.BundleID, s.AppPath, s.AppVersion, s.Title, float64(s.EditingSeconds), s.Work, spanBucket(s), s.Project,
+				strings.Join(s.Contexts, ","), s.Manual, s.NowPlaying, s.Category, s.Domain, s.Issue,
+			})
+		}
+	}
+	return t, nil
+}
+
+func annotationsTable() (*queryTable, error) {
+	days, err := journalDays("annotations")
+	if err != nil {
+		return nil, err
+	}
+	t := &queryTable{columns: []string{"day", "start_time", "end_time", "seconds", "tag", "note"}}
+	for _, day := range days {
+		annotations, err := readAnnotations(day)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range annotations {
+			t.rows = append(t.rows, []any{
+				a.Start.Local().Format("2006-01-02"),
+				a.Start.Local().Format("2006-01-02 15:04:05"),
+				a.End.Local().Format("2006-01-02 15:04:05"),
+				math.Round(a.End.Sub(a.Start).Seconds()),
+				a.Tag, a.Note,
+			})
+		}
+	}
+	return t, nil
+}
+
+// writeQueryTable prints the result as aligned columns, numbers to two
+// decimals at most and aligned right.
+func writeQueryTable(w io.Writer, r *queryResult) {
+	cells := make([][]string, len(r.rows))
+	widths := make([]int, len(r.columns))
+	numeric := make([]bool, len(r.columns))
+	for i, name := range r.columns {
+		widths[i] = len([]rune(name))
+	}
+	for i, row := range r.rows {
+		for j, v := range row {
+			text := queryText(v)
+			if n, ok := v.(float64); ok {
+				numeric[j] = true
+				if n != math.Trunc(n) {
+					text = strconv.FormatFloat(n, 'f', 2, 64)
+				}
+			}
+			cells[i] = append(cells[i], text)
+			if n := len([]rune(text)); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+	line := func(values []string) {
+		parts := make([]string, len(values))
+		for j, text := range values {
+			pad := strings.Repeat(" ", widths[j]-len([]rune(text)))
+			if numeric[j] {
+				parts[j] = pad + text
+			} else {
+				parts[j] = text + pad
+			}
+		}
+		fmt.Fprintln(w, strings.TrimRight(strings.Join(parts, "  "), " "))
+	}
+	line(r.columns)
+	rule := make([]string, len(r.columns))
+	for j := range rule {
+		rule[j] = strings.Repeat("-", widths[j])
+	}
+	line(rule)
+	for _, row := range cells {
+		line(row)
+	}
+	if len(r.rows) == 1 {
+		fmt.Fprintln(w, "(1 row)")
+	} else {
+		fmt.Fprintf(w, "(%d rows)\n", len(r.rows))
+	}
+}
+
+func writeQueryCSV(w io.Writer, r *queryResult) error {
+	cw := csv.NewWriter(w)
+	cw.Write(r.columns)
+	for _, row := range r.rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = queryText(v)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeQueryJSON writes an array with an object per row.
+func writeQueryJSON(w io.Writer, r *queryResult) error {
+	rows := make([]map[string]any, 0, len(r.rows))
+	for _, row := range r.rows {
+		obj := make(map[string]any, len(row))
+		for i, v := range row {
+			obj[r.columns[i]] = v
+		}
+		rows = append(rows, obj)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
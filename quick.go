@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// runQuick handles the "quick" commands for launchers such as Raycast and
+// Alfred. Each prints one JSON object and reads the running tracker's
+// status.json instead of the logs, so it returns in milliseconds. Errors
+// are JSON too, with a non-zero exit status.
+func runQuick(args []string) error {
+	result, err := quickResult(args)
+	enc := json.NewEncoder(os.Stdout)
+	if err != nil {
+		enc.Encode(map[string]string{"error": err.Error()})
+		os.Exit(1)
+	}
+	return enc.Encode(result)
+}
+
+func quickResult(args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, errors.New("usage: quick today | current | projects | switch-project NAME | pause | resume")
+	}
+	switch args[0] {
+	case "today":
+		return quickToday()
+	case "current":
+		return quickCurrent()
+	case "projects":
+		names := make([]string, 0, len(projects))
+		for _, p := range projects {
+			names = append(names, p.name)
+		}
+		return map[string]any{"projects": names, "current": manualProject()}, nil
+	case "switch-project":
+		name, known, err := setManualProject(strings.TrimSpace(strings.Join(args[1:], " ")))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"project": name, "known": known}, nil
+	case "pause", "resume":
+		if err := setPaused(args[0] == "pause"); err != nil {
+			return nil, err
+		}
+		return map[string]bool{"paused": args[0] == "pause"}, nil
+	}
+	return nil, errors.New("unknown quick command " + args[0])
+}
+
+type quickTotals struct {
+	Running        bool   `json:"running"`
+	WorkSeconds    int    `json:"work_seconds"`
+	OutsideSeconds int    `json:"outside_seconds"`
+	Work           string `json:"work"`
+	Outside        string `json:"outside"`
+}
+
+// quickToday adds the span in progress to the totals the tracker published.
+// Without a running tracker it falls back to the span journal.
+func quickToday() (quickTotals, error) {
+	now := time.Now()
+	status, running, err := readStatus()
+	if err != nil || !running || status.Updated.Format("2006-01-02") != now.Format("2006-01-02") {
+		work, outside := todaysTotals(now, status, false)
+		return newQuickTotals(false, work, outside), nil
+	}
+	work := time.Duration(status.WorkToday) * time.Second
+	outside := time.Duration(status.OutToday) * time.Second
+	if status.App != "" && status.App != lockedApp {
+		if classify(Span{Start: status.Since, End: now, App: status.App}).Work {
+			work += now.Sub(status.Since)
+		} else {
+			outside += now.Sub(status.Since)
+		}
+	}
+	return newQuickTotals(true, work, outside), nil
+}
+
+func newQuickTotals(running bool, work, outside time.Duration) quickTotals {
+	return quickTotals{
+		Running:        running,
+		WorkSeconds:    int(work.Seconds()),
+		OutsideSeconds: int(outside.Seconds()),
+		Work:           shortDuration(work),
+		Outside:        shortDuration(outside),
+	}
+}
+
+type quickFocus struct {
+	Running        bool   `json:"running"`
+	Paused         bool   `json:"paused"`
+	App            string `json:"app,omitempty"`
+	Project        string `json:"project,omitempty"`
+	ElapsedSeconds int    `json:"elapsed_seconds"`
+	Elapsed        string `json:"elapsed"`
+}
+
+// quickCurrent reports what the tracker is timing; a tracker that is not
+// running is not an error here.
+func quickCurrent() (quickFocus, error) {
+	status, running, _ := readStatus()
+	f := quickFocus{Running: running, Paused: running && status.App == ""}
+	if running && status.App != "" {
+		elapsed := time.Since(status.Since)
+		f.App, f.Project = status.App, status.Project
+		f.ElapsedSeconds, f.Elapsed = int(elapsed.Seconds()), shortDuration(elapsed)
+	}
+	return f, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// redaction keeps window titles out of the logs. [redact] sections of the
+// rules file apply to the apps their app/bundle_id patterns match, or to
+// every app without them, and are checked in file order:
+//
+//	[redact "Mail"]
+//	app = ^(Mail|Microsoft Outlook)$
+//	drop_title = true
+//
+//	[redact "Customer names"]
+//	scrub = (?i)\b(acme|globex)\b
+//	replace = [client]
+//
+// Every matching scrub is applied; drop_title records the app alone and
+// hash_title replaces the title with a stable hash, so reports still tell
+// windows apart.
+type redaction struct {
+	name      string
+	app       *regexp.Regexp
+	bundleID  *regexp.Regexp
+	scrub     *regexp.Regexp
+	replace   string
+	dropTitle bool
+	hashTitle bool
+}
+
+var redactions []redaction
+
+func (r redaction) appliesTo(app, bundleID string) bool {
+	if r.app != nil && !r.app.MatchString(app) {
+		return false
+	}
+	if r.bundleID != nil && !r.bundleID.MatchString(bundleID) {
+		return false
+	}
+	return true
+}
+
+// redactWindow applies the [redact] rules to a window's title and browser
+// domain before anything is recorded.
+func redactWindow(app, bundleID, title, domain string) (string, string) {
+	var hash bool
+	for _, r := range redactions {
+		if !r.appliesTo(app, bundleID) {
+			continue
+		}
+		if r.dropTitle {
+			return "", ""
+		}
+		if r.scrub != nil {
+			title = r.scrub.ReplaceAllLiteralString(title, r.replace)
+		}
+		hash = hash || r.hashTitle
+	}
+	if hash {
+		return hashText(title), hashText(domain)
+	}
+	return title, domain
+}
+
+func parseRedaction(path string, sec ruleSection) (redaction, []error) {
+	r := redaction{name: sec.name, replace: "[redacted]"}
+	var errs []error
+	for _, k := range sec.keys {
+		where := fmt.Sprintf("%s:%d", path, k.line)
+		var err error
+		switch k.key {
+		case "app":
+			r.app, err = regexp.Compile(k.value)
+		case "bundle_id":
+			r.bundleID, err = regexp.Compile(k.value)
+		case "scrub":
+			r.scrub, err = regexp.Compile(k.value)
+		case "replace":
+			r.replace = k.value
+		case "drop_title":
+			r.dropTitle, err = parseBool(k.value)
+		case "hash_title":
+			r.hashTitle, err = parseBool(k.value)
+		default:
+			msg := fmt.Sprintf("%s: unknown redact key %q", where, k.key)
+			if guess := closestMatch(k.key, []string{"app", "bundle_id", "scrub", "replace", "drop_title", "hash_title"}); guess != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", guess)
+			}
+			errs = append(errs, errors.New(msg))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", where, k.key, err))
+		}
+	}
+	if r.scrub == nil && !r.dropTitle && !r.hashTitle {
+		errs = append(errs, fmt.Errorf("%s:%d: redact %q needs scrub, drop_title or hash_title", path, sec.line, sec.name))
+	}
+	return r, errs
+}
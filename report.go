@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runReport prints a day's summary from the span journal, including per
+// project totals and time spent outside a project's allowed hours, or with
+// -week, -month or -from the summary of several days.
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	dayStr, outPath := dayFlags(fs)
+	weekStr := fs.String("week", "", "report an ISO week instead of a day (YYYY-Www, e.g. 2024-W19)")
+	monthStr := fs.String("month", "", "report a month instead of a day (YYYY-MM)")
+	fromStr := fs.String("from", "", "report the days from this one instead of a single day (YYYY-MM-DD, today or yesterday)")
+	toStr := fs.String("to", "", "last day reported with -from (default today)")
+	contextName := fs.String("context", "", "only report spans belonging to this context")
+	appName := fs.String("app", "", "only report time in this app")
+	categoryName := fs.String("category", "", "only report time in this category")
+	minSpan := fs.Duration("min", 0, "leave out spans shorter than this, e.g. 5m for quick glances")
+	format := fs.String("format", "text", "output format: text, json, html (a page with charts) or svg (a timeline image)")
+	withShots := fs.Bool("screenshots", false, "show the day's screenshots as thumbnails under the svg timeline")
+	fs.Parse(args)
+	if *format != "text" && *format != "json" && *format != "html" && *format != "svg" {
+		return fmt.Errorf("unknown format %q, expected text, json, html or svg", *format)
+	}
+	period := *weekStr != "" || *monthStr != "" || *fromStr != "" || *toStr != ""
+	if period && (*dayStr != "" || *format == "svg") {
+		return errors.New("-week, -month and -from cannot be combined with -day or -format svg")
+	}
+
+	var day, from, to time.Time
+	var label string
+	var err error
+	if period {
+		if from, to, label, err = parseReportPeriod(*weekStr, *monthStr, *fromStr, *toStr); err != nil {
+			return err
+		}
+	} else {
+		if day, err = parseDay(*dayStr); err != nil {
+			return err
+		}
+		from, to = day, day.AddDate(0, 0, 1)
+	}
+	var spans []Span
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		daySpans, err := readSpans(d)
+		if err != nil {
+			return err
+		}
+		spans = append(spans, daySpans...)
+	}
+	spans = withContexts(spans)
+	if *contextName != "" {
+		if !hasContext(*contextName) {
+			return fmt.Errorf("unknown context %q; contexts are [context \"Name\"] sections of the rules file", *contextName)
+		}
+		spans = spansInContext(spans, *contextName)
+	}
+	if *categoryName != "" && !hasCategory(*categoryName) {
+		return fmt.Errorf("unknown category %q; categories are [category \"Name\"] sections of the rules file", *categoryName)
+	}
+	spans = spansMatching(spans, *appName, *categoryName, *minSpan)
+	w, err := outputFile(*outPath)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if *format == "json" {
+		// The same summary as /range of the JSON API
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summarizeSpans(from, to.AddDate(0, 0, -1), spans))
+	}
+	if *format == "html" {
+		return writeHTMLReport(w, label, from, to, spans)
+	}
+	if period {
+		writePeriodReport(w, label, from, to, spans)
+		return nil
+	}
+	if *format == "svg" {
+		var shots []timelineShot
+		if *withShots {
+			if shots, err = timelineShots(spans); err != nil {
+				return err
+			}
+		}
+		writeTimelineSVG(w, day, spans, shots)
+		return nil
+	}
+	pomodoros, err := readPomodoros(day)
+	if err != nil {
+		return err
+	}
+	breaks, err := readBreaks(day)
+	if err != nil {
+		return err
+	}
+	annotations, err := readAnnotations(day)
+	if err != nil {
+		return err
+	}
+	writeReport(w, day, spans)
+	writeCategories(w, spans)
+	writeDomains(w, spans)
+	writeIssues(w, spans)
+	if over := overLimits(spans); len(over) > 0 {
+		fmt.Fprintf(w, "\nOver daily limit\n")
+		for _, line := range over {
+			fmt.Fprintln(w, paintOver(fmt.Sprintf("  %s %s", glyphs.warn, line)))
+		}
+	}
+	writeAnnotations(w, annotations, spans)
+	writePomodoros(w, pomodoros)
+	writeEditing(w, spans)
+	writeListening(w, spans)
+	if len(breaks) > 0 {
+		fmt.Fprintln(w)
+		writeBreakStats(w, breaks)
+	}
+	return nil
+}
+
+// withContexts matches spans journaled before contexts were configured
+// against the current rules.
+func withContexts(spans []Span) []Span {
+	result := make([]Span, len(spans))
+	for i, s := range spans {
+		if s.Contexts == nil && !s.Away() {
+			if s.Project == "" {
+				s.Project = projectFor(s)
+			}
+			s.Contexts = contextsFor(s)
+		}
+		if s.Category == "" && !s.Away() {
+			s.Category = categoryFor(s)
+		}
+		s.Issue = issueFor(s)
+		result[i] = s
+	}
+	return result
+}
+
+// spansInContext keeps the spans belonging to the named context.
+func spansInContext(spans []Span, name string) []Span {
+	var kept []Span
+	for _, s := range spans {
+		for _, c := range s.Contexts {
+			if strings.EqualFold(c, name) {
+				kept = append(kept, s)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// spansMatching keeps the spans of the app and category, ignoring case, that
+// last at least min. Empty names match every span.
+func spansMatching(spans []Span, app, category string, min time.Duration) []Span {
+	if app == "" && category == "" && min <= 0 {
+		return spans
+	}
+	var kept []Span
+	for _, s := range spans {
+		if app != "" && !strings.EqualFold(s.App, app) {
+			continue
+		}
+		if category != "" && !strings.EqualFold(s.Category, category) {
+			continue
+		}
+		if s.Duration() < min {
+			continue
+		}
+		kept = append(kept, s)
+	}
+	return kept
+}
+
+// projectUsage is the time recorded on a project and how much of it fell
+// outside the project's allowed hours.
+type projectUsage struct {
+	total, outside time.Duration
+}
+
+func projectUsages(spans []Span) map[string]*projectUsage {
+	usage := make(map[string]*projectUsage)
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		if s.Project == "" {
+			// recorded before a matching rule existed
+			s.Project = projectFor(s)
+		}
+		u, ok := usage[s.Project]
+		if !ok {
+			u = &projectUsage{}
+			usage[s.Project] = u
+		}
+		u.total += s.Duration()
+		if p, ok := lookupProject(s.Project); ok {
+			u.outside += s.Duration() - p.allowedTime(s)
+		}
+	}
+	return usage
+}
+
+// writeReport summarizes one day: the total per bucket, the hourly
+// activity and the time per app and project. Away time is left out of the
+// totals, as in the JSON summary and the -week report.
+func writeReport(w io.Writer, day time.Time, spans []Span) {
+	var tracked []Span
+	var away time.Duration
+	for _, s := range spans {
+		if s.Away() {
+			away += s.Duration()
+		} else {
+			tracked = append(tracked, s)
+		}
+	}
+	buckets := totalsFromSpans(tracked)
+	fmt.Fprintf(w, "Focus report for %s\n", day.Format("2006-01-02"))
+	fmt.Fprintf(w, "----------------------------------------\n")
+	for _, suffix := range sortedKeys(buckets) {
+		var sum time.Duration
+		for _, titles := range buckets[suffix] {
+			for _, d := range titles {
+				sum += d
+			}
+		}
+		fmt.Fprintf(w, "%s: %s\n", bucketLabel(suffix), paintBucket(suffix, shortDuration(sum)))
+	}
+	if away > 0 {
+		fmt.Fprintf(w, "away: %s\n", shortDuration(away))
+	}
+
+	if len(spans) > 0 {
+		fmt.Fprintf(w, "\nActivity by hour\n")
+		fmt.Fprintf(w, "  |%s|\n", sparkline(hourlyActivity(spans, day)))
+		fmt.Fprintf(w, "   0     6     12    18\n")
+	}
+	writeApps(w, spans)
+	writeProjects(w, spans)
+}
+
+// writeApps lists the time per app, largest first, with bars.
+func writeApps(w io.Writer, spans []Span) {
+	apps := make(map[string]time.Duration)
+	width := 0
+	for _, s := range spans {
+		apps[s.App] += s.Duration()
+		if n := len([]rune(s.App)); n > width && n <= 30 {
+			width = n
+		}
+	}
+	fmt.Fprintf(w, "\nApps\n")
+	sorted := sortedByDuration(apps)
+	for i, app := range sorted {
+		pad := strings.Repeat(" ", width-minInt(width, len([]rune(app))))
+		name := app
+		if i < 3 && !(Span{App: app}).Away() {
+			name = paintTop(app)
+		}
+		fmt.Fprintf(w, "  %s: %s%6s  %s\n", name, pad, shortDuration(apps[app]), bar(apps[app], apps[sorted[0]]))
+	}
+}
+
+// writeProjects lists the time per project, flagging time outside the
+// project's allowed hours. It is left out while no projects are set up.
+func writeProjects(w io.Writer, spans []Span) {
+	usage := projectUsages(spans)
+	if len(projects) == 0 && len(usage) <= 1 {
+		return
+	}
+	fmt.Fprintf(w, "\nProjects\n")
+	names := make(map[string]time.Duration, len(usage))
+	for name, u := range usage {
+		names[name] = u.total
+	}
+	for _, name := range sortedByDuration(names) {
+		u := usage[name]
+		label := name
+		if label == "" {
+			label = "(no project)"
+		}
+		line := fmt.Sprintf("  %s: %s", label, shortDuration(u.total))
+		if u.outside >= time.Minute {
+			p, _ := lookupProject(name)
+			line += paintOver(fmt.Sprintf("  %s %s outside allowed hours (%s)", glyphs.warn, shortDuration(u.outside), p.scheduleString()))
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func writePomodoros(w io.Writer, pomodoros []pomodoro) {
+	if len(pomodoros) == 0 {
+		return
+	}
+	perProject := make(map[string]int)
+	for _, p := range pomodoros {
+		perProject[p.Project]++
+	}
+	fmt.Fprintf(w, "\nPomodoros: %d\n", len(pomodoros))
+	for _, name := range sortedKeys(perProject) {
+		label := name
+		if label == "" {
+			label = "(no project)"
+		}
+		fmt.Fprintf(w, "  %s: %d\n", label, perProject[name])
+	}
+}
+
+// scheduleString describes the project's allowed hours, e.g. "Mon,Tue 09:00-13:00".
+func (p project) scheduleString() string {
+	var parts []string
+	if p.days != nil {
+		var days []string
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if p.days[d] {
+				days = append(days, d.String()[:3])
+			}
+		}
+		parts = append(parts, strings.Join(days, ","))
+	}
+	for _, h := range p.hours {
+		parts = append(parts, h.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// sortedByDuration returns the keys with the largest duration first.
+func sortedByDuration(m map[string]time.Duration) []string {
+	keys := sortedKeys(m)
+	sort.SliceStable(keys, func(i, j int) bool { return m[keys[i]] > m[keys[j]] })
+	return keys
+}
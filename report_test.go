@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReportTotalsMatchSummary checks that the day report and the JSON
+// summary agree on the work and outside-hours totals, with away time
+// counted in neither.
+func TestReportTotalsMatchSummary(t *testing.T) {
+	day := time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	spans := []Span{
+		{Start: at(9, 0), End: at(10, 30), App: "Code", Work: true},
+		{Start: at(10, 30), End: at(11, 15), App: lockedApp, Work: true},
+		{Start: at(11, 15), End: at(12, 0), App: "Safari", Work: true},
+		{Start: at(12, 0), End: at(12, 40), App: pausedApp, Work: true},
+		{Start: at(19, 0), End: at(19, 20), App: "Slack"},
+		{Start: at(22, 0), End: at(22, 30), App: lockedApp},
+	}
+
+	var b bytes.Buffer
+	writeReport(&b, day, spans)
+	lines := make(map[string]string)
+	for _, line := range strings.Split(b.String(), "\n") {
+		if label, value, ok := strings.Cut(line, ": "); ok {
+			lines[label] = value
+		}
+	}
+
+	sum := summarizeSpans(day, day, spans)
+	tests := []struct {
+		label string
+		want  time.Duration
+	}{
+		{bucketLabel(""), time.Duration(sum.WorkSeconds) * time.Second},
+		{bucketLabel("_outside"), time.Duration(sum.OutsideSeconds) * time.Second},
+		{"away", 45*time.Minute + 40*time.Minute + 30*time.Minute},
+	}
+	for _, tt := range tests {
+		if got := lines[tt.label]; got != shortDuration(tt.want) {
+			t.Errorf("%s: report says %q, want %q", tt.label, got, shortDuration(tt.want))
+		}
+	}
+	if sum.WorkSeconds != int((2*time.Hour + 15*time.Minute).Seconds()) {
+		t.Errorf("summary work seconds = %d, want %d", sum.WorkSeconds, int((2*time.Hour + 15*time.Minute).Seconds()))
+	}
+}
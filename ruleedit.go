@@ -0,0 +1,373 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rulesDoc is the rules file as lines, for commands that edit it in place.
+// Edits keep comments and layout, and the file is only saved if it still
+// loads cleanly.
+type rulesDoc struct {
+	path     string
+	original string
+	lines    []string
+	sections []ruleSection
+}
+
+func openRulesDoc() (*rulesDoc, error) {
+	path, _ := rulesPath()
+	if path == "" {
+		return nil, errors.New("no rules file; set RULES_FILE")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	doc := &rulesDoc{path: path, original: string(data)}
+	if text := strings.TrimRight(string(data), "\n"); text != "" {
+		doc.lines = strings.Split(text, "\n")
+	}
+	if errs := doc.parse(); len(errs) > 0 {
+		return nil, fmt.Errorf("fix the rules file first:\n  %w", joinErrors(errs))
+	}
+	return doc, nil
+}
+
+func (d *rulesDoc) parse() []error {
+	var errs []error
+	d.sections, errs = parseRuleSections(d.path, strings.NewReader(strings.Join(d.lines, "\n")))
+	return errs
+}
+
+// find returns the index of the section of kind named name, or -1.
+func (d *rulesDoc) find(kind, name string) int {
+	for i, sec := range d.sections {
+		if sec.kind == kind && strings.EqualFold(sec.name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// end is the line index after the last key of section i.
+func (d *rulesDoc) end(i int) int {
+	sec := d.sections[i]
+	last := sec.line
+	for _, k := range sec.keys {
+		last = k.line
+	}
+	return last
+}
+
+func (d *rulesDoc) insert(at int, lines ...string) {
+	d.lines = append(d.lines[:at], append(lines, d.lines[at:]...)...)
+	d.parse()
+}
+
+// set replaces the first value of key in section i, or adds it.
+func (d *rulesDoc) set(i int, key, value string) {
+	for _, k := range d.sections[i].keys {
+		if k.key == key {
+			d.lines[k.line-1] = key + " = " + quoteRuleValue(value)
+			d.parse()
+			return
+		}
+	}
+	d.add(i, key, value)
+}
+
+// add appends a key to section i, for keys that may repeat.
+func (d *rulesDoc) add(i int, key, value string) {
+	d.insert(d.end(i), key+" = "+quoteRuleValue(value))
+}
+
+// remove deletes every line of key in section i.
+func (d *rulesDoc) remove(i int, key string) {
+	keys := d.sections[i].keys
+	for j := len(keys) - 1; j >= 0; j-- {
+		if keys[j].key == key {
+			d.lines = append(d.lines[:keys[j].line-1], d.lines[keys[j].line:]...)
+		}
+	}
+	d.parse()
+}
+
+func (d *rulesDoc) rename(i int, name string) {
+	sec := d.sections[i]
+	d.lines[sec.line-1] = ruleHeader(sec.kind, name)
+	d.parse()
+}
+
+// appendSection adds a section at the end of the file and returns its index.
+func (d *rulesDoc) appendSection(kind, name string) int {
+	if len(d.lines) > 0 {
+		d.lines = append(d.lines, "")
+	}
+	d.lines = append(d.lines, ruleHeader(kind, name))
+	d.parse()
+	return len(d.sections) - 1
+}
+
+// save writes the file and reloads the rules from it; if they no longer
+// load, the old file is put back and the errors returned.
+func (d *rulesDoc) save() error {
+	if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(d.path, strings.Join(d.lines, "\n")+"\n"); err != nil {
+		return err
+	}
+	if errs := loadRules(d.path, true); len(errs) > 0 {
+		if d.original == "" {
+			os.Remove(d.path)
+		} else if err := writeFileAtomic(d.path, d.original); err != nil {
+			return err
+		}
+		loadRules(d.path, false)
+		return fmt.Errorf("not saved:\n  %w", joinErrors(errs))
+	}
+	return nil
+}
+
+func writeFileAtomic(path, data string) error {
+	if err := os.WriteFile(path+".tmp", []byte(data), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func ruleHeader(kind, name string) string {
+	return fmt.Sprintf("[%s %q]", kind, name)
+}
+
+// quoteRuleValue quotes values that would otherwise lose a comment-like
+// part or surrounding spaces.
+func quoteRuleValue(v string) string {
+	if !strings.Contains(v, "#") && strings.TrimSpace(v) == v && unquote(v) == v {
+		return v
+	}
+	if strings.Contains(v, `"`) {
+		return "'" + v + "'"
+	}
+	return `"` + v + `"`
+}
+
+// nameAndFlags parses "NAME -flag ..." as well as "-flag ... NAME".
+func nameAndFlags(fs *flag.FlagSet, args []string) string {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		fs.Parse(args[1:])
+		return args[0]
+	}
+	fs.Parse(args)
+	return strings.Join(fs.Args(), " ")
+}
+
+// sectionFlag is a flag written to a rules key when given.
+type sectionFlag struct {
+	flag, key, usage string
+	value            *string
+	repeat           bool // split on ";" into one key per part
+}
+
+func defineSectionFlags(fs *flag.FlagSet, defs []sectionFlag) []sectionFlag {
+	for i := range defs {
+		defs[i].value = fs.String(defs[i].flag, "", defs[i].usage)
+	}
+	return defs
+}
+
+// applySectionFlags writes the flags given on the command line to section i.
+func applySectionFlags(d *rulesDoc, i int, fs *flag.FlagSet, defs []sectionFlag) {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for _, def := range defs {
+		if !given[def.flag] {
+			continue
+		}
+		if !def.repeat {
+			d.set(i, def.key, *def.value)
+			continue
+		}
+		d.remove(i, def.key)
+		for _, part := range splitLines(*def.value) {
+			d.add(i, def.key, part)
+		}
+	}
+}
+
+var clientFlags = []sectionFlag{
+	{flag: "name", key: "name", usage: "name on invoices"},
+	{flag: "address", key: "address", usage: "address lines separated by ;", repeat: true},
+	{flag: "email", key: "email", usage: "billing email"},
+	{flag: "rate", key: "rate", usage: "default hourly rate of its projects"},
+	{flag: "currency", key: "currency", usage: "currency of its rates"},
+	{flag: "round", key: "round", usage: "rounding increment per project and day, e.g. 15m"},
+	{flag: "round-mode", key: "round_mode", usage: "up, nearest or down"},
+	{flag: "tax", key: "tax", usage: "tax percentage added to invoices"},
+}
+
+var projectFlags = []sectionFlag{
+	{flag: "client", key: "client", usage: "client the project is billed to"},
+	{flag: "rate", key: "rate", usage: "hourly rate, overriding the client's"},
+	{flag: "app", key: "app", usage: "app name regular expression"},
+	{flag: "title", key: "title", usage: "window title regular expression"},
+	{flag: "hours", key: "hours", usage: "allowed hours, e.g. 09:00-13:00"},
+	{flag: "days", key: "days", usage: "allowed days, e.g. Mon,Tue"},
+	{flag: "billable", key: "billable", usage: "false to keep its time off invoices"},
+	{flag: "cost-center", key: "cost_center", usage: "cost center its time is booked to on timesheets"},
+	{flag: "wbs", key: "wbs", usage: "WBS element its time is booked to on timesheets"},
+	{flag: "activity", key: "activity", usage: "activity type on timesheets"},
+	{flag: "harvest-project", key: "harvest_project", usage: "Harvest project its time is booked to (default its name)"},
+	{flag: "harvest-task", key: "harvest_task", usage: "Harvest task its time is booked to (default HARVEST_TASK)"},
+	{flag: "retention", key: "retention", usage: "how long to keep its titles and spans, e.g. 7y or off"},
+}
+
+// editSection handles the add, edit, rename, archive and unarchive actions
+// shared by the client and project commands.
+func editSection(kind string, defs []sectionFlag, args []string) error {
+	action := args[0]
+	doc, err := openRulesDoc()
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "add", "edit":
+		fs := flag.NewFlagSet(kind+" "+action, flag.ExitOnError)
+		defs := defineSectionFlags(fs, append([]sectionFlag(nil), defs...))
+		name := nameAndFlags(fs, args[1:])
+		if name == "" {
+			return fmt.Errorf("usage: %s %s NAME [flags]", kind, action)
+		}
+		i := doc.find(kind, name)
+		if action == "add" {
+			if i >= 0 {
+				return fmt.Errorf("%s %q already exists; change it with %s edit", kind, doc.sections[i].name, kind)
+			}
+			i = doc.appendSection(kind, name)
+		} else if i < 0 {
+			return fmt.Errorf("no %s %q in %s", kind, name, doc.path)
+		}
+		applySectionFlags(doc, i, fs, defs)
+		if err := doc.save(); err != nil {
+			return err
+		}
+		audit(kind, "%s %s %s", action, doc.sections[i].name, strings.Join(args[1:], " "))
+		if action == "add" {
+			fmt.Printf("%s Added %s %s to %s\n", glyphs.ok, kind, name, doc.path)
+		} else {
+			fmt.Printf("%s Updated %s %s\n", glyphs.ok, kind, doc.sections[i].name)
+		}
+		return nil
+
+	case "rename":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: %s rename OLD NEW", kind)
+		}
+		oldName, newName := args[1], args[2]
+		i := doc.find(kind, oldName)
+		if i < 0 {
+			return fmt.Errorf("no %s %q in %s", kind, oldName, doc.path)
+		}
+		if j := doc.find(kind, newName); j >= 0 && j != i {
+			return fmt.Errorf("%s %q already exists", kind, newName)
+		}
+		oldName = doc.sections[i].name
+		doc.rename(i, newName)
+		// The old name stays as an alias so the journal and invoice
+		// numbering still find it.
+		doc.add(i, "alias", oldName)
+		renameReferences(doc, kind, oldName, newName)
+		if err := doc.save(); err != nil {
+			return err
+		}
+		audit(kind, "rename %s to %s", oldName, newName)
+		fmt.Printf("%s Renamed %s %s to %s; history under the old name is kept\n", glyphs.ok, kind, oldName, newName)
+		return nil
+
+	case "archive", "unarchive":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: %s %s NAME", kind, action)
+		}
+		name := strings.Join(args[1:], " ")
+		i := doc.find(kind, name)
+		if i < 0 {
+			return fmt.Errorf("no %s %q in %s", kind, name, doc.path)
+		}
+		if action == "archive" {
+			doc.set(i, "archived", "true")
+		} else {
+			doc.remove(i, "archived")
+		}
+		if err := doc.save(); err != nil {
+			return err
+		}
+		audit(kind, "%s %s", action, doc.sections[i].name)
+		done := map[string]string{"archive": "Archived", "unarchive": "Unarchived"}[action]
+		fmt.Printf("%s %s %s %s\n", glyphs.ok, done, kind, doc.sections[i].name)
+		return nil
+	}
+	return fmt.Errorf("unknown %s action %q", kind, action)
+}
+
+// renameReferences points the keys naming a renamed client or project at
+// its new name: projects' client, and contexts' project lists.
+func renameReferences(doc *rulesDoc, kind, oldName, newName string) {
+	for i, sec := range doc.sections {
+		for _, k := range sec.keys {
+			switch {
+			case kind == "client" && sec.kind == "project" && k.key == "client" && strings.EqualFold(k.value, oldName):
+				doc.set(i, "client", newName)
+			case kind == "project" && sec.kind == "context" && k.key == "projects":
+				names := strings.Split(k.value, ",")
+				for j, n := range names {
+					if strings.EqualFold(strings.TrimSpace(n), oldName) {
+						names[j] = newName
+					}
+				}
+				for j := range names {
+					names[j] = strings.TrimSpace(names[j])
+				}
+				doc.set(i, "projects", strings.Join(names, ", "))
+			}
+		}
+	}
+}
+
+// runClient handles "client list" and the edits of [client] sections.
+func runClient(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: client list | add NAME [flags] | edit NAME [flags] | rename OLD NEW | archive NAME | unarchive NAME")
+	}
+	if args[0] != "list" {
+		return editSection("client", clientFlags, args)
+	}
+	if len(clients) == 0 {
+		fmt.Println("No clients; add one with: client add NAME -rate 100")
+		return nil
+	}
+	for _, c := range clients {
+		line := c.name
+		if c.rate > 0 {
+			line += fmt.Sprintf("  %s %s/h", formatMoney(c.rate), c.currencyCode())
+		}
+		var names []string
+		for _, p := range projects {
+			if strings.EqualFold(p.client, c.name) {
+				names = append(names, p.name)
+			}
+		}
+		if len(names) > 0 {
+			line += "  projects: " + strings.Join(names, ", ")
+		}
+		if c.archived {
+			line += "  " + paintOver("archived")
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// project groups spans by app and/or title and optionally restricts when
+// time on it is allowed (e.g. a client that is only billable mornings).
+type project struct {
+	name     string
+	app      *regexp.Regexp
+	bundleID *regexp.Regexp
+	title    *regexp.Regexp
+	hours    []window
+	days     map[time.Weekday]bool
+
+	client      string // billed to this client, if any
+	rate        int64  // hourly rate in cents, overriding the client's
+	nonBillable bool
+
+	aliases  []string // earlier names, still found in the journal
+	archived bool     // kept for history, no longer matched
+
+	costCenter, wbs, activity string // receiver of its time on timesheets
+
+	harvestProject, harvestTask string // where sync harvest books its time
+
+	retention *retention // of its detail, overriding RETENTION
+}
+
+var projects []project
+
+// matches reports whether every pattern set on the project matches the span.
+func (p project) matches(s Span) bool {
+	if p.archived || (p.app == nil && p.bundleID == nil && p.title == nil) {
+		return false
+	}
+	if p.app != nil && !p.app.MatchString(s.App) {
+		return false
+	}
+	if p.bundleID != nil && !p.bundleID.MatchString(s.BundleID) {
+		return false
+	}
+	if p.title != nil && !p.title.MatchString(s.Title) {
+		return false
+	}
+	return true
+}
+
+// projectFor returns the first project in file order matching the span.
+func projectFor(s Span) string {
+	for _, p := range projects {
+		if p.matches(s) {
+			return p.name
+		}
+	}
+	return ""
+}
+
+// hasProject reports whether a project is defined, ignoring case.
+func hasProject(name string) bool {
+	for _, p := range projects {
+		if strings.EqualFold(p.name, name) || p.hasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupProject finds a project by its name or an earlier one, preferring
+// an exact match.
+func lookupProject(name string) (project, bool) {
+	for _, p := range projects {
+		if p.name == name {
+			return p, true
+		}
+	}
+	for _, p := range projects {
+		if strings.EqualFold(p.name, name) || p.hasAlias(name) {
+			return p, true
+		}
+	}
+	return project{}, false
+}
+
+// canonicalProject maps a project name from the journal to the project's
+// current name, so renamed projects keep their history.
+func canonicalProject(name string) string {
+	if name == "" {
+		return ""
+	}
+	if p, ok := lookupProject(name); ok {
+		return p.name
+	}
+	return name
+}
+
+func (p project) hasAlias(name string) bool {
+	for _, a := range p.aliases {
+		if strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedTime is how much of the span falls inside the project's windows.
+// A coarse span has no time of day to check and counts as allowed.
+func (p project) allowedTime(s Span) time.Duration {
+	if s.Coarse || len(p.hours) == 0 && p.days == nil {
+		return s.Duration()
+	}
+	hours := p.hours
+	if len(hours) == 0 {
+		hours = []window{{TimeOfDay{0, 0}, TimeOfDay{0, 0}}}
+	}
+
+	var allowed time.Duration
+	// Start one day early so windows running past midnight into the span are seen.
+	day := time.Date(s.Start.Year(), s.Start.Month(), s.Start.Day()-1, 0, 0, 0, 0, s.Start.Location())
+	for ; day.Before(s.End); day = day.AddDate(0, 0, 1) {
+		if p.days != nil && !p.days[day.Weekday()] {
+			continue
+		}
+		for _, w := range hours {
+			from := day.Add(time.Duration(w.start.Hour)*time.Hour + time.Duration(w.start.Minute)*time.Minute)
+			to := day.Add(time.Duration(w.end.Hour)*time.Hour + time.Duration(w.end.Minute)*time.Minute)
+			if !to.After(from) {
+				to = to.AddDate(0, 0, 1)
+			}
+			allowed += overlap(s.Start, s.End, from, to)
+		}
+	}
+	return allowed
+}
+
+func overlap(aStart, aEnd, bStart, bEnd time.Time) time.Duration {
+	if bStart.After(aStart) {
+		aStart = bStart
+	}
+	if bEnd.Before(aEnd) {
+		aEnd = bEnd
+	}
+	if !aEnd.After(aStart) {
+		return 0
+	}
+	return aEnd.Sub(aStart)
+}
+
+// ruleSection is one "[kind "name"]" block of the rules file.
+type ruleSection struct {
+	kind, name string
+	line       int
+	keys       []ruleKey
+}
+
+type ruleKey struct {
+	key, value string
+	line       int
+}
+
+// ruleState is everything loadRules sets, to put back after a failed reload.
+type ruleState struct {
+	projects   []project
+	contexts   []workContext
+	clients    []client
+	categories []category
+	redactions []redaction
+	allowRules []allowRule
+}
+
+func currentRules() ruleState {
+	return ruleState{projects, contexts, clients, categories, redactions, allowRules}
+}
+
+func (r ruleState) restore() {
+	projects, contexts, clients, categories, redactions, allowRules = r.projects, r.contexts, r.clients, r.categories, r.redactions, r.allowRules
+}
+
+// loadRules reads the rules file, a git-config style list of sections:
+//
+//	[project "Client A"]
+//	title = (?i)acme
+//	hours = 09:00-13:00
+func loadRules(path string, mustExist bool) []error {
+	sections, errs := readRuleSections(path, mustExist)
+	var loaded []project
+	var loadedContexts []workContext
+	var loadedClients []client
+	var loadedCategories []category
+	var loadedRedactions []redaction
+	var loadedAllow []allowRule
+	for _, sec := range sections {
+		where := fmt.Sprintf("%s:%d", path, sec.line)
+		switch sec.kind {
+		case "project":
+			if sec.name == "" {
+				errs = append(errs, fmt.Errorf("%s: project section needs a name, e.g. [project \"Client A\"]", where))
+				continue
+			}
+			p, perrs := parseProject(path, sec)
+			errs = append(errs, perrs...)
+			loaded = append(loaded, p)
+		case "context":
+			if sec.name == "" {
+				errs = append(errs, fmt.Errorf("%s: context section needs a name, e.g. [context \"Employer\"]", where))
+				continue
+			}
+			c, cerrs := parseContext(path, sec)
+			errs = append(errs, cerrs...)
+			loadedContexts = append(loadedContexts, c)
+		case "client":
+			if sec.name == "" {
+				errs = append(errs, fmt.Errorf("%s: client section needs a name, e.g. [client \"Acme\"]", where))
+				continue
+			}
+			c, cerrs := parseClient(path, sec)
+			errs = append(errs, cerrs...)
+			loadedClients = append(loadedClients, c)
+		case "category":
+			if sec.name == "" {
+				errs = append(errs, fmt.Errorf("%s: category section needs a name, e.g. [category \"Communication\"]", where))
+				continue
+			}
+			c, cerrs := parseCategory(path, sec)
+			errs = append(errs, cerrs...)
+			loadedCategories = append(loadedCategories, c)
+		case "redact":
+			if sec.name == "" {
+				errs = append(errs, fmt.Errorf("%s: redact section needs a name, e.g. [redact \"Mail\"]", where))
+				continue
+			}
+			r, rerrs := parseRedaction(path, sec)
+			errs = append(errs, rerrs...)
+			loadedRedactions = append(loadedRedactions, r)
+		case "allow":
+			if sec.name == "" {
+				errs = append(errs, fmt.Errorf("%s: allow section needs a name, e.g. [allow \"Editors\"]", where))
+				continue
+			}
+			r, rerrs := parseAllow(path, sec)
+			errs = append(errs, rerrs...)
+			loadedAllow = append(loadedAllow, r)
+		default:
+			msg := fmt.Sprintf("%s: unknown section %q", where, sec.kind)
+			if guess := closestMatch(sec.kind, []string{"project", "context", "client", "category", "redact", "allow"}); guess != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", guess)
+			}
+			errs = append(errs, errors.New(msg))
+		}
+	}
+	projects = loaded
+	contexts = loadedContexts
+	clients = loadedClients
+	categories = loadedCategories
+	redactions = loadedRedactions
+	allowRules = loadedAllow
+	for _, p := range projects {
+		if p.client != "" {
+			if _, ok := lookupClient(p.client); !ok {
+				errs = append(errs, fmt.Errorf("%s: project %q refers to unknown client %q", path, p.name, p.client))
+			}
+		}
+	}
+	for _, c := range contexts {
+		for name := range c.projects {
+			if !hasProject(name) {
+				errs = append(errs, fmt.Errorf("%s: context %q refers to unknown project %q", path, c.name, name))
+			}
+		}
+	}
+	return errs
+}
+
+func parseProject(path string, sec ruleSection) (project, []error) {
+	p := project{name: sec.name}
+	var errs []error
+	for _, k := range sec.keys {
+		where := fmt.Sprintf("%s:%d", path, k.line)
+		var err error
+		switch k.key {
+		case "app":
+			p.app, err = regexp.Compile(k.value)
+		case "bundle_id":
+			p.bundleID, err = regexp.Compile(k.value)
+		case "title":
+			p.title, err = regexp.Compile(k.value)
+		case "hours":
+			p.hours, err = parseWindows(k.value)
+		case "days":
+			p.days, err = parseWorkdays(k.value)
+		case "client":
+			p.client = k.value
+		case "rate":
+			p.rate, err = parseMoney(k.value)
+		case "billable":
+			var billable bool
+			billable, err = parseBool(k.value)
+			p.nonBillable = !billable
+		case "alias":
+			p.aliases = append(p.aliases, k.value)
+		case "archived":
+			p.archived, err = parseBool(k.value)
+		case "cost_center":
+			p.costCenter = k.value
+		case "wbs":
+			p.wbs = k.value
+		case "activity":
+			p.activity = k.value
+		case "harvest_project":
+			p.harvestProject = k.value
+		case "harvest_task":
+			p.harvestTask = k.value
+		case "retention":
+			var r retention
+			r, err = parseRetention(k.value)
+			p.retention = &r
+		default:
+			msg := fmt.Sprintf("%s: unknown project key %q", where, k.key)
+			if guess := closestMatch(k.key, []string{"app", "bundle_id", "title", "hours", "days", "client", "rate", "billable", "alias", "archived", "cost_center", "wbs", "activity", "harvest_project", "harvest_task", "retention"}); guess != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", guess)
+			}
+			errs = append(errs, errors.New(msg))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", where, k.key, err))
+		}
+	}
+	if p.app == nil && p.bundleID == nil && p.title == nil {
+		errs = append(errs, fmt.Errorf("%s:%d: project %q needs an app, bundle_id or title pattern", path, sec.line, sec.name))
+	}
+	return p, errs
+}
+
+func readRuleSections(path string, mustExist bool) ([]ruleSection, []error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && !mustExist {
+			return nil, nil
+		}
+		return nil, []error{err}
+	}
+	defer f.Close()
+	return parseRuleSections(path, f)
+}
+
+func parseRuleSections(path string, r io.Reader) ([]ruleSection, []error) {
+	var sections []ruleSection
+	var errs []error
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		where := fmt.Sprintf("%s:%d", path, lineNo)
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				errs = append(errs, fmt.Errorf("%s: unterminated section header %q", where, line))
+				continue
+			}
+			kind, name, _ := strings.Cut(strings.TrimSpace(line[1:len(line)-1]), " ")
+			sections = append(sections, ruleSection{
+				kind: strings.ToLower(kind),
+				name: unquote(strings.TrimSpace(name)),
+				line: lineNo,
+			})
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: expected \"key = value\", got %q", where, line))
+			continue
+		}
+		if len(sections) == 0 {
+			errs = append(errs, fmt.Errorf("%s: %q is outside of any section", where, line))
+			continue
+		}
+		sec := &sections[len(sections)-1]
+		sec.keys = append(sec.keys, ruleKey{
+			key:   strings.ToLower(strings.TrimSpace(key)),
+			value: unquote(strings.TrimSpace(value)),
+			line:  lineNo,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", path, err))
+	}
+	return sections, errs
+}
@@ -0,0 +1,20 @@
+package main
+
+import "time"
+
+// trackingBlocked returns why the tracker may not probe or record at all
+// right now, or "" when tracking is allowed.
+func trackingBlocked(now time.Time) string {
+	if pausedByUser() {
+		return "paused"
+	}
+	for _, w := range quietHours {
+		if w.contains(now) {
+			return "quiet hours"
+		}
+	}
+	if workHoursOnly && !isWorkHour(now) {
+		return "outside work hours"
+	}
+	return ""
+}
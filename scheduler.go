@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scheduledJob runs one of the tracker's commands on a cron schedule, e.g.
+// "0 2 * * * sync notion -day yesterday".
+type scheduledJob struct {
+	spec                         string
+	minutes, hours, doms, months uint64 // bit n set when n matches
+	dows                         uint64 // 0 is Sunday
+	anyDOM, anyDOW               bool
+	args                         []string
+	running                      sync.Mutex
+}
+
+var cronShortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+var cronDays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+var cronMonths = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+
+// parseSchedule reads jobs separated by ";", each a five-field cron
+// expression (or @hourly, @daily, @weekly, @monthly) followed by a command:
+// "0 2 * * * sync notion -day yesterday; 5 17 * * fri site -o /Users/me/Sites/focus".
+func parseSchedule(input string) ([]*scheduledJob, error) {
+	var jobs []*scheduledJob
+	for _, entry := range strings.Split(input, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if spec, ok := cronShortcuts[strings.ToLower(fields[0])]; ok {
+			fields = append(strings.Fields(spec), fields[1:]...)
+		}
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("invalid schedule %q, expected a cron expression and a command like \"0 2 * * * sync notion -day yesterday\"", strings.TrimSpace(entry))
+		}
+		job := &scheduledJob{spec: strings.Join(fields[:5], " "), args: fields[5:]}
+		var err error
+		for i, f := range []struct {
+			bits     *uint64
+			min, max int
+			names    map[string]int
+		}{
+			{&job.minutes, 0, 59, nil},
+			{&job.hours, 0, 23, nil},
+			{&job.doms, 1, 31, nil},
+			{&job.months, 1, 12, cronMonths},
+			{&job.dows, 0, 7, cronDays},
+		} {
+			if *f.bits, err = parseCronField(fields[i], f.min, f.max, f.names); err != nil {
+				return nil, fmt.Errorf("schedule %q: %v", job.spec, err)
+			}
+		}
+		if job.dows&(1<<7) != 0 {
+			job.dows |= 1 // 7 is Sunday too
+		}
+		job.anyDOM, job.anyDOW = fields[2] == "*", fields[4] == "*"
+		if job.args[0] == "schedule" {
+			return nil, fmt.Errorf("schedule %q: cannot schedule the schedule command", job.spec)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// parseCronField reads one field: *, a value, a range a-b, a step */n or
+// a-b/n, or a comma separated list of those.
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = cronValue(first, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = cronValue(last, min, max, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("%q is not a value from %d to %d", s, min, max)
+	}
+	return v, nil
+}
+
+// matches reports whether the job is due in the minute starting at t. Like
+// cron, a day matches either restricted day field when both are restricted.
+func (j *scheduledJob) matches(t time.Time) bool {
+	if j.minutes&(1<<t.Minute()) == 0 || j.hours&(1<<t.Hour()) == 0 || j.months&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom, dow := j.doms&(1<<t.Day()) != 0, j.dows&(1<<int(t.Weekday())) != 0
+	if j.anyDOM || j.anyDOW {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the job's next run after t, or the zero time if it never
+// runs within a year (e.g. "0 0 31 2 *").
+func (j *scheduledJob) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(1, 0, 0); t.Before(end); t = t.Add(time.Minute) {
+		if j.matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// maxCatchUp is how far back missed runs are made up, e.g. a nightly sync
+// that was due while the Mac slept runs once on wake.
+const maxCatchUp = 24 * time.Hour
+
+// runScheduler starts due jobs while the tracker runs.
+func runScheduler(jobs []*scheduledJob) {
+	last := time.Now().Truncate(time.Minute)
+	for range time.Tick(15 * time.Second) {
+		now := time.Now().Truncate(time.Minute)
+		if !now.After(last) {
+			continue
+		}
+		from := last.Add(time.Minute)
+		if now.Sub(from) > maxCatchUp {
+			from = now.Add(-maxCatchUp)
+		}
+		for _, job := range jobs {
+			for t := from; !t.After(now); t = t.Add(time.Minute) {
+				if job.matches(t) {
+					go runJob(job)
+					break
+				}
+			}
+		}
+		last = now
+	}
+}
+
+func schedulePath() string {
+	return filepath.Join(logs, "schedule.log")
+}
+
+// runJob runs the job's command as a child process with its output
+// appended to LOG_PATH/schedule.log. A job still running from its last
+// start is not started again.
+func runJob(job *scheduledJob) {
+	if !job.running.TryLock() {
+		return
+	}
+	defer job.running.Unlock()
+
+	command := strings.Join(job.args, " ")
+	noteEvent("scheduled %s", job.args[0])
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Printf("%s Scheduled %q: %v\n", glyphs.warn, command, err)
+		return
+	}
+	log, err := os.OpenFile(schedulePath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Printf("%s Scheduled %q: %v\n", glyphs.warn, command, err)
+		return
+	}
+	defer log.Close()
+	fmt.Fprintf(log, "=== %s %s\n", time.Now().Format("2006-01-02 15:04:05"), command)
+
+	cmd := exec.Command(exe, job.args...)
+	cmd.Stdout, cmd.Stderr = log, log
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(log, "=== failed: %v\n", err)
+		fmt.Printf("%s Scheduled %q failed (%v), see %s\n", glyphs.warn, command, err, schedulePath())
+	}
+}
+
+// runSchedule lists the configured jobs and when each runs next.
+func runSchedule(args []string) error {
+	if len(scheduledJobs) == 0 {
+		fmt.Println("No scheduled jobs; set SCHEDULE to run commands from the tracker.")
+		return nil
+	}
+	now := time.Now()
+	for _, job := range scheduledJobs {
+		next := "never"
+		if t := job.next(now); !t.IsZero() {
+			next = t.Format("Mon 2006-01-02 15:04")
+		}
+		fmt.Printf("%-20s %-40s next: %s\n", job.spec, strings.Join(job.args, " "), next)
+	}
+	return nil
+}
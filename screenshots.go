@@ -0,0 +1,373 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Screenshots are opt-in (SCREENSHOTS): every interval the main display is
+// captured at thumbnail size, encrypted with AES-GCM and stored as
+// LOG_PATH/screenshots/YYYY-MM-DD/HHMMSS.jpg.enc. The span that was focused
+// lists it in its Screenshots.
+
+const keychainService = "focus-tracker-screenshots"
+
+func screenshotsDir() string {
+	return filepath.Join(logs, "screenshots")
+}
+
+// screenshotPath maps a name from a span's Screenshots to its file.
+func screenshotPath(name string) string {
+	return filepath.Join(screenshotsDir(), name)
+}
+
+// screenshotKey is the encryption key: from SCREENSHOT_KEY if set, else a
+// random key kept in the login Keychain, created on first use.
+func screenshotKey(create bool) ([]byte, error) {
+	if screenshotPassphrase != "" {
+		key := sha256.Sum256([]byte(screenshotPassphrase))
+		return key[:], nil
+	}
+	account := "focus-tracker"
+	if u, err := user.Current(); err == nil {
+		account = u.Username
+	}
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w").Output()
+	if err == nil {
+		return hex.DecodeString(strings.TrimSpace(string(out)))
+	}
+	if !create {
+		return nil, errors.New("no screenshot key in the Keychain; set SCREENSHOT_KEY if the screenshots were taken with one")
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if out, err := exec.Command("security", "add-generic-password", "-s", keychainService, "-a", account, "-w", hex.EncodeToString(key)).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("storing the screenshot key in the Keychain: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return key, nil
+}
+
+func sealScreenshot(key, image []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, image, nil), nil
+}
+
+func openScreenshot(key []byte, name string) ([]byte, error) {
+	data, err := os.ReadFile(screenshotPath(name))
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%s: not a screenshot", name)
+	}
+	image, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot decrypt, wrong key", name)
+	}
+	return image, nil
+}
+
+// captureScreenshot takes a screenshot of the main display, shrinks it to
+// at most screenshotSize pixels and stores it encrypted, returning its name.
+func captureScreenshot(key []byte, now time.Time) (string, error) {
+	tmp, err := os.CreateTemp("", "focus-tracker-*.jpg")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if out, err := exec.Command("screencapture", "-x", "-t", "jpg", tmp.Name()).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("screencapture: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	if out, err := exec.Command("sips", "-Z", fmt.Sprint(screenshotSize), tmp.Name()).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("sips: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	image, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", err
+	}
+	if len(image) == 0 {
+		// screencapture writes nothing without Screen Recording permission
+		return "", fmt.Errorf("empty screenshot; allow Screen Recording for your terminal in System Settings %s Privacy & Security", glyphs.arrow)
+	}
+	sealed, err := sealScreenshot(key, image)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Join(now.Format("2006-01-02"), now.Format("150405")+".jpg.enc")
+	if err := os.MkdirAll(filepath.Dir(screenshotPath(name)), 0700); err != nil {
+		return "", err
+	}
+	return name, os.WriteFile(screenshotPath(name), sealed, 0600)
+}
+
+// pendingScreenshots are taken but not yet attached to a span.
+var pendingScreenshots struct {
+	sync.Mutex
+	names []string
+	at    []time.Time
+}
+
+// takeScreenshots captures a screenshot every interval while the tracker
+// runs, except while tracking is paused, the screen is idle or shared.
+func takeScreenshots(interval time.Duration) {
+	key, err := screenshotKey(true)
+	if err != nil {
+		fmt.Printf("%s Screenshots are off: %v\n", glyphs.warn, err)
+		return
+	}
+	for {
+		time.Sleep(interval)
+		now := time.Now()
+		if trackingBlocked(now) != "" || screenSharing.Load() || desktop.idleSeconds() > idleThresholdAt(now) {
+			continue
+		}
+		name, err := captureScreenshot(key, now)
+		if err != nil {
+			fmt.Printf("%s Could not take screenshot: %v\n", glyphs.warn, err)
+			continue
+		}
+		pendingScreenshots.Lock()
+		pendingScreenshots.names = append(pendingScreenshots.names, name)
+		pendingScreenshots.at = append(pendingScreenshots.at, now)
+		pendingScreenshots.Unlock()
+	}
+}
+
+// screenshotsDuring hands over the pending screenshots taken before end.
+func screenshotsDuring(end time.Time) []string {
+	pendingScreenshots.Lock()
+	defer pendingScreenshots.Unlock()
+	var taken []string
+	i := 0
+	for ; i < len(pendingScreenshots.at) && !pendingScreenshots.at[i].After(end); i++ {
+		taken = append(taken, pendingScreenshots.names[i])
+	}
+	pendingScreenshots.names = pendingScreenshots.names[i:]
+	pendingScreenshots.at = pendingScreenshots.at[i:]
+	return taken
+}
+
+// screenshotTime is when a screenshot was taken, from its name.
+func screenshotTime(name string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02/150405", strings.TrimSuffix(filepath.ToSlash(name), ".jpg.enc"), time.Local)
+}
+
+// dayScreenshots lists the names of a day's screenshots.
+func dayScreenshots(day time.Time) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(screenshotsDir(), day.Format("2006-01-02")))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".jpg.enc") {
+			names = append(names, filepath.Join(day.Format("2006-01-02"), e.Name()))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// timelineShot is a decrypted screenshot for the timeline.
+type timelineShot struct {
+	at      time.Time
+	dataURI string
+}
+
+// timelineShots decrypts the screenshots of the spans for embedding.
+func timelineShots(spans []Span) ([]timelineShot, error) {
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Screenshots...)
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	key, err := screenshotKey(false)
+	if err != nil {
+		return nil, err
+	}
+	var shots []timelineShot
+	for _, name := range names {
+		at, err := screenshotTime(name)
+		if err != nil {
+			continue
+		}
+		image, err := openScreenshot(key, name)
+		if os.IsNotExist(err) {
+			continue // deleted
+		} else if err != nil {
+			return nil, err
+		}
+		shots = append(shots, timelineShot{at, "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(image)})
+	}
+	return shots, nil
+}
+
+// runScreenshots handles "screenshots list [-day D] | show NAME [-o file] |
+// delete (-day D | -from D -to D | -all)".
+func runScreenshots(args []string) error {
+	usage := errors.New("usage: screenshots list [-day YYYY-MM-DD] | show NAME [-o file.jpg] | delete -day YYYY-MM-DD | -from YYYY-MM-DD -to YYYY-MM-DD | -all")
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("screenshots list", flag.ExitOnError)
+		dayStr := fs.String("day", "", "day (YYYY-MM-DD, today or yesterday)")
+		fs.Parse(args[1:])
+		day, err := parseDay(*dayStr)
+		if err != nil {
+			return err
+		}
+		names, err := dayScreenshots(day)
+		if err != nil {
+			return err
+		}
+		spans, err := readSpans(day)
+		if err != nil {
+			return err
+		}
+		in := make(map[string]Span)
+		for _, s := range spans {
+			for _, name := range s.Screenshots {
+				in[name] = s
+			}
+		}
+		if len(names) == 0 {
+			fmt.Println("No screenshots.")
+		}
+		for _, name := range names {
+			s, ok := in[name]
+			where := "(not in a span)"
+			if ok {
+				where = s.App
+				if s.Title != "" {
+					where += " — " + s.Title
+				}
+			}
+			fmt.Printf("%s  %s\n", name, truncate(where, 80))
+		}
+		return nil
+
+	case "show":
+		fs := flag.NewFlagSet("screenshots show", flag.ExitOnError)
+		out := fs.String("o", "", "write the image to this file instead of opening it")
+		if len(args) < 2 {
+			return usage
+		}
+		fs.Parse(args[2:])
+		key, err := screenshotKey(false)
+		if err != nil {
+			return err
+		}
+		image, err := openScreenshot(key, args[1])
+		if err != nil {
+			return err
+		}
+		if *out != "" {
+			return os.WriteFile(*out, image, 0600)
+		}
+		tmp, err := os.CreateTemp("", "focus-tracker-screenshot-*.jpg")
+		if err != nil {
+			return err
+		}
+		defer tmp.Close()
+		defer os.Remove(tmp.Name())
+		if _, err := tmp.Write(image); err != nil {
+			return err
+		}
+		if err := exec.Command("open", tmp.Name()).Run(); err != nil {
+			return err
+		}
+		// Give the viewer time to load it before the decrypted copy goes
+		time.Sleep(5 * time.Second)
+		return nil
+
+	case "delete":
+		fs := flag.NewFlagSet("screenshots delete", flag.ExitOnError)
+		dayStr := fs.String("day", "", "delete one day's screenshots")
+		fromStr := fs.String("from", "", "first day of a range to delete")
+		toStr := fs.String("to", "", "last day of the range (default today)")
+		all := fs.Bool("all", false, "delete every screenshot")
+		fs.Parse(args[1:])
+		if *all {
+			if err := os.RemoveAll(screenshotsDir()); err != nil {
+				return err
+			}
+			fmt.Printf("%s Deleted all screenshots.\n", glyphs.ok)
+			return nil
+		}
+		var from, to time.Time
+		var err error
+		switch {
+		case *dayStr != "":
+			if from, err = parseDay(*dayStr); err != nil {
+				return err
+			}
+			to = from
+		case *fromStr != "":
+			if from, err = parseDay(*fromStr); err != nil {
+				return err
+			}
+			if to, err = parseDay(*toStr); err != nil {
+				return err
+			}
+		default:
+			return usage
+		}
+		n := 0
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			names, err := dayScreenshots(day)
+			if err != nil {
+				return err
+			}
+			n += len(names)
+			if err := os.RemoveAll(filepath.Join(screenshotsDir(), day.Format("2006-01-02"))); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("%s Deleted %d screenshots.\n", glyphs.ok, n)
+		return nil
+	}
+	return usage
+}
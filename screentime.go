@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// screenTimeUsage is time Screen Time recorded in one app on one device.
+type screenTimeUsage struct {
+	app    string // bundle ID from knowledgeC.db, or the name in a CSV
+	device string // "" for this Mac
+	time   time.Duration
+}
+
+// Core Data timestamps count seconds from 2001-01-01 UTC.
+const coreDataEpoch = 978307200
+
+func defaultKnowledgeDB() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "Library", "Application Support", "Knowledge", "knowledgeC.db")
+}
+
+// readKnowledgeDB reads app usage in [from, to) from Screen Time's
+// knowledgeC.db with the sqlite3 command line tool that ships with macOS.
+// Reading it needs Full Disk Access for the terminal.
+func readKnowledgeDB(path string, from, to time.Time) ([]screenTimeUsage, error) {
+	query := fmt.Sprintf(`SELECT o.ZVALUESTRING, COALESCE(s.ZDEVICEID, ''),
+  MIN(o.ZENDDATE, %d) - MAX(o.ZSTARTDATE, %d)
+FROM ZOBJECT o LEFT JOIN ZSOURCE s ON o.ZSOURCE = s.Z_PK
+WHERE o.ZSTREAMNAME = '/app/usage' AND o.ZENDDATE > %d AND o.ZSTARTDATE < %d;`,
+		to.Unix()-coreDataEpoch, from.Unix()-coreDataEpoch, from.Unix()-coreDataEpoch, to.Unix()-coreDataEpoch)
+	out, err := exec.Command("sqlite3", "-readonly", "-separator", "\t", path, query).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("reading %s: %v (grant your terminal Full Disk Access in System Settings %s Privacy & Security, or use -csv)", path, err, glyphs.arrow)
+	}
+	var usage []screenTimeUsage
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		secs, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || secs <= 0 {
+			continue
+		}
+		usage = append(usage, screenTimeUsage{app: fields[0], device: fields[1], time: time.Duration(secs * float64(time.Second))})
+	}
+	return usage, nil
+}
+
+// readScreenTimeCSV reads usage copied out of Screen Time by hand, as
+// "day,app,minutes[,device]" rows with a header.
+func readScreenTimeCSV(path string, from, to time.Time) ([]screenTimeUsage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	var usage []screenTimeUsage
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return usage, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[0]), "day") {
+			continue
+		}
+		if len(rec) < 3 {
+			return nil, fmt.Errorf("%s:%d: expected day,app,minutes[,device]", path, line)
+		}
+		day, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(rec[0]), time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid day %q", path, line, rec[0])
+		}
+		minutes, err := strconv.ParseFloat(strings.TrimSpace(rec[2]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid minutes %q", path, line, rec[2])
+		}
+		if day.Before(from) || !day.Before(to) {
+			continue
+		}
+		u := screenTimeUsage{app: strings.TrimSpace(rec[1]), time: time.Duration(minutes * float64(time.Minute))}
+		if len(rec) > 3 {
+			u.device = strings.TrimSpace(rec[3])
+		}
+		usage = append(usage, u)
+	}
+}
+
+// runScreenTime handles "screentime [-day D | -from D -to D] [-db path |
+// -csv file]": the tracker's time per app next to Screen Time's.
+func runScreenTime(args []string) error {
+	fs := flag.NewFlagSet("screentime", flag.ExitOnError)
+	dayStr := fs.String("day", "", "day to compare (YYYY-MM-DD, today or yesterday; default yesterday)")
+	fromStr := fs.String("from", "", "first day of a range instead of -day")
+	toStr := fs.String("to", "", "last day of the range (default yesterday)")
+	dbPath := fs.String("db", defaultKnowledgeDB(), "Screen Time's knowledgeC.db")
+	csvPath := fs.String("csv", "", "read Screen Time usage from a day,app,minutes[,device] CSV instead of the database")
+	fs.Parse(args)
+
+	var from, to time.Time
+	var err error
+	if *fromStr != "" {
+		if from, err = parseDay(*fromStr); err != nil {
+			return err
+		}
+		if *toStr == "" {
+			*toStr = "yesterday"
+		}
+		if to, err = parseDay(*toStr); err != nil {
+			return err
+		}
+	} else {
+		if *dayStr == "" {
+			*dayStr = "yesterday"
+		}
+		if from, err = parseDay(*dayStr); err != nil {
+			return err
+		}
+		to = from
+	}
+	to = to.AddDate(0, 0, 1)
+
+	var usage []screenTimeUsage
+	if *csvPath != "" {
+		usage, err = readScreenTimeCSV(*csvPath, from, to)
+	} else {
+		usage, err = readKnowledgeDB(*dbPath, from, to)
+	}
+	if err != nil {
+		return err
+	}
+	spans, err := readSpanRange(from, to)
+	if err != nil {
+		return err
+	}
+
+	// Key both sides by app name, translating Screen Time's bundle IDs with
+	// the bundle IDs the tracker saw.
+	names := make(map[string]string)
+	tracked := make(map[string]time.Duration)
+	for _, s := range spans {
+		if s.Away() || s.Manual {
+			continue
+		}
+		if s.BundleID != "" {
+			names[strings.ToLower(s.BundleID)] = s.App
+		}
+		tracked[s.App] += overlap(s.Start, s.End, from, to)
+	}
+	lower := make(map[string]string)
+	for app := range tracked {
+		lower[strings.ToLower(app)] = app
+	}
+	screen := make(map[string]time.Duration)
+	devices := make(map[string]time.Duration)
+	for _, u := range usage {
+		if u.device != "" {
+			devices[u.device] += u.time
+			continue
+		}
+		app := u.app
+		if name, ok := names[strings.ToLower(app)]; ok {
+			app = name
+		} else if name, ok := lower[strings.ToLower(app)]; ok {
+			app = name
+		} else if i := strings.LastIndex(app, "."); i >= 0 {
+			// Spans without a bundle ID: com.apple.Safari is most likely Safari
+			if name, ok := lower[strings.ToLower(app[i+1:])]; ok {
+				app = name
+			}
+		}
+		screen[app] += u.time
+	}
+
+	all := make(map[string]time.Duration)
+	var trackedTotal, screenTotal, matched time.Duration
+	for app, d := range tracked {
+		all[app] = d
+		trackedTotal += d
+	}
+	for app, d := range screen {
+		if d > all[app] {
+			all[app] = d
+		}
+		screenTotal += d
+		if t := tracked[app]; t < d {
+			matched += t
+		} else {
+			matched += d
+		}
+	}
+
+	period := from.Format("2006-01-02")
+	if to.Sub(from) > 24*time.Hour {
+		period += " to " + to.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	fmt.Printf("Tracker vs Screen Time, %s\n", period)
+	fmt.Printf("%-32s %10s %12s %10s\n", "App", "Tracker", "Screen Time", "Diff")
+	for _, app := range sortedByDuration(all) {
+		t, st := tracked[app], screen[app]
+		diff := t - st
+		sign := "+"
+		if diff < 0 {
+			sign, diff = "-", -diff
+		}
+		if diff < time.Minute {
+			sign = " "
+		}
+		fmt.Printf("%-32s %10s %12s %10s\n", truncate(app, 32), shortDuration(t), shortDuration(st), sign+shortDuration(diff))
+	}
+	fmt.Printf("%-32s %10s %12s\n", "Total", shortDuration(trackedTotal), shortDuration(screenTotal))
+	if screenTotal > 0 {
+		fmt.Printf("\nThe tracker saw %s of the %s Screen Time recorded on this Mac (%.0f%%).\n",
+			shortDuration(matched), shortDuration(screenTotal), 100*matched.Seconds()/screenTotal.Seconds())
+	}
+	if len(devices) > 0 {
+		fmt.Printf("\nOther devices, not seen by the tracker\n")
+		for _, device := range sortedByDuration(devices) {
+			fmt.Printf("  %s: %s\n", device, shortDuration(devices[device]))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// serveControl runs the tracker's local HTTP server on CONTROL_ADDR. It has
+// no authentication and is meant to be bound to localhost; localOnly keeps
+// web pages from reaching it through the browser.
+func serveControl(addr string) {
+	mux := http.NewServeMux()
+	deckRoutes(mux)
+	grafanaRoutes(mux)
+	spanRoutes(mux)
+	apiRoutes(mux)
+	eventRoutes(mux)
+	if err := http.ListenAndServe(addr, localOnly(mux)); err != nil {
+		fmt.Printf("%s Control endpoint on %s stopped: %v\n", glyphs.warn, addr, err)
+	}
+}
+
+// localOnly guards the local HTTP servers against web pages the user has
+// open. A page that rebinds its own domain to 127.0.0.1 still sends that
+// domain as Host, so only loopback hosts are served. A plain form post
+// cannot set headers or a JSON Content-Type, so requests that change
+// something need either the X-Focus-Tracker header or a JSON body.
+func localOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !loopbackHost(r.Host) {
+			http.Error(w, "only served to localhost", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Header.Get("X-Focus-Tracker") == "" {
+			if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+				http.Error(w, "send the X-Focus-Tracker header or a JSON body", http.StatusForbidden)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// loopbackHost reports whether a Host header names this machine.
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
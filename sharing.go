@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// screenSharing is set while the screen is shared or recorded. Window
+// titles are not captured then, notifications are held back and the widget
+// and menu bar show no details.
+var screenSharing atomic.Bool
+
+// sharingScript lists the windows of the meeting apps and browsers, and
+// reports the screenshot and recording UI of macOS.
+const sharingScript = `set AppleScript's text item delimiters to linefeed
+tell application "System Events"
+	set out to ""
+	if exists process "screencaptureui" then set out to "screencaptureui" & linefeed
+	repeat with n in {"zoom.us", "Microsoft Teams", "Microsoft Teams (work or school)", "Webex", "Slack", "Google Chrome", "Microsoft Edge", "Brave Browser", "Arc", "Firefox", "Safari"}
+		if exists process n then
+			set out to out & ((name of every window of process n) as text) & linefeed
+		end if
+	end repeat
+	return out
+end tell`
+
+// sharingWindow matches the windows meeting apps and browsers show while
+// sharing: Zoom's share toolbar, Teams' sharing control bar, Slack's huddle
+// share and the browsers' "is sharing your screen" bar.
+var sharingWindow = regexp.MustCompile(`(?i)^screencaptureui$|^zoom share|^sharing (control|tool) ?bar|is sharing (your screen|a window|a tab|this tab)|^screen share`)
+
+// watchScreenSharing polls for a share every few seconds while the tracker
+// runs.
+func watchScreenSharing() {
+	for {
+		out, err := runAppleScript(sharingScript)
+		sharing := false
+		if err == nil {
+			for _, name := range strings.Split(out, "\n") {
+				sharing = sharing || sharingWindow.MatchString(strings.TrimSpace(name))
+			}
+		}
+		if was := screenSharing.Swap(sharing); was != sharing {
+			if sharing {
+				fmt.Println(paintDim("Screen sharing: window titles are not recorded until it ends."))
+			} else {
+				fmt.Println(paintDim("Screen sharing ended."))
+			}
+			// Let the widget and menu bar know right away
+			select {
+			case statusChanged <- struct{}{}:
+			default:
+			}
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
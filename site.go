@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// siteDay is one day of the static site.
+type siteDay struct {
+	Date          time.Time
+	Work, Outside time.Duration
+	First, Last   time.Time
+	Groups        []siteGroup
+	Timeline      template.HTML
+}
+
+type siteGroup struct {
+	Name  string
+	Total time.Duration
+}
+
+type siteWeek struct {
+	Label string
+	Work  time.Duration
+	Days  []siteDay
+}
+
+// runSite writes a static HTML site of recent days that can be published
+// without a server. Only work/outside totals and working hours are shown
+// unless -detail asks for project or app names; window titles never are.
+func runSite(args []string) error {
+	fs := flag.NewFlagSet("site", flag.ExitOnError)
+	out := fs.String("o", "site", "directory to write the site to")
+	days := fs.Int("days", 28, "number of days up to today to include")
+	detail := fs.String("detail", "none", "what to name in the pages: none, projects or apps")
+	fs.Parse(args)
+	if *detail != "none" && *detail != "projects" && *detail != "apps" {
+		return fmt.Errorf("unknown detail %q, expected none, projects or apps", *detail)
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+
+	today, _ := parseDay("")
+	var weeks []siteWeek
+	for i := *days - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i)
+		spans, err := readSpans(day)
+		if err != nil {
+			return err
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		d := buildSiteDay(day, anonymizeSpans(spans, *detail))
+		if err := writeSitePage(filepath.Join(*out, "day-"+day.Format("2006-01-02")+".html"), sitePageTemplate, d); err != nil {
+			return err
+		}
+
+		year, week := day.ISOWeek()
+		label := fmt.Sprintf("%d-W%02d", year, week)
+		if len(weeks) == 0 || weeks[len(weeks)-1].Label != label {
+			weeks = append(weeks, siteWeek{Label: label})
+		}
+		w := &weeks[len(weeks)-1]
+		w.Days = append(w.Days, d)
+		w.Work += d.Work
+	}
+	// Newest week first
+	for i, j := 0, len(weeks)-1; i < j; i, j = i+1, j-1 {
+		weeks[i], weeks[j] = weeks[j], weeks[i]
+	}
+	if err := writeSitePage(filepath.Join(*out, "index.html"), siteIndexTemplate, weeks); err != nil {
+		return err
+	}
+	fmt.Printf("%s Site written to %s\n", glyphs.ok, filepath.Join(*out, "index.html"))
+	return nil
+}
+
+// anonymizeSpans drops titles and, depending on detail, project and app
+// names, leaving "Work" or "Outside hours" as the only label.
+func anonymizeSpans(spans []Span, detail string) []Span {
+	result := make([]Span, 0, len(spans))
+	for _, s := range spans {
+		if s.Project == "" {
+			s.Project = projectFor(s)
+		}
+		s.Title, s.BundleID, s.AppPath, s.AppVersion = "", "", "", ""
+		s.Contexts, s.NowPlaying, s.Domain, s.Issue, s.Screenshots = nil, "", "", "", nil
+		generic := "Outside hours"
+		if s.Work {
+			generic = "Work"
+		}
+		switch {
+		case s.Away():
+		case detail == "none":
+			s.App, s.Project = generic, generic
+		case detail == "projects":
+			s.App = generic
+			if s.Project == "" {
+				s.Project = generic
+			}
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+func buildSiteDay(day time.Time, spans []Span) siteDay {
+	d := siteDay{Date: day}
+	totals := make(map[string]time.Duration)
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		if s.Work {
+			d.Work += s.Duration()
+		} else {
+			d.Outside += s.Duration()
+		}
+		totals[timelineLabel(s)] += s.Duration()
+		if s.Coarse {
+			continue
+		}
+		if d.First.IsZero() || s.Start.Before(d.First) {
+			d.First = s.Start
+		}
+		if s.End.After(d.Last) {
+			d.Last = s.End
+		}
+	}
+	for _, name := range sortedByDuration(totals) {
+		d.Groups = append(d.Groups, siteGroup{name, totals[name]})
+	}
+	var svg bytes.Buffer
+	writeTimelineSVG(&svg, day, spans, nil)
+	d.Timeline = template.HTML(svg.String())
+	return d
+}
+
+func writeSitePage(path string, tmpl *template.Template, data any) error {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+var siteFuncs = template.FuncMap{
+	"dur":  shortDuration,
+	"date": func(t time.Time) string { return t.Format("Mon 2006-01-02") },
+	"file": func(t time.Time) string { return "day-" + t.Format("2006-01-02") + ".html" },
+	"hm":   func(t time.Time) string { return t.Format("15:04") },
+}
+
+const siteStyle = `<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; max-width: 980px; margin: 2em auto; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+td, th { padding: 4px 12px; text-align: left; border-bottom: 1px solid #eee; }
+td.num { text-align: right; }
+</style>`
+
+var siteIndexTemplate = template.Must(template.New("index").Funcs(siteFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Focus tracker</title>` + siteStyle + `</head>
+<body>
+<h1>Focus tracker</h1>
+{{range .}}
+<h2>{{.Label}} &middot; {{dur .Work}} work</h2>
+<table>
+<tr><th>Day</th><th>Work</th><th>Outside hours</th><th>Active</th></tr>
+{{range .Days}}<tr><td><a href="{{file .Date}}">{{date .Date}}</a></td><td class="num">{{dur .Work}}</td><td class="num">{{dur .Outside}}</td><td>{{if not .First.IsZero}}{{hm .First}}&ndash;{{hm .Last}}{{end}}</td></tr>
+{{end}}</table>
+{{else}}<p>Nothing tracked in this period.</p>
+{{end}}
+</body></html>
+`))
+
+var sitePageTemplate = template.Must(template.New("day").Funcs(siteFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{date .Date}}</title>` + siteStyle + `</head>
+<body>
+<p><a href="index.html">&larr; All days</a></p>
+<h1>{{date .Date}}</h1>
+<p>{{dur .Work}} work, {{dur .Outside}} outside hours{{if not .First.IsZero}}, active {{hm .First}}&ndash;{{hm .Last}}{{end}}</p>
+{{.Timeline}}
+<table>
+{{range .Groups}}<tr><td>{{.Name}}</td><td class="num">{{dur .Total}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snowflakeBatch is the number of rows bound to one MERGE statement.
+const snowflakeBatch = 500
+
+// snowflakeClient runs statements through the Snowflake SQL API with
+// key-pair authentication.
+type snowflakeClient struct {
+	base  string
+	token string
+}
+
+// syncSnowflake creates or extends the span table and merges the day's
+// spans into it.
+func syncSnowflake(day time.Time, spans []Span) error {
+	if snowflakeAccount == "" || snowflakeUser == "" || snowflakeKey == "" || snowflakeDatabase == "" {
+		return errors.New("SNOWFLAKE_ACCOUNT, SNOWFLAKE_USER, SNOWFLAKE_PRIVATE_KEY and SNOWFLAKE_DATABASE must be set")
+	}
+	c, err := newSnowflakeClient()
+	if err != nil {
+		return err
+	}
+	var cols []string
+	for _, col := range warehouseColumns {
+		cols = append(cols, col.name+" "+col.snowflake)
+	}
+	if err := c.exec("CREATE TABLE IF NOT EXISTS "+snowflakeTable+" ("+strings.Join(cols, ", ")+")", nil); err != nil {
+		return err
+	}
+	for _, col := range cols {
+		if err := c.exec("ALTER TABLE "+snowflakeTable+" ADD COLUMN IF NOT EXISTS "+col, nil); err != nil {
+			return err
+		}
+	}
+
+	spans = withContexts(spans)
+	for i := 0; i < len(spans); i += snowflakeBatch {
+		batch := spans[i:minInt(i+snowflakeBatch, len(spans))]
+		query, bindings := snowflakeMerge(batch)
+		if err := c.exec(query, bindings); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%s Merged %d spans for %s into %s.%s.%s\n", glyphs.ok, len(spans), day.Format("2006-01-02"), snowflakeDatabase, snowflakeSchema, snowflakeTable)
+	return nil
+}
+
+// newSnowflakeClient signs a key-pair JWT; the issuer names the public key
+// by its SHA-256 fingerprint, as registered with ALTER USER ... SET
+// RSA_PUBLIC_KEY.
+func newSnowflakeClient() (*snowflakeClient, error) {
+	key, err := readRSAKey(snowflakeKey)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(der)
+	// The account identifier in the JWT has no region or cloud suffix
+	account, _, _ := strings.Cut(snowflakeAccount, ".")
+	user := account + "." + snowflakeUser
+	now := time.Now()
+	token, err := signJWT(map[string]any{
+		"iss": user + ".SHA256:" + base64.StdEncoding.EncodeToString(fingerprint[:]),
+		"sub": user,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	base := "https://" + strings.ToLower(snowflakeAccount) + ".snowflakecomputing.com/api/v2/statements"
+	return &snowflakeClient{base: base, token: token}, nil
+}
+
+// snowflakeMerge builds an upsert of the spans, bound as text and cast by
+// the statement.
+func snowflakeMerge(spans []Span) (string, map[string]any) {
+	n := len(warehouseColumns)
+	var placeholders, selects, sets, names []string
+	for i, col := range warehouseColumns {
+		selects = append(selects, fmt.Sprintf("TRY_CAST(NULLIF(column%d, '') AS %s) AS %s", i+1, col.snowflake, col.name))
+		sets = append(sets, fmt.Sprintf("%s = s.%s", col.name, col.name))
+		names = append(names, col.name)
+	}
+	bindings := make(map[string]any)
+	for r, s := range spans {
+		marks := make([]string, n)
+		for i, v := range warehouseRow(s) {
+			marks[i] = "?"
+			bindings[strconv.Itoa(r*n+i+1)] = map[string]string{"type": "TEXT", "value": v}
+		}
+		placeholders = append(placeholders, "("+strings.Join(marks, ", ")+")")
+	}
+	var on []string
+	for _, k := range warehouseKey {
+		on = append(on, fmt.Sprintf("t.%s = s.%s", k, k))
+	}
+	query := fmt.Sprintf("MERGE INTO %s t USING (SELECT %s FROM VALUES %s) s ON %s "+
+		"WHEN MATCHED THEN UPDATE SET %s WHEN NOT MATCHED THEN INSERT (%s) VALUES (s.%s)",
+		snowflakeTable, strings.Join(selects, ", "), strings.Join(placeholders, ", "), strings.Join(on, " AND "),
+		strings.Join(sets, ", "), strings.Join(names, ", "), strings.Join(names, ", s."))
+	return query, bindings
+}
+
+// exec runs one statement and waits for it to finish.
+func (c *snowflakeClient) exec(statement string, bindings map[string]any) error {
+	body := map[string]any{
+		"statement": statement,
+		"timeout":   120,
+		"database":  snowflakeDatabase,
+		"schema":    snowflakeSchema,
+	}
+	if snowflakeWarehouse != "" {
+		body["warehouse"] = snowflakeWarehouse
+	}
+	if snowflakeRole != "" {
+		body["role"] = snowflakeRole
+	}
+	if bindings != nil {
+		body["bindings"] = bindings
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	status, result, err := c.call("POST", c.base, data)
+	for err == nil && status == http.StatusAccepted {
+		var pending struct {
+			Handle string `json:"statementHandle"`
+		}
+		json.Unmarshal(result, &pending)
+		if pending.Handle == "" {
+			return errors.New("Snowflake accepted the statement without a handle")
+		}
+		time.Sleep(time.Second)
+		status, result, err = c.call("GET", c.base+"/"+pending.Handle, nil)
+	}
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(result, &failure)
+		if failure.Message == "" {
+			failure.Message = string(bytes.TrimSpace(result))
+		}
+		return newStatusError(status, "Snowflake: %d: %s", status, failure.Message)
+	}
+	return nil
+}
+
+func (c *snowflakeClient) call(method, url string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxSpanPage and maxSpanRangeDays bound one /spans request, so a client
+	// exporting a year pages through it instead of receiving one huge reply.
+	maxSpanPage      = 5000
+	defaultSpanPage  = 500
+	maxSpanRangeDays = 366
+)
+
+// spanRoutes registers GET /spans?from=YYYY-MM-DD&to=YYYY-MM-DD&limit=N&cursor=C,
+// the journal's spans one page at a time. Each page reads only the journal
+// lines it returns; next_cursor, present while more spans follow, resumes
+// exactly where the page ended, even in a later session.
+func spanRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/spans", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, err := parseDay(q.Get("from"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := parseDay(q.Get("to"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if to.Before(from) {
+			http.Error(w, "to is before from", http.StatusBadRequest)
+			return
+		}
+		if to.Sub(from) >= maxSpanRangeDays*24*time.Hour {
+			http.Error(w, fmt.Sprintf("at most %d days per request", maxSpanRangeDays), http.StatusBadRequest)
+			return
+		}
+		limit := defaultSpanPage
+		if v := q.Get("limit"); v != "" {
+			if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxSpanPage {
+				http.Error(w, fmt.Sprintf("limit must be 1 to %d", maxSpanPage), http.StatusBadRequest)
+				return
+			}
+		}
+		day, offset := from, int64(0)
+		if c := q.Get("cursor"); c != "" {
+			if day, offset, err = parseSpanCursor(c); err != nil || day.Before(from) || day.After(to) {
+				http.Error(w, "invalid cursor for this range", http.StatusBadRequest)
+				return
+			}
+		}
+
+		spans := make([]Span, 0, limit)
+		for ; !day.After(to); day, offset = day.AddDate(0, 0, 1), 0 {
+			offset, err = scanJournal(spansPath(day), offset, func(s Span) bool {
+				spans = append(spans, s)
+				return len(spans) < limit
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(spans) == limit {
+				break
+			}
+		}
+		page := map[string]any{"spans": withContexts(spans)}
+		// A full page may have ended exactly at the end of the range; the
+		// client then gets one empty page, which is cheaper than looking ahead
+		if len(spans) == limit {
+			page["next_cursor"] = spanCursor(day, offset)
+		}
+		writeJSON(w, page)
+	})
+}
+
+// spanCursor encodes a journal position: the day and a byte offset into
+// its journal, which only ever grows.
+func spanCursor(day time.Time, offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(day.Format("2006-01-02") + ":" + strconv.FormatInt(offset, 10)))
+}
+
+func parseSpanCursor(cursor string) (time.Time, int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	dayStr, offsetStr, _ := strings.Cut(string(data), ":")
+	day, err := time.ParseInLocation("2006-01-02", dayStr, time.Local)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	if err != nil || offset < 0 {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor offset")
+	}
+	return day, offset, nil
+}
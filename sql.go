@@ -0,0 +1,1078 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A small SQL dialect for the query command:
+//
+//	SELECT expr [AS name], ... FROM table
+//	  [WHERE expr] [GROUP BY expr, ...] [ORDER BY expr [ASC|DESC], ...] [LIMIT n]
+//
+// Expressions support AND/OR/NOT, comparisons, LIKE, IN (...), + - * / % ||,
+// the aggregates count, sum, avg, min and max, and a few scalar functions.
+// There are no joins or subqueries.
+
+// queryTable is an in-memory table the query runs over.
+type queryTable struct {
+	columns []string
+	rows    [][]any // float64, string or bool values
+}
+
+// queryResult is the output of a query.
+type queryResult struct {
+	columns []string
+	rows    [][]any
+}
+
+type selectItem struct {
+	expr queryExpr // nil for *
+	name string
+}
+
+type orderItem struct {
+	expr queryExpr
+	desc bool
+}
+
+type selectQuery struct {
+	items   []selectItem
+	from    string
+	where   queryExpr
+	groupBy []queryExpr
+	orderBy []orderItem
+	limit   int // -1 for no limit
+}
+
+// queryContext is what an expression is evaluated against: one row, or in
+// a grouped query the group's rows with its first row for plain columns.
+type queryContext struct {
+	table *queryTable
+	row   []any
+	group [][]any
+}
+
+type queryExpr interface {
+	eval(c *queryContext) (any, error)
+}
+
+type queryLiteral struct{ value any }
+
+type queryColumn struct{ name string }
+
+type queryUnary struct {
+	op string
+	x  queryExpr
+}
+
+type queryBinary struct {
+	op   string
+	x, y queryExpr
+}
+
+type queryIn struct {
+	x    queryExpr
+	list []queryExpr
+	not  bool
+}
+
+type queryCall struct {
+	fn   string
+	args []queryExpr
+	star bool // count(*)
+}
+
+var queryAggregates = map[string]bool{"count": true, "sum": true, "avg": true, "min": true, "max": true}
+
+var queryKeywords = map[string]bool{
+	"select": true, "from": true, "where": true, "group": true, "order": true, "by": true, "limit": true,
+	"as": true, "and": true, "or": true, "not": true, "like": true, "in": true, "asc": true, "desc": true,
+}
+
+type queryToken struct {
+	kind       byte // 'i' identifier, 'n' number, 's' string, 'o' operator, 0 end
+	text       string
+	start, end int
+}
+
+func lexQuery(src string) ([]queryToken, error) {
+	var toks []queryToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(src) {
+					return nil, fmt.Errorf("unterminated string starting at %q", src[i:])
+				}
+				if src[j] == '\'' {
+					if j+1 < len(src) && src[j+1] == '\'' {
+						b.WriteByte('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(src[j])
+				j++
+			}
+			toks = append(toks, queryToken{'s', b.String(), i, j + 1})
+			i = j + 1
+			continue
+		case c == '"':
+			j := strings.IndexByte(src[i+1:], '"')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated identifier starting at %q", src[i:])
+			}
+			toks = append(toks, queryToken{'i', src[i+1 : i+1+j], i, i + j + 2})
+			i += j + 2
+			continue
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, queryToken{'n', src[i:j], i, j})
+			i = j
+			continue
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, queryToken{'i', src[i:j], i, j})
+			i = j
+			continue
+		}
+		if i+1 < len(src) {
+			if two := src[i : i+2]; two == "<=" || two == ">=" || two == "<>" || two == "!=" || two == "||" {
+				toks = append(toks, queryToken{'o', two, i, i + 2})
+				i += 2
+				continue
+			}
+		}
+		if !strings.ContainsRune("=<>+-*/%(),;", rune(c)) {
+			return nil, fmt.Errorf("unexpected %q", src[i:i+1])
+		}
+		toks = append(toks, queryToken{'o', src[i : i+1], i, i + 1})
+		i++
+	}
+	return append(toks, queryToken{start: len(src), end: len(src)}), nil
+}
+
+type queryParser struct {
+	src  string
+	toks []queryToken
+	pos  int
+}
+
+func (p *queryParser) peek() queryToken { return p.toks[p.pos] }
+
+func (p *queryParser) next() queryToken {
+	t := p.toks[p.pos]
+	if t.kind != 0 {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the keyword if it comes next.
+func (p *queryParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == 'i' && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// op consumes the operator if it comes next.
+func (p *queryParser) op(text string) bool {
+	if t := p.peek(); t.kind == 'o' && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	t := p.peek()
+	near := "end of query"
+	if t.kind != 0 {
+		near = fmt.Sprintf("%q", p.src[t.start:])
+		if len(near) > 30 {
+			near = near[:27] + "...\""
+		}
+	}
+	return fmt.Errorf(format+" near %s", append(args, near)...)
+}
+
+func parseQuery(src string) (*selectQuery, error) {
+	toks, err := lexQuery(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{src: src, toks: toks}
+	q := &selectQuery{limit: -1}
+	if !p.keyword("select") {
+		return nil, p.errorf("expected SELECT")
+	}
+	for {
+		if p.op("*") {
+			q.items = append(q.items, selectItem{name: "*"})
+		} else {
+			start := p.peek().start
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			name := strings.TrimSpace(src[start:p.toks[p.pos-1].end])
+			if col, ok := e.(queryColumn); ok {
+				name = col.name
+			}
+			if p.keyword("as") {
+				t := p.next()
+				if t.kind != 'i' && t.kind != 's' {
+					return nil, p.errorf("expected a name after AS")
+				}
+				name = t.text
+			} else if t := p.peek(); t.kind == 'i' && !queryKeywords[strings.ToLower(t.text)] {
+				name = p.next().text
+			}
+			q.items = append(q.items, selectItem{expr: e, name: name})
+		}
+		if !p.op(",") {
+			break
+		}
+	}
+	if !p.keyword("from") {
+		return nil, p.errorf("expected FROM")
+	}
+	if t := p.next(); t.kind == 'i' {
+		q.from = strings.ToLower(t.text)
+	} else {
+		return nil, p.errorf("expected a table name")
+	}
+	if p.keyword("where") {
+		if q.where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if hasAggregate(q.where) {
+			return nil, fmt.Errorf("aggregates are not allowed in WHERE")
+		}
+	}
+	if p.keyword("group") {
+		if !p.keyword("by") {
+			return nil, p.errorf("expected BY")
+		}
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			q.groupBy = append(q.groupBy, e)
+			if !p.op(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("order") {
+		if !p.keyword("by") {
+			return nil, p.errorf("expected BY")
+		}
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			item := orderItem{expr: e}
+			if p.keyword("desc") {
+				item.desc = true
+			} else {
+				p.keyword("asc")
+			}
+			q.orderBy = append(q.orderBy, item)
+			if !p.op(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("limit") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != 'n' || err != nil || n < 0 {
+			return nil, p.errorf("expected a row count after LIMIT")
+		}
+		q.limit = n
+	}
+	p.op(";")
+	if p.peek().kind != 0 {
+		return nil, p.errorf("unexpected input")
+	}
+	return q, nil
+}
+
+func (p *queryParser) parseExpr() (queryExpr, error) {
+	x, err := p.parseAnd()
+	for err == nil && p.keyword("or") {
+		var y queryExpr
+		if y, err = p.parseAnd(); err == nil {
+			x = queryBinary{"or", x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	x, err := p.parseNot()
+	for err == nil && p.keyword("and") {
+		var y queryExpr
+		if y, err = p.parseNot(); err == nil {
+			x = queryBinary{"and", x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *queryParser) parseNot() (queryExpr, error) {
+	if p.keyword("not") {
+		x, err := p.parseNot()
+		return queryUnary{"not", x}, err
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (queryExpr, error) {
+	x, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"=", "!=", "<>", "<=", ">=", "<", ">"} {
+		if p.op(op) {
+			y, err := p.parseAdditive()
+			if op == "<>" {
+				op = "!="
+			}
+			return queryBinary{op, x, y}, err
+		}
+	}
+	save := p.pos
+	not := p.keyword("not")
+	switch {
+	case p.keyword("like"):
+		y, err := p.parseAdditive()
+		var e queryExpr = queryBinary{"like", x, y}
+		if not {
+			e = queryUnary{"not", e}
+		}
+		return e, err
+	case p.keyword("in"):
+		if !p.op("(") {
+			return nil, p.errorf("expected ( after IN")
+		}
+		in := queryIn{x: x, not: not}
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, e)
+			if !p.op(",") {
+				break
+			}
+		}
+		if !p.op(")") {
+			return nil, p.errorf("expected )")
+		}
+		return in, nil
+	}
+	p.pos = save
+	return x, nil
+}
+
+func (p *queryParser) parseAdditive() (queryExpr, error) {
+	x, err := p.parseMultiplicative()
+	for err == nil {
+		t := p.peek()
+		if t.kind != 'o' || (t.text != "+" && t.text != "-" && t.text != "||") {
+			break
+		}
+		p.next()
+		var y queryExpr
+		if y, err = p.parseMultiplicative(); err == nil {
+			x = queryBinary{t.text, x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *queryParser) parseMultiplicative() (queryExpr, error) {
+	x, err := p.parseUnary()
+	for err == nil {
+		t := p.peek()
+		if t.kind != 'o' || (t.text != "*" && t.text != "/" && t.text != "%") {
+			break
+		}
+		p.next()
+		var y queryExpr
+		if y, err = p.parseUnary(); err == nil {
+			x = queryBinary{t.text, x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *queryParser) parseUnary() (queryExpr, error) {
+	if p.op("-") {
+		x, err := p.parseUnary()
+		return queryUnary{"-", x}, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case 'n':
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return queryLiteral{v}, nil
+	case 's':
+		return queryLiteral{t.text}, nil
+	case 'o':
+		if t.text == "(" {
+			x, err := p.parseExpr()
+			if err == nil && !p.op(")") {
+				err = p.errorf("expected )")
+			}
+			return x, err
+		}
+	case 'i':
+		name := strings.ToLower(t.text)
+		switch name {
+		case "true":
+			return queryLiteral{true}, nil
+		case "false":
+			return queryLiteral{false}, nil
+		case "null":
+			return queryLiteral{nil}, nil
+		}
+		if !p.op("(") {
+			if queryKeywords[name] {
+				p.pos--
+				return nil, p.errorf("expected an expression")
+			}
+			if name == "date" {
+				name = "day" // both tables' day column, by its more common name
+			}
+			return queryColumn{name}, nil
+		}
+		call := queryCall{fn: name}
+		if p.op("*") {
+			call.star = true
+		} else if !(p.peek().kind == 'o' && p.peek().text == ")") {
+			for {
+				arg, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+				if !p.op(",") {
+					break
+				}
+			}
+		}
+		if !p.op(")") {
+			return nil, p.errorf("expected )")
+		}
+		if call.star && name != "count" {
+			return nil, fmt.Errorf("%s(*) is not supported, only count(*)", name)
+		}
+		if queryAggregates[name] {
+			if !call.star && len(call.args) != 1 {
+				return nil, fmt.Errorf("%s takes one argument", name)
+			}
+			if !call.star && hasAggregate(call.args[0]) {
+				return nil, fmt.Errorf("aggregates cannot be nested")
+			}
+		}
+		return call, nil
+	}
+	p.pos--
+	return nil, p.errorf("expected an expression")
+}
+
+// walkQuery calls fn for e and every expression below it.
+func walkQuery(e queryExpr, fn func(queryExpr)) {
+	fn(e)
+	switch e := e.(type) {
+	case queryUnary:
+		walkQuery(e.x, fn)
+	case queryBinary:
+		walkQuery(e.x, fn)
+		walkQuery(e.y, fn)
+	case queryIn:
+		walkQuery(e.x, fn)
+		for _, x := range e.list {
+			walkQuery(x, fn)
+		}
+	case queryCall:
+		for _, x := range e.args {
+			walkQuery(x, fn)
+		}
+	}
+}
+
+func hasAggregate(e queryExpr) bool {
+	found := false
+	walkQuery(e, func(x queryExpr) {
+		if call, ok := x.(queryCall); ok && queryAggregates[call.fn] {
+			found = true
+		}
+	})
+	return found
+}
+
+// run evaluates the query over the table.
+func (q *selectQuery) run(t *queryTable) (*queryResult, error) {
+	index := make(map[string]int, len(t.columns))
+	for i, name := range t.columns {
+		index[name] = i
+	}
+	// GROUP BY and ORDER BY may name an output column or give its position
+	for i, e := range q.groupBy {
+		var err error
+		if q.groupBy[i], err = q.outputRef(e, index); err != nil {
+			return nil, err
+		}
+	}
+	for i, o := range q.orderBy {
+		var err error
+		if q.orderBy[i].expr, err = q.outputRef(o.expr, index); err != nil {
+			return nil, err
+		}
+	}
+	var check []queryExpr
+	if q.where != nil {
+		check = append(check, q.where)
+	}
+	check = append(check, q.groupBy...)
+	grouped := len(q.groupBy) > 0
+	for _, item := range q.items {
+		if item.expr == nil {
+			continue
+		}
+		check = append(check, item.expr)
+		grouped = grouped || hasAggregate(item.expr)
+	}
+	for _, o := range q.orderBy {
+		check = append(check, o.expr)
+		grouped = grouped || hasAggregate(o.expr)
+	}
+	for _, e := range check {
+		var err error
+		walkQuery(e, func(x queryExpr) {
+			if col, ok := x.(queryColumn); ok && err == nil {
+				if _, known := index[col.name]; !known {
+					err = fmt.Errorf("unknown column %q; columns are %s", col.name, strings.Join(t.columns, ", "))
+				}
+			}
+			if call, ok := x.(queryCall); ok && err == nil && !queryAggregates[call.fn] && queryFunctions[call.fn] == nil {
+				err = fmt.Errorf("unknown function %s", call.fn)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rows [][]any
+	for _, row := range t.rows {
+		if q.where != nil {
+			v, err := q.where.eval(&queryContext{table: t, row: row})
+			if err != nil {
+				return nil, err
+			}
+			if !queryTruthy(v) {
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	var contexts []*queryContext
+	if !grouped {
+		for _, row := range rows {
+			contexts = append(contexts, &queryContext{table: t, row: row})
+		}
+	} else if len(q.groupBy) == 0 {
+		c := &queryContext{table: t, group: rows}
+		if len(rows) > 0 {
+			c.row = rows[0]
+		}
+		contexts = append(contexts, c)
+	} else {
+		groups := make(map[string]*queryContext)
+		for _, row := range rows {
+			var key strings.Builder
+			for _, e := range q.groupBy {
+				v, err := e.eval(&queryContext{table: t, row: row})
+				if err != nil {
+					return nil, err
+				}
+				fmt.Fprintf(&key, "%T:%v\x00", v, v)
+			}
+			c, ok := groups[key.String()]
+			if !ok {
+				c = &queryContext{table: t, row: row}
+				groups[key.String()] = c
+				contexts = append(contexts, c)
+			}
+			c.group = append(c.group, row)
+		}
+	}
+
+	result := &queryResult{}
+	for _, item := range q.items {
+		if item.expr == nil {
+			result.columns = append(result.columns, t.columns...)
+		} else {
+			result.columns = append(result.columns, item.name)
+		}
+	}
+	keys := make([][]any, len(contexts))
+	for i, c := range contexts {
+		var out []any
+		for _, item := range q.items {
+			if item.expr == nil {
+				out = append(out, c.row...)
+				continue
+			}
+			v, err := item.expr.eval(c)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		for _, o := range q.orderBy {
+			v, err := o.expr.eval(c)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = append(keys[i], v)
+		}
+		result.rows = append(result.rows, out)
+	}
+	if len(q.orderBy) > 0 {
+		order := make([]int, len(result.rows))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			for k, o := range q.orderBy {
+				cmp := compareQueryValues(keys[order[a]][k], keys[order[b]][k])
+				if o.desc {
+					cmp = -cmp
+				}
+				if cmp != 0 {
+					return cmp < 0
+				}
+			}
+			return false
+		})
+		sorted := make([][]any, len(order))
+		for i, j := range order {
+			sorted[i] = result.rows[j]
+		}
+		result.rows = sorted
+	}
+	if q.limit >= 0 && len(result.rows) > q.limit {
+		result.rows = result.rows[:q.limit]
+	}
+	return result, nil
+}
+
+// outputRef replaces a reference to a selected column, by alias or by
+// position, with the column's expression.
+func (q *selectQuery) outputRef(e queryExpr, index map[string]int) (queryExpr, error) {
+	switch e := e.(type) {
+	case queryColumn:
+		if _, ok := index[e.name]; ok {
+			return e, nil
+		}
+		for _, item := range q.items {
+			if item.expr != nil && strings.EqualFold(item.name, e.name) {
+				return item.expr, nil
+			}
+		}
+	case queryLiteral:
+		if n, ok := e.value.(float64); ok {
+			if n < 1 || int(n) > len(q.items) || q.items[int(n)-1].expr == nil {
+				return nil, fmt.Errorf("%g is not the position of a selected column", n)
+			}
+			return q.items[int(n)-1].expr, nil
+		}
+	}
+	return e, nil
+}
+
+func (e queryLiteral) eval(*queryContext) (any, error) { return e.value, nil }
+
+func (e queryColumn) eval(c *queryContext) (any, error) {
+	if c.row == nil {
+		return nil, nil
+	}
+	for i, name := range c.table.columns {
+		if name == e.name {
+			return c.row[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown column %q", e.name)
+}
+
+func (e queryUnary) eval(c *queryContext) (any, error) {
+	v, err := e.x.eval(c)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if e.op == "not" {
+		return !queryTruthy(v), nil
+	}
+	n, ok := queryNumber(v)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate %q", queryText(v))
+	}
+	return -n, nil
+}
+
+func (e queryBinary) eval(c *queryContext) (any, error) {
+	x, err := e.x.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	// AND and OR short-circuit
+	switch e.op {
+	case "and":
+		if !queryTruthy(x) {
+			return false, nil
+		}
+	case "or":
+		if queryTruthy(x) {
+			return true, nil
+		}
+	}
+	y, err := e.y.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "and", "or":
+		return queryTruthy(y), nil
+	case "||":
+		return queryText(x) + queryText(y), nil
+	}
+	if x == nil || y == nil {
+		return nil, nil
+	}
+	switch e.op {
+	case "=":
+		return compareQueryValues(x, y) == 0, nil
+	case "!=":
+		return compareQueryValues(x, y) != 0, nil
+	case "<":
+		return compareQueryValues(x, y) < 0, nil
+	case "<=":
+		return compareQueryValues(x, y) <= 0, nil
+	case ">":
+		return compareQueryValues(x, y) > 0, nil
+	case ">=":
+		return compareQueryValues(x, y) >= 0, nil
+	case "like":
+		re, err := likePattern(queryText(y))
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(queryText(x)), nil
+	}
+	a, ok1 := queryNumber(x)
+	b, ok2 := queryNumber(y)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%q %s %q: not numbers", queryText(x), e.op, queryText(y))
+	}
+	switch e.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return nil, nil
+		}
+		return a / b, nil
+	}
+	if b == 0 {
+		return nil, nil
+	}
+	return math.Mod(a, b), nil
+}
+
+func (e queryIn) eval(c *queryContext) (any, error) {
+	x, err := e.x.eval(c)
+	if err != nil || x == nil {
+		return nil, err
+	}
+	for _, item := range e.list {
+		y, err := item.eval(c)
+		if err != nil {
+			return nil, err
+		}
+		if y != nil && compareQueryValues(x, y) == 0 {
+			return !e.not, nil
+		}
+	}
+	return e.not, nil
+}
+
+func (e queryCall) eval(c *queryContext) (any, error) {
+	if !queryAggregates[e.fn] {
+		args := make([]any, len(e.args))
+		for i, arg := range e.args {
+			v, err := arg.eval(c)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		return queryFunctions[e.fn](args)
+	}
+	if c.group == nil && c.row != nil {
+		return nil, fmt.Errorf("%s used outside an aggregate query", e.fn)
+	}
+	if e.star {
+		return float64(len(c.group)), nil
+	}
+	var values []any
+	for _, row := range c.group {
+		v, err := e.args[0].eval(&queryContext{table: c.table, row: row})
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			values = append(values, v)
+		}
+	}
+	if e.fn == "count" {
+		return float64(len(values)), nil
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	switch e.fn {
+	case "min", "max":
+		best := values[0]
+		for _, v := range values[1:] {
+			if cmp := compareQueryValues(v, best); (e.fn == "min") == (cmp < 0) && cmp != 0 {
+				best = v
+			}
+		}
+		return best, nil
+	}
+	var sum float64
+	for _, v := range values {
+		n, ok := queryNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("%s(%q): not a number", e.fn, queryText(v))
+		}
+		sum += n
+	}
+	if e.fn == "avg" {
+		return sum / float64(len(values)), nil
+	}
+	return sum, nil
+}
+
+// queryFunctions are the scalar functions; they receive evaluated arguments.
+var queryFunctions = map[string]func(args []any) (any, error){
+	"lower": func(args []any) (any, error) {
+		return stringFunction("lower", args, strings.ToLower)
+	},
+	"upper": func(args []any) (any, error) {
+		return stringFunction("upper", args, strings.ToUpper)
+	},
+	"length": func(args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("length takes one argument")
+		}
+		if args[0] == nil {
+			return nil, nil
+		}
+		return float64(len([]rune(queryText(args[0])))), nil
+	},
+	"substr": func(args []any) (any, error) {
+		if len(args) != 2 && len(args) != 3 {
+			return nil, fmt.Errorf("substr takes a string, a start and an optional length")
+		}
+		if args[0] == nil {
+			return nil, nil
+		}
+		s := []rune(queryText(args[0]))
+		start, _ := queryNumber(args[1])
+		from := minInt(len(s), int(math.Max(start, 1))-1)
+		to := len(s)
+		if len(args) == 3 {
+			n, _ := queryNumber(args[2])
+			to = minInt(to, from+int(math.Max(n, 0)))
+		}
+		return string(s[from:to]), nil
+	},
+	"round": func(args []any) (any, error) {
+		if len(args) != 1 && len(args) != 2 {
+			return nil, fmt.Errorf("round takes a number and optional digits")
+		}
+		n, ok := queryNumber(args[0])
+		if !ok {
+			return nil, nil
+		}
+		digits := 0.0
+		if len(args) == 2 {
+			digits, _ = queryNumber(args[1])
+		}
+		scale := math.Pow(10, digits)
+		return math.Round(n*scale) / scale, nil
+	},
+	"abs": func(args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("abs takes one argument")
+		}
+		n, ok := queryNumber(args[0])
+		if !ok {
+			return nil, nil
+		}
+		return math.Abs(n), nil
+	},
+	"coalesce": func(args []any) (any, error) {
+		for _, v := range args {
+			if v != nil && v != "" {
+				return v, nil
+			}
+		}
+		return nil, nil
+	},
+}
+
+func stringFunction(name string, args []any, fn func(string) string) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s takes one argument", name)
+	}
+	if args[0] == nil {
+		return nil, nil
+	}
+	return fn(queryText(args[0])), nil
+}
+
+// likePattern compiles a LIKE pattern; like SQLite it ignores case.
+func likePattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func queryTruthy(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return false
+}
+
+func queryNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func queryText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return v.(string)
+}
+
+// compareQueryValues orders NULL first, then numbers (and booleans) before
+// text; a number compared with numeric text compares as numbers.
+func compareQueryValues(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	x, xNum := queryNumber(a)
+	y, yNum := queryNumber(b)
+	_, aText := a.(string)
+	_, bText := b.(string)
+	if xNum && yNum && !(aText && bText) {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	if aText != bText {
+		if aText {
+			return 1
+		}
+		return -1
+	}
+	return strings.Compare(queryText(a), queryText(b))
+}
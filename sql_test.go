@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testQueryTable has a NULL and an empty project, and an app with a quote.
+func testQueryTable() *queryTable {
+	return &queryTable{
+		columns: []string{"app", "project", "seconds"},
+		rows: [][]any{
+			{"Code", "Acme", 600.0},
+			{"Code", "Acme", 300.0},
+			{"Safari", "", 120.0},
+			{"Slack", nil, 60.0},
+			{"O'Brien", "Beta", 0.0},
+		},
+	}
+}
+
+func runTestQuery(src string) (*queryResult, error) {
+	q, err := parseQuery(src)
+	if err != nil {
+		return nil, err
+	}
+	return q.run(testQueryTable())
+}
+
+func TestQueryValues(t *testing.T) {
+	tests := []struct {
+		expr string
+		want any
+	}{
+		{`'it''s'`, "it's"},
+		{`''`, ""},
+		{`"seconds"`, 600.0},
+		{`-seconds`, -600.0},
+		{`7 / 2`, 3.5},
+		{`7 % 4`, 3.0},
+		{`1 / 0`, nil},
+		{`seconds / 0`, nil},
+		{`5 % 0`, nil},
+		{`NULL`, nil},
+		{`NULL + 1`, nil},
+		{`NULL = NULL`, nil},
+		{`'a' || NULL || 'b'`, "ab"},
+		{`'10' = 10`, true},
+		{`'abc' = 'ABC'`, false},
+		{`'ABC' LIKE 'a_c'`, true},
+		{`'a.c' LIKE 'a%'`, true},
+		{`'abc' LIKE 'a.c'`, false},
+		{`2 IN (1, 2)`, true},
+		{`3 NOT IN (1, 2)`, true},
+		{`NULL IN (1)`, nil},
+		{`NOT (1 = 1 AND 2 = 3)`, true},
+		{`coalesce(NULL, '', 'x')`, "x"},
+		{`length(NULL)`, nil},
+		{`length('día')`, 3.0},
+		{`substr('focus', 2, 3)`, "ocu"},
+		{`round(2.345, 2)`, 2.35},
+		{`upper(app)`, "CODE"},
+	}
+	for _, tt := range tests {
+		res, err := runTestQuery("SELECT " + tt.expr + " FROM spans LIMIT 1")
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := res.rows[0][0]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %#v, want %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestQueryRows(t *testing.T) {
+	tests := []struct {
+		src     string
+		columns []string
+		rows    [][]any
+	}{
+		{
+			// NULL and empty are separate groups, NULL sorting first
+			"SELECT project, count(*) AS n, sum(seconds) FROM spans GROUP BY project ORDER BY 1",
+			[]string{"project", "n", "sum(seconds)"},
+			[][]any{{nil, 1.0, 60.0}, {"", 1.0, 120.0}, {"Acme", 2.0, 900.0}, {"Beta", 1.0, 0.0}},
+		},
+		{
+			"SELECT project, sum(seconds) / count(*) AS per FROM spans WHERE project != '' GROUP BY project ORDER BY per DESC",
+			[]string{"project", "per"},
+			[][]any{{"Acme", 450.0}, {"Beta", 0.0}},
+		},
+		{
+			"SELECT app, sum(seconds) / sum(0) FROM spans GROUP BY 1 ORDER BY 1 LIMIT 2",
+			[]string{"app", "sum(seconds) / sum(0)"},
+			[][]any{{"Code", nil}, {"O'Brien", nil}},
+		},
+		{
+			// count(x) skips NULL but not empty values
+			"SELECT count(project), count(*), max(app), min(seconds), avg(seconds) FROM spans",
+			[]string{"count(project)", "count(*)", "max(app)", "min(seconds)", "avg(seconds)"},
+			[][]any{{4.0, 5.0, "Slack", 0.0, 216.0}},
+		},
+		{
+			"SELECT count(*), sum(seconds) FROM spans WHERE app = 'Nope'",
+			[]string{"count(*)", "sum(seconds)"},
+			[][]any{{0.0, nil}},
+		},
+		{
+			"SELECT app FROM spans WHERE app LIKE 'o''%' OR project IN ('', 'Nope')",
+			[]string{"app"},
+			[][]any{{"Safari"}, {"O'Brien"}},
+		},
+		{
+			`SELECT "app" a, seconds FROM spans ORDER BY seconds LIMIT 2;`,
+			[]string{"a", "seconds"},
+			[][]any{{"O'Brien", 0.0}, {"Slack", 60.0}},
+		},
+	}
+	for _, tt := range tests {
+		res, err := runTestQuery(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(res.columns, tt.columns) {
+			t.Errorf("%s: columns %q, want %q", tt.src, res.columns, tt.columns)
+		}
+		if !reflect.DeepEqual(res.rows, tt.rows) {
+			t.Errorf("%s: rows %#v, want %#v", tt.src, res.rows, tt.rows)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, src := range []string{
+		"SELECT 'open FROM spans",
+		`SELECT "app FROM spans`,
+		"SELECT app FROM spans WHERE app ? 1",
+		"SELECT app spans",
+		"SELECT nope FROM spans",
+		"SELECT nope(app) FROM spans",
+		"SELECT sum(count(*)) FROM spans",
+		"SELECT sum(*) FROM spans",
+		"SELECT app FROM spans WHERE count(*) > 1",
+		"SELECT app FROM spans ORDER BY 3",
+		"SELECT app FROM spans LIMIT -1",
+		"SELECT app FROM spans extra",
+		"SELECT app - 1 FROM spans",
+	} {
+		if _, err := runTestQuery(src); err == nil {
+			t.Errorf("%s: no error", src)
+		}
+	}
+}
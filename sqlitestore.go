@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With STORE=sqlite:///path.db every span is also written as a row of an
+// SQLite database, through the sqlite3 command line tool that ships with
+// macOS, and today's totals are rebuilt from it at startup instead of from
+// the summary files.
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS spans (
+  day       TEXT NOT NULL,    -- YYYY-MM-DD, local, of the start
+  start     TEXT NOT NULL,    -- RFC 3339 with the UTC offset
+  end       TEXT NOT NULL,
+  seconds   REAL NOT NULL,
+  app       TEXT NOT NULL,
+  bundle_id TEXT NOT NULL DEFAULT '',
+  title     TEXT NOT NULL DEFAULT '',
+  work      INTEGER NOT NULL, -- 1 in work hours, 0 outside
+  bucket    TEXT NOT NULL DEFAULT '',
+  project   TEXT NOT NULL DEFAULT '',
+  contexts  TEXT NOT NULL DEFAULT '', -- comma-separated
+  manual    INTEGER NOT NULL DEFAULT 0,
+  app_path        TEXT NOT NULL DEFAULT '',
+  app_version     TEXT NOT NULL DEFAULT '',
+  editing_seconds INTEGER NOT NULL DEFAULT 0,
+  category        TEXT NOT NULL DEFAULT '',
+  domain          TEXT NOT NULL DEFAULT '',
+  issue           TEXT NOT NULL DEFAULT '',
+  now_playing     TEXT NOT NULL DEFAULT '',
+  span            TEXT NOT NULL DEFAULT '' -- the whole span as JSON, as in the journal
+);
+CREATE INDEX IF NOT EXISTS spans_day ON spans (day);
+`
+
+// sqliteSchemaVersion is the layout of the spans table, kept in the
+// database's user_version. Databases of an older layout get the columns
+// added since, in sqliteAddedColumns, when the tracker starts.
+const sqliteSchemaVersion = 2
+
+var sqliteAddedColumns = []struct {
+	version    int
+	name, decl string
+}{
+	{2, "app_path", "TEXT NOT NULL DEFAULT ''"},
+	{2, "app_version", "TEXT NOT NULL DEFAULT ''"},
+	{2, "editing_seconds", "INTEGER NOT NULL DEFAULT 0"},
+	{2, "category", "TEXT NOT NULL DEFAULT ''"},
+	{2, "domain", "TEXT NOT NULL DEFAULT ''"},
+	{2, "issue", "TEXT NOT NULL DEFAULT ''"},
+	{2, "now_playing", "TEXT NOT NULL DEFAULT ''"},
+	{2, "span", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrateSQLite brings the database's spans table up to
+// sqliteSchemaVersion. Rows written before keep their empty new columns;
+// the journal still has those fields.
+func migrateSQLite(path string) error {
+	out, err := sqliteRun(path, "PRAGMA user_version;\n")
+	if err != nil {
+		return err
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return fmt.Errorf("sqlite3 %s: user_version %q: %v", path, out, err)
+	}
+	switch {
+	case version == sqliteSchemaVersion:
+		return nil
+	case version > sqliteSchemaVersion:
+		return fmt.Errorf("%s was written by a newer focus-tracker (schema version %d, this one reads %d); upgrade focus-tracker", path, version, sqliteSchemaVersion)
+	}
+	// A new database was created with every column already
+	out, err = sqliteRun(path, "SELECT name FROM pragma_table_info('spans');\n")
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool)
+	for _, name := range strings.Fields(string(out)) {
+		have[name] = true
+	}
+	var b strings.Builder
+	b.WriteString("BEGIN;\n")
+	for _, c := range sqliteAddedColumns {
+		if c.version > version && !have[c.name] {
+			fmt.Fprintf(&b, "ALTER TABLE spans ADD COLUMN %s %s;\n", c.name, c.decl)
+		}
+	}
+	fmt.Fprintf(&b, "PRAGMA user_version = %d;\nCOMMIT;\n", sqliteSchemaVersion)
+	_, err = sqliteRun(path, b.String())
+	return err
+}
+
+// parseStore reads "journal" or "sqlite:///absolute/path.db" and returns
+// the database path, "" for the journal alone.
+func parseStore(v string) (string, error) {
+	if v == "journal" {
+		return "", nil
+	}
+	path, ok := strings.CutPrefix(v, "sqlite://")
+	if !ok || path == "" {
+		return "", fmt.Errorf("STORE must be journal or sqlite:///path/to/focus.db, got %q", v)
+	}
+	return expandHome(path), nil
+}
+
+// sqliteRun runs SQL against the database, creating it and the table on
+// first use, and returns what sqlite3 printed.
+func sqliteRun(path, query string, args ...string) ([]byte, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("sqlite3", append(append([]string{"-bail"}, args...), path)...)
+	cmd.Stdin = strings.NewReader(sqliteSchema + query)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("sqlite3 %s: %v", path, err)
+	}
+	return out, nil
+}
+
+// sqliteQuote makes a string literal.
+func sqliteQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func sqliteBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// sqlitePending are the finished spans not written to SQLite yet. The
+// tracker writes them in one transaction per flush, at every checkpoint and
+// save, instead of starting sqlite3 for each span.
+var sqlitePending struct {
+	sync.Mutex
+	spans []Span
+}
+
+func queueSQLiteSpan(s Span) {
+	sqlitePending.Lock()
+	defer sqlitePending.Unlock()
+	sqlitePending.spans = append(sqlitePending.spans, s)
+}
+
+// flushSQLite writes the queued spans. They stay queued when that fails,
+// for the next flush.
+func flushSQLite() error {
+	sqlitePending.Lock()
+	defer sqlitePending.Unlock()
+	if sqlitePath == "" || len(sqlitePending.spans) == 0 {
+		return nil
+	}
+	if err := insertSQLiteSpans(sqlitePath, sqlitePending.spans); err != nil {
+		return err
+	}
+	sqlitePending.spans = nil
+	return nil
+}
+
+// insertSQLiteSpans writes spans as rows in one transaction.
+func insertSQLiteSpans(path string, spans []Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("BEGIN;\n")
+	for _, s := range spans {
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b,
+			"INSERT INTO spans (day, start, end, seconds, app, bundle_id, title, work, bucket, project, contexts, manual, "+
+				"app_path, app_version, editing_seconds, category, domain, issue, now_playing, span) "+
+				"VALUES (%s, %s, %s, %.3f, %s, %s, %s, %d, %s, %s, %s, %d, %s, %s, %d, %s, %s, %s, %s, %s);\n",
+			sqliteQuote(s.Start.Format("2006-01-02")), sqliteQuote(s.Start.Format(time.RFC3339Nano)), sqliteQuote(s.End.Format(time.RFC3339Nano)),
+			s.Duration().Seconds(), sqliteQuote(s.App), sqliteQuote(s.BundleID), sqliteQuote(s.Title), sqliteBool(s.Work),
+			sqliteQuote(s.Bucket), sqliteQuote(s.Project), sqliteQuote(strings.Join(s.Contexts, ",")), sqliteBool(s.Manual),
+			sqliteQuote(s.AppPath), sqliteQuote(s.AppVersion), s.EditingSeconds, sqliteQuote(s.Category), sqliteQuote(s.Domain),
+			sqliteQuote(s.Issue), sqliteQuote(s.NowPlaying), sqliteQuote(string(data)))
+	}
+	b.WriteString("COMMIT;\n")
+	_, err := sqliteRun(path, b.String())
+	return err
+}
+
+// catchUpSQLite adds the day's journaled spans the database is missing,
+// e.g. those still queued when the tracker was killed. Rows are matched by
+// their start.
+func catchUpSQLite(path string, day time.Time) error {
+	spans, err := readSpans(day)
+	if err != nil || len(spans) == 0 {
+		return err
+	}
+	out, err := sqliteRun(path, fmt.Sprintf("SELECT start FROM spans WHERE day = %s;\n", sqliteQuote(day.Format("2006-01-02"))))
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool)
+	for _, start := range strings.Split(string(out), "\n") {
+		have[start] = true
+	}
+	var missing []Span
+	for _, s := range spans {
+		if !have[s.Start.Format(time.RFC3339Nano)] {
+			missing = append(missing, s)
+		}
+	}
+	return insertSQLiteSpans(path, missing)
+}
+
+// sqliteTotals sums a day's rows per bucket, context, app and title in the
+// database, for the tracker's totals.
+func sqliteTotals(path string, day time.Time) (map[string]Totals, error) {
+	out, err := sqliteRun(path, fmt.Sprintf(
+		"SELECT work, bucket, contexts, app, title, sum(seconds) AS seconds FROM spans WHERE day = %s "+
+			"GROUP BY work, bucket, contexts, app, title;\n",
+		sqliteQuote(day.Format("2006-01-02"))), "-json")
+	if err != nil || len(strings.TrimSpace(string(out))) == 0 {
+		return nil, err
+	}
+	var rows []struct {
+		Work     int     `json:"work"`
+		Bucket   string  `json:"bucket"`
+		Contexts string  `json:"contexts"`
+		App      string  `json:"app"`
+		Title    string  `json:"title"`
+		Seconds  float64 `json:"seconds"`
+	}
+	if err := json.Unmarshal(out, &rows); err != nil {
+		return nil, fmt.Errorf("sqlite3 %s: %v", path, err)
+	}
+	buckets := make(map[string]Totals)
+	for _, r := range rows {
+		s := Span{App: r.App, Title: r.Title, Work: r.Work == 1, Bucket: r.Bucket}
+		s.End = s.Start.Add(time.Duration(r.Seconds * float64(time.Second)))
+		if r.Contexts != "" {
+			s.Contexts = strings.Split(r.Contexts, ",")
+		}
+		for _, suffix := range s.Suffixes() {
+			buckets[suffix] = buckets[suffix].add(s)
+		}
+	}
+	return buckets, nil
+}
+
+// loadSQLiteTotals rebuilds today's buckets from the database. It reports
+// false without STORE or when the database has nothing for today yet, e.g.
+// on the first run with STORE set, so the summary files are read instead.
+func loadSQLiteTotals(buckets map[string]Totals) (bool, error) {
+	if sqlitePath == "" {
+		return false, nil
+	}
+	// Spans journaled since the last flush, or recovered just now, first
+	if err := flushSQLite(); err != nil {
+		return false, err
+	}
+	if err := catchUpSQLite(sqlitePath, time.Now()); err != nil {
+		return false, err
+	}
+	totals, err := sqliteTotals(sqlitePath, time.Now())
+	if err != nil || len(totals) == 0 {
+		return false, err
+	}
+	for suffix, t := range totals {
+		buckets[suffix] = t
+	}
+	return true, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// runStandup handles "standup [-day D] [-by project|category] [-round 15m]":
+// the work hours of the last working day as a short Markdown list to paste
+// into a stand-up channel.
+func runStandup(args []string) error {
+	fs := flag.NewFlagSet("standup", flag.ExitOnError)
+	dayStr := fs.String("day", "", "day to summarize (YYYY-MM-DD, today or yesterday; default the last working day before today)")
+	by := fs.String("by", "project", "group by project or category; time without one is listed by app")
+	round := fs.Duration("round", 15*time.Minute, "round each line to a multiple of this")
+	fs.Parse(args)
+	if *by != "project" && *by != "category" {
+		return fmt.Errorf("unknown grouping %q, expected project or category", *by)
+	}
+	if *round <= 0 {
+		return errors.New("-round must be positive")
+	}
+
+	var day time.Time
+	var err error
+	if *dayStr != "" {
+		if day, err = parseDay(*dayStr); err != nil {
+			return err
+		}
+	} else {
+		day = lastWorkingDay()
+	}
+	spans, err := readSpans(day)
+	if err != nil {
+		return err
+	}
+	annotations, err := readAnnotations(day)
+	if err != nil {
+		return err
+	}
+	writeStandup(os.Stdout, day, withContexts(spans), annotations, *by, *round)
+	return nil
+}
+
+// lastWorkingDay is the latest day before today with work hours, looking
+// back two weeks at most, else yesterday.
+func lastWorkingDay() time.Time {
+	today, _ := parseDay("")
+	for i := 1; i <= 14; i++ {
+		if day := today.AddDate(0, 0, -i); len(workDayWindows(day)) > 0 {
+			return day
+		}
+	}
+	return today.AddDate(0, 0, -1)
+}
+
+// writeStandup lists the day's work-hours time per project or category,
+// each rounded to the nearest multiple of round, and the day's notes.
+// Lines that round to nothing are left out.
+func writeStandup(w io.Writer, day time.Time, spans []Span, annotations []annotation, by string, round time.Duration) {
+	groups := make(map[string]time.Duration)
+	var work time.Duration
+	for _, s := range spans {
+		if s.Away() || !s.Work {
+			continue
+		}
+		name := s.Project
+		if by == "category" {
+			name = s.Category
+		}
+		if name == "" {
+			name = s.App
+		}
+		groups[name] += s.Duration()
+		work += s.Duration()
+	}
+
+	when := day.Format("Monday 2006-01-02")
+	if yesterday, _ := parseDay("yesterday"); day.Equal(yesterday) {
+		when = "Yesterday, " + when
+	}
+	if work == 0 {
+		fmt.Fprintf(w, "%s: nothing tracked in work hours\n", when)
+	} else {
+		fmt.Fprintf(w, "%s: %s of work\n", when, standupDuration(work.Round(round)))
+	}
+	for _, name := range sortedByDuration(groups) {
+		if d := groups[name].Round(round); d > 0 {
+			fmt.Fprintf(w, "- %s: %s\n", markdownEscape(name), standupDuration(d))
+		}
+	}
+	var notes []string
+	for _, a := range annotations {
+		if a.Tag == "" && a.Note != "" {
+			notes = append(notes, fmt.Sprintf("- %s %s", a.Start.Format("15:04"), markdownEscape(a.Note)))
+		}
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(w, "\nNotes:\n%s\n", strings.Join(notes, "\n"))
+	}
+}
+
+// standupDuration is shortDuration without the zero minutes of whole
+// hours, e.g. "2h" instead of "2h0m".
+func standupDuration(d time.Duration) string {
+	return strings.Replace(shortDuration(d), "h0m", "h", 1)
+}
+
+// markdownEscape keeps names such as "__init__.py" or "*scratch*" from
+// turning into emphasis.
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`).Replace(s)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// storeVersion is the layout version of the files in LOG_PATH, recorded in
+// LOG_PATH/store_version. A change to the format of the summaries or
+// journals adds a migration that converts older files and bumps it.
+const storeVersion = 1
+
+// migration upgrades LOG_PATH from version-1 to version.
+type migration struct {
+	version     int
+	description string
+	apply       func() error
+}
+
+var migrations = []migration{
+	// Directories written before versioning have this layout already
+	{1, "daily summaries, span journals and annotations as JSON lines", nil},
+}
+
+func storeVersionPath() string {
+	return filepath.Join(logs, "store_version")
+}
+
+// readStoreVersion returns the store version of LOG_PATH, 0 for files
+// written before versioning, and whether LOG_PATH exists at all.
+func readStoreVersion() (version int, exists bool, err error) {
+	if _, err := os.Stat(logs); os.IsNotExist(err) {
+		return 0, false, nil // nothing written yet
+	}
+	data, err := os.ReadFile(storeVersionPath())
+	if os.IsNotExist(err) {
+		return 0, true, nil
+	}
+	if err != nil {
+		return 0, true, err
+	}
+	if version, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil {
+		return 0, true, fmt.Errorf("%s: %v", storeVersionPath(), err)
+	}
+	if version > storeVersion {
+		return version, true, fmt.Errorf("%s was written by a newer focus-tracker (store version %d, this one reads %d); upgrade focus-tracker", logs, version, storeVersion)
+	}
+	return version, true, nil
+}
+
+// pendingMigrations are the migrations that change files written at version.
+func pendingMigrations(version int) []migration {
+	var pending []migration
+	for _, m := range migrations {
+		if m.version > version && m.apply != nil {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// checkStore lets the commands other than the tracker read LOG_PATH: only
+// the tracker, holding the lock, migrates it, so files of another version
+// are refused rather than misread.
+func checkStore() error {
+	version, _, err := readStoreVersion()
+	if err == nil && len(pendingMigrations(version)) > 0 {
+		err = fmt.Errorf("%s has store version %d and needs an upgrade to %d; start the tracker once to upgrade it", logs, version, storeVersion)
+	}
+	return err
+}
+
+// migrateStore brings LOG_PATH up to storeVersion, backing it up first
+// when a migration changes files, and the SQLite database of STORE up to
+// sqliteSchemaVersion. A directory or database written by a newer version
+// is left alone and an error returned, rather than risk damaging it. Only
+// the tracker calls it, after lockTracker, so no other tracker writes to
+// the files while they change.
+func migrateStore() error {
+	if sqlitePath != "" {
+		if err := migrateSQLite(sqlitePath); err != nil {
+			return err
+		}
+	}
+	version, exists, err := readStoreVersion()
+	if err != nil || !exists || version == storeVersion {
+		return err
+	}
+
+	if pending := pendingMigrations(version); len(pending) > 0 {
+		backup, err := backupStore(version)
+		if err != nil {
+			return fmt.Errorf("backing up %s before upgrading it: %v", logs, err)
+		}
+		fmt.Printf("Upgrading %s from store version %d to %d; backup in %s\n", logs, version, storeVersion, backup)
+		for _, m := range pending {
+			if err := m.apply(); err != nil {
+				return fmt.Errorf("store migration %d (%s): %v; restore from %s", m.version, m.description, err, backup)
+			}
+			if err := writeStoreVersion(m.version); err != nil {
+				return err
+			}
+		}
+	}
+	err = writeStoreVersion(storeVersion)
+	if errors.Is(err, os.ErrPermission) {
+		return nil // tracking goes on, as it does when the journal cannot be written
+	}
+	return err
+}
+
+func writeStoreVersion(version int) error {
+	return os.WriteFile(storeVersionPath(), []byte(strconv.Itoa(version)+"\n"), 0644)
+}
+
+// backupStore zips the files at the top of LOG_PATH into
+// LOG_PATH/backups/store-v<version>-<time>.zip.
+func backupStore(version int) (string, error) {
+	dir := filepath.Join(logs, "backups")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("store-v%d-%s.zip", version, time.Now().Format("20060102-150405")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	entries, err := os.ReadDir(logs)
+	if err != nil {
+		return "", err
+	}
+	zw := zip.NewWriter(f)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		w, err := zw.Create(entry.Name())
+		if err != nil {
+			return "", err
+		}
+		src, err := os.Open(filepath.Join(logs, entry.Name()))
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(w, src)
+		src.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// deckState is the JSON served to Stream Deck and similar controllers.
+type deckState struct {
+	Paused         bool   `json:"paused"`
+	App            string `json:"app,omitempty"`
+	Project        string `json:"project,omitempty"`
+	ElapsedSeconds int    `json:"elapsed_seconds"`
+	Elapsed        string `json:"elapsed"`
+	Today          string `json:"today"`
+}
+
+func currentDeckState() deckState {
+	now := time.Now()
+	status := currentStatus()
+	work, _ := todaysTotals(now, status, true)
+	st := deckState{
+		Paused:  status.App == "",
+		App:     status.App,
+		Project: status.Project,
+		Today:   shortDuration(work),
+	}
+	if !st.Paused {
+		st.ElapsedSeconds = int(now.Sub(status.Since).Seconds())
+		st.Elapsed = shortDuration(now.Sub(status.Since))
+	}
+	return st
+}
+
+// deckRoutes registers the endpoints for hardware controllers such as a
+// Stream Deck: state and an icon to poll, and buttons for pausing and
+// switching the project.
+func deckRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(currentDeckState())
+	})
+	mux.HandleFunc("/icon.svg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Header().Set("Cache-Control", "no-store")
+		writeDeckIcon(w, currentDeckState())
+	})
+	mux.HandleFunc("/pause", deckAction(func(r *http.Request) error { return setPaused(true) }))
+	mux.HandleFunc("/resume", deckAction(func(r *http.Request) error { return setPaused(false) }))
+	mux.HandleFunc("/toggle", deckAction(func(r *http.Request) error { return setPaused(!pausedByUser()) }))
+	mux.HandleFunc("/project", deckAction(func(r *http.Request) error {
+		_, _, err := setManualProject(r.FormValue("name"))
+		return err
+	}))
+}
+
+// deckAction wraps a button: POST only, answering with the new state. The
+// tracker notices pause and project changes on its next pass.
+func deckAction(do func(r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := do(r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		st := currentDeckState()
+		st.Paused = pausedByUser()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+	}
+}
+
+// writeDeckIcon draws a 144x144 key image: the project (or app) and the
+// elapsed time, green while tracking and grey while paused.
+func writeDeckIcon(w http.ResponseWriter, st deckState) {
+	color, line1, line2 := "#2e7d32", st.Project, st.Elapsed
+	if line1 == "" {
+		line1 = st.App
+	}
+	if st.Paused {
+		color, line1, line2 = "#616161", "Paused", st.Today
+	}
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="144" height="144" viewBox="0 0 144 144" font-family="Helvetica, Arial, sans-serif" fill="#ffffff" text-anchor="middle">`+
+		`<rect width="144" height="144" fill="%s"/>`+
+		`<text x="72" y="62" font-size="20">%s</text>`+
+		`<text x="72" y="100" font-size="30" font-weight="bold">%s</text></svg>`,
+		color, svgEscape(truncate(line1, 11)), svgEscape(line2))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// timelinePalette colors the legend entries in order of time spent.
+var timelinePalette = []string{
+	"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f",
+	"#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac",
+}
+
+// timelineLabel is what a span is colored by: its project, else its app.
+func timelineLabel(s Span) string {
+	if s.Project != "" {
+		return s.Project
+	}
+	if p := projectFor(s); p != "" {
+		return p
+	}
+	return s.App
+}
+
+// writeTimelineSVG draws the day as one horizontal lane of colored blocks
+// with an hour axis and a legend. Away time and the coarse spans of pruned
+// days are left blank. Screenshots are drawn as thumbnails in a strip under
+// the axis.
+func writeTimelineSVG(w io.Writer, day time.Time, spans []Span, shots []timelineShot) {
+	const (
+		width   = 960
+		margin  = 20
+		laneTop = 40
+		laneH   = 36
+		rowH    = 22
+		thumbW  = 96
+		thumbH  = 60
+	)
+
+	totals := make(map[string]time.Duration)
+	var from, to time.Time
+	for _, s := range spans {
+		if s.Away() || s.Coarse {
+			continue
+		}
+		totals[timelineLabel(s)] += s.Duration()
+		if from.IsZero() || s.Start.Before(from) {
+			from = s.Start
+		}
+		if s.End.After(to) {
+			to = s.End
+		}
+	}
+	legend := sortedByDuration(totals)
+	// Everything past the palette shares its last color as "other"
+	color := make(map[string]string)
+	for i, label := range legend {
+		color[label] = timelinePalette[minInt(i, len(timelinePalette)-1)]
+	}
+
+	if from.IsZero() {
+		from = day.Add(time.Duration(workStart.Hour) * time.Hour)
+		if windows := workWindows(day.Weekday()); len(windows) > 0 {
+			from = day.Add(time.Duration(windows[0].start.Hour) * time.Hour)
+		}
+		to = from.Add(time.Hour)
+	}
+	// Round out to whole hours
+	from = from.Truncate(time.Hour)
+	if to.Truncate(time.Hour) != to {
+		to = to.Truncate(time.Hour).Add(time.Hour)
+	}
+	scale := float64(width-2*margin) / to.Sub(from).Seconds()
+	x := func(t time.Time) float64 {
+		return margin + t.Sub(from).Seconds()*scale
+	}
+
+	stripH := 0
+	if len(shots) > 0 {
+		stripH = thumbH + 24
+	}
+	height := laneTop + laneH + 30 + stripH + len(legend)*rowH + margin
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="-apple-system, Helvetica, Arial, sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	fmt.Fprintf(w, `<text x="%d" y="24" font-size="15" font-weight="bold">Focus timeline %s</text>`+"\n", margin, day.Format("2006-01-02"))
+	fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d" fill="#f2f2f2"/>`+"\n", margin, laneTop, width-2*margin, laneH)
+
+	for _, s := range spans {
+		if s.Away() || s.Coarse || s.Duration() <= 0 {
+			continue
+		}
+		label := timelineLabel(s)
+		fmt.Fprintf(w, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"><title>%s %s-%s (%s)</title></rect>`+"\n",
+			x(s.Start), laneTop, x(s.End)-x(s.Start), laneH, color[label],
+			svgEscape(label), s.Start.Format("15:04"), s.End.Format("15:04"), shortDuration(s.Duration()))
+	}
+
+	// Hour ticks, thinned out on long days
+	step := 1
+	if hours := int(to.Sub(from).Hours()); hours > 12 {
+		step = 2
+	}
+	for t := from; !t.After(to); t = t.Add(time.Duration(step) * time.Hour) {
+		fmt.Fprintf(w, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#999999"/>`+"\n", x(t), laneTop+laneH, x(t), laneTop+laneH+5)
+		fmt.Fprintf(w, `<text x="%.1f" y="%d" text-anchor="middle" fill="#555555">%s</text>`+"\n", x(t), laneTop+laneH+18, t.Format("15:04"))
+	}
+
+	// Thumbnails that would overlap the previous one are marked with a tick
+	// only; hovering shows the time
+	y := laneTop + laneH + 30
+	right := 0.0
+	for _, shot := range shots {
+		at := x(shot.at)
+		fmt.Fprintf(w, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#555555"/>`+"\n", at, y-4, at, y)
+		left := at - thumbW/2
+		if left < margin {
+			left = margin
+		}
+		if left+thumbW > width-margin {
+			left = width - margin - thumbW
+		}
+		if left < right {
+			continue
+		}
+		fmt.Fprintf(w, `<image x="%.1f" y="%d" width="%d" height="%d" href="%s"><title>%s</title></image>`+"\n",
+			left, y, thumbW, thumbH, shot.dataURI, shot.at.Format("15:04"))
+		right = left + thumbW + 4
+	}
+	y += stripH
+	for _, label := range legend {
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`+"\n", margin, y, color[label])
+		fmt.Fprintf(w, `<text x="%d" y="%d">%s  %s</text>`+"\n", margin+18, y+11, svgEscape(label), shortDuration(totals[label]))
+		y += rowH
+	}
+	fmt.Fprintln(w, "</svg>")
+}
+
+func svgEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// syncTargets push a day's spans to an external service. Each writes the
+// whole day idempotently, so a sync can be repeated or retried safely.
+var syncTargets = map[string]func(day time.Time, spans []Span) error{
+	"notion":    syncNotion,
+	"influx":    syncInflux,
+	"bigquery":  syncBigQuery,
+	"snowflake": syncSnowflake,
+	"toggl":     syncToggl,
+	"clockify":  syncClockify,
+	"harvest":   syncHarvest,
+	"jira":      syncJira,
+}
+
+// syncDryRun makes a sync list what it would send instead of sending it,
+// for the targets in syncDryRunTargets.
+var (
+	syncDryRun        bool
+	syncDryRunTargets = map[string]bool{"clockify": true, "harvest": true, "jira": true}
+)
+
+// runSync pushes a day's spans to an external service: sync <target>
+// [-day D | -from D -to D] [-dry-run], the latter to backfill day by day. A
+// day that fails on the network is queued in the outbox and retried by the
+// running tracker.
+func runSync(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: sync notion|influx|bigquery|snowflake|toggl|clockify|harvest|jira [-day YYYY-MM-DD | -from YYYY-MM-DD -to YYYY-MM-DD]")
+	}
+	target := args[0]
+	if syncTargets[target] == nil {
+		return fmt.Errorf("unknown sync target %q", target)
+	}
+	fs := flag.NewFlagSet("sync "+target, flag.ExitOnError)
+	dayStr := fs.String("day", "", "day to sync (YYYY-MM-DD, today or yesterday)")
+	fromStr := fs.String("from", "", "first day to backfill (YYYY-MM-DD) instead of -day")
+	toStr := fs.String("to", "", "last day to backfill (YYYY-MM-DD, default today)")
+	fs.BoolVar(&syncDryRun, "dry-run", false, "show what would be sent without sending it (clockify, harvest, jira)")
+	fs.Parse(args[1:])
+	if syncDryRun && !syncDryRunTargets[target] {
+		return fmt.Errorf("sync %s has no -dry-run", target)
+	}
+
+	from, err := parseDay(*dayStr)
+	if err != nil {
+		return err
+	}
+	to := from
+	switch {
+	case *fromStr != "" && *dayStr != "":
+		return errors.New("use -day or -from, not both")
+	case *fromStr != "":
+		if from, err = parseDay(*fromStr); err != nil {
+			return err
+		}
+		if to, err = parseDay(*toStr); err != nil {
+			return err
+		}
+		if to.Before(from) {
+			return errors.New("-to is before -from")
+		}
+	case *toStr != "":
+		return errors.New("-to needs -from")
+	}
+
+	queued, days := 0, 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		days++
+		err := syncDay(target, day)
+		if retryable(err) && !syncDryRun {
+			if qerr := enqueue(outboxSync, syncPayload{Target: target, Day: day.Format("2006-01-02")}, err); qerr == nil {
+				if from.Equal(to) {
+					return fmt.Errorf("%v (queued in the outbox; the tracker retries it)", err)
+				}
+				fmt.Printf("%s %s: %v (queued in the outbox)\n", glyphs.warn, day.Format("2006-01-02"), err)
+				queued++
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if queued > 0 {
+		return fmt.Errorf("%d of %d days could not be synced and were queued in the outbox; the tracker retries them", queued, days)
+	}
+	return nil
+}
+
+func syncDay(target string, day time.Time) error {
+	spans, err := readSpans(day)
+	if err != nil {
+		return err
+	}
+	if err := syncTargets[target](day, spans); err != nil {
+		return err
+	}
+	queuedDays.Delete(target + " " + day.Format("2006-01-02"))
+	return nil
+}
+
+// queuedDays holds the target and day of each sync a live sink has queued,
+// so a network outage queues a day once rather than once per span.
+var queuedDays sync.Map
+
+// queueDaySync is how live sinks that send spans one by one recover: when
+// sending a span to target failed with err, a sync of its whole day is
+// queued in the outbox, and it skips the spans that did make it.
+func queueDaySync(target, service string, span Span, err error) {
+	day := span.Start.Format("2006-01-02")
+	if retryable(err) {
+		if _, done := queuedDays.LoadOrStore(target+" "+day, true); done {
+			return
+		}
+		if enqueue(outboxSync, syncPayload{Target: target, Day: day}, err) == nil {
+			fmt.Printf("%s Could not send to %s, will retry: %v\n", glyphs.warn, service, err)
+			return
+		}
+		queuedDays.Delete(target + " " + day)
+	}
+	fmt.Printf("%s Could not send to %s: %v\n", glyphs.warn, service, err)
+}
+
+// apiRequest sends body as JSON to an integration's API and decodes the
+// JSON answer into out; auth sets the integration's credentials and any
+// headers of its own. When the service answers 429 it is asked again, up
+// to three times, after the Retry-After it gives. Other answers of 300 and
+// up are statusErrors.
+func apiRequest(method, target string, auth func(*http.Request), body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, target, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		auth(req)
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < 3 {
+			wait, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			time.Sleep(time.Duration(wait+1) * time.Second)
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return newStatusError(resp.StatusCode, "%s %s: %s: %s", method, req.URL.Path, resp.Status, bytes.TrimSpace(data))
+		}
+		if out != nil && len(data) > 0 {
+			return json.Unmarshal(data, out)
+		}
+		return nil
+	}
+}
+
+// statusError is an error response from an integration's API.
+type statusError struct {
+	code int
+	text string
+}
+
+func (e *statusError) Error() string { return e.text }
+
+func newStatusError(code int, format string, args ...any) error {
+	return &statusError{code: code, text: fmt.Sprintf(format, args...)}
+}
+
+// retryable reports whether err is likely to go away on its own: the
+// network failed, or the service was overloaded or down. Other errors,
+// such as a rejected token, need the user's attention.
+func retryable(err error) bool {
+	var urlErr *url.Error
+	var status *statusError
+	switch {
+	case errors.As(err, &urlErr):
+		return true
+	case errors.As(err, &status):
+		return status.code == http.StatusRequestTimeout || status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	return false
+}
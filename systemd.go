@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// systemdUnit names the user service of "service install".
+const systemdUnit = "focus-tracker.service"
+
+func systemdUnitPath() string {
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		home, _ := os.UserHomeDir()
+		config = filepath.Join(home, ".config")
+	}
+	return filepath.Join(config, "systemd", "user", systemdUnit)
+}
+
+// runService handles "service install [-log-path DIR]" and "service
+// uninstall", which manage a systemd user unit running the tracker.
+func runService(args []string) error {
+	usage := errors.New("usage: service install [-log-path DIR] | service uninstall")
+	if len(args) == 0 {
+		return usage
+	}
+	if runtime.GOOS != "linux" {
+		return errors.New("service needs systemd on Linux")
+	}
+	switch args[0] {
+	case "install":
+		fs := flag.NewFlagSet("service install", flag.ExitOnError)
+		logPath := fs.String("log-path", "", "LOG_PATH for the service (default: the configured one, or ~/.local/state/focus-tracker)")
+		fs.Parse(args[1:])
+		return installSystemdUnit(*logPath)
+	case "uninstall":
+		if len(args) > 1 {
+			return usage
+		}
+		return uninstallSystemdUnit()
+	}
+	return usage
+}
+
+// installSystemdUnit writes a systemd user unit for the tracker, enables it
+// for the graphical session and starts it right away.
+func installSystemdUnit(logPath string) error {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return fmt.Errorf("service install needs systemd: %v", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	if logPath == "" {
+		logPath = logs
+		// The default /var/logs is not writable for a user service
+		if logPath == "/var/logs" {
+			home, _ := os.UserHomeDir()
+			logPath = filepath.Join(home, ".local", "state", "focus-tracker")
+			if state := os.Getenv("XDG_STATE_HOME"); state != "" {
+				logPath = filepath.Join(state, "focus-tracker")
+			}
+		}
+	}
+	if err := os.MkdirAll(logPath, 0755); err != nil {
+		return err
+	}
+	env := map[string]string{"LOG_PATH": logPath}
+	if path, ok := os.LookupEnv("CONFIG_PATH"); ok {
+		env["CONFIG_PATH"] = path
+	}
+
+	if err := os.MkdirAll(filepath.Dir(systemdUnitPath()), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(systemdUnitPath(), []byte(systemdUnitFile(exe, env)), 0644); err != nil {
+		return err
+	}
+	for _, args := range [][]string{{"daemon-reload"}, {"enable", systemdUnit}, {"restart", systemdUnit}} {
+		if out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("systemctl --user %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+		}
+	}
+	fmt.Printf("%s Installed %s and started the tracker.\n", glyphs.ok, systemdUnitPath())
+	fmt.Printf("It starts with the graphical session and restarts after a crash. Logs: journalctl --user -u %s, totals in %s\n", systemdUnit, logPath)
+	return nil
+}
+
+// uninstallSystemdUnit stops the tracker started by systemd and removes the
+// unit. The logs stay.
+func uninstallSystemdUnit() error {
+	if _, err := os.Stat(systemdUnitPath()); os.IsNotExist(err) {
+		fmt.Println("No systemd unit is installed.")
+		return nil
+	}
+	exec.Command("systemctl", "--user", "disable", "--now", systemdUnit).Run()
+	if err := os.Remove(systemdUnitPath()); err != nil {
+		return err
+	}
+	exec.Command("systemctl", "--user", "daemon-reload").Run()
+	fmt.Printf("%s Stopped the tracker and removed %s.\n", glyphs.ok, systemdUnitPath())
+	return nil
+}
+
+// systemdUnitFile runs the tracker in the graphical session, where DISPLAY or
+// WAYLAND_DISPLAY is set, and restarts it after an error or a kill but not
+// after a clean exit. Its output goes to the journal.
+func systemdUnitFile(exe string, env map[string]string) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\nDescription=focus-tracker\nPartOf=graphical-session.target\nAfter=graphical-session.target\n\n")
+	b.WriteString("[Service]\n")
+	// ExecStart also expands $VARIABLES, Environment does not
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.ReplaceAll(systemdQuote(exe), "$", "$$"))
+	for _, key := range sortedKeys(env) {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(key+"="+env[key]))
+	}
+	b.WriteString("Restart=on-failure\nRestartSec=10\n\n")
+	b.WriteString("[Install]\nWantedBy=graphical-session.target\n")
+	return b.String()
+}
+
+// systemdQuote quotes a word for a unit file, where "%" starts a specifier.
+func systemdQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(s)
+	return `"` + s + `"`
+}
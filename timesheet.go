@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timesheetColumn is one column of the generic timesheet CSV: a header and
+// the field written under it.
+type timesheetColumn struct {
+	header, field string
+}
+
+var timesheetFields = []string{"date", "employee", "cost_center", "wbs", "activity", "hours", "minutes", "projects"}
+
+// parseTimesheetColumns reads TIMESHEET_COLUMNS, e.g.
+// "Date=date, Kostenstelle=cost_center, Stunden=hours".
+func parseTimesheetColumns(input string) ([]timesheetColumn, error) {
+	var cols []timesheetColumn
+	for _, part := range strings.Split(input, ",") {
+		header, field, ok := strings.Cut(part, "=")
+		header, field = strings.TrimSpace(header), strings.ToLower(strings.TrimSpace(field))
+		if !ok || header == "" {
+			return nil, fmt.Errorf("invalid column %q, expected Header=field", strings.TrimSpace(part))
+		}
+		known := false
+		for _, f := range timesheetFields {
+			known = known || f == field
+		}
+		if !known {
+			msg := fmt.Sprintf("unknown field %q for column %q; fields are %s", field, header, strings.Join(timesheetFields, ", "))
+			if guess := closestMatch(field, timesheetFields); guess != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", guess)
+			}
+			return nil, errors.New(msg)
+		}
+		cols = append(cols, timesheetColumn{header, field})
+	}
+	return cols, nil
+}
+
+// parseDateFormat turns a pattern like DD.MM.YYYY into a Go layout.
+func parseDateFormat(input string) (string, error) {
+	layout := strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(strings.ToUpper(input))
+	if !strings.Contains(layout, "01") || !strings.Contains(layout, "02") || !strings.Contains(layout, "06") {
+		return "", fmt.Errorf("invalid date format %q, expected e.g. YYYY-MM-DD or DD.MM.YYYY", input)
+	}
+	return layout, nil
+}
+
+// timesheetRow is the time booked on one day to one receiver: a cost
+// center, WBS element and activity type.
+type timesheetRow struct {
+	day                       time.Time
+	costCenter, wbs, activity string
+	time                      time.Duration
+	projects                  []string
+}
+
+// timesheetRows totals the spans per day and receiver. Spans are booked to
+// their project's cost_center, wbs and activity, falling back to
+// TIMESHEET_COST_CENTER and TIMESHEET_ACTIVITY for work time outside a
+// mapped project. It also returns the tracked time that had no cost center.
+func timesheetRows(spans []Span) ([]timesheetRow, time.Duration) {
+	rows := make(map[string]*timesheetRow)
+	var unbooked time.Duration
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		if s.Project == "" {
+			s.Project = projectFor(s)
+		}
+		p, _ := lookupProject(s.Project)
+		r := timesheetRow{costCenter: p.costCenter, wbs: p.wbs, activity: p.activity}
+		if r.costCenter == "" && r.wbs == "" {
+			if !s.Work {
+				continue
+			}
+			r.costCenter = timesheetCostCenter
+		}
+		if r.activity == "" {
+			r.activity = timesheetActivity
+		}
+		if r.costCenter == "" && r.wbs == "" {
+			unbooked += s.Duration()
+			continue
+		}
+		r.day = time.Date(s.Start.Year(), s.Start.Month(), s.Start.Day(), 0, 0, 0, 0, s.Start.Location())
+		key := strings.Join([]string{r.day.Format("2006-01-02"), r.costCenter, r.wbs, r.activity}, "\x00")
+		row := rows[key]
+		if row == nil {
+			row = &r
+			rows[key] = row
+		}
+		row.time += s.Duration()
+		if p.name != "" && !containsString(row.projects, p.name) {
+			row.projects = append(row.projects, p.name)
+		}
+	}
+	result := make([]timesheetRow, 0, len(rows))
+	for _, r := range rows {
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if !a.day.Equal(b.day) {
+			return a.day.Before(b.day)
+		}
+		return a.costCenter+a.wbs+a.activity < b.costCenter+b.wbs+b.activity
+	})
+	return result, unbooked
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (r timesheetRow) field(name, dateLayout string) string {
+	switch name {
+	case "date":
+		return r.day.Format(dateLayout)
+	case "employee":
+		return timesheetEmployee
+	case "cost_center":
+		return r.costCenter
+	case "wbs":
+		return r.wbs
+	case "activity":
+		return r.activity
+	case "hours":
+		return strconv.FormatFloat(r.time.Hours(), 'f', 2, 64)
+	case "minutes":
+		return strconv.Itoa(int(r.time.Round(time.Minute).Minutes()))
+	case "projects":
+		return strings.Join(r.projects, ", ")
+	}
+	return ""
+}
+
+// catsColumns is the layout of a CATS upload: personnel number, work date,
+// receiver cost center, WBS element, activity type, hours and short text.
+var catsColumns = []timesheetColumn{
+	{"PERNR", "employee"},
+	{"WORKDATE", "date"},
+	{"RKOSTL", "cost_center"},
+	{"RPROJ", "wbs"},
+	{"LSTAR", "activity"},
+	{"CATSHOURS", "hours"},
+	{"LTXA1", "projects"},
+}
+
+// runTimesheet handles "timesheet [-format csv|cats|pdf] [-client NAME]
+// [-month YYYY-MM] [-from D -to D] [-o file]".
+func runTimesheet(args []string) error {
+	fs := flag.NewFlagSet("timesheet", flag.ExitOnError)
+	format := fs.String("format", "csv", "csv (columns from TIMESHEET_COLUMNS), cats (SAP CATS upload) or pdf (to sign)")
+	clientName := fs.String("client", "", "client of a pdf timesheet, a [client] section of the rules file (default all work time)")
+	monthStr := fs.String("month", "", "month (YYYY-MM, default last month)")
+	fromStr := fs.String("from", "", "first day (YYYY-MM-DD) instead of a month")
+	toStr := fs.String("to", "", "last day (YYYY-MM-DD, default yesterday)")
+	out := fs.String("o", "", "output file (default stdout, for pdf timesheet_FROM_TO.pdf)")
+	fs.Parse(args)
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 1, 0)
+	var err error
+	switch {
+	case *fromStr != "":
+		if from, err = parseDay(*fromStr); err != nil {
+			return err
+		}
+		if *toStr == "" {
+			*toStr = "yesterday"
+		}
+		last, err := parseDay(*toStr)
+		if err != nil {
+			return err
+		}
+		to = last.AddDate(0, 0, 1)
+	case *monthStr != "":
+		if from, err = time.ParseInLocation("2006-01", *monthStr, time.Local); err != nil {
+			return fmt.Errorf("invalid month %q, expected YYYY-MM", *monthStr)
+		}
+		to = from.AddDate(0, 1, 0)
+	}
+
+	cols, delimiter, dateLayout := timesheetColumns, timesheetDelimiter, timesheetDateFormat
+	switch *format {
+	case "csv":
+	case "cats":
+		if timesheetEmployee == "" {
+			return errors.New("set TIMESHEET_EMPLOYEE to your personnel number for CATS")
+		}
+		cols, delimiter, dateLayout = catsColumns, ';', "20060102"
+	case "pdf":
+	default:
+		return fmt.Errorf("unknown timesheet format %q, expected csv, cats or pdf", *format)
+	}
+	var c client
+	if *clientName != "" {
+		if *format != "pdf" {
+			return errors.New("-client only applies to -format pdf")
+		}
+		var ok bool
+		if c, ok = lookupClient(*clientName); !ok {
+			return fmt.Errorf("unknown client %q; clients are [client \"Name\"] sections of the rules file", *clientName)
+		}
+	}
+
+	spans, err := readSpanRange(from, to)
+	if err != nil {
+		return err
+	}
+	var inRange []Span
+	for _, s := range spans {
+		if !s.Start.Before(from) && s.Start.Before(to) {
+			inRange = append(inRange, s)
+		}
+	}
+	if *format == "pdf" {
+		return writeTimesheetPDFFile(*out, c, from, to, inRange)
+	}
+	rows, unbooked := timesheetRows(inRange)
+
+	f, err := outputFile(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Comma = delimiter
+	record := make([]string, len(cols))
+	for i, c := range cols {
+		record[i] = c.header
+	}
+	w.Write(record)
+	for _, r := range rows {
+		for i, c := range cols {
+			record[i] = r.field(c.field, dateLayout)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if unbooked > 0 {
+		fmt.Fprintf(os.Stderr, "%s %s of tracked time has no cost center and was left out; set cost_center on its projects or TIMESHEET_COST_CENTER\n", glyphs.warn, shortDuration(unbooked))
+	}
+	return f.Close()
+}
+
+// writeTimesheetPDFFile writes the pdf timesheet of the days [from, to),
+// signed by TIMESHEET_NAME or else the first line of INVOICE_FROM.
+func writeTimesheetPDFFile(path string, c client, from, to time.Time, spans []Span) error {
+	usage := timesheetProjectDays(spans, c)
+	if len(usage) == 0 {
+		if c.name != "" {
+			return fmt.Errorf("nothing billable to %s in %s; projects are billed when their section has client = %s", c.name, timesheetHeading(from, to), c.name)
+		}
+		return fmt.Errorf("no work time in %s", timesheetHeading(from, to))
+	}
+	name := timesheetName
+	if name == "" {
+		if from := splitLines(invoiceFrom); len(from) > 0 {
+			name = from[0]
+		}
+	}
+	if path == "" {
+		path = fmt.Sprintf("timesheet_%s_%s.pdf", from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeTimesheetPDF(f, name, c, timesheetHeading(from, to), from, to, usage); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%s Wrote %s\n", glyphs.ok, path)
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// timesheetProjectDays totals the spans per project and day (YYYY-MM-DD)
+// for "timesheet -format pdf". For a client only its billable projects
+// count, rounded and within their allowed hours as on its invoices;
+// otherwise all project time counts, and other work time as "Other work".
+func timesheetProjectDays(spans []Span, c client) map[string]map[string]time.Duration {
+	if c.name != "" {
+		return billableDays(spans, c)
+	}
+	usage := make(map[string]map[string]time.Duration)
+	for _, s := range spans {
+		if s.Away() {
+			continue
+		}
+		if s.Project == "" {
+			s.Project = projectFor(s)
+		}
+		name := "Other work"
+		if p, ok := lookupProject(s.Project); ok {
+			name = p.name
+		} else if !s.Work {
+			continue
+		}
+		if usage[name] == nil {
+			usage[name] = make(map[string]time.Duration)
+		}
+		usage[name][s.Start.Format("2006-01-02")] += s.Duration()
+	}
+	return usage
+}
+
+// writeTimesheetPDF lays out a timesheet to be signed: a header with the
+// name, client, period and rate, the hours of each day, the hours per
+// project, priced at its rate for a client, and signature lines for both
+// sides.
+func writeTimesheetPDF(w io.Writer, name string, c client, heading string, from, to time.Time, usage map[string]map[string]time.Duration) error {
+	const (
+		left  = pdfMargin
+		right = pdfPageWidth - pdfMargin
+	)
+	var d pdfDoc
+	d.text(left, d.next(18), 18, true, "Timesheet")
+	d.next(8)
+	header := [][2]string{{"Name", name}}
+	if c.name != "" {
+		header = append(header, [2]string{"Client", c.billingName})
+	}
+	header = append(header, [2]string{"Period", heading})
+	if c.rate > 0 {
+		header = append(header, [2]string{"Rate", fmt.Sprintf("%s %s per hour", formatMoney(c.rate), c.currencyCode())})
+	}
+	for _, h := range header {
+		if h[1] == "" {
+			continue
+		}
+		y := d.next(14)
+		d.text(left, y, 10, true, h[0])
+		d.text(left+70, y, 10, false, h[1])
+	}
+
+	d.next(16)
+	d.text(left, d.next(14), 12, true, "Hours per day")
+	y := d.next(16)
+	d.text(left, y, 9, true, "Date")
+	d.textRight(left+150, y, 9, true, "Hours")
+	d.text(left+170, y, 9, true, "Projects")
+	d.line(left, y-4, right, y-4)
+	var total time.Duration
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		var dayTotal time.Duration
+		var projects []string
+		for _, p := range sortedKeys(usage) {
+			if t := usage[p][key]; t > 0 {
+				dayTotal += t
+				projects = append(projects, p)
+			}
+		}
+		if dayTotal == 0 {
+			continue
+		}
+		total += dayTotal
+		y := d.next(14)
+		d.text(left, y, 9, false, day.Format("Mon 2006-01-02"))
+		d.textRight(left+150, y, 9, false, fmt.Sprintf("%.2f", dayTotal.Hours()))
+		d.text(left+170, y, 9, false, pdfFit(strings.Join(projects, ", "), 9, right-left-170))
+	}
+	y = d.next(16)
+	d.line(left, y+10, right, y+10)
+	d.text(left, y, 9, true, "Total")
+	d.textRight(left+150, y, 9, true, fmt.Sprintf("%.2f", total.Hours()))
+
+	d.next(16)
+	d.text(left, d.next(14), 12, true, "Projects")
+	y = d.next(16)
+	priced := c.name != ""
+	d.text(left, y, 9, true, "Project")
+	d.textRight(left+290, y, 9, true, "Hours")
+	if priced {
+		d.textRight(left+380, y, 9, true, "Rate ("+c.currencyCode()+")")
+		d.textRight(right, y, 9, true, "Amount ("+c.currencyCode()+")")
+	}
+	d.line(left, y-4, right, y-4)
+	totals := make(map[string]time.Duration)
+	for p, days := range usage {
+		for _, t := range days {
+			totals[p] += t
+		}
+	}
+	var amount int64
+	for _, p := range sortedByDuration(totals) {
+		y := d.next(14)
+		d.text(left, y, 9, false, pdfFit(p, 9, 220))
+		d.textRight(left+290, y, 9, false, fmt.Sprintf("%.2f", totals[p].Hours()))
+		if priced {
+			proj, _ := lookupProject(p)
+			rate := proj.hourlyRate(c)
+			amount += billedAmount(totals[p], rate)
+			d.textRight(left+380, y, 9, false, formatMoney(rate))
+			d.textRight(right, y, 9, false, formatMoney(billedAmount(totals[p], rate)))
+		}
+	}
+	y = d.next(16)
+	d.line(left, y+10, right, y+10)
+	d.text(left, y, 9, true, "Total")
+	d.textRight(left+290, y, 9, true, fmt.Sprintf("%.2f", total.Hours()))
+	if priced {
+		d.textRight(right, y, 9, true, formatMoney(amount))
+	}
+
+	y = d.next(60)
+	approver := c.billingName
+	if approver == "" {
+		approver = "approved by"
+	}
+	for i, who := range []string{name, approver} {
+		label := "Date and signature"
+		if who != "" {
+			label += ", " + who
+		}
+		x := left + float64(i)*(right-left)/2
+		d.line(x, y, x+(right-left)/2-30, y)
+		d.text(x, y-12, 8, false, label)
+	}
+	_, err := d.WriteTo(w)
+	return err
+}
+
+// timesheetHeading names the period, "September 2024 (2024-09-01 to
+// 2024-09-30)" for a whole month.
+func timesheetHeading(from, to time.Time) string {
+	label := ""
+	if from.Day() == 1 && to.Equal(from.AddDate(0, 1, 0)) {
+		label = from.Format("January 2006")
+	}
+	return reportHeading(label, from, to)
+}
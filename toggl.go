@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const togglAPI = "https://api.track.toggl.com/api/v9"
+
+// Spans go to Toggl Track as time entries in TOGGL_WORKSPACE_ID, in the
+// Toggl project of the same name as their rules project if there is one,
+// billable when the project is, and tagged with their category. A span's
+// external ID is its start time to the second: an entry starting then is
+// taken to be the span's own, so a day can be synced again, or retried from
+// the outbox, without duplicating entries.
+
+// syncToggl creates time entries for the day's spans that have none yet.
+func syncToggl(day time.Time, spans []Span) error {
+	if togglToken == "" || togglWorkspace == 0 {
+		return errors.New("TOGGL_TOKEN and TOGGL_WORKSPACE_ID must be set")
+	}
+	var wanted []Span
+	for _, s := range withContexts(spans) {
+		if togglWanted(s) {
+			wanted = append(wanted, s)
+		}
+	}
+	if len(wanted) == 0 {
+		fmt.Printf("%s Toggl: nothing to send for %s\n", glyphs.ok, day.Format("2006-01-02"))
+		return nil
+	}
+	existing, err := togglEntryStarts(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf("toggl: %w", err)
+	}
+	var created, skipped int
+	for _, s := range wanted {
+		if existing[s.Start.Unix()] {
+			skipped++
+			continue
+		}
+		if err := togglCreate(s); err != nil {
+			return fmt.Errorf("toggl: %s %s: %w", s.Start.Format("2006-01-02 15:04"), s.App, err)
+		}
+		created++
+	}
+	fmt.Printf("%s Toggl: %d new time entries for %s, %d already there\n", glyphs.ok, created, day.Format("2006-01-02"), skipped)
+	return nil
+}
+
+// togglRecord is the tracker's live sink: it creates a time entry for each
+// span as it is recorded, in the background. When that fails on the
+// network, the span's day is queued for a sync, which skips the entries
+// that did make it.
+func togglRecord(span Span) {
+	if !togglWanted(span) {
+		return
+	}
+	go func() {
+		if err := togglCreate(span); err != nil {
+			queueDaySync("toggl", "Toggl", span, err)
+		}
+	}()
+}
+
+// togglWanted reports whether a span goes to Toggl: work-hours or project
+// time of at least TOGGL_MIN_SPAN, and with TOGGL_PROJECTS_ONLY only
+// project time.
+func togglWanted(s Span) bool {
+	if s.Away() || s.Bucket == "lunch" || s.Duration() < togglMinSpan {
+		return false
+	}
+	if s.Project == "" {
+		return s.Work && !togglProjectsOnly
+	}
+	return true
+}
+
+// togglEntry is a time entry as the Toggl Track API takes it.
+type togglEntry struct {
+	CreatedWith string   `json:"created_with"`
+	WorkspaceID int64    `json:"workspace_id"`
+	ProjectID   int64    `json:"project_id,omitempty"`
+	Description string   `json:"description"`
+	Start       string   `json:"start"`
+	Stop        string   `json:"stop"`
+	Duration    int64    `json:"duration"`
+	Billable    bool     `json:"billable"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+func togglCreate(s Span) error {
+	e := togglEntry{
+		CreatedWith: "focus-tracker",
+		WorkspaceID: togglWorkspace,
+		Description: s.App,
+		Start:       s.Start.UTC().Format(time.RFC3339),
+		Stop:        s.End.UTC().Format(time.RFC3339),
+		Duration:    int64(s.Duration().Seconds()),
+	}
+	if s.Title != "" {
+		e.Description += ": " + s.Title
+	}
+	if s.Category != "" {
+		e.Tags = []string{s.Category}
+	}
+	if p, ok := lookupProject(s.Project); ok {
+		e.Billable = p.billable()
+		id, err := togglProjectID(p.name)
+		if err != nil {
+			return err
+		}
+		e.ProjectID = id
+	}
+	return togglRequest(http.MethodPost, fmt.Sprintf("/workspaces/%d/time_entries", togglWorkspace), e, nil)
+}
+
+// togglEntryStarts returns the start times (Unix seconds) of the entries in
+// the workspace that start in [from, to).
+func togglEntryStarts(from, to time.Time) (map[int64]bool, error) {
+	q := url.Values{"start_date": {from.Format(time.RFC3339)}, "end_date": {to.Format(time.RFC3339)}}
+	var entries []struct {
+		WorkspaceID int64     `json:"workspace_id"`
+		Start       time.Time `json:"start"`
+	}
+	if err := togglRequest(http.MethodGet, "/me/time_entries?"+q.Encode(), nil, &entries); err != nil {
+		return nil, err
+	}
+	starts := make(map[int64]bool)
+	for _, e := range entries {
+		if e.WorkspaceID == togglWorkspace {
+			starts[e.Start.Unix()] = true
+		}
+	}
+	return starts, nil
+}
+
+// togglProjects maps the workspace's active project names, lower-cased,
+// to their IDs. They are fetched again once an hour, so projects added in
+// Toggl while the tracker runs are picked up.
+var togglProjects struct {
+	sync.Mutex
+	ids     map[string]int64
+	fetched time.Time
+}
+
+// togglProjectID is the ID of the Toggl project named like a rules
+// project, or 0 if there is none.
+func togglProjectID(name string) (int64, error) {
+	togglProjects.Lock()
+	defer togglProjects.Unlock()
+	if togglProjects.ids == nil || time.Since(togglProjects.fetched) > time.Hour {
+		var projects []struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		}
+		path := fmt.Sprintf("/workspaces/%d/projects?active=true&per_page=200", togglWorkspace)
+		if err := togglRequest(http.MethodGet, path, nil, &projects); err != nil {
+			return 0, err
+		}
+		togglProjects.ids = make(map[string]int64)
+		for _, p := range projects {
+			togglProjects.ids[strings.ToLower(p.Name)] = p.ID
+		}
+		togglProjects.fetched = time.Now()
+	}
+	return togglProjects.ids[strings.ToLower(name)], nil
+}
+
+func togglRequest(method, path string, body, out any) error {
+	return apiRequest(method, togglAPI+path, func(req *http.Request) {
+		req.SetBasicAuth(togglToken, "api_token")
+	}, body, out)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The browse command is a full-screen history browser drawn with plain ANSI
+// escapes; the terminal is switched to raw mode with stty.
+
+const (
+	viewApps = iota
+	viewTitles
+	viewCalendar
+)
+
+var bucketFilters = []string{"all", "work", "outside"}
+
+type browser struct {
+	day    time.Time
+	spans  []Span
+	view   int
+	cursor int
+	app    string // the app drilled into in viewTitles
+	filter int    // index into bucketFilters
+	cal    time.Time
+	rows   int
+	status string
+}
+
+type browserRow struct {
+	label string
+	total time.Duration
+}
+
+func runBrowse(args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: browse [YYYY-MM-DD]")
+	}
+	var dayStr string
+	if len(args) == 1 {
+		dayStr = args[0]
+	}
+	day, err := parseDay(dayStr)
+	if err != nil {
+		return err
+	}
+
+	saved, err := stty("-g")
+	if err != nil {
+		return errors.New("browse needs an interactive terminal")
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return err
+	}
+	fmt.Print("\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		stty(saved)
+	}()
+
+	b := &browser{rows: 24}
+	if size, err := stty("size"); err == nil {
+		if rows, err := strconv.Atoi(strings.Fields(size)[0]); err == nil && rows > 5 {
+			b.rows = rows
+		}
+	}
+	b.open(day)
+
+	buf := make([]byte, 16)
+	for {
+		b.draw()
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		if !b.key(string(buf[:n])) {
+			return nil
+		}
+	}
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func (b *browser) open(day time.Time) {
+	spans, err := readSpans(day)
+	b.day, b.spans, b.cursor, b.status = day, spans, 0, ""
+	if err != nil {
+		b.status = err.Error()
+	}
+	if b.view == viewTitles {
+		b.view = viewApps
+	}
+}
+
+// visible returns the day's spans that pass the bucket filter.
+func (b *browser) visible() []Span {
+	var result []Span
+	for _, s := range b.spans {
+		switch bucketFilters[b.filter] {
+		case "work":
+			if !s.Work || s.Away() {
+				continue
+			}
+		case "outside":
+			if s.Work || s.Away() {
+				continue
+			}
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+func (b *browser) list() []browserRow {
+	totals := make(map[string]time.Duration)
+	for _, s := range b.visible() {
+		if b.view == viewTitles {
+			if s.App != b.app {
+				continue
+			}
+			title := s.Title
+			if title == "" {
+				title = "(no title)"
+			}
+			totals[title] += s.Duration()
+		} else {
+			totals[s.App] += s.Duration()
+		}
+	}
+	var rows []browserRow
+	for _, label := range sortedByDuration(totals) {
+		rows = append(rows, browserRow{label, totals[label]})
+	}
+	return rows
+}
+
+// key handles one keypress and returns false to quit.
+func (b *browser) key(k string) bool {
+	switch k {
+	case "q", "\x03":
+		return false
+	case "c":
+		b.view, b.cal = viewCalendar, b.day
+		return true
+	case "t":
+		today, _ := parseDay("")
+		b.open(today)
+		return true
+	}
+
+	if b.view == viewCalendar {
+		switch k {
+		case "\x1b[D", "h":
+			b.cal = b.cal.AddDate(0, 0, -1)
+		case "\x1b[C", "l":
+			b.cal = b.cal.AddDate(0, 0, 1)
+		case "\x1b[A", "k":
+			b.cal = b.cal.AddDate(0, 0, -7)
+		case "\x1b[B", "j":
+			b.cal = b.cal.AddDate(0, 0, 7)
+		case "<":
+			b.cal = b.cal.AddDate(0, -1, 0)
+		case ">":
+			b.cal = b.cal.AddDate(0, 1, 0)
+		case "\r", "\n":
+			b.view = viewApps
+			b.open(b.cal)
+		case "\x1b", "\x7f":
+			b.view = viewApps
+		}
+		return true
+	}
+
+	rows := b.list()
+	switch k {
+	case "\x1b[A", "k":
+		if b.cursor > 0 {
+			b.cursor--
+		}
+	case "\x1b[B", "j":
+		if b.cursor < len(rows)-1 {
+			b.cursor++
+		}
+	case "\r", "\n", "\x1b[C", "l":
+		if b.view == viewApps && b.cursor < len(rows) {
+			b.app, b.view, b.cursor = rows[b.cursor].label, viewTitles, 0
+		}
+	case "\x1b[D", "h", "\x1b", "\x7f":
+		if b.view == viewTitles {
+			b.view, b.cursor = viewApps, 0
+			for i, r := range b.list() {
+				if r.label == b.app {
+					b.cursor = i
+				}
+			}
+		}
+	case "n", "]":
+		b.open(b.day.AddDate(0, 0, 1))
+	case "p", "[":
+		b.open(b.day.AddDate(0, 0, -1))
+	case "b":
+		b.filter = (b.filter + 1) % len(bucketFilters)
+		b.cursor = 0
+	}
+	return true
+}
+
+func (b *browser) draw() {
+	var lines []string
+	add := func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	if b.view == viewCalendar {
+		b.drawCalendar(add)
+	} else {
+		title := b.day.Format("Monday 2006-01-02")
+		if b.view == viewTitles {
+			title += " " + glyphs.crumb + " " + b.app
+		}
+		add("%s  [%s]", title, bucketFilters[b.filter])
+		add("")
+		rows := b.list()
+		var total time.Duration
+		for _, r := range rows {
+			total += r.total
+		}
+		if len(rows) == 0 {
+			add("  nothing recorded")
+		}
+		// Scroll so the cursor stays on screen
+		height := b.rows - 6
+		first := 0
+		if b.cursor >= height {
+			first = b.cursor - height + 1
+		}
+		for i := first; i < len(rows) && i < first+height; i++ {
+			marker := "  "
+			if i == b.cursor {
+				marker = "> "
+			}
+			add("%s%-50s %7s %3d%%", marker, truncate(rows[i].label, 50), shortDuration(rows[i].total), percent(rows[i].total, total))
+		}
+		add("")
+		add("  total %s", shortDuration(total))
+	}
+
+	if b.status != "" {
+		add("%s", b.status)
+	}
+	help := "↑↓ move  → open  ← back  n/p next/prev day  b buckets  c calendar  t today  q quit"
+	if b.view == viewCalendar {
+		help = "arrows move  </> month  enter open  ← back  q quit"
+	}
+	if asciiOutput {
+		help = strings.NewReplacer("↑↓", "up/down", "→", "right", "←", "left").Replace(help)
+	}
+	for len(lines) < b.rows-1 {
+		add("")
+	}
+	add("%s", help)
+	fmt.Print("\x1b[H\x1b[2J" + strings.Join(lines, "\r\n"))
+}
+
+// drawCalendar shows the month around the calendar cursor; days with a span
+// journal are marked with *.
+func (b *browser) drawCalendar(add func(string, ...any)) {
+	first := time.Date(b.cal.Year(), b.cal.Month(), 1, 0, 0, 0, 0, b.cal.Location())
+	add("%s", first.Format("January 2006"))
+	add("")
+	add(" Mon  Tue  Wed  Thu  Fri  Sat  Sun")
+	line := strings.Repeat("     ", (int(first.Weekday())+6)%7)
+	for d := first; d.Month() == first.Month(); d = d.AddDate(0, 0, 1) {
+		mark := " "
+		if _, err := os.Stat(spansPath(d)); err == nil {
+			mark = "*"
+		}
+		cell := fmt.Sprintf(" %2d%s ", d.Day(), mark)
+		if d.Day() == b.cal.Day() {
+			cell = "\x1b[7m" + cell + "\x1b[0m"
+		}
+		line += cell
+		if d.Weekday() == time.Sunday {
+			add("%s", line)
+			line = ""
+		}
+	}
+	if line != "" {
+		add("%s", line)
+	}
+	add("")
+	add("* has tracked time")
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-len([]rune(glyphs.ellipsis))]) + glyphs.ellipsis
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// warehouseColumn is one column of the exported span table, with its type
+// in each warehouse and in the DuckDB views of the dataset command.
+type warehouseColumn struct {
+	name      string
+	bigquery  string
+	snowflake string
+	duckdb    string
+}
+
+// warehouseColumns is the span table's schema. Columns may be added at the
+// end; the exporters add missing ones to existing tables.
+var warehouseColumns = []warehouseColumn{
+	{"day", "DATE", "DATE", "DATE"},
+	{"start_time", "TIMESTAMP", "TIMESTAMP_TZ", "TIMESTAMPTZ"},
+	{"end_time", "TIMESTAMP", "TIMESTAMP_TZ", "TIMESTAMPTZ"},
+	{"seconds", "INT64", "NUMBER", "BIGINT"},
+	{"app", "STRING", "VARCHAR", "VARCHAR"},
+	{"bundle_id", "STRING", "VARCHAR", "VARCHAR"},
+	{"title", "STRING", "VARCHAR", "VARCHAR"},
+	{"work", "BOOL", "BOOLEAN", "BOOLEAN"},
+	{"bucket", "STRING", "VARCHAR", "VARCHAR"},
+	{"project", "STRING", "VARCHAR", "VARCHAR"},
+	{"contexts", "STRING", "VARCHAR", "VARCHAR"},
+}
+
+// warehouseKey identifies a span across exports: re-exporting a day
+// updates rows with the same start time and app instead of duplicating.
+var warehouseKey = []string{"start_time", "app"}
+
+const warehouseTime = "2006-01-02T15:04:05.000000-07:00"
+
+// warehouseRow renders a span as text values in column order; the SQL
+// casts them to the column types.
+func warehouseRow(s Span) []string {
+	return []string{
+		s.Start.Format("2006-01-02"),
+		s.Start.Format(warehouseTime),
+		s.End.Format(warehouseTime),
+		strconv.FormatInt(int64(s.Duration()/time.Second), 10),
+		s.App,
+		s.BundleID,
+		s.Title,
+		strconv.FormatBool(s.Work),
+		spanBucket(s),
+		s.Project,
+		strings.Join(s.Contexts, ","),
+	}
+}
+
+// spanBucket names the span's bucket as exports show it: work, outside,
+// outside_<bucket> or lunch.
+func spanBucket(s Span) string {
+	if bucket := strings.TrimPrefix(s.Suffix(), "_"); bucket != "" {
+		return bucket
+	}
+	return "work"
+}
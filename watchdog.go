@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lastProbe is the Unix time in nanoseconds of the last tracker pass that
+// either read the frontmost app or deliberately skipped probing.
+var lastProbe atomic.Int64
+
+// probeOK tells the watchdog the tracking loop is healthy.
+func probeOK() {
+	lastProbe.Store(time.Now().UnixNano())
+}
+
+// provider holds the context osascript probes run under. Cancelling it
+// kills probes that hang, e.g. when System Events stops answering.
+var provider struct {
+	sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func providerContext() context.Context {
+	provider.Lock()
+	defer provider.Unlock()
+	if provider.ctx == nil {
+		provider.ctx, provider.cancel = context.WithCancel(context.Background())
+	}
+	return provider.ctx
+}
+
+// restartProvider kills in-flight probes and restarts what the platform
+// probes through, e.g. System Events on macOS. It returns what it
+// restarted, or "" if only the probes were killed.
+func restartProvider() string {
+	provider.Lock()
+	if provider.cancel != nil {
+		provider.cancel()
+	}
+	provider.ctx, provider.cancel = context.WithCancel(context.Background())
+	provider.Unlock()
+	return desktop.restart()
+}
+
+// watchdog checks that the tracking loop keeps probing. After stall without
+// a successful probe it restarts the provider; if that does not help within
+// another stall it notifies the user, once, until tracking recovers.
+func watchdog(stall time.Duration) {
+	var restarted time.Time
+	notified := false
+	for range time.Tick(stall / 5) {
+		last := time.Unix(0, lastProbe.Load())
+		now := time.Now()
+		if now.Sub(last) < stall {
+			if !restarted.IsZero() {
+				fmt.Printf("%s Tracking recovered.\n", glyphs.ok)
+				noteEvent("watchdog: recovered")
+				restarted, notified = time.Time{}, false
+			}
+			continue
+		}
+		if restarted.IsZero() {
+			restartedWhat := restartProvider()
+			if restartedWhat == "" {
+				restartedWhat = "the probes"
+			}
+			fmt.Printf("%s No successful probe since %s, restarted %s.\n", glyphs.warn, last.Format("15:04"), restartedWhat)
+			noteEvent("watchdog: stalled since %s, restarted %s", last.Format("15:04:05"), restartedWhat)
+			restarted = now
+			continue
+		}
+		if !notified && now.Sub(restarted) >= stall {
+			noteEvent("watchdog: recovery failed")
+			notify("Tracking stalled", fmt.Sprintf("Nothing recorded since %s. Check permissions or restart focus-tracker.", last.Format("15:04")))
+			notified = true
+		}
+	}
+}
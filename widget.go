@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The floating widget is a small always-on-top panel drawn by a JavaScript
+// for Automation script, so no cgo is needed. The widget command feeds it by
+// rewriting a text file the script polls; removing the file closes it.
+
+func widgetPIDPath() string {
+	return filepath.Join(logs, "widget.pid")
+}
+
+func widgetTextPath() string {
+	return filepath.Join(logs, "widget.txt")
+}
+
+// runWidget handles "widget" (run in the foreground), "widget toggle" and
+// "widget stop".
+func runWidget(args []string) error {
+	action := ""
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "":
+		return showWidget()
+	case "stop":
+		if pid, ok := widgetRunning(); ok {
+			return stopProcess(pid)
+		}
+		return nil
+	case "toggle":
+		if pid, ok := widgetRunning(); ok {
+			return stopProcess(pid)
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(exe, "widget")
+		detach(cmd)
+		return cmd.Start()
+	}
+	return errors.New("usage: widget [toggle | stop]")
+}
+
+func widgetRunning() (int, bool) {
+	data, err := os.ReadFile(widgetPIDPath())
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || !processAlive(pid) {
+		return 0, false
+	}
+	return pid, true
+}
+
+func showWidget() error {
+	if _, ok := widgetRunning(); ok {
+		return errors.New("the widget is already shown; use \"widget stop\" to close it")
+	}
+	if err := os.WriteFile(widgetPIDPath(), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(widgetPIDPath())
+	if err := os.WriteFile(widgetTextPath(), []byte(widgetText()), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(widgetTextPath())
+
+	path, _ := json.Marshal(widgetTextPath())
+	cmd := exec.Command("osascript", "-l", "JavaScript", "-e", fmt.Sprintf(widgetScript, path))
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	tick := time.NewTicker(2 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-sig:
+			// The script closes the panel once the text file is gone
+			os.Remove(widgetTextPath())
+			return <-done
+		case <-tick.C:
+			tmp := widgetTextPath() + ".tmp"
+			if err := os.WriteFile(tmp, []byte(widgetText()), 0644); err == nil {
+				os.Rename(tmp, widgetTextPath())
+			}
+		}
+	}
+}
+
+// widgetText is the current app timer and today's total, or "" to hide the
+// panel while the screen is shared.
+func widgetText() string {
+	now := time.Now()
+	status, running, _ := readStatus()
+	if running && status.Sharing {
+		return ""
+	}
+	work, _ := todaysTotals(now, status, running)
+	current := "Not tracking"
+	switch {
+	case running && pausedByUser():
+		current = "Paused"
+	case running && status.App != "":
+		current = fmt.Sprintf("%s %s", truncate(status.App, 18), shortDuration(now.Sub(status.Since)))
+	}
+	return fmt.Sprintf("%s\nToday %s", current, shortDuration(work))
+}
+
+const widgetScript = `
+ObjC.import('Cocoa');
+var path = %s;
+$.NSApplication.sharedApplication.setActivationPolicy($.NSApplicationActivationPolicyAccessory);
+var screen = $.NSScreen.mainScreen.visibleFrame;
+var rect = $.NSMakeRect(screen.origin.x + screen.size.width - 230, screen.origin.y + screen.size.height - 64, 220, 54);
+var panel = $.NSPanel.alloc.initWithContentRectStyleMaskBackingDefer(rect,
+	$.NSWindowStyleMaskBorderless | $.NSWindowStyleMaskNonactivatingPanel, $.NSBackingStoreBuffered, false);
+panel.level = $.NSFloatingWindowLevel;
+panel.opaque = false;
+panel.hasShadow = true;
+panel.ignoresMouseEvents = true;
+panel.collectionBehavior = $.NSWindowCollectionBehaviorCanJoinAllSpaces | $.NSWindowCollectionBehaviorStationary;
+panel.backgroundColor = $.NSColor.colorWithCalibratedWhiteAlpha(0.1, 0.75);
+var label = $.NSTextField.labelWithString('');
+label.frame = $.NSMakeRect(12, 8, 200, 38);
+label.textColor = $.NSColor.whiteColor;
+label.font = $.NSFont.monospacedDigitSystemFontOfSizeWeight(12, 0);
+panel.contentView.addSubview(label);
+panel.orderFrontRegardless;
+while (true) {
+	var text = $.NSString.stringWithContentsOfFileEncodingError(path, $.NSUTF8StringEncoding, null);
+	if (text.isNil()) break;
+	label.stringValue = text;
+	if (text.length == 0) panel.orderOut(null); else panel.orderFrontRegardless;
+	$.NSRunLoop.currentRunLoop.runUntilDate($.NSDate.dateWithTimeIntervalSinceNow(1));
+}
+`